@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"time"
 
 	"dailycards/internal/database" 
 	"dailycards/internal/server"
@@ -27,9 +28,17 @@ func main() {
 	}
 	defer pool.Close()
 
-	queries := database.New(pool)
+	blobs, err := setup.MediaStore(ctx, env)
+	if err != nil {
+		log.Fatalf("media store error: %v", err)
+	}
 
-	srv := server.New(queries, env.SECRET)
+	srv := server.New(pool, server.Config{
+		Secret:          env.SECRET,
+		StudySessionTTL: setup.Duration(env.STUDY_SESSION_TTL, 2*time.Hour),
+		TrashRetention:  setup.Duration(env.TRASH_RETENTION, 30*24*time.Hour),
+		Media:           blobs,
+	})
 	srv.Setup()
 
 	log.Println("⇨ listening on :8080")
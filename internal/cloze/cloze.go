@@ -0,0 +1,132 @@
+// Package cloze parses cloze deletion markup: "{{c1::Paris}} is the capital
+// of {{c2::France::country}}". Every cloze number becomes a separate item in
+// which the deletions of that number are hidden and all others are shown.
+package cloze
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+const (
+	openMark  = "{{"
+	closeMark = "}}"
+
+	// Blank replaces a hidden deletion that has no hint.
+	Blank = "[...]"
+)
+
+// ordPattern is the cloze number as the database reads it: 1 to 999.
+var ordPattern = regexp.MustCompile(`^[0-9]{1,3}$`)
+
+var (
+	ErrNoDeletions = errors.New("cloze text must contain at least one {{c1::...}} deletion")
+	ErrUnclosed    = errors.New("cloze deletion is not closed with }}")
+	ErrNested      = errors.New("cloze deletions cannot be nested")
+)
+
+type part struct {
+	text string
+	ord  int // 0 for plain text
+	hint string
+}
+
+// Note is parsed cloze text.
+type Note struct {
+	parts []part
+}
+
+// Parse validates the markup in text.
+func Parse(text string) (Note, error) {
+	var n Note
+	rest := text
+	for {
+		i := strings.Index(rest, openMark)
+		if i < 0 {
+			break
+		}
+		if i > 0 {
+			n.parts = append(n.parts, part{text: rest[:i]})
+		}
+		rest = rest[i+len(openMark):]
+
+		j := strings.Index(rest, closeMark)
+		if j < 0 {
+			return Note{}, ErrUnclosed
+		}
+		body := rest[:j]
+		rest = rest[j+len(closeMark):]
+		if strings.Contains(body, openMark) {
+			return Note{}, ErrNested
+		}
+
+		p, err := parseDeletion(body)
+		if err != nil {
+			return Note{}, err
+		}
+		n.parts = append(n.parts, p)
+	}
+	if rest != "" {
+		n.parts = append(n.parts, part{text: rest})
+	}
+	if len(n.Ords()) == 0 {
+		return Note{}, ErrNoDeletions
+	}
+	return n, nil
+}
+
+// parseDeletion reads "c<N>::text" or "c<N>::text::hint".
+func parseDeletion(body string) (part, error) {
+	fields := strings.SplitN(body, "::", 3)
+	if len(fields) < 2 || !strings.HasPrefix(fields[0], "c") {
+		return part{}, fmt.Errorf("malformed cloze deletion %q", openMark+body+closeMark)
+	}
+	ord, err := strconv.Atoi(fields[0][1:])
+	if !ordPattern.MatchString(fields[0][1:]) || err != nil || ord < 1 {
+		return part{}, fmt.Errorf("invalid cloze number in %q", openMark+body+closeMark)
+	}
+	p := part{text: fields[1], ord: ord}
+	if strings.TrimSpace(p.text) == "" {
+		return part{}, fmt.Errorf("cloze deletion c%d is empty", ord)
+	}
+	if len(fields) == 3 {
+		p.hint = strings.TrimSpace(fields[2])
+	}
+	return p, nil
+}
+
+// Ords lists the cloze numbers in ascending order.
+func (n Note) Ords() []int {
+	var ords []int
+	for _, p := range n.parts {
+		if p.ord > 0 && !slices.Contains(ords, p.ord) {
+			ords = append(ords, p.ord)
+		}
+	}
+	slices.Sort(ords)
+	return ords
+}
+
+// Render returns the item for cloze number ord: the text with those
+// deletions blanked out, and the hidden text that answers it.
+func (n Note) Render(ord int) (question, answer string) {
+	var q strings.Builder
+	var hidden []string
+	for _, p := range n.parts {
+		switch {
+		case p.ord != ord:
+			q.WriteString(p.text)
+		case p.hint != "":
+			q.WriteString("[" + p.hint + "]")
+			hidden = append(hidden, p.text)
+		default:
+			q.WriteString(Blank)
+			hidden = append(hidden, p.text)
+		}
+	}
+	return q.String(), strings.Join(hidden, ", ")
+}
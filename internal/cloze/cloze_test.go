@@ -0,0 +1,124 @@
+package cloze
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	type item struct{ question, answer string }
+	tests := []struct {
+		name  string
+		text  string
+		ords  []int
+		items []item
+	}{
+		{
+			name:  "single deletion",
+			text:  "{{c1::Paris}} is the capital of France",
+			ords:  []int{1},
+			items: []item{{"[...] is the capital of France", "Paris"}},
+		},
+		{
+			name: "two numbers with a hint",
+			text: "{{c1::Paris}} is the capital of {{c2::France::country}}",
+			ords: []int{1, 2},
+			items: []item{
+				{"[...] is the capital of France", "Paris"},
+				{"Paris is the capital of [country]", "France"},
+			},
+		},
+		{
+			name:  "same number twice",
+			text:  "{{c1::H}}2{{c1::O}} is water",
+			ords:  []int{1},
+			items: []item{{"[...]2[...] is water", "H, O"}},
+		},
+		{
+			name: "numbers out of order",
+			text: "{{c3::a}} {{c1::b}} {{c3::c}}",
+			ords: []int{1, 3},
+			items: []item{
+				{"a [...] c", "b"},
+				{"[...] b [...]", "a, c"},
+			},
+		},
+		{
+			name:  "hint is trimmed, text keeps colons",
+			text:  "at {{c1::12:30::  time  }}",
+			ords:  []int{1},
+			items: []item{{"at [time]", "12:30"}},
+		},
+		{
+			name:  "hint may contain the separator",
+			text:  "{{c1::a::b::c}}",
+			ords:  []int{1},
+			items: []item{{"[b::c]", "a"}},
+		},
+		{
+			name:  "leading zeros and the highest number",
+			text:  "{{c007::a}} {{c999::b}}",
+			ords:  []int{7, 999},
+			items: []item{{"[...] b", "a"}, {"a [...]", "b"}},
+		},
+		{
+			name:  "stray closing braces are text",
+			text:  "}} {{c1::x}}",
+			ords:  []int{1},
+			items: []item{{"}} [...]", "x"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n, err := Parse(tt.text)
+			if err != nil {
+				t.Fatalf("Parse(%q): %v", tt.text, err)
+			}
+			if got := n.Ords(); !slices.Equal(got, tt.ords) {
+				t.Fatalf("Ords = %v, want %v", got, tt.ords)
+			}
+			for i, ord := range tt.ords {
+				q, a := n.Render(ord)
+				if q != tt.items[i].question || a != tt.items[i].answer {
+					t.Errorf("Render(%d) = %q, %q, want %q, %q", ord, q, a, tt.items[i].question, tt.items[i].answer)
+				}
+			}
+		})
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		text    string
+		wantErr error  // when the error is one of the sentinels
+		wantMsg string // otherwise, part of the message
+	}{
+		{text: "no deletions here", wantErr: ErrNoDeletions},
+		{text: "", wantErr: ErrNoDeletions},
+		{text: "{{c1::Paris", wantErr: ErrUnclosed},
+		{text: "{{c1::{{c2::x}}}}", wantErr: ErrNested},
+		{text: "{{Paris}}", wantMsg: "malformed"},
+		{text: "{{x1::Paris}}", wantMsg: "malformed"},
+		{text: "{{cx::Paris}}", wantMsg: "invalid cloze number"},
+		{text: "{{c0::Paris}}", wantMsg: "invalid cloze number"},
+		{text: "{{c-1::Paris}}", wantMsg: "invalid cloze number"},
+		{text: "{{c+5::Paris}}", wantMsg: "invalid cloze number"},
+		{text: "{{c 5::Paris}}", wantMsg: "invalid cloze number"},
+		{text: "{{c1000::Paris}}", wantMsg: "invalid cloze number"},
+		{text: "{{c3000000000::Paris}}", wantMsg: "invalid cloze number"},
+		{text: "{{c1::  }}", wantMsg: "empty"},
+	}
+	for _, tt := range tests {
+		_, err := Parse(tt.text)
+		switch {
+		case err == nil:
+			t.Errorf("Parse(%q) succeeded", tt.text)
+		case tt.wantErr != nil && !errors.Is(err, tt.wantErr):
+			t.Errorf("Parse(%q) error = %v, want %v", tt.text, err, tt.wantErr)
+		case tt.wantErr == nil && !strings.Contains(err.Error(), tt.wantMsg):
+			t.Errorf("Parse(%q) error = %v, want it to mention %q", tt.text, err, tt.wantMsg)
+		}
+	}
+}
@@ -0,0 +1,84 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.26.0
+// source: achievements.sql
+
+package database
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const achievementFacts = `-- name: AchievementFacts :one
+SELECT (SELECT COUNT(*) FROM packs p WHERE p.owner_id = $1 AND p.deleted_at IS NULL)::int AS packs_created,
+       (SELECT COUNT(*) FROM reviews r WHERE r.user_id = $1)::bigint AS reviews,
+       COALESCE((
+           SELECT MAX(pm.card_count)
+           FROM pack_masteries pm
+           JOIN packs p ON p.id = pm.pack_id AND p.deleted_at IS NULL
+           WHERE pm.user_id = $1
+       ), 0)::bigint AS largest_mastered_pack
+`
+
+type AchievementFactsRow struct {
+	PacksCreated        int32
+	Reviews             int64
+	LargestMasteredPack int64
+}
+
+// What the achievement rules look at, apart from the streak. Mastered packs
+// count with the size they had when mastered.
+func (q *Queries) AchievementFacts(ctx context.Context, userID pgtype.UUID) (AchievementFactsRow, error) {
+	row := q.db.QueryRow(ctx, achievementFacts, userID)
+	var i AchievementFactsRow
+	err := row.Scan(&i.PacksCreated, &i.Reviews, &i.LargestMasteredPack)
+	return i, err
+}
+
+const awardAchievement = `-- name: AwardAchievement :one
+INSERT INTO user_achievements (user_id, achievement)
+VALUES ($1, $2)
+ON CONFLICT DO NOTHING
+RETURNING user_id, achievement, awarded_at
+`
+
+type AwardAchievementParams struct {
+	UserID      pgtype.UUID
+	Achievement string
+}
+
+// No row when the user has the achievement already.
+func (q *Queries) AwardAchievement(ctx context.Context, arg AwardAchievementParams) (UserAchievement, error) {
+	row := q.db.QueryRow(ctx, awardAchievement, arg.UserID, arg.Achievement)
+	var i UserAchievement
+	err := row.Scan(&i.UserID, &i.Achievement, &i.AwardedAt)
+	return i, err
+}
+
+const listUserAchievements = `-- name: ListUserAchievements :many
+SELECT user_id, achievement, awarded_at FROM user_achievements
+WHERE user_id = $1
+ORDER BY awarded_at, achievement
+`
+
+func (q *Queries) ListUserAchievements(ctx context.Context, userID pgtype.UUID) ([]UserAchievement, error) {
+	rows, err := q.db.Query(ctx, listUserAchievements, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []UserAchievement
+	for rows.Next() {
+		var i UserAchievement
+		if err := rows.Scan(&i.UserID, &i.Achievement, &i.AwardedAt); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
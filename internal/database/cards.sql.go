@@ -11,17 +11,36 @@ import (
 	"github.com/jackc/pgx/v5/pgtype"
 )
 
+const cardLastWrong = `-- name: CardLastWrong :one
+SELECT card_last_wrong($1::uuid, $2::uuid)::bool
+`
+
+type CardLastWrongParams struct {
+	CardID pgtype.UUID
+	UserID pgtype.UUID
+}
+
+func (q *Queries) CardLastWrong(ctx context.Context, arg CardLastWrongParams) (bool, error) {
+	row := q.db.QueryRow(ctx, cardLastWrong, arg.CardID, arg.UserID)
+	var column_1 bool
+	err := row.Scan(&column_1)
+	return column_1, err
+}
+
 const createCard = `-- name: CreateCard :one
-INSERT INTO cards (question, answer, pack_id, rating)
-VALUES ($1, $2, $3, $4)
-RETURNING id, question, answer, pack_id, rating, created_at, updated_at, last_wrong
+INSERT INTO cards (question, answer, pack_id, rating, direction, card_type, content_format)
+VALUES ($1, $2, $3, $4, $5, $6, $7)
+RETURNING id, question, answer, pack_id, rating, created_at, updated_at, last_wrong, direction, card_type, content_format, deleted_at, deleted_by, upstream_card_id, upstream_synced_at, upstream_hash
 `
 
 type CreateCardParams struct {
-	Question string
-	Answer   string
-	PackID   pgtype.UUID
-	Rating   pgtype.Int4
+	Question      string
+	Answer        string
+	PackID        pgtype.UUID
+	Rating        pgtype.Int4
+	Direction     pgtype.Text
+	CardType      string
+	ContentFormat string
 }
 
 func (q *Queries) CreateCard(ctx context.Context, arg CreateCardParams) (Card, error) {
@@ -30,6 +49,9 @@ func (q *Queries) CreateCard(ctx context.Context, arg CreateCardParams) (Card, e
 		arg.Answer,
 		arg.PackID,
 		arg.Rating,
+		arg.Direction,
+		arg.CardType,
+		arg.ContentFormat,
 	)
 	var i Card
 	err := row.Scan(
@@ -41,28 +63,137 @@ func (q *Queries) CreateCard(ctx context.Context, arg CreateCardParams) (Card, e
 		&i.CreatedAt,
 		&i.UpdatedAt,
 		&i.LastWrong,
+		&i.Direction,
+		&i.CardType,
+		&i.ContentFormat,
+		&i.DeletedAt,
+		&i.DeletedBy,
+		&i.UpstreamCardID,
+		&i.UpstreamSyncedAt,
+		&i.UpstreamHash,
 	)
 	return i, err
 }
 
-const deleteCard = `-- name: DeleteCard :exec
-DELETE FROM cards WHERE id = $1
+const listCardsByPack = `-- name: ListCardsByPack :many
+SELECT c.id, c.question, c.answer, c.pack_id, c.rating, c.created_at, c.updated_at, c.last_wrong, c.direction, c.card_type, c.content_format, c.deleted_at, c.deleted_by, c.upstream_card_id, c.upstream_synced_at, c.upstream_hash, card_last_wrong(c.id, $1::uuid)::bool AS user_last_wrong
+FROM cards c
+WHERE c.pack_id = $2
+  AND c.deleted_at IS NULL
+  AND pack_visible(c.pack_id, $1::uuid)
+  AND ($3::bool IS NULL OR EXISTS (
+        SELECT 1 FROM card_progress cp
+        WHERE cp.card_id = c.id AND cp.user_id = $1::uuid AND cp.due_at <= NOW()
+      ) = $3::bool)
+  AND ($4::uuid IS NULL OR CASE
+        WHEN $5::text = 'question' AND $6::bool
+            THEN (c.question, c.id) < ($7::text, $4::uuid)
+        WHEN $5::text = 'question'
+            THEN (c.question, c.id) > ($7::text, $4::uuid)
+        WHEN $5::text = 'updated_at' AND $6::bool
+            THEN (c.updated_at, c.id) < ($8::timestamptz, $4::uuid)
+        WHEN $5::text = 'updated_at'
+            THEN (c.updated_at, c.id) > ($8::timestamptz, $4::uuid)
+        WHEN $5::text = 'rating' AND $6::bool
+            THEN (COALESCE(c.rating, 0), c.id) < ($9::int, $4::uuid)
+        WHEN $5::text = 'rating'
+            THEN (COALESCE(c.rating, 0), c.id) > ($9::int, $4::uuid)
+        WHEN $6::bool
+            THEN (c.created_at, c.id) < ($8::timestamptz, $4::uuid)
+        ELSE (c.created_at, c.id) > ($8::timestamptz, $4::uuid)
+      END)
+ORDER BY
+  CASE WHEN $5::text = 'question'   AND NOT $6::bool THEN c.question END ASC,
+  CASE WHEN $5::text = 'question'   AND $6::bool     THEN c.question END DESC,
+  CASE WHEN $5::text = 'updated_at' AND NOT $6::bool THEN c.updated_at END ASC,
+  CASE WHEN $5::text = 'updated_at' AND $6::bool     THEN c.updated_at END DESC,
+  CASE WHEN $5::text = 'rating'     AND NOT $6::bool THEN COALESCE(c.rating, 0) END ASC,
+  CASE WHEN $5::text = 'rating'     AND $6::bool     THEN COALESCE(c.rating, 0) END DESC,
+  -- ties are broken by id alone, as in the cursor
+  CASE WHEN $5::text NOT IN ('question', 'updated_at', 'rating') AND NOT $6::bool THEN c.created_at END ASC,
+  CASE WHEN $5::text NOT IN ('question', 'updated_at', 'rating') AND $6::bool     THEN c.created_at END DESC,
+  CASE WHEN NOT $6::bool THEN c.id END ASC,
+  CASE WHEN $6::bool     THEN c.id END DESC
+LIMIT $10
 `
 
-func (q *Queries) DeleteCard(ctx context.Context, id pgtype.UUID) error {
-	_, err := q.db.Exec(ctx, deleteCard, id)
-	return err
+type ListCardsByPackParams struct {
+	UserID      pgtype.UUID
+	PackID      pgtype.UUID
+	Due         pgtype.Bool
+	CursorID    pgtype.UUID
+	SortBy      string
+	SortDesc    bool
+	CursorName  pgtype.Text
+	CursorTime  pgtype.Timestamptz
+	CursorCount pgtype.Int4
+	PageLimit   int32
 }
 
-const listCardsByPack = `-- name: ListCardsByPack :many
-SELECT id, question, answer, pack_id, rating, created_at, updated_at, last_wrong
-FROM cards
-WHERE pack_id = $1
-ORDER BY created_at DESC
+type ListCardsByPackRow struct {
+	Card          Card
+	UserLastWrong bool
+}
+
+// Keyset-paginated, see ListPacks for the cursor_* convention. Due cards
+// have a prompt the user is due to review, as counted by ListPacks.
+func (q *Queries) ListCardsByPack(ctx context.Context, arg ListCardsByPackParams) ([]ListCardsByPackRow, error) {
+	rows, err := q.db.Query(ctx, listCardsByPack,
+		arg.UserID,
+		arg.PackID,
+		arg.Due,
+		arg.CursorID,
+		arg.SortBy,
+		arg.SortDesc,
+		arg.CursorName,
+		arg.CursorTime,
+		arg.CursorCount,
+		arg.PageLimit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListCardsByPackRow
+	for rows.Next() {
+		var i ListCardsByPackRow
+		if err := rows.Scan(
+			&i.Card.ID,
+			&i.Card.Question,
+			&i.Card.Answer,
+			&i.Card.PackID,
+			&i.Card.Rating,
+			&i.Card.CreatedAt,
+			&i.Card.UpdatedAt,
+			&i.Card.LastWrong,
+			&i.Card.Direction,
+			&i.Card.CardType,
+			&i.Card.ContentFormat,
+			&i.Card.DeletedAt,
+			&i.Card.DeletedBy,
+			&i.Card.UpstreamCardID,
+			&i.Card.UpstreamSyncedAt,
+			&i.Card.UpstreamHash,
+			&i.UserLastWrong,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listPackCards = `-- name: ListPackCards :many
+SELECT id, question, answer, pack_id, rating, created_at, updated_at, last_wrong, direction, card_type, content_format, deleted_at, deleted_by, upstream_card_id, upstream_synced_at, upstream_hash FROM cards
+WHERE pack_id = $1 AND deleted_at IS NULL
+ORDER BY created_at, id
 `
 
-func (q *Queries) ListCardsByPack(ctx context.Context, packID pgtype.UUID) ([]Card, error) {
-	rows, err := q.db.Query(ctx, listCardsByPack, packID)
+func (q *Queries) ListPackCards(ctx context.Context, packID pgtype.UUID) ([]Card, error) {
+	rows, err := q.db.Query(ctx, listPackCards, packID)
 	if err != nil {
 		return nil, err
 	}
@@ -79,6 +210,14 @@ func (q *Queries) ListCardsByPack(ctx context.Context, packID pgtype.UUID) ([]Ca
 			&i.CreatedAt,
 			&i.UpdatedAt,
 			&i.LastWrong,
+			&i.Direction,
+			&i.CardType,
+			&i.ContentFormat,
+			&i.DeletedAt,
+			&i.DeletedBy,
+			&i.UpstreamCardID,
+			&i.UpstreamSyncedAt,
+			&i.UpstreamHash,
 		); err != nil {
 			return nil, err
 		}
@@ -91,20 +230,51 @@ func (q *Queries) ListCardsByPack(ctx context.Context, packID pgtype.UUID) ([]Ca
 }
 
 const listRepeatCards = `-- name: ListRepeatCards :many
-SELECT id, question, answer, pack_id, rating, created_at, updated_at, last_wrong FROM cards
-WHERE pack_id = $1
-ORDER BY last_wrong DESC, created_at ASC
+SELECT c.id, c.question, c.answer, c.pack_id, c.rating, c.created_at, c.updated_at, c.last_wrong, c.direction, c.card_type, c.content_format, c.deleted_at, c.deleted_by, c.upstream_card_id, c.upstream_synced_at, c.upstream_hash, card_prompts(c.card_type, c.question, c.direction, p.direction)::text[] AS directions,
+       card_last_wrong(c.id, $1::uuid)::bool AS user_last_wrong
+FROM cards c
+JOIN packs p ON p.id = c.pack_id
+WHERE c.pack_id = $2 AND c.deleted_at IS NULL
+  AND pack_visible(c.pack_id, $1::uuid)
+ORDER BY user_last_wrong DESC, c.created_at ASC
 `
 
-func (q *Queries) ListRepeatCards(ctx context.Context, packID pgtype.UUID) ([]Card, error) {
-	rows, err := q.db.Query(ctx, listRepeatCards, packID)
+type ListRepeatCardsParams struct {
+	UserID pgtype.UUID
+	PackID pgtype.UUID
+}
+
+type ListRepeatCardsRow struct {
+	ID               pgtype.UUID
+	Question         string
+	Answer           string
+	PackID           pgtype.UUID
+	Rating           pgtype.Int4
+	CreatedAt        pgtype.Timestamptz
+	UpdatedAt        pgtype.Timestamptz
+	LastWrong        pgtype.Bool
+	Direction        pgtype.Text
+	CardType         string
+	ContentFormat    string
+	DeletedAt        pgtype.Timestamptz
+	DeletedBy        pgtype.UUID
+	UpstreamCardID   pgtype.UUID
+	UpstreamSyncedAt pgtype.Timestamptz
+	UpstreamHash     pgtype.Text
+	Directions       []string
+	UserLastWrong    bool
+}
+
+// Cards the user last answered wrong come first.
+func (q *Queries) ListRepeatCards(ctx context.Context, arg ListRepeatCardsParams) ([]ListRepeatCardsRow, error) {
+	rows, err := q.db.Query(ctx, listRepeatCards, arg.UserID, arg.PackID)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
-	var items []Card
+	var items []ListRepeatCardsRow
 	for rows.Next() {
-		var i Card
+		var i ListRepeatCardsRow
 		if err := rows.Scan(
 			&i.ID,
 			&i.Question,
@@ -114,6 +284,16 @@ func (q *Queries) ListRepeatCards(ctx context.Context, packID pgtype.UUID) ([]Ca
 			&i.CreatedAt,
 			&i.UpdatedAt,
 			&i.LastWrong,
+			&i.Direction,
+			&i.CardType,
+			&i.ContentFormat,
+			&i.DeletedAt,
+			&i.DeletedBy,
+			&i.UpstreamCardID,
+			&i.UpstreamSyncedAt,
+			&i.UpstreamHash,
+			&i.Directions,
+			&i.UserLastWrong,
 		); err != nil {
 			return nil, err
 		}
@@ -125,26 +305,26 @@ func (q *Queries) ListRepeatCards(ctx context.Context, packID pgtype.UUID) ([]Ca
 	return items, nil
 }
 
-const markCardWrong = `-- name: MarkCardWrong :exec
-UPDATE cards
-SET last_wrong = $1
-WHERE id = $2
+const purgeDeletedCards = `-- name: PurgeDeletedCards :execrows
+DELETE FROM cards WHERE deleted_at < $1::timestamptz
 `
 
-type MarkCardWrongParams struct {
-	LastWrong pgtype.Bool
-	ID        pgtype.UUID
-}
-
-func (q *Queries) MarkCardWrong(ctx context.Context, arg MarkCardWrongParams) error {
-	_, err := q.db.Exec(ctx, markCardWrong, arg.LastWrong, arg.ID)
-	return err
+func (q *Queries) PurgeDeletedCards(ctx context.Context, before pgtype.Timestamptz) (int64, error) {
+	result, err := q.db.Exec(ctx, purgeDeletedCards, before)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
 }
 
 const readCard = `-- name: ReadCard :one
-SELECT id, question, answer, pack_id, rating, created_at, updated_at, last_wrong FROM cards WHERE id = $1
+SELECT c.id, c.question, c.answer, c.pack_id, c.rating, c.created_at, c.updated_at, c.last_wrong, c.direction, c.card_type, c.content_format, c.deleted_at, c.deleted_by, c.upstream_card_id, c.upstream_synced_at, c.upstream_hash
+FROM cards c
+JOIN packs p ON p.id = c.pack_id
+WHERE c.id = $1 AND c.deleted_at IS NULL AND p.deleted_at IS NULL
 `
 
+// Cards in the trash, or in a pack in the trash, are not found.
 func (q *Queries) ReadCard(ctx context.Context, id pgtype.UUID) (Card, error) {
 	row := q.db.QueryRow(ctx, readCard, id)
 	var i Card
@@ -157,25 +337,152 @@ func (q *Queries) ReadCard(ctx context.Context, id pgtype.UUID) (Card, error) {
 		&i.CreatedAt,
 		&i.UpdatedAt,
 		&i.LastWrong,
+		&i.Direction,
+		&i.CardType,
+		&i.ContentFormat,
+		&i.DeletedAt,
+		&i.DeletedBy,
+		&i.UpstreamCardID,
+		&i.UpstreamSyncedAt,
+		&i.UpstreamHash,
 	)
 	return i, err
 }
 
+const readCardAny = `-- name: ReadCardAny :one
+SELECT id, question, answer, pack_id, rating, created_at, updated_at, last_wrong, direction, card_type, content_format, deleted_at, deleted_by, upstream_card_id, upstream_synced_at, upstream_hash FROM cards WHERE id = $1
+`
+
+// Like ReadCard, but also finds cards in the trash.
+func (q *Queries) ReadCardAny(ctx context.Context, id pgtype.UUID) (Card, error) {
+	row := q.db.QueryRow(ctx, readCardAny, id)
+	var i Card
+	err := row.Scan(
+		&i.ID,
+		&i.Question,
+		&i.Answer,
+		&i.PackID,
+		&i.Rating,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.LastWrong,
+		&i.Direction,
+		&i.CardType,
+		&i.ContentFormat,
+		&i.DeletedAt,
+		&i.DeletedBy,
+		&i.UpstreamCardID,
+		&i.UpstreamSyncedAt,
+		&i.UpstreamHash,
+	)
+	return i, err
+}
+
+const restoreCard = `-- name: RestoreCard :one
+UPDATE cards
+SET deleted_at = NULL, deleted_by = NULL
+WHERE id = $1 AND deleted_at IS NOT NULL
+RETURNING id, question, answer, pack_id, rating, created_at, updated_at, last_wrong, direction, card_type, content_format, deleted_at, deleted_by, upstream_card_id, upstream_synced_at, upstream_hash
+`
+
+func (q *Queries) RestoreCard(ctx context.Context, id pgtype.UUID) (Card, error) {
+	row := q.db.QueryRow(ctx, restoreCard, id)
+	var i Card
+	err := row.Scan(
+		&i.ID,
+		&i.Question,
+		&i.Answer,
+		&i.PackID,
+		&i.Rating,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.LastWrong,
+		&i.Direction,
+		&i.CardType,
+		&i.ContentFormat,
+		&i.DeletedAt,
+		&i.DeletedBy,
+		&i.UpstreamCardID,
+		&i.UpstreamSyncedAt,
+		&i.UpstreamHash,
+	)
+	return i, err
+}
+
+const setCardDirection = `-- name: SetCardDirection :execrows
+UPDATE cards
+SET direction = $1
+FROM packs p
+WHERE cards.id = $2 AND cards.pack_id = $3 AND cards.deleted_at IS NULL
+  AND p.id = cards.pack_id
+  AND pack_role(p.id, $4::uuid) IN ('owner', 'editor')
+`
+
+type SetCardDirectionParams struct {
+	Direction pgtype.Text
+	ID        pgtype.UUID
+	PackID    pgtype.UUID
+	OwnerID   pgtype.UUID
+}
+
+func (q *Queries) SetCardDirection(ctx context.Context, arg SetCardDirectionParams) (int64, error) {
+	result, err := q.db.Exec(ctx, setCardDirection,
+		arg.Direction,
+		arg.ID,
+		arg.PackID,
+		arg.OwnerID,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const softDeleteCard = `-- name: SoftDeleteCard :execrows
+UPDATE cards
+SET deleted_at = NOW(), deleted_by = $1
+WHERE id = $2 AND deleted_at IS NULL
+`
+
+type SoftDeleteCardParams struct {
+	DeletedBy pgtype.UUID
+	ID        pgtype.UUID
+}
+
+func (q *Queries) SoftDeleteCard(ctx context.Context, arg SoftDeleteCardParams) (int64, error) {
+	result, err := q.db.Exec(ctx, softDeleteCard, arg.DeletedBy, arg.ID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
 const updateCard = `-- name: UpdateCard :one
 UPDATE cards
-SET question = $1, answer = $2, rating = 2
-WHERE id = $3
-RETURNING id, question, answer, pack_id, rating, created_at, updated_at, last_wrong
+SET question = $1, answer = $2, card_type = $3,
+    content_format = $4, direction = $5
+WHERE id = $6 AND deleted_at IS NULL
+RETURNING id, question, answer, pack_id, rating, created_at, updated_at, last_wrong, direction, card_type, content_format, deleted_at, deleted_by, upstream_card_id, upstream_synced_at, upstream_hash
 `
 
 type UpdateCardParams struct {
-	Question string
-	Answer   string
-	ID       pgtype.UUID
+	Question      string
+	Answer        string
+	CardType      string
+	ContentFormat string
+	Direction     pgtype.Text
+	ID            pgtype.UUID
 }
 
 func (q *Queries) UpdateCard(ctx context.Context, arg UpdateCardParams) (Card, error) {
-	row := q.db.QueryRow(ctx, updateCard, arg.Question, arg.Answer, arg.ID)
+	row := q.db.QueryRow(ctx, updateCard,
+		arg.Question,
+		arg.Answer,
+		arg.CardType,
+		arg.ContentFormat,
+		arg.Direction,
+		arg.ID,
+	)
 	var i Card
 	err := row.Scan(
 		&i.ID,
@@ -186,6 +493,14 @@ func (q *Queries) UpdateCard(ctx context.Context, arg UpdateCardParams) (Card, e
 		&i.CreatedAt,
 		&i.UpdatedAt,
 		&i.LastWrong,
+		&i.Direction,
+		&i.CardType,
+		&i.ContentFormat,
+		&i.DeletedAt,
+		&i.DeletedBy,
+		&i.UpstreamCardID,
+		&i.UpstreamSyncedAt,
+		&i.UpstreamHash,
 	)
 	return i, err
 }
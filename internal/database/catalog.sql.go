@@ -0,0 +1,370 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.26.0
+// source: catalog.sql
+
+package database
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const deletePackRating = `-- name: DeletePackRating :execrows
+DELETE FROM pack_ratings WHERE pack_id = $1 AND user_id = $2
+`
+
+type DeletePackRatingParams struct {
+	PackID pgtype.UUID
+	UserID pgtype.UUID
+}
+
+func (q *Queries) DeletePackRating(ctx context.Context, arg DeletePackRatingParams) (int64, error) {
+	result, err := q.db.Exec(ctx, deletePackRating, arg.PackID, arg.UserID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const listCatalog = `-- name: ListCatalog :many
+WITH RECURSIVE category_tree AS (
+    SELECT categories.id FROM categories WHERE categories.id = $2::uuid
+    UNION ALL
+    SELECT ch.id FROM categories ch JOIN category_tree t ON ch.parent_id = t.id
+)
+SELECT p.id, p.name, p.description, cat.name AS category, p.category_id,
+       p.owner_id, u.username AS owner_name,
+       p.source_lang, p.target_lang, p.cover_color, p.cover_icon, p.created_at,
+       sc.subscriber_count::bigint AS subscriber_count,
+       cc.card_count::bigint AS card_count,
+       r.rating_count::bigint AS rating_count,
+       r.rating_avg::float8 AS rating_avg,
+       r.rating_score::bigint AS rating_score,
+       COALESCE(GREATEST(p.updated_at, cc.last_card_at), p.created_at)::timestamptz AS active_at,
+       EXISTS (
+           SELECT 1 FROM subscriptions s
+           WHERE s.pack_id = p.id AND s.user_id = $1::uuid
+       )::bool AS subscribed
+FROM packs p
+LEFT JOIN categories cat ON cat.id = p.category_id
+LEFT JOIN users u ON u.id = p.owner_id
+CROSS JOIN LATERAL (
+    SELECT COUNT(*) AS subscriber_count FROM subscriptions s WHERE s.pack_id = p.id
+) sc
+CROSS JOIN LATERAL (
+    SELECT COUNT(*) AS card_count, MAX(c.updated_at) AS last_card_at
+    FROM cards c
+    WHERE c.pack_id = p.id AND c.deleted_at IS NULL
+) cc
+CROSS JOIN LATERAL (
+    SELECT COUNT(*) AS rating_count,
+           COALESCE(AVG(pr.stars), 0) AS rating_avg,
+           1000 * (COALESCE(SUM(pr.stars), 0) + 15) / (COUNT(*) + 5) AS rating_score
+    FROM pack_ratings pr
+    WHERE pr.pack_id = p.id
+) r
+WHERE p.visibility = 'public' AND p.deleted_at IS NULL
+  AND ($2::uuid IS NULL OR p.category_id IN (SELECT category_tree.id FROM category_tree))
+  AND ($3::text IS NULL OR
+       to_tsvector('russian', p.name || ' ' || p.description) @@ websearch_to_tsquery('russian', $3::text))
+  AND ($4::text IS NULL OR lower(p.source_lang) = lower($4::text))
+  AND ($5::text IS NULL OR lower(p.target_lang) = lower($5::text))
+  AND ($6::uuid IS NULL OR CASE
+        WHEN $7::text = 'name' AND $8::bool
+            THEN (p.name, p.id) < ($9::text, $6::uuid)
+        WHEN $7::text = 'name'
+            THEN (p.name, p.id) > ($9::text, $6::uuid)
+        WHEN $7::text = 'popular' AND $8::bool
+            THEN (sc.subscriber_count, p.id) < ($10::bigint, $6::uuid)
+        WHEN $7::text = 'popular'
+            THEN (sc.subscriber_count, p.id) > ($10::bigint, $6::uuid)
+        WHEN $7::text = 'rating' AND $8::bool
+            THEN (r.rating_score, p.id) < ($10::bigint, $6::uuid)
+        WHEN $7::text = 'rating'
+            THEN (r.rating_score, p.id) > ($10::bigint, $6::uuid)
+        WHEN $7::text = 'recent' AND $8::bool
+            THEN (COALESCE(GREATEST(p.updated_at, cc.last_card_at), p.created_at), p.id) < ($11::timestamptz, $6::uuid)
+        WHEN $7::text = 'recent'
+            THEN (COALESCE(GREATEST(p.updated_at, cc.last_card_at), p.created_at), p.id) > ($11::timestamptz, $6::uuid)
+        WHEN $8::bool
+            THEN (p.created_at, p.id) < ($11::timestamptz, $6::uuid)
+        ELSE (p.created_at, p.id) > ($11::timestamptz, $6::uuid)
+      END)
+ORDER BY
+  CASE WHEN $7::text = 'name'    AND NOT $8::bool THEN p.name END ASC,
+  CASE WHEN $7::text = 'name'    AND $8::bool     THEN p.name END DESC,
+  CASE WHEN $7::text = 'popular' AND NOT $8::bool THEN sc.subscriber_count END ASC,
+  CASE WHEN $7::text = 'popular' AND $8::bool     THEN sc.subscriber_count END DESC,
+  CASE WHEN $7::text = 'rating'  AND NOT $8::bool THEN r.rating_score END ASC,
+  CASE WHEN $7::text = 'rating'  AND $8::bool     THEN r.rating_score END DESC,
+  CASE WHEN $7::text = 'recent'  AND NOT $8::bool THEN COALESCE(GREATEST(p.updated_at, cc.last_card_at), p.created_at) END ASC,
+  CASE WHEN $7::text = 'recent'  AND $8::bool     THEN COALESCE(GREATEST(p.updated_at, cc.last_card_at), p.created_at) END DESC,
+  CASE WHEN NOT $8::bool THEN p.created_at END ASC,
+  CASE WHEN $8::bool     THEN p.created_at END DESC,
+  CASE WHEN NOT $8::bool THEN p.id END ASC,
+  CASE WHEN $8::bool     THEN p.id END DESC
+LIMIT $12
+`
+
+type ListCatalogParams struct {
+	UserID      pgtype.UUID
+	CategoryID  pgtype.UUID
+	Query       pgtype.Text
+	SourceLang  pgtype.Text
+	TargetLang  pgtype.Text
+	CursorID    pgtype.UUID
+	SortBy      string
+	SortDesc    bool
+	CursorName  pgtype.Text
+	CursorCount pgtype.Int8
+	CursorTime  pgtype.Timestamptz
+	PageLimit   int32
+}
+
+type ListCatalogRow struct {
+	ID              pgtype.UUID
+	Name            string
+	Description     string
+	Category        pgtype.Text
+	CategoryID      pgtype.UUID
+	OwnerID         pgtype.UUID
+	OwnerName       pgtype.Text
+	SourceLang      pgtype.Text
+	TargetLang      pgtype.Text
+	CoverColor      pgtype.Text
+	CoverIcon       pgtype.Text
+	CreatedAt       pgtype.Timestamptz
+	SubscriberCount int64
+	CardCount       int64
+	RatingCount     int64
+	RatingAvg       float64
+	RatingScore     int64
+	ActiveAt        pgtype.Timestamptz
+	Subscribed      bool
+}
+
+// Public packs, keyset-paginated like ListPacks. rating_score is a Bayesian
+// average (five votes of 3 stars are assumed for every pack) times 1000, so
+// a single 5-star vote does not put a pack on top. Activity is the last
+// change of the pack or any of its cards.
+func (q *Queries) ListCatalog(ctx context.Context, arg ListCatalogParams) ([]ListCatalogRow, error) {
+	rows, err := q.db.Query(ctx, listCatalog,
+		arg.UserID,
+		arg.CategoryID,
+		arg.Query,
+		arg.SourceLang,
+		arg.TargetLang,
+		arg.CursorID,
+		arg.SortBy,
+		arg.SortDesc,
+		arg.CursorName,
+		arg.CursorCount,
+		arg.CursorTime,
+		arg.PageLimit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListCatalogRow
+	for rows.Next() {
+		var i ListCatalogRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.Name,
+			&i.Description,
+			&i.Category,
+			&i.CategoryID,
+			&i.OwnerID,
+			&i.OwnerName,
+			&i.SourceLang,
+			&i.TargetLang,
+			&i.CoverColor,
+			&i.CoverIcon,
+			&i.CreatedAt,
+			&i.SubscriberCount,
+			&i.CardCount,
+			&i.RatingCount,
+			&i.RatingAvg,
+			&i.RatingScore,
+			&i.ActiveAt,
+			&i.Subscribed,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listPackRatings = `-- name: ListPackRatings :many
+SELECT pr.user_id, u.username, pr.stars, pr.review, pr.created_at, pr.updated_at
+FROM pack_ratings pr
+JOIN users u ON u.id = pr.user_id
+WHERE pr.pack_id = $1
+  AND ($2::uuid IS NULL
+       OR (pr.updated_at, pr.user_id) < ($3::timestamptz, $2::uuid))
+ORDER BY pr.updated_at DESC, pr.user_id DESC
+LIMIT $4
+`
+
+type ListPackRatingsParams struct {
+	PackID     pgtype.UUID
+	CursorID   pgtype.UUID
+	CursorTime pgtype.Timestamptz
+	PageLimit  int32
+}
+
+type ListPackRatingsRow struct {
+	UserID    pgtype.UUID
+	Username  string
+	Stars     int16
+	Review    string
+	CreatedAt pgtype.Timestamptz
+	UpdatedAt pgtype.Timestamptz
+}
+
+// Newest first, keyset-paginated on updated_at.
+func (q *Queries) ListPackRatings(ctx context.Context, arg ListPackRatingsParams) ([]ListPackRatingsRow, error) {
+	rows, err := q.db.Query(ctx, listPackRatings,
+		arg.PackID,
+		arg.CursorID,
+		arg.CursorTime,
+		arg.PageLimit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListPackRatingsRow
+	for rows.Next() {
+		var i ListPackRatingsRow
+		if err := rows.Scan(
+			&i.UserID,
+			&i.Username,
+			&i.Stars,
+			&i.Review,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const packRatingSummary = `-- name: PackRatingSummary :one
+SELECT COUNT(*)::bigint AS rating_count,
+       COALESCE(AVG(stars), 0)::float8 AS rating_avg,
+       COUNT(*) FILTER (WHERE stars = 1)::bigint AS stars_1,
+       COUNT(*) FILTER (WHERE stars = 2)::bigint AS stars_2,
+       COUNT(*) FILTER (WHERE stars = 3)::bigint AS stars_3,
+       COUNT(*) FILTER (WHERE stars = 4)::bigint AS stars_4,
+       COUNT(*) FILTER (WHERE stars = 5)::bigint AS stars_5
+FROM pack_ratings
+WHERE pack_id = $1
+`
+
+type PackRatingSummaryRow struct {
+	RatingCount int64
+	RatingAvg   float64
+	Stars1      int64
+	Stars2      int64
+	Stars3      int64
+	Stars4      int64
+	Stars5      int64
+}
+
+func (q *Queries) PackRatingSummary(ctx context.Context, packID pgtype.UUID) (PackRatingSummaryRow, error) {
+	row := q.db.QueryRow(ctx, packRatingSummary, packID)
+	var i PackRatingSummaryRow
+	err := row.Scan(
+		&i.RatingCount,
+		&i.RatingAvg,
+		&i.Stars1,
+		&i.Stars2,
+		&i.Stars3,
+		&i.Stars4,
+		&i.Stars5,
+	)
+	return i, err
+}
+
+const subscribe = `-- name: Subscribe :execrows
+INSERT INTO subscriptions (user_id, pack_id)
+VALUES ($1, $2)
+ON CONFLICT (user_id, pack_id) DO NOTHING
+`
+
+type SubscribeParams struct {
+	UserID pgtype.UUID
+	PackID pgtype.UUID
+}
+
+func (q *Queries) Subscribe(ctx context.Context, arg SubscribeParams) (int64, error) {
+	result, err := q.db.Exec(ctx, subscribe, arg.UserID, arg.PackID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const unsubscribe = `-- name: Unsubscribe :execrows
+DELETE FROM subscriptions WHERE user_id = $1 AND pack_id = $2
+`
+
+type UnsubscribeParams struct {
+	UserID pgtype.UUID
+	PackID pgtype.UUID
+}
+
+func (q *Queries) Unsubscribe(ctx context.Context, arg UnsubscribeParams) (int64, error) {
+	result, err := q.db.Exec(ctx, unsubscribe, arg.UserID, arg.PackID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const upsertPackRating = `-- name: UpsertPackRating :one
+INSERT INTO pack_ratings (pack_id, user_id, stars, review)
+VALUES ($1, $2, $3, $4)
+ON CONFLICT (pack_id, user_id) DO UPDATE
+SET stars = EXCLUDED.stars, review = EXCLUDED.review
+RETURNING pack_id, user_id, stars, review, created_at, updated_at
+`
+
+type UpsertPackRatingParams struct {
+	PackID pgtype.UUID
+	UserID pgtype.UUID
+	Stars  int16
+	Review string
+}
+
+func (q *Queries) UpsertPackRating(ctx context.Context, arg UpsertPackRatingParams) (PackRating, error) {
+	row := q.db.QueryRow(ctx, upsertPackRating,
+		arg.PackID,
+		arg.UserID,
+		arg.Stars,
+		arg.Review,
+	)
+	var i PackRating
+	err := row.Scan(
+		&i.PackID,
+		&i.UserID,
+		&i.Stars,
+		&i.Review,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+	)
+	return i, err
+}
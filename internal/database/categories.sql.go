@@ -0,0 +1,225 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.26.0
+// source: categories.sql
+
+package database
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const countCategoryChildren = `-- name: CountCategoryChildren :one
+SELECT COUNT(*) FROM categories WHERE parent_id = $1
+`
+
+func (q *Queries) CountCategoryChildren(ctx context.Context, parentID pgtype.UUID) (int64, error) {
+	row := q.db.QueryRow(ctx, countCategoryChildren, parentID)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
+const createCategory = `-- name: CreateCategory :one
+INSERT INTO categories (name, slug, parent_id, owner_id, created_by)
+VALUES ($1, slugify(COALESCE(NULLIF($2::text, ''), $1)), $3, $4, $5)
+RETURNING id, name, slug, parent_id, owner_id, created_by, created_at, updated_at
+`
+
+type CreateCategoryParams struct {
+	Name      string
+	Slug      string
+	ParentID  pgtype.UUID
+	OwnerID   pgtype.UUID
+	CreatedBy pgtype.UUID
+}
+
+func (q *Queries) CreateCategory(ctx context.Context, arg CreateCategoryParams) (Category, error) {
+	row := q.db.QueryRow(ctx, createCategory,
+		arg.Name,
+		arg.Slug,
+		arg.ParentID,
+		arg.OwnerID,
+		arg.CreatedBy,
+	)
+	var i Category
+	err := row.Scan(
+		&i.ID,
+		&i.Name,
+		&i.Slug,
+		&i.ParentID,
+		&i.OwnerID,
+		&i.CreatedBy,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+	)
+	return i, err
+}
+
+const deleteCategory = `-- name: DeleteCategory :exec
+DELETE FROM categories WHERE id = $1
+`
+
+func (q *Queries) DeleteCategory(ctx context.Context, id pgtype.UUID) error {
+	_, err := q.db.Exec(ctx, deleteCategory, id)
+	return err
+}
+
+const findCategoryBySlug = `-- name: FindCategoryBySlug :one
+SELECT id, name, slug, parent_id, owner_id, created_by, created_at, updated_at FROM categories
+WHERE slug = slugify($1::text) AND (owner_id IS NULL OR owner_id = $2::uuid)
+ORDER BY owner_id NULLS LAST
+LIMIT 1
+`
+
+type FindCategoryBySlugParams struct {
+	Name   string
+	UserID pgtype.UUID
+}
+
+// The user's own category wins over a global one with the same slug.
+func (q *Queries) FindCategoryBySlug(ctx context.Context, arg FindCategoryBySlugParams) (Category, error) {
+	row := q.db.QueryRow(ctx, findCategoryBySlug, arg.Name, arg.UserID)
+	var i Category
+	err := row.Scan(
+		&i.ID,
+		&i.Name,
+		&i.Slug,
+		&i.ParentID,
+		&i.OwnerID,
+		&i.CreatedBy,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+	)
+	return i, err
+}
+
+const isCategoryDescendant = `-- name: IsCategoryDescendant :one
+WITH RECURSIVE tree AS (
+    SELECT categories.id FROM categories WHERE categories.id = $2::uuid
+    UNION ALL
+    SELECT ch.id FROM categories ch JOIN tree t ON ch.parent_id = t.id
+)
+SELECT EXISTS (SELECT 1 FROM tree WHERE tree.id = $1::uuid)
+`
+
+type IsCategoryDescendantParams struct {
+	Candidate pgtype.UUID
+	ID        pgtype.UUID
+}
+
+// Reports whether candidate lies in the subtree rooted at id (id included),
+// used to refuse re-parenting that would create a cycle.
+func (q *Queries) IsCategoryDescendant(ctx context.Context, arg IsCategoryDescendantParams) (bool, error) {
+	row := q.db.QueryRow(ctx, isCategoryDescendant, arg.Candidate, arg.ID)
+	var exists bool
+	err := row.Scan(&exists)
+	return exists, err
+}
+
+const listCategories = `-- name: ListCategories :many
+SELECT id, name, slug, parent_id, owner_id, created_by, created_at, updated_at FROM categories
+WHERE (owner_id IS NULL OR owner_id = $1::uuid)
+  AND ($2::uuid IS NULL OR parent_id = $2::uuid)
+ORDER BY name, id
+`
+
+type ListCategoriesParams struct {
+	UserID   pgtype.UUID
+	ParentID pgtype.UUID
+}
+
+func (q *Queries) ListCategories(ctx context.Context, arg ListCategoriesParams) ([]Category, error) {
+	rows, err := q.db.Query(ctx, listCategories, arg.UserID, arg.ParentID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Category
+	for rows.Next() {
+		var i Category
+		if err := rows.Scan(
+			&i.ID,
+			&i.Name,
+			&i.Slug,
+			&i.ParentID,
+			&i.OwnerID,
+			&i.CreatedBy,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const readCategory = `-- name: ReadCategory :one
+SELECT id, name, slug, parent_id, owner_id, created_by, created_at, updated_at FROM categories
+WHERE id = $1 AND (owner_id IS NULL OR owner_id = $2::uuid)
+`
+
+type ReadCategoryParams struct {
+	ID     pgtype.UUID
+	UserID pgtype.UUID
+}
+
+// Only categories visible to the user: global ones and their own.
+func (q *Queries) ReadCategory(ctx context.Context, arg ReadCategoryParams) (Category, error) {
+	row := q.db.QueryRow(ctx, readCategory, arg.ID, arg.UserID)
+	var i Category
+	err := row.Scan(
+		&i.ID,
+		&i.Name,
+		&i.Slug,
+		&i.ParentID,
+		&i.OwnerID,
+		&i.CreatedBy,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+	)
+	return i, err
+}
+
+const updateCategory = `-- name: UpdateCategory :one
+UPDATE categories
+SET name = $1,
+    slug = slugify(COALESCE(NULLIF($2::text, ''), $1)),
+    parent_id = $3
+WHERE id = $4
+RETURNING id, name, slug, parent_id, owner_id, created_by, created_at, updated_at
+`
+
+type UpdateCategoryParams struct {
+	Name     string
+	Slug     string
+	ParentID pgtype.UUID
+	ID       pgtype.UUID
+}
+
+func (q *Queries) UpdateCategory(ctx context.Context, arg UpdateCategoryParams) (Category, error) {
+	row := q.db.QueryRow(ctx, updateCategory,
+		arg.Name,
+		arg.Slug,
+		arg.ParentID,
+		arg.ID,
+	)
+	var i Category
+	err := row.Scan(
+		&i.ID,
+		&i.Name,
+		&i.Slug,
+		&i.ParentID,
+		&i.OwnerID,
+		&i.CreatedBy,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+	)
+	return i, err
+}
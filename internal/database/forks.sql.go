@@ -0,0 +1,354 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.26.0
+// source: forks.sql
+
+package database
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const copyUpstreamCards = `-- name: CopyUpstreamCards :many
+INSERT INTO cards (question, answer, pack_id, rating, direction, card_type, content_format,
+                   upstream_card_id, upstream_synced_at, upstream_hash)
+SELECT u.question, u.answer, $1::uuid, 0, u.direction, u.card_type, u.content_format,
+       u.id, u.updated_at,
+       card_content_hash(u.question, u.answer, u.card_type, u.content_format, u.direction)
+FROM cards u
+WHERE u.pack_id = $2::uuid AND u.deleted_at IS NULL
+  AND ($3::uuid[] IS NULL OR u.id = ANY($3::uuid[]))
+  AND NOT EXISTS (
+        SELECT 1 FROM cards f
+        WHERE f.pack_id = $1::uuid AND f.upstream_card_id = u.id
+      )
+ORDER BY u.created_at, u.id
+RETURNING id, question, answer, pack_id, rating, created_at, updated_at, last_wrong, direction, card_type, content_format, deleted_at, deleted_by, upstream_card_id, upstream_synced_at, upstream_hash
+`
+
+type CopyUpstreamCardsParams struct {
+	PackID     pgtype.UUID
+	UpstreamID pgtype.UUID
+	CardIds    []pgtype.UUID
+}
+
+// Copies live upstream cards that have no copy in the fork yet, all of them
+// or only @card_ids. A copy in the trash counts, so cards deleted from the
+// fork are not offered again.
+func (q *Queries) CopyUpstreamCards(ctx context.Context, arg CopyUpstreamCardsParams) ([]Card, error) {
+	rows, err := q.db.Query(ctx, copyUpstreamCards, arg.PackID, arg.UpstreamID, arg.CardIds)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Card
+	for rows.Next() {
+		var i Card
+		if err := rows.Scan(
+			&i.ID,
+			&i.Question,
+			&i.Answer,
+			&i.PackID,
+			&i.Rating,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.LastWrong,
+			&i.Direction,
+			&i.CardType,
+			&i.ContentFormat,
+			&i.DeletedAt,
+			&i.DeletedBy,
+			&i.UpstreamCardID,
+			&i.UpstreamSyncedAt,
+			&i.UpstreamHash,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const copyUpstreamMedia = `-- name: CopyUpstreamMedia :exec
+INSERT INTO card_media (card_id, hash, position)
+SELECT f.id, cm.hash, cm.position
+FROM cards f
+JOIN card_media cm ON cm.card_id = f.upstream_card_id
+WHERE f.id = ANY($1::uuid[])
+ON CONFLICT DO NOTHING
+`
+
+func (q *Queries) CopyUpstreamMedia(ctx context.Context, cardIds []pgtype.UUID) error {
+	_, err := q.db.Exec(ctx, copyUpstreamMedia, cardIds)
+	return err
+}
+
+const forkPack = `-- name: ForkPack :one
+INSERT INTO packs (name, category_id, owner_id, direction, forked_from,
+                   description, source_lang, target_lang, cover_color, cover_icon)
+SELECT $1,
+       COALESCE($2::uuid, (
+           SELECT cat.id FROM categories cat
+           WHERE cat.id = up.category_id
+             AND (cat.owner_id IS NULL OR cat.owner_id = $3::uuid)
+       )),
+       $3::uuid, up.direction, up.id,
+       up.description, up.source_lang, up.target_lang, up.cover_color, up.cover_icon
+FROM packs up
+WHERE up.id = $4
+RETURNING id, name, created_at, updated_at, owner_id, category_id, direction, deleted_at, deleted_by, forked_from, description, source_lang, target_lang, cover_color, cover_icon, visibility, org_id
+`
+
+type ForkPackParams struct {
+	Name       string
+	CategoryID pgtype.UUID
+	OwnerID    pgtype.UUID
+	UpstreamID pgtype.UUID
+}
+
+// The upstream category is kept when the new owner can use it. A fork
+// starts out private.
+func (q *Queries) ForkPack(ctx context.Context, arg ForkPackParams) (Pack, error) {
+	row := q.db.QueryRow(ctx, forkPack,
+		arg.Name,
+		arg.CategoryID,
+		arg.OwnerID,
+		arg.UpstreamID,
+	)
+	var i Pack
+	err := row.Scan(
+		&i.ID,
+		&i.Name,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.OwnerID,
+		&i.CategoryID,
+		&i.Direction,
+		&i.DeletedAt,
+		&i.DeletedBy,
+		&i.ForkedFrom,
+		&i.Description,
+		&i.SourceLang,
+		&i.TargetLang,
+		&i.CoverColor,
+		&i.CoverIcon,
+		&i.Visibility,
+		&i.OrgID,
+	)
+	return i, err
+}
+
+const listUpstreamAdded = `-- name: ListUpstreamAdded :many
+SELECT u.id, u.question, u.answer, u.pack_id, u.rating, u.created_at, u.updated_at, u.last_wrong, u.direction, u.card_type, u.content_format, u.deleted_at, u.deleted_by, u.upstream_card_id, u.upstream_synced_at, u.upstream_hash
+FROM cards u
+WHERE u.pack_id = $1::uuid AND u.deleted_at IS NULL
+  AND NOT EXISTS (
+        SELECT 1 FROM cards f
+        WHERE f.pack_id = $2::uuid AND f.upstream_card_id = u.id
+      )
+ORDER BY u.created_at, u.id
+`
+
+type ListUpstreamAddedParams struct {
+	UpstreamID pgtype.UUID
+	PackID     pgtype.UUID
+}
+
+func (q *Queries) ListUpstreamAdded(ctx context.Context, arg ListUpstreamAddedParams) ([]Card, error) {
+	rows, err := q.db.Query(ctx, listUpstreamAdded, arg.UpstreamID, arg.PackID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Card
+	for rows.Next() {
+		var i Card
+		if err := rows.Scan(
+			&i.ID,
+			&i.Question,
+			&i.Answer,
+			&i.PackID,
+			&i.Rating,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.LastWrong,
+			&i.Direction,
+			&i.CardType,
+			&i.ContentFormat,
+			&i.DeletedAt,
+			&i.DeletedBy,
+			&i.UpstreamCardID,
+			&i.UpstreamSyncedAt,
+			&i.UpstreamHash,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listUpstreamChanged = `-- name: ListUpstreamChanged :many
+SELECT f.id, f.question, f.answer, f.pack_id, f.rating, f.created_at, f.updated_at, f.last_wrong, f.direction, f.card_type, f.content_format, f.deleted_at, f.deleted_by, f.upstream_card_id, f.upstream_synced_at, f.upstream_hash,
+       u.question AS upstream_question, u.answer AS upstream_answer,
+       u.card_type AS upstream_card_type, u.content_format AS upstream_content_format,
+       u.direction AS upstream_direction, u.updated_at AS upstream_updated_at
+FROM cards f
+JOIN cards u ON u.id = f.upstream_card_id
+WHERE f.pack_id = $1::uuid AND f.deleted_at IS NULL
+  AND u.pack_id = $2::uuid AND u.deleted_at IS NULL
+  AND card_content_hash(u.question, u.answer, u.card_type, u.content_format, u.direction)
+      IS DISTINCT FROM f.upstream_hash
+  AND (u.question, u.answer, u.card_type, u.content_format, u.direction)
+      IS DISTINCT FROM (f.question, f.answer, f.card_type, f.content_format, f.direction)
+ORDER BY f.created_at, f.id
+`
+
+type ListUpstreamChangedParams struct {
+	PackID     pgtype.UUID
+	UpstreamID pgtype.UUID
+}
+
+type ListUpstreamChangedRow struct {
+	ID                    pgtype.UUID
+	Question              string
+	Answer                string
+	PackID                pgtype.UUID
+	Rating                pgtype.Int4
+	CreatedAt             pgtype.Timestamptz
+	UpdatedAt             pgtype.Timestamptz
+	LastWrong             pgtype.Bool
+	Direction             pgtype.Text
+	CardType              string
+	ContentFormat         string
+	DeletedAt             pgtype.Timestamptz
+	DeletedBy             pgtype.UUID
+	UpstreamCardID        pgtype.UUID
+	UpstreamSyncedAt      pgtype.Timestamptz
+	UpstreamHash          pgtype.Text
+	UpstreamQuestion      string
+	UpstreamAnswer        string
+	UpstreamCardType      string
+	UpstreamContentFormat string
+	UpstreamDirection     pgtype.Text
+	UpstreamUpdatedAt     pgtype.Timestamptz
+}
+
+// Copies whose upstream content changed since the last sync and differs
+// from the copy. Local edits alone do not count.
+func (q *Queries) ListUpstreamChanged(ctx context.Context, arg ListUpstreamChangedParams) ([]ListUpstreamChangedRow, error) {
+	rows, err := q.db.Query(ctx, listUpstreamChanged, arg.PackID, arg.UpstreamID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListUpstreamChangedRow
+	for rows.Next() {
+		var i ListUpstreamChangedRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.Question,
+			&i.Answer,
+			&i.PackID,
+			&i.Rating,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.LastWrong,
+			&i.Direction,
+			&i.CardType,
+			&i.ContentFormat,
+			&i.DeletedAt,
+			&i.DeletedBy,
+			&i.UpstreamCardID,
+			&i.UpstreamSyncedAt,
+			&i.UpstreamHash,
+			&i.UpstreamQuestion,
+			&i.UpstreamAnswer,
+			&i.UpstreamCardType,
+			&i.UpstreamContentFormat,
+			&i.UpstreamDirection,
+			&i.UpstreamUpdatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listUpstreamRemoved = `-- name: ListUpstreamRemoved :many
+SELECT f.id, f.question, f.answer, f.pack_id, f.rating, f.created_at, f.updated_at, f.last_wrong, f.direction, f.card_type, f.content_format, f.deleted_at, f.deleted_by, f.upstream_card_id, f.upstream_synced_at, f.upstream_hash
+FROM cards f
+LEFT JOIN cards u ON u.id = f.upstream_card_id
+WHERE f.pack_id = $1::uuid AND f.deleted_at IS NULL
+  AND f.upstream_card_id IS NOT NULL
+  AND (u.id IS NULL OR u.deleted_at IS NOT NULL OR u.pack_id <> $2::uuid)
+ORDER BY f.created_at, f.id
+`
+
+type ListUpstreamRemovedParams struct {
+	PackID     pgtype.UUID
+	UpstreamID pgtype.UUID
+}
+
+// Copies whose upstream card was deleted or moved out of the upstream pack.
+func (q *Queries) ListUpstreamRemoved(ctx context.Context, arg ListUpstreamRemovedParams) ([]Card, error) {
+	rows, err := q.db.Query(ctx, listUpstreamRemoved, arg.PackID, arg.UpstreamID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Card
+	for rows.Next() {
+		var i Card
+		if err := rows.Scan(
+			&i.ID,
+			&i.Question,
+			&i.Answer,
+			&i.PackID,
+			&i.Rating,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.LastWrong,
+			&i.Direction,
+			&i.CardType,
+			&i.ContentFormat,
+			&i.DeletedAt,
+			&i.DeletedBy,
+			&i.UpstreamCardID,
+			&i.UpstreamSyncedAt,
+			&i.UpstreamHash,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const markUpstreamSynced = `-- name: MarkUpstreamSynced :exec
+UPDATE cards f
+SET upstream_synced_at = u.updated_at,
+    upstream_hash = card_content_hash(u.question, u.answer, u.card_type, u.content_format, u.direction)
+FROM cards u
+WHERE u.id = f.upstream_card_id AND f.id = ANY($1::uuid[])
+`
+
+func (q *Queries) MarkUpstreamSynced(ctx context.Context, cardIds []pgtype.UUID) error {
+	_, err := q.db.Exec(ctx, markUpstreamSynced, cardIds)
+	return err
+}
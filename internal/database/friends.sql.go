@@ -0,0 +1,131 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.26.0
+// source: friends.sql
+
+package database
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const acceptFriendRequest = `-- name: AcceptFriendRequest :one
+UPDATE friendships
+SET accepted_at = NOW()
+WHERE user_id = $1
+  AND friend_id = $2
+  AND accepted_at IS NULL
+RETURNING user_id, friend_id, accepted_at, created_at
+`
+
+type AcceptFriendRequestParams struct {
+	RequesterID pgtype.UUID
+	UserID      pgtype.UUID
+}
+
+func (q *Queries) AcceptFriendRequest(ctx context.Context, arg AcceptFriendRequestParams) (Friendship, error) {
+	row := q.db.QueryRow(ctx, acceptFriendRequest, arg.RequesterID, arg.UserID)
+	var i Friendship
+	err := row.Scan(
+		&i.UserID,
+		&i.FriendID,
+		&i.AcceptedAt,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const deleteFriendship = `-- name: DeleteFriendship :execrows
+DELETE FROM friendships
+WHERE (user_id = $1 AND friend_id = $2)
+   OR (user_id = $2 AND friend_id = $1)
+`
+
+type DeleteFriendshipParams struct {
+	UserID  pgtype.UUID
+	OtherID pgtype.UUID
+}
+
+// Unfriends, withdraws a request or declines one.
+func (q *Queries) DeleteFriendship(ctx context.Context, arg DeleteFriendshipParams) (int64, error) {
+	result, err := q.db.Exec(ctx, deleteFriendship, arg.UserID, arg.OtherID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const listFriends = `-- name: ListFriends :many
+SELECT u.id AS user_id, u.username,
+       (CASE WHEN f.accepted_at IS NOT NULL THEN 'friend'
+             WHEN f.friend_id = $1 THEN 'incoming'
+             ELSE 'outgoing' END)::text AS status,
+       f.accepted_at, f.created_at
+FROM friendships f
+JOIN users u ON u.id = CASE WHEN f.user_id = $1 THEN f.friend_id ELSE f.user_id END
+WHERE f.user_id = $1 OR f.friend_id = $1
+ORDER BY f.accepted_at IS NULL, u.username
+`
+
+type ListFriendsRow struct {
+	UserID     pgtype.UUID
+	Username   string
+	Status     string
+	AcceptedAt pgtype.Timestamptz
+	CreatedAt  pgtype.Timestamptz
+}
+
+// Friends first, then requests: "incoming" ones wait for the user to
+// accept, "outgoing" ones for the other side.
+func (q *Queries) ListFriends(ctx context.Context, userID pgtype.UUID) ([]ListFriendsRow, error) {
+	rows, err := q.db.Query(ctx, listFriends, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListFriendsRow
+	for rows.Next() {
+		var i ListFriendsRow
+		if err := rows.Scan(
+			&i.UserID,
+			&i.Username,
+			&i.Status,
+			&i.AcceptedAt,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const requestFriend = `-- name: RequestFriend :one
+INSERT INTO friendships (user_id, friend_id)
+VALUES ($1, $2)
+ON CONFLICT DO NOTHING
+RETURNING user_id, friend_id, accepted_at, created_at
+`
+
+type RequestFriendParams struct {
+	UserID   pgtype.UUID
+	FriendID pgtype.UUID
+}
+
+// No row when the two users are friends or one asked already.
+func (q *Queries) RequestFriend(ctx context.Context, arg RequestFriendParams) (Friendship, error) {
+	row := q.db.QueryRow(ctx, requestFriend, arg.UserID, arg.FriendID)
+	var i Friendship
+	err := row.Scan(
+		&i.UserID,
+		&i.FriendID,
+		&i.AcceptedAt,
+		&i.CreatedAt,
+	)
+	return i, err
+}
@@ -0,0 +1,214 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.26.0
+// source: leaderboards.sql
+
+package database
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const leaderboard = `-- name: Leaderboard :many
+WITH period AS (
+    SELECT e.user_id, SUM(e.delta) AS total
+    FROM rating_events e
+    WHERE e.created_at >= $3::timestamptz
+      AND e.created_at < $4::timestamptz
+    GROUP BY e.user_id
+), scores AS (
+    SELECT u.id AS user_id, u.username,
+           (CASE WHEN $3::timestamptz IS NULL THEN COALESCE(us.rating, 0)
+                 ELSE p.total END)::int AS score
+    FROM users u
+    LEFT JOIN user_stats us ON us.user_id = u.id
+    LEFT JOIN user_settings st ON st.user_id = u.id
+    LEFT JOIN period p ON p.user_id = u.id
+    WHERE NOT COALESCE(st.leaderboard_opt_out, FALSE)
+      AND (CASE WHEN $3::timestamptz IS NULL THEN us.user_id IS NOT NULL
+                ELSE p.user_id IS NOT NULL END)
+      AND ($5::uuid IS NULL OR EXISTS (
+            SELECT 1 FROM organization_members om
+            WHERE om.org_id = $5::uuid AND om.user_id = u.id
+              AND om.joined_at IS NOT NULL))
+      AND ($6::uuid IS NULL OR u.id = $6::uuid OR EXISTS (
+            SELECT 1 FROM friendships f
+            WHERE f.accepted_at IS NOT NULL
+              AND ((f.user_id = $6::uuid AND f.friend_id = u.id)
+                OR (f.friend_id = $6::uuid AND f.user_id = u.id))))
+), ranked AS (
+    SELECT user_id, username, score,
+           RANK() OVER (ORDER BY score DESC)::int AS rank,
+           ROW_NUMBER() OVER (ORDER BY score DESC, username) AS position,
+           COUNT(*) OVER ()::int AS entrants
+    FROM scores
+)
+SELECT user_id, username, score, rank, entrants
+FROM ranked
+WHERE position <= $1::int OR user_id = $2::uuid
+ORDER BY position
+`
+
+type LeaderboardParams struct {
+	PageLimit int32
+	UserID    pgtype.UUID
+	Since     pgtype.Timestamptz
+	Until     pgtype.Timestamptz
+	OrgID     pgtype.UUID
+	FriendsOf pgtype.UUID
+}
+
+type LeaderboardRow struct {
+	UserID   pgtype.UUID
+	Username string
+	Score    int32
+	Rank     int32
+	Entrants int32
+}
+
+// Live ranking by rating points. With @since the score is what was earned
+// from then on, otherwise the rating itself; only users who earned points
+// in the period are ranked. The events of the period are summed in one
+// pass over idx_rating_events_created_at. @org_id limits the board to
+// members of an organization, @friends_of to a user and their friends.
+// Users who opted out are left off. Returns the top @page_limit plus the
+// row of @user_id.
+func (q *Queries) Leaderboard(ctx context.Context, arg LeaderboardParams) ([]LeaderboardRow, error) {
+	rows, err := q.db.Query(ctx, leaderboard,
+		arg.PageLimit,
+		arg.UserID,
+		arg.Since,
+		arg.Until,
+		arg.OrgID,
+		arg.FriendsOf,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []LeaderboardRow
+	for rows.Next() {
+		var i LeaderboardRow
+		if err := rows.Scan(
+			&i.UserID,
+			&i.Username,
+			&i.Score,
+			&i.Rank,
+			&i.Entrants,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const snapshotWeeklyLeaderboard = `-- name: SnapshotWeeklyLeaderboard :execrows
+INSERT INTO leaderboard_snapshots (week_start, user_id, score, rank)
+SELECT $1::date, e.user_id, SUM(e.delta)::int,
+       RANK() OVER (ORDER BY SUM(e.delta) DESC)::int
+FROM rating_events e
+LEFT JOIN user_settings st ON st.user_id = e.user_id
+WHERE e.created_at >= ($1::date)::timestamp AT TIME ZONE 'UTC'
+  AND e.created_at < ($1::date + 7)::timestamp AT TIME ZONE 'UTC'
+  AND NOT COALESCE(st.leaderboard_opt_out, FALSE)
+  AND NOT EXISTS (
+      SELECT 1 FROM leaderboard_snapshots s
+      WHERE s.week_start = $1::date)
+GROUP BY e.user_id
+ON CONFLICT DO NOTHING
+`
+
+// Freezes the global ranking of the week (UTC) starting @week_start. A week
+// is only ever snapshotted once, so its ranks stay put.
+func (q *Queries) SnapshotWeeklyLeaderboard(ctx context.Context, weekStart pgtype.Date) (int64, error) {
+	result, err := q.db.Exec(ctx, snapshotWeeklyLeaderboard, weekStart)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const weeklyLeaderboardSnapshot = `-- name: WeeklyLeaderboardSnapshot :many
+WITH ranked AS (
+    SELECT s.user_id, u.username, s.score,
+           (CASE WHEN $3::uuid IS NULL AND $4::uuid IS NULL THEN s.rank
+                 ELSE RANK() OVER (ORDER BY s.score DESC) END)::int AS rank,
+           ROW_NUMBER() OVER (ORDER BY s.score DESC, u.username) AS position,
+           COUNT(*) OVER ()::int AS entrants
+    FROM leaderboard_snapshots s
+    JOIN users u ON u.id = s.user_id
+    LEFT JOIN user_settings st ON st.user_id = s.user_id
+    WHERE s.week_start = $5::date
+      AND NOT COALESCE(st.leaderboard_opt_out, FALSE)
+      AND ($3::uuid IS NULL OR EXISTS (
+            SELECT 1 FROM organization_members om
+            WHERE om.org_id = $3::uuid AND om.user_id = s.user_id
+              AND om.joined_at IS NOT NULL))
+      AND ($4::uuid IS NULL OR s.user_id = $4::uuid OR EXISTS (
+            SELECT 1 FROM friendships f
+            WHERE f.accepted_at IS NOT NULL
+              AND ((f.user_id = $4::uuid AND f.friend_id = s.user_id)
+                OR (f.friend_id = $4::uuid AND f.user_id = s.user_id))))
+)
+SELECT user_id, username, score, rank, entrants
+FROM ranked
+WHERE position <= $1::int OR user_id = $2::uuid
+ORDER BY position
+`
+
+type WeeklyLeaderboardSnapshotParams struct {
+	PageLimit int32
+	UserID    pgtype.UUID
+	OrgID     pgtype.UUID
+	FriendsOf pgtype.UUID
+	WeekStart pgtype.Date
+}
+
+type WeeklyLeaderboardSnapshotRow struct {
+	UserID   pgtype.UUID
+	Username string
+	Score    int32
+	Rank     int32
+	Entrants int32
+}
+
+// A past week as it was snapshotted. The global board keeps the frozen
+// ranks; organization and friends boards are ranked among their entrants.
+// Users who opt out later disappear from old weeks too.
+func (q *Queries) WeeklyLeaderboardSnapshot(ctx context.Context, arg WeeklyLeaderboardSnapshotParams) ([]WeeklyLeaderboardSnapshotRow, error) {
+	rows, err := q.db.Query(ctx, weeklyLeaderboardSnapshot,
+		arg.PageLimit,
+		arg.UserID,
+		arg.OrgID,
+		arg.FriendsOf,
+		arg.WeekStart,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []WeeklyLeaderboardSnapshotRow
+	for rows.Next() {
+		var i WeeklyLeaderboardSnapshotRow
+		if err := rows.Scan(
+			&i.UserID,
+			&i.Username,
+			&i.Score,
+			&i.Rank,
+			&i.Entrants,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
@@ -0,0 +1,265 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.26.0
+// source: media.sql
+
+package database
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const createMedia = `-- name: CreateMedia :one
+INSERT INTO media (hash, content_type, kind, size_bytes, uploaded_by)
+VALUES ($1, $2, $3, $4, $5)
+ON CONFLICT (hash) DO UPDATE SET created_at = now()
+RETURNING hash, content_type, kind, size_bytes, uploaded_by, created_at
+`
+
+type CreateMediaParams struct {
+	Hash        string
+	ContentType string
+	Kind        string
+	SizeBytes   int64
+	UploadedBy  pgtype.UUID
+}
+
+// Uploading known content returns the existing row and restarts its grace
+// period, so an orphan is not collected right after it was uploaded again.
+func (q *Queries) CreateMedia(ctx context.Context, arg CreateMediaParams) (Medium, error) {
+	row := q.db.QueryRow(ctx, createMedia,
+		arg.Hash,
+		arg.ContentType,
+		arg.Kind,
+		arg.SizeBytes,
+		arg.UploadedBy,
+	)
+	var i Medium
+	err := row.Scan(
+		&i.Hash,
+		&i.ContentType,
+		&i.Kind,
+		&i.SizeBytes,
+		&i.UploadedBy,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const deleteOrphanMedium = `-- name: DeleteOrphanMedium :execrows
+DELETE FROM media m
+WHERE m.hash = $1 AND m.created_at < $2::timestamptz
+  AND NOT EXISTS (SELECT 1 FROM card_media cm WHERE cm.hash = m.hash)
+`
+
+type DeleteOrphanMediumParams struct {
+	Hash   string
+	Before pgtype.Timestamptz
+}
+
+// Deletes @hash if it is still an orphan, see ListOrphanMedia.
+func (q *Queries) DeleteOrphanMedium(ctx context.Context, arg DeleteOrphanMediumParams) (int64, error) {
+	result, err := q.db.Exec(ctx, deleteOrphanMedium, arg.Hash, arg.Before)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const getMedia = `-- name: GetMedia :one
+SELECT hash, content_type, kind, size_bytes, uploaded_by, created_at FROM media WHERE hash = $1
+`
+
+func (q *Queries) GetMedia(ctx context.Context, hash string) (Medium, error) {
+	row := q.db.QueryRow(ctx, getMedia, hash)
+	var i Medium
+	err := row.Scan(
+		&i.Hash,
+		&i.ContentType,
+		&i.Kind,
+		&i.SizeBytes,
+		&i.UploadedBy,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const listCardMedia = `-- name: ListCardMedia :many
+SELECT cm.card_id, m.hash, m.content_type, m.kind, m.size_bytes
+FROM card_media cm
+JOIN media m ON m.hash = cm.hash
+WHERE cm.card_id = ANY($1::uuid[])
+ORDER BY cm.card_id, cm.position
+`
+
+type ListCardMediaRow struct {
+	CardID      pgtype.UUID
+	Hash        string
+	ContentType string
+	Kind        string
+	SizeBytes   int64
+}
+
+func (q *Queries) ListCardMedia(ctx context.Context, cardIds []pgtype.UUID) ([]ListCardMediaRow, error) {
+	rows, err := q.db.Query(ctx, listCardMedia, cardIds)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListCardMediaRow
+	for rows.Next() {
+		var i ListCardMediaRow
+		if err := rows.Scan(
+			&i.CardID,
+			&i.Hash,
+			&i.ContentType,
+			&i.Kind,
+			&i.SizeBytes,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listOrphanMedia = `-- name: ListOrphanMedia :many
+SELECT m.hash FROM media m
+WHERE m.created_at < $1::timestamptz
+  AND NOT EXISTS (SELECT 1 FROM card_media cm WHERE cm.hash = m.hash)
+`
+
+// Media no card refers to. Fresh uploads get a grace period to be attached.
+func (q *Queries) ListOrphanMedia(ctx context.Context, before pgtype.Timestamptz) ([]string, error) {
+	rows, err := q.db.Query(ctx, listOrphanMedia, before)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []string
+	for rows.Next() {
+		var hash string
+		if err := rows.Scan(&hash); err != nil {
+			return nil, err
+		}
+		items = append(items, hash)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listPackMedia = `-- name: ListPackMedia :many
+SELECT DISTINCT m.hash, m.content_type, m.kind, m.size_bytes, m.uploaded_by, m.created_at
+FROM media m
+JOIN card_media cm ON cm.hash = m.hash
+JOIN cards c ON c.id = cm.card_id
+WHERE c.pack_id = $1 AND c.deleted_at IS NULL
+`
+
+func (q *Queries) ListPackMedia(ctx context.Context, packID pgtype.UUID) ([]Medium, error) {
+	rows, err := q.db.Query(ctx, listPackMedia, packID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Medium
+	for rows.Next() {
+		var i Medium
+		if err := rows.Scan(
+			&i.Hash,
+			&i.ContentType,
+			&i.Kind,
+			&i.SizeBytes,
+			&i.UploadedBy,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const lockMedia = `-- name: LockMedia :exec
+SELECT pg_advisory_xact_lock(hashtext($1::text))
+`
+
+// Serializes the upload and the collection of one hash until the end of the
+// transaction, so a blob is not deleted under a row that was just created.
+func (q *Queries) LockMedia(ctx context.Context, hash string) error {
+	_, err := q.db.Exec(ctx, lockMedia, hash)
+	return err
+}
+
+const mediaVisible = `-- name: MediaVisible :one
+SELECT (EXISTS (
+    SELECT 1 FROM media m
+    WHERE m.hash = $1 AND m.uploaded_by = $2::uuid
+) OR EXISTS (
+    SELECT 1 FROM card_media cm
+    JOIN cards c ON c.id = cm.card_id
+    WHERE cm.hash = $1 AND c.deleted_at IS NULL
+      AND pack_visible(c.pack_id, $2::uuid)
+))::bool AS visible
+`
+
+type MediaVisibleParams struct {
+	Hash   string
+	UserID pgtype.UUID
+}
+
+// Media can be seen by the user who uploaded it and by everybody who can
+// see a card it is attached to.
+func (q *Queries) MediaVisible(ctx context.Context, arg MediaVisibleParams) (bool, error) {
+	row := q.db.QueryRow(ctx, mediaVisible, arg.Hash, arg.UserID)
+	var visible bool
+	err := row.Scan(&visible)
+	return visible, err
+}
+
+const replaceCardMedia = `-- name: ReplaceCardMedia :exec
+WITH removed AS (
+    DELETE FROM card_media
+    WHERE card_id = $1 AND hash <> ALL($2::text[])
+)
+INSERT INTO card_media (card_id, hash, position)
+SELECT $1, t.hash, t.ord - 1
+FROM unnest($2::text[]) WITH ORDINALITY AS t(hash, ord)
+ON CONFLICT (card_id, hash) DO UPDATE SET position = EXCLUDED.position
+`
+
+type ReplaceCardMediaParams struct {
+	CardID pgtype.UUID
+	Hashes []string
+}
+
+// Sets the attachments of a card to @hashes, in that order.
+func (q *Queries) ReplaceCardMedia(ctx context.Context, arg ReplaceCardMediaParams) error {
+	_, err := q.db.Exec(ctx, replaceCardMedia, arg.CardID, arg.Hashes)
+	return err
+}
+
+const touchMedia = `-- name: TouchMedia :execrows
+UPDATE media SET created_at = now() WHERE hash = ANY($1::text[])
+`
+
+// Restarts the grace period of media about to be attached and counts the
+// rows that still exist. The row lock makes a concurrent DeleteOrphanMedia
+// either finish first or skip these rows.
+func (q *Queries) TouchMedia(ctx context.Context, hashes []string) (int64, error) {
+	result, err := q.db.Exec(ctx, touchMedia, hashes)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
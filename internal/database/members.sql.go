@@ -0,0 +1,234 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.26.0
+// source: members.sql
+
+package database
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const acceptPackInvitation = `-- name: AcceptPackInvitation :one
+UPDATE pack_members
+SET accepted_at = NOW()
+WHERE pack_id = $1 AND user_id = $2 AND accepted_at IS NULL
+RETURNING pack_id, user_id, role, invited_by, accepted_at, created_at
+`
+
+type AcceptPackInvitationParams struct {
+	PackID pgtype.UUID
+	UserID pgtype.UUID
+}
+
+func (q *Queries) AcceptPackInvitation(ctx context.Context, arg AcceptPackInvitationParams) (PackMember, error) {
+	row := q.db.QueryRow(ctx, acceptPackInvitation, arg.PackID, arg.UserID)
+	var i PackMember
+	err := row.Scan(
+		&i.PackID,
+		&i.UserID,
+		&i.Role,
+		&i.InvitedBy,
+		&i.AcceptedAt,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const invitePackMember = `-- name: InvitePackMember :one
+INSERT INTO pack_members (pack_id, user_id, role, invited_by)
+VALUES ($1, $2, $3, $4)
+ON CONFLICT (pack_id, user_id) DO NOTHING
+RETURNING pack_id, user_id, role, invited_by, accepted_at, created_at
+`
+
+type InvitePackMemberParams struct {
+	PackID    pgtype.UUID
+	UserID    pgtype.UUID
+	Role      string
+	InvitedBy pgtype.UUID
+}
+
+// No row when the user is already a member or invited.
+func (q *Queries) InvitePackMember(ctx context.Context, arg InvitePackMemberParams) (PackMember, error) {
+	row := q.db.QueryRow(ctx, invitePackMember,
+		arg.PackID,
+		arg.UserID,
+		arg.Role,
+		arg.InvitedBy,
+	)
+	var i PackMember
+	err := row.Scan(
+		&i.PackID,
+		&i.UserID,
+		&i.Role,
+		&i.InvitedBy,
+		&i.AcceptedAt,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const listInvitations = `-- name: ListInvitations :many
+SELECT m.pack_id, p.name AS pack_name, m.role, m.invited_by,
+       u.username AS invited_by_username, m.created_at
+FROM pack_members m
+JOIN packs p ON p.id = m.pack_id
+LEFT JOIN users u ON u.id = m.invited_by
+WHERE m.user_id = $1 AND m.accepted_at IS NULL AND p.deleted_at IS NULL
+ORDER BY m.created_at DESC
+`
+
+type ListInvitationsRow struct {
+	PackID            pgtype.UUID
+	PackName          string
+	Role              string
+	InvitedBy         pgtype.UUID
+	InvitedByUsername pgtype.Text
+	CreatedAt         pgtype.Timestamptz
+}
+
+func (q *Queries) ListInvitations(ctx context.Context, userID pgtype.UUID) ([]ListInvitationsRow, error) {
+	rows, err := q.db.Query(ctx, listInvitations, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListInvitationsRow
+	for rows.Next() {
+		var i ListInvitationsRow
+		if err := rows.Scan(
+			&i.PackID,
+			&i.PackName,
+			&i.Role,
+			&i.InvitedBy,
+			&i.InvitedByUsername,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listPackMembers = `-- name: ListPackMembers :many
+SELECT user_id, username, role, invited_by, invited_at, accepted_at FROM (
+    SELECT u.id AS user_id, u.username, 'owner'::text AS role,
+           NULL::uuid AS invited_by, p.created_at AS invited_at,
+           p.created_at AS accepted_at
+    FROM packs p
+    JOIN users u ON u.id = p.owner_id
+    WHERE p.id = $1
+    UNION ALL
+    SELECT m.user_id, u.username, m.role::text,
+           m.invited_by, m.created_at, m.accepted_at
+    FROM pack_members m
+    JOIN users u ON u.id = m.user_id
+    WHERE m.pack_id = $1
+) members
+ORDER BY accepted_at ASC NULLS LAST, username
+`
+
+type ListPackMembersRow struct {
+	UserID     pgtype.UUID
+	Username   string
+	Role       string
+	InvitedBy  pgtype.UUID
+	InvitedAt  pgtype.Timestamptz
+	AcceptedAt pgtype.Timestamptz
+}
+
+// The owner of the pack first, then accepted members, then invitations.
+func (q *Queries) ListPackMembers(ctx context.Context, packID pgtype.UUID) ([]ListPackMembersRow, error) {
+	rows, err := q.db.Query(ctx, listPackMembers, packID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListPackMembersRow
+	for rows.Next() {
+		var i ListPackMembersRow
+		if err := rows.Scan(
+			&i.UserID,
+			&i.Username,
+			&i.Role,
+			&i.InvitedBy,
+			&i.InvitedAt,
+			&i.AcceptedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const packRole = `-- name: PackRole :one
+SELECT COALESCE(pack_role($1::uuid, $2::uuid), '')::text AS role
+`
+
+type PackRoleParams struct {
+	PackID pgtype.UUID
+	UserID pgtype.UUID
+}
+
+// Empty when the user has no role in the pack.
+func (q *Queries) PackRole(ctx context.Context, arg PackRoleParams) (string, error) {
+	row := q.db.QueryRow(ctx, packRole, arg.PackID, arg.UserID)
+	var role string
+	err := row.Scan(&role)
+	return role, err
+}
+
+const removePackMember = `-- name: RemovePackMember :execrows
+DELETE FROM pack_members WHERE pack_id = $1 AND user_id = $2
+`
+
+type RemovePackMemberParams struct {
+	PackID pgtype.UUID
+	UserID pgtype.UUID
+}
+
+func (q *Queries) RemovePackMember(ctx context.Context, arg RemovePackMemberParams) (int64, error) {
+	result, err := q.db.Exec(ctx, removePackMember, arg.PackID, arg.UserID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const setPackMemberRole = `-- name: SetPackMemberRole :one
+UPDATE pack_members
+SET role = $1
+WHERE pack_id = $2 AND user_id = $3
+RETURNING pack_id, user_id, role, invited_by, accepted_at, created_at
+`
+
+type SetPackMemberRoleParams struct {
+	Role   string
+	PackID pgtype.UUID
+	UserID pgtype.UUID
+}
+
+func (q *Queries) SetPackMemberRole(ctx context.Context, arg SetPackMemberRoleParams) (PackMember, error) {
+	row := q.db.QueryRow(ctx, setPackMemberRole, arg.Role, arg.PackID, arg.UserID)
+	var i PackMember
+	err := row.Scan(
+		&i.PackID,
+		&i.UserID,
+		&i.Role,
+		&i.InvitedBy,
+		&i.AcceptedAt,
+		&i.CreatedAt,
+	)
+	return i, err
+}
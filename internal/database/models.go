@@ -9,14 +9,84 @@ import (
 )
 
 type Card struct {
+	ID               pgtype.UUID
+	Question         string
+	Answer           string
+	PackID           pgtype.UUID
+	Rating           pgtype.Int4
+	CreatedAt        pgtype.Timestamptz
+	UpdatedAt        pgtype.Timestamptz
+	LastWrong        pgtype.Bool
+	Direction        pgtype.Text
+	CardType         string
+	ContentFormat    string
+	DeletedAt        pgtype.Timestamptz
+	DeletedBy        pgtype.UUID
+	UpstreamCardID   pgtype.UUID
+	UpstreamSyncedAt pgtype.Timestamptz
+	UpstreamHash     pgtype.Text
+}
+
+type CardMedium struct {
+	CardID   pgtype.UUID
+	Hash     string
+	Position int16
+}
+
+type CardProgress struct {
+	UserID         pgtype.UUID
+	CardID         pgtype.UUID
+	Reps           int32
+	Lapses         int32
+	IntervalDays   int32
+	Ease           float64
+	DueAt          pgtype.Timestamptz
+	LastReviewedAt pgtype.Timestamptz
+	CreatedAt      pgtype.Timestamptz
+	UpdatedAt      pgtype.Timestamptz
+	Direction      string
+}
+
+type CardRevision struct {
 	ID        pgtype.UUID
-	Question  string
-	Answer    string
+	CardID    pgtype.UUID
 	PackID    pgtype.UUID
-	Rating    pgtype.Int4
+	Action    string
+	Before    []byte
+	After     []byte
+	ChangedBy pgtype.UUID
+	CreatedAt pgtype.Timestamptz
+}
+
+type CardTag struct {
+	CardID    pgtype.UUID
+	TagID     pgtype.UUID
+	CreatedAt pgtype.Timestamptz
+}
+
+type Category struct {
+	ID        pgtype.UUID
+	Name      string
+	Slug      string
+	ParentID  pgtype.UUID
+	OwnerID   pgtype.UUID
+	CreatedBy pgtype.UUID
 	CreatedAt pgtype.Timestamptz
 	UpdatedAt pgtype.Timestamptz
-	LastWrong pgtype.Bool
+}
+
+type Friendship struct {
+	UserID     pgtype.UUID
+	FriendID   pgtype.UUID
+	AcceptedAt pgtype.Timestamptz
+	CreatedAt  pgtype.Timestamptz
+}
+
+type LeaderboardSnapshot struct {
+	WeekStart pgtype.Date
+	UserID    pgtype.UUID
+	Score     int32
+	Rank      int32
 }
 
 type Log struct {
@@ -31,14 +101,155 @@ type Log struct {
 	UpdatedAt      pgtype.Timestamptz
 }
 
-type Pack struct {
+type Medium struct {
+	Hash        string
+	ContentType string
+	Kind        string
+	SizeBytes   int64
+	UploadedBy  pgtype.UUID
+	CreatedAt   pgtype.Timestamptz
+}
+
+type Organization struct {
 	ID        pgtype.UUID
 	Name      string
-	Category  pgtype.Text
+	CreatedBy pgtype.UUID
 	CreatedAt pgtype.Timestamptz
 	UpdatedAt pgtype.Timestamptz
 }
 
+type OrganizationMember struct {
+	OrgID     pgtype.UUID
+	UserID    pgtype.UUID
+	Role      string
+	JoinedAt  pgtype.Timestamptz
+	InvitedBy pgtype.UUID
+	CreatedAt pgtype.Timestamptz
+}
+
+type Pack struct {
+	ID          pgtype.UUID
+	Name        string
+	CreatedAt   pgtype.Timestamptz
+	UpdatedAt   pgtype.Timestamptz
+	OwnerID     pgtype.UUID
+	CategoryID  pgtype.UUID
+	Direction   string
+	DeletedAt   pgtype.Timestamptz
+	DeletedBy   pgtype.UUID
+	ForkedFrom  pgtype.UUID
+	Description string
+	SourceLang  pgtype.Text
+	TargetLang  pgtype.Text
+	CoverColor  pgtype.Text
+	CoverIcon   pgtype.Text
+	Visibility  string
+	OrgID       pgtype.UUID
+}
+
+type PackAssignment struct {
+	ID         pgtype.UUID
+	OrgID      pgtype.UUID
+	PackID     pgtype.UUID
+	UserID     pgtype.UUID
+	AssignedBy pgtype.UUID
+	DueAt      pgtype.Timestamptz
+	CreatedAt  pgtype.Timestamptz
+}
+
+type PackMastery struct {
+	UserID     pgtype.UUID
+	PackID     pgtype.UUID
+	MasteredAt pgtype.Timestamptz
+	CardCount  int32
+}
+
+type PackMember struct {
+	PackID     pgtype.UUID
+	UserID     pgtype.UUID
+	Role       string
+	InvitedBy  pgtype.UUID
+	AcceptedAt pgtype.Timestamptz
+	CreatedAt  pgtype.Timestamptz
+}
+
+type PackRating struct {
+	PackID    pgtype.UUID
+	UserID    pgtype.UUID
+	Stars     int16
+	Review    string
+	CreatedAt pgtype.Timestamptz
+	UpdatedAt pgtype.Timestamptz
+}
+
+type PackShareLink struct {
+	ID        pgtype.UUID
+	PackID    pgtype.UUID
+	CreatedBy pgtype.UUID
+	ExpiresAt pgtype.Timestamptz
+	MaxUses   pgtype.Int4
+	UseCount  int32
+	RevokedAt pgtype.Timestamptz
+	CreatedAt pgtype.Timestamptz
+}
+
+type RatingEvent struct {
+	ID        int64
+	UserID    pgtype.UUID
+	Delta     int32
+	CreatedAt pgtype.Timestamptz
+}
+
+type Review struct {
+	ID           pgtype.UUID
+	UserID       pgtype.UUID
+	CardID       pgtype.UUID
+	PackID       pgtype.UUID
+	Correct      bool
+	NewCard      bool
+	IntervalDays int32
+	Ease         float64
+	DurationMs   pgtype.Int4
+	ReviewedAt   pgtype.Timestamptz
+	Direction    string
+}
+
+type StreakDay struct {
+	UserID   pgtype.UUID
+	Day      pgtype.Date
+	GoalType string
+	Goal     int32
+	Met      bool
+	Frozen   bool
+}
+
+type StudySession struct {
+	ID           pgtype.UUID
+	UserID       pgtype.UUID
+	PackID       pgtype.UUID
+	Source       string
+	Tags         []string
+	Status       string
+	Queue        []string
+	TotalCards   int32
+	CorrectCount int32
+	WrongCount   int32
+	ExpiresAt    pgtype.Timestamptz
+	CompletedAt  pgtype.Timestamptz
+	CreatedAt    pgtype.Timestamptz
+	UpdatedAt    pgtype.Timestamptz
+}
+
+type StudySessionAnswer struct {
+	ID         pgtype.UUID
+	SessionID  pgtype.UUID
+	CardID     pgtype.UUID
+	Correct    bool
+	DurationMs pgtype.Int4
+	AnsweredAt pgtype.Timestamptz
+	Direction  string
+}
+
 type Subscription struct {
 	ID        pgtype.UUID
 	UserID    pgtype.UUID
@@ -47,6 +258,14 @@ type Subscription struct {
 	UpdatedAt pgtype.Timestamptz
 }
 
+type Tag struct {
+	ID        pgtype.UUID
+	OwnerID   pgtype.UUID
+	Name      string
+	CreatedAt pgtype.Timestamptz
+	UpdatedAt pgtype.Timestamptz
+}
+
 type User struct {
 	ID           pgtype.UUID
 	Username     string
@@ -55,9 +274,27 @@ type User struct {
 	UpdatedAt    pgtype.Timestamptz
 }
 
+type UserAchievement struct {
+	UserID      pgtype.UUID
+	Achievement string
+	AwardedAt   pgtype.Timestamptz
+}
+
+type UserSetting struct {
+	UserID               pgtype.UUID
+	NewCardsPerDay       int32
+	ReviewsPerDay        int32
+	CreatedAt            pgtype.Timestamptz
+	UpdatedAt            pgtype.Timestamptz
+	Timezone             string
+	DayRolloverHour      int32
+	GoalType             string
+	DailyGoal            int32
+	StreakFreezesPerWeek int32
+	LeaderboardOptOut    bool
+}
+
 type UserStat struct {
-	UserID        pgtype.UUID
-	Rating        pgtype.Int4
-	PacksCreated  pgtype.Int4
-	PacksMastered pgtype.Int4
+	UserID pgtype.UUID
+	Rating pgtype.Int4
 }
@@ -0,0 +1,584 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.26.0
+// source: orgs.sql
+
+package database
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const acceptOrgInvitation = `-- name: AcceptOrgInvitation :one
+UPDATE organization_members
+SET joined_at = NOW()
+WHERE org_id = $1 AND user_id = $2 AND joined_at IS NULL
+RETURNING org_id, user_id, role, joined_at, invited_by, created_at
+`
+
+type AcceptOrgInvitationParams struct {
+	OrgID  pgtype.UUID
+	UserID pgtype.UUID
+}
+
+func (q *Queries) AcceptOrgInvitation(ctx context.Context, arg AcceptOrgInvitationParams) (OrganizationMember, error) {
+	row := q.db.QueryRow(ctx, acceptOrgInvitation, arg.OrgID, arg.UserID)
+	var i OrganizationMember
+	err := row.Scan(
+		&i.OrgID,
+		&i.UserID,
+		&i.Role,
+		&i.JoinedAt,
+		&i.InvitedBy,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const assignPack = `-- name: AssignPack :many
+WITH assigned AS (
+    INSERT INTO pack_assignments (org_id, pack_id, user_id, assigned_by, due_at)
+    SELECT om.org_id, $1::uuid, om.user_id, $2::uuid, $3::timestamptz
+    FROM organization_members om
+    WHERE om.org_id = $4::uuid AND om.joined_at IS NOT NULL
+      AND ($5::uuid[] IS NULL OR om.user_id = ANY($5::uuid[]))
+    ON CONFLICT (org_id, pack_id, user_id) DO UPDATE
+    SET due_at = EXCLUDED.due_at, assigned_by = EXCLUDED.assigned_by
+    RETURNING id, org_id, pack_id, user_id, assigned_by, due_at, created_at
+), subscribed AS (
+    INSERT INTO subscriptions (user_id, pack_id)
+    SELECT assigned.user_id, assigned.pack_id FROM assigned
+    ON CONFLICT (user_id, pack_id) DO NOTHING
+)
+SELECT id, org_id, pack_id, user_id, assigned_by, due_at, created_at FROM assigned
+`
+
+type AssignPackParams struct {
+	PackID     pgtype.UUID
+	AssignedBy pgtype.UUID
+	DueAt      pgtype.Timestamptz
+	OrgID      pgtype.UUID
+	UserIds    []pgtype.UUID
+}
+
+type AssignPackRow struct {
+	ID         pgtype.UUID
+	OrgID      pgtype.UUID
+	PackID     pgtype.UUID
+	UserID     pgtype.UUID
+	AssignedBy pgtype.UUID
+	DueAt      pgtype.Timestamptz
+	CreatedAt  pgtype.Timestamptz
+}
+
+// Assigns a pack to the given members, or to all of them when user_ids is
+// NULL, and subscribes them to it. Assigning again moves the due date of
+// this organization's assignment only.
+func (q *Queries) AssignPack(ctx context.Context, arg AssignPackParams) ([]AssignPackRow, error) {
+	rows, err := q.db.Query(ctx, assignPack,
+		arg.PackID,
+		arg.AssignedBy,
+		arg.DueAt,
+		arg.OrgID,
+		arg.UserIds,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []AssignPackRow
+	for rows.Next() {
+		var i AssignPackRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.OrgID,
+			&i.PackID,
+			&i.UserID,
+			&i.AssignedBy,
+			&i.DueAt,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const createOrganization = `-- name: CreateOrganization :one
+WITH org AS (
+    INSERT INTO organizations (name, created_by)
+    VALUES ($1, $2::uuid)
+    RETURNING id, name, created_by, created_at, updated_at
+), admin AS (
+    INSERT INTO organization_members (org_id, user_id, role)
+    SELECT org.id, org.created_by, 'admin' FROM org
+)
+SELECT id, name, created_by, created_at, updated_at FROM org
+`
+
+type CreateOrganizationParams struct {
+	Name      string
+	CreatedBy pgtype.UUID
+}
+
+type CreateOrganizationRow struct {
+	ID        pgtype.UUID
+	Name      string
+	CreatedBy pgtype.UUID
+	CreatedAt pgtype.Timestamptz
+	UpdatedAt pgtype.Timestamptz
+}
+
+// The creator becomes the first admin.
+func (q *Queries) CreateOrganization(ctx context.Context, arg CreateOrganizationParams) (CreateOrganizationRow, error) {
+	row := q.db.QueryRow(ctx, createOrganization, arg.Name, arg.CreatedBy)
+	var i CreateOrganizationRow
+	err := row.Scan(
+		&i.ID,
+		&i.Name,
+		&i.CreatedBy,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+	)
+	return i, err
+}
+
+const deleteAssignment = `-- name: DeleteAssignment :execrows
+DELETE FROM pack_assignments WHERE id = $1 AND org_id = $2
+`
+
+type DeleteAssignmentParams struct {
+	ID    pgtype.UUID
+	OrgID pgtype.UUID
+}
+
+func (q *Queries) DeleteAssignment(ctx context.Context, arg DeleteAssignmentParams) (int64, error) {
+	result, err := q.db.Exec(ctx, deleteAssignment, arg.ID, arg.OrgID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const inviteOrgMember = `-- name: InviteOrgMember :one
+INSERT INTO organization_members (org_id, user_id, role, invited_by, joined_at)
+VALUES ($1, $2, $3, $4, NULL)
+ON CONFLICT (org_id, user_id) DO NOTHING
+RETURNING org_id, user_id, role, joined_at, invited_by, created_at
+`
+
+type InviteOrgMemberParams struct {
+	OrgID     pgtype.UUID
+	UserID    pgtype.UUID
+	Role      string
+	InvitedBy pgtype.UUID
+}
+
+// No row when the user is a member or invited already.
+func (q *Queries) InviteOrgMember(ctx context.Context, arg InviteOrgMemberParams) (OrganizationMember, error) {
+	row := q.db.QueryRow(ctx, inviteOrgMember,
+		arg.OrgID,
+		arg.UserID,
+		arg.Role,
+		arg.InvitedBy,
+	)
+	var i OrganizationMember
+	err := row.Scan(
+		&i.OrgID,
+		&i.UserID,
+		&i.Role,
+		&i.JoinedAt,
+		&i.InvitedBy,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const listAssignmentProgress = `-- name: ListAssignmentProgress :many
+SELECT a.id, a.org_id, o.name AS org_name, a.pack_id, p.name AS pack_name,
+       a.user_id, u.username, a.due_at, a.created_at,
+       cc.card_count::bigint AS card_count,
+       st.studied_count::bigint AS studied_count,
+       st.last_studied_at::timestamptz AS last_studied_at
+FROM pack_assignments a
+JOIN organizations o ON o.id = a.org_id
+JOIN organization_members om ON om.org_id = a.org_id AND om.user_id = a.user_id AND om.joined_at IS NOT NULL
+JOIN packs p ON p.id = a.pack_id AND p.deleted_at IS NULL
+JOIN users u ON u.id = a.user_id
+CROSS JOIN LATERAL (
+    SELECT COUNT(*) AS card_count
+    FROM cards c
+    WHERE c.pack_id = a.pack_id AND c.deleted_at IS NULL
+) cc
+CROSS JOIN LATERAL (
+    SELECT COUNT(DISTINCT cp.card_id) AS studied_count,
+           MAX(cp.last_reviewed_at) AS last_studied_at
+    FROM card_progress cp
+    JOIN cards c ON c.id = cp.card_id
+    WHERE cp.user_id = a.user_id AND c.pack_id = a.pack_id AND c.deleted_at IS NULL
+) st
+WHERE ($1::uuid IS NULL OR a.org_id = $1::uuid)
+  AND ($2::uuid IS NULL OR a.user_id = $2::uuid)
+ORDER BY u.username, a.due_at ASC NULLS LAST, p.name
+`
+
+type ListAssignmentProgressParams struct {
+	OrgID  pgtype.UUID
+	UserID pgtype.UUID
+}
+
+type ListAssignmentProgressRow struct {
+	ID            pgtype.UUID
+	OrgID         pgtype.UUID
+	OrgName       string
+	PackID        pgtype.UUID
+	PackName      string
+	UserID        pgtype.UUID
+	Username      string
+	DueAt         pgtype.Timestamptz
+	CreatedAt     pgtype.Timestamptz
+	CardCount     int64
+	StudiedCount  int64
+	LastStudiedAt pgtype.Timestamptz
+}
+
+// Assignments of current members with how many of the cards of the pack
+// the member has studied. Filtered by organization, member or both.
+func (q *Queries) ListAssignmentProgress(ctx context.Context, arg ListAssignmentProgressParams) ([]ListAssignmentProgressRow, error) {
+	rows, err := q.db.Query(ctx, listAssignmentProgress, arg.OrgID, arg.UserID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListAssignmentProgressRow
+	for rows.Next() {
+		var i ListAssignmentProgressRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.OrgID,
+			&i.OrgName,
+			&i.PackID,
+			&i.PackName,
+			&i.UserID,
+			&i.Username,
+			&i.DueAt,
+			&i.CreatedAt,
+			&i.CardCount,
+			&i.StudiedCount,
+			&i.LastStudiedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listOrgInvitations = `-- name: ListOrgInvitations :many
+SELECT om.org_id, o.name AS org_name, om.role, om.invited_by,
+       u.username AS invited_by_username, om.created_at
+FROM organization_members om
+JOIN organizations o ON o.id = om.org_id
+LEFT JOIN users u ON u.id = om.invited_by
+WHERE om.user_id = $1 AND om.joined_at IS NULL
+ORDER BY om.created_at DESC
+`
+
+type ListOrgInvitationsRow struct {
+	OrgID             pgtype.UUID
+	OrgName           string
+	Role              string
+	InvitedBy         pgtype.UUID
+	InvitedByUsername pgtype.Text
+	CreatedAt         pgtype.Timestamptz
+}
+
+func (q *Queries) ListOrgInvitations(ctx context.Context, userID pgtype.UUID) ([]ListOrgInvitationsRow, error) {
+	rows, err := q.db.Query(ctx, listOrgInvitations, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListOrgInvitationsRow
+	for rows.Next() {
+		var i ListOrgInvitationsRow
+		if err := rows.Scan(
+			&i.OrgID,
+			&i.OrgName,
+			&i.Role,
+			&i.InvitedBy,
+			&i.InvitedByUsername,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listOrgMembers = `-- name: ListOrgMembers :many
+SELECT om.user_id, u.username, om.role, om.joined_at,
+       COALESCE(us.rating, 0)::int AS rating,
+       (SELECT COUNT(*) FROM packs p WHERE p.owner_id = om.user_id AND p.deleted_at IS NULL)::int AS packs_created,
+       (SELECT COUNT(*) FROM pack_masteries pm WHERE pm.user_id = om.user_id)::int AS packs_mastered
+FROM organization_members om
+JOIN users u ON u.id = om.user_id
+LEFT JOIN user_stats us ON us.user_id = om.user_id
+WHERE om.org_id = $1 AND om.joined_at IS NOT NULL
+ORDER BY om.role = 'member', u.username
+`
+
+type ListOrgMembersRow struct {
+	UserID        pgtype.UUID
+	Username      string
+	Role          string
+	JoinedAt      pgtype.Timestamptz
+	Rating        int32
+	PacksCreated  int32
+	PacksMastered int32
+}
+
+// Members who joined, with their rating and the packs they own and mastered.
+func (q *Queries) ListOrgMembers(ctx context.Context, orgID pgtype.UUID) ([]ListOrgMembersRow, error) {
+	rows, err := q.db.Query(ctx, listOrgMembers, orgID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListOrgMembersRow
+	for rows.Next() {
+		var i ListOrgMembersRow
+		if err := rows.Scan(
+			&i.UserID,
+			&i.Username,
+			&i.Role,
+			&i.JoinedAt,
+			&i.Rating,
+			&i.PacksCreated,
+			&i.PacksMastered,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listPendingOrgMembers = `-- name: ListPendingOrgMembers :many
+SELECT om.user_id, u.username, om.role, om.created_at
+FROM organization_members om
+JOIN users u ON u.id = om.user_id
+WHERE om.org_id = $1 AND om.joined_at IS NULL
+ORDER BY u.username
+`
+
+type ListPendingOrgMembersRow struct {
+	UserID    pgtype.UUID
+	Username  string
+	Role      string
+	CreatedAt pgtype.Timestamptz
+}
+
+// Invitations not accepted yet, for admins.
+func (q *Queries) ListPendingOrgMembers(ctx context.Context, orgID pgtype.UUID) ([]ListPendingOrgMembersRow, error) {
+	rows, err := q.db.Query(ctx, listPendingOrgMembers, orgID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListPendingOrgMembersRow
+	for rows.Next() {
+		var i ListPendingOrgMembersRow
+		if err := rows.Scan(
+			&i.UserID,
+			&i.Username,
+			&i.Role,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listUserOrganizations = `-- name: ListUserOrganizations :many
+SELECT o.id, o.name, om.role, om.joined_at,
+       (SELECT COUNT(*) FROM organization_members m
+        WHERE m.org_id = o.id AND m.joined_at IS NOT NULL)::bigint AS member_count
+FROM organizations o
+JOIN organization_members om ON om.org_id = o.id
+WHERE om.user_id = $1 AND om.joined_at IS NOT NULL
+ORDER BY o.name
+`
+
+type ListUserOrganizationsRow struct {
+	ID          pgtype.UUID
+	Name        string
+	Role        string
+	JoinedAt    pgtype.Timestamptz
+	MemberCount int64
+}
+
+func (q *Queries) ListUserOrganizations(ctx context.Context, userID pgtype.UUID) ([]ListUserOrganizationsRow, error) {
+	rows, err := q.db.Query(ctx, listUserOrganizations, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListUserOrganizationsRow
+	for rows.Next() {
+		var i ListUserOrganizationsRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.Name,
+			&i.Role,
+			&i.JoinedAt,
+			&i.MemberCount,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const orgRole = `-- name: OrgRole :one
+SELECT COALESCE((
+    SELECT role FROM organization_members
+    WHERE org_id = $1 AND user_id = $2 AND joined_at IS NOT NULL
+), '')::text AS role
+`
+
+type OrgRoleParams struct {
+	OrgID  pgtype.UUID
+	UserID pgtype.UUID
+}
+
+// Empty when the user is not a member of the organization, or has not
+// accepted the invitation yet.
+func (q *Queries) OrgRole(ctx context.Context, arg OrgRoleParams) (string, error) {
+	row := q.db.QueryRow(ctx, orgRole, arg.OrgID, arg.UserID)
+	var role string
+	err := row.Scan(&role)
+	return role, err
+}
+
+const readOrganization = `-- name: ReadOrganization :one
+SELECT id, name, created_by, created_at, updated_at FROM organizations WHERE id = $1
+`
+
+func (q *Queries) ReadOrganization(ctx context.Context, id pgtype.UUID) (Organization, error) {
+	row := q.db.QueryRow(ctx, readOrganization, id)
+	var i Organization
+	err := row.Scan(
+		&i.ID,
+		&i.Name,
+		&i.CreatedBy,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+	)
+	return i, err
+}
+
+const removeOrgMember = `-- name: RemoveOrgMember :execrows
+DELETE FROM organization_members m
+WHERE m.org_id = $1 AND m.user_id = $2
+  AND (m.role <> 'admin' OR EXISTS (
+        SELECT 1 FROM organization_members o
+        WHERE o.org_id = $1 AND o.user_id <> $2 AND o.role = 'admin'
+          AND o.joined_at IS NOT NULL
+      ))
+`
+
+type RemoveOrgMemberParams struct {
+	OrgID  pgtype.UUID
+	UserID pgtype.UUID
+}
+
+// Like SetOrgMemberRole, the last admin cannot be removed. Also withdraws
+// or declines an invitation.
+func (q *Queries) RemoveOrgMember(ctx context.Context, arg RemoveOrgMemberParams) (int64, error) {
+	result, err := q.db.Exec(ctx, removeOrgMember, arg.OrgID, arg.UserID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const renameOrganization = `-- name: RenameOrganization :one
+UPDATE organizations SET name = $1 WHERE id = $2 RETURNING id, name, created_by, created_at, updated_at
+`
+
+type RenameOrganizationParams struct {
+	Name string
+	ID   pgtype.UUID
+}
+
+func (q *Queries) RenameOrganization(ctx context.Context, arg RenameOrganizationParams) (Organization, error) {
+	row := q.db.QueryRow(ctx, renameOrganization, arg.Name, arg.ID)
+	var i Organization
+	err := row.Scan(
+		&i.ID,
+		&i.Name,
+		&i.CreatedBy,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+	)
+	return i, err
+}
+
+const setOrgMemberRole = `-- name: SetOrgMemberRole :one
+UPDATE organization_members m
+SET role = $1
+WHERE m.org_id = $2 AND m.user_id = $3
+  AND ($1::text = 'admin' OR m.role <> 'admin' OR EXISTS (
+        SELECT 1 FROM organization_members o
+        WHERE o.org_id = $2 AND o.user_id <> $3 AND o.role = 'admin'
+          AND o.joined_at IS NOT NULL
+      ))
+RETURNING org_id, user_id, role, joined_at, invited_by, created_at
+`
+
+type SetOrgMemberRoleParams struct {
+	Role   string
+	OrgID  pgtype.UUID
+	UserID pgtype.UUID
+}
+
+// An organization always keeps one admin: demoting the last one finds no row.
+func (q *Queries) SetOrgMemberRole(ctx context.Context, arg SetOrgMemberRoleParams) (OrganizationMember, error) {
+	row := q.db.QueryRow(ctx, setOrgMemberRole, arg.Role, arg.OrgID, arg.UserID)
+	var i OrganizationMember
+	err := row.Scan(
+		&i.OrgID,
+		&i.UserID,
+		&i.Role,
+		&i.JoinedAt,
+		&i.InvitedBy,
+		&i.CreatedAt,
+	)
+	return i, err
+}
@@ -12,59 +12,215 @@ import (
 )
 
 const createPack = `-- name: CreatePack :one
-INSERT INTO packs (name, category)
-VALUES ($1, $2)
-RETURNING id, name, category, created_at, updated_at
+INSERT INTO packs (name, category_id, owner_id, org_id, direction, description,
+                   source_lang, target_lang, cover_color, cover_icon, visibility)
+VALUES ($1, $2, $3, $4, $5, $6,
+        $7, $8, $9, $10, $11)
+RETURNING id, name, created_at, updated_at, owner_id, category_id, direction, deleted_at, deleted_by, forked_from, description, source_lang, target_lang, cover_color, cover_icon, visibility, org_id
 `
 
 type CreatePackParams struct {
-	Name     string
-	Category pgtype.Text
+	Name        string
+	CategoryID  pgtype.UUID
+	OwnerID     pgtype.UUID
+	OrgID       pgtype.UUID
+	Direction   string
+	Description string
+	SourceLang  pgtype.Text
+	TargetLang  pgtype.Text
+	CoverColor  pgtype.Text
+	CoverIcon   pgtype.Text
+	Visibility  string
 }
 
+// A pack has either owner_id or org_id.
 func (q *Queries) CreatePack(ctx context.Context, arg CreatePackParams) (Pack, error) {
-	row := q.db.QueryRow(ctx, createPack, arg.Name, arg.Category)
+	row := q.db.QueryRow(ctx, createPack,
+		arg.Name,
+		arg.CategoryID,
+		arg.OwnerID,
+		arg.OrgID,
+		arg.Direction,
+		arg.Description,
+		arg.SourceLang,
+		arg.TargetLang,
+		arg.CoverColor,
+		arg.CoverIcon,
+		arg.Visibility,
+	)
 	var i Pack
 	err := row.Scan(
 		&i.ID,
 		&i.Name,
-		&i.Category,
 		&i.CreatedAt,
 		&i.UpdatedAt,
+		&i.OwnerID,
+		&i.CategoryID,
+		&i.Direction,
+		&i.DeletedAt,
+		&i.DeletedBy,
+		&i.ForkedFrom,
+		&i.Description,
+		&i.SourceLang,
+		&i.TargetLang,
+		&i.CoverColor,
+		&i.CoverIcon,
+		&i.Visibility,
+		&i.OrgID,
 	)
 	return i, err
 }
 
-const deletePack = `-- name: DeletePack :exec
-DELETE FROM packs WHERE id = $1
+const listPacks = `-- name: ListPacks :many
+WITH RECURSIVE category_tree AS (
+    SELECT categories.id FROM categories WHERE categories.id = $5::uuid
+    UNION ALL
+    SELECT ch.id FROM categories ch JOIN category_tree t ON ch.parent_id = t.id
+)
+SELECT p.id, p.name, cat.name AS category, p.category_id, p.owner_id,
+       p.description, p.source_lang, p.target_lang, p.cover_color, p.cover_icon,
+       p.visibility, p.forked_from, p.org_id,
+       p.created_at, p.updated_at, d.due_count::bigint AS due_count,
+       COALESCE(pack_role(p.id, $1::uuid), '')::text AS role
+FROM packs p
+LEFT JOIN categories cat ON cat.id = p.category_id
+CROSS JOIN LATERAL (
+    SELECT COUNT(DISTINCT c.id) AS due_count
+    FROM cards c
+    JOIN card_progress cp ON cp.card_id = c.id
+    WHERE c.pack_id = p.id
+      AND c.deleted_at IS NULL
+      AND cp.user_id = $1::uuid
+      AND cp.due_at <= NOW()
+) d
+WHERE p.deleted_at IS NULL
+  AND pack_listed(p.id, $1::uuid)
+  AND ($2::text IS NULL OR p.visibility = $2::text)
+  AND ($3::text IS NULL OR lower(p.source_lang) = lower($3::text))
+  AND ($4::text IS NULL OR lower(p.target_lang) = lower($4::text))
+  AND ($5::uuid IS NULL OR p.category_id IN (SELECT category_tree.id FROM category_tree))
+  AND ($6::uuid IS NULL OR p.owner_id = $6::uuid)
+  AND ($7::uuid IS NULL OR p.org_id = $7::uuid)
+  AND ($8::bool IS NULL OR EXISTS (
+        SELECT 1 FROM subscriptions s
+        WHERE s.pack_id = p.id AND s.user_id = $1::uuid
+      ) = $8::bool)
+  AND ($9::uuid IS NULL OR CASE
+        WHEN $10::text = 'name' AND $11::bool
+            THEN (p.name, p.id) < ($12::text, $9::uuid)
+        WHEN $10::text = 'name'
+            THEN (p.name, p.id) > ($12::text, $9::uuid)
+        WHEN $10::text = 'updated_at' AND $11::bool
+            THEN (p.updated_at, p.id) < ($13::timestamptz, $9::uuid)
+        WHEN $10::text = 'updated_at'
+            THEN (p.updated_at, p.id) > ($13::timestamptz, $9::uuid)
+        WHEN $10::text = 'due_count' AND $11::bool
+            THEN (d.due_count, p.id) < ($14::bigint, $9::uuid)
+        WHEN $10::text = 'due_count'
+            THEN (d.due_count, p.id) > ($14::bigint, $9::uuid)
+        WHEN $11::bool
+            THEN (p.created_at, p.id) < ($13::timestamptz, $9::uuid)
+        ELSE (p.created_at, p.id) > ($13::timestamptz, $9::uuid)
+      END)
+ORDER BY
+  CASE WHEN $10::text = 'name'       AND NOT $11::bool THEN p.name END ASC,
+  CASE WHEN $10::text = 'name'       AND $11::bool     THEN p.name END DESC,
+  CASE WHEN $10::text = 'updated_at' AND NOT $11::bool THEN p.updated_at END ASC,
+  CASE WHEN $10::text = 'updated_at' AND $11::bool     THEN p.updated_at END DESC,
+  CASE WHEN $10::text = 'due_count'  AND NOT $11::bool THEN d.due_count END ASC,
+  CASE WHEN $10::text = 'due_count'  AND $11::bool     THEN d.due_count END DESC,
+  -- ties are broken by id alone, as in the cursor
+  CASE WHEN $10::text NOT IN ('name', 'updated_at', 'due_count') AND NOT $11::bool THEN p.created_at END ASC,
+  CASE WHEN $10::text NOT IN ('name', 'updated_at', 'due_count') AND $11::bool     THEN p.created_at END DESC,
+  CASE WHEN NOT $11::bool THEN p.id END ASC,
+  CASE WHEN $11::bool     THEN p.id END DESC
+LIMIT $15
 `
 
-func (q *Queries) DeletePack(ctx context.Context, id pgtype.UUID) error {
-	_, err := q.db.Exec(ctx, deletePack, id)
-	return err
+type ListPacksParams struct {
+	UserID      pgtype.UUID
+	Visibility  pgtype.Text
+	SourceLang  pgtype.Text
+	TargetLang  pgtype.Text
+	CategoryID  pgtype.UUID
+	OwnerID     pgtype.UUID
+	OrgID       pgtype.UUID
+	Subscribed  pgtype.Bool
+	CursorID    pgtype.UUID
+	SortBy      string
+	SortDesc    bool
+	CursorName  pgtype.Text
+	CursorTime  pgtype.Timestamptz
+	CursorCount pgtype.Int8
+	PageLimit   int32
 }
 
-const listPacks = `-- name: ListPacks :many
-SELECT id, name, category, created_at, updated_at
-FROM packs
-ORDER BY created_at DESC
-`
+type ListPacksRow struct {
+	ID          pgtype.UUID
+	Name        string
+	Category    pgtype.Text
+	CategoryID  pgtype.UUID
+	OwnerID     pgtype.UUID
+	Description string
+	SourceLang  pgtype.Text
+	TargetLang  pgtype.Text
+	CoverColor  pgtype.Text
+	CoverIcon   pgtype.Text
+	Visibility  string
+	ForkedFrom  pgtype.UUID
+	OrgID       pgtype.UUID
+	CreatedAt   pgtype.Timestamptz
+	UpdatedAt   pgtype.Timestamptz
+	DueCount    int64
+	Role        string
+}
 
-func (q *Queries) ListPacks(ctx context.Context) ([]Pack, error) {
-	rows, err := q.db.Query(ctx, listPacks)
+// Keyset-paginated listing: the cursor_* arguments hold the sort value and id
+// of the last row of the previous page, only the one matching sort_by is used.
+// A category filter matches the category itself and all of its descendants.
+func (q *Queries) ListPacks(ctx context.Context, arg ListPacksParams) ([]ListPacksRow, error) {
+	rows, err := q.db.Query(ctx, listPacks,
+		arg.UserID,
+		arg.Visibility,
+		arg.SourceLang,
+		arg.TargetLang,
+		arg.CategoryID,
+		arg.OwnerID,
+		arg.OrgID,
+		arg.Subscribed,
+		arg.CursorID,
+		arg.SortBy,
+		arg.SortDesc,
+		arg.CursorName,
+		arg.CursorTime,
+		arg.CursorCount,
+		arg.PageLimit,
+	)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
-	var items []Pack
+	var items []ListPacksRow
 	for rows.Next() {
-		var i Pack
+		var i ListPacksRow
 		if err := rows.Scan(
 			&i.ID,
 			&i.Name,
 			&i.Category,
+			&i.CategoryID,
+			&i.OwnerID,
+			&i.Description,
+			&i.SourceLang,
+			&i.TargetLang,
+			&i.CoverColor,
+			&i.CoverIcon,
+			&i.Visibility,
+			&i.ForkedFrom,
+			&i.OrgID,
 			&i.CreatedAt,
 			&i.UpdatedAt,
+			&i.DueCount,
+			&i.Role,
 		); err != nil {
 			return nil, err
 		}
@@ -76,8 +232,53 @@ func (q *Queries) ListPacks(ctx context.Context) ([]Pack, error) {
 	return items, nil
 }
 
+const packEditable = `-- name: PackEditable :one
+SELECT COALESCE(pack_role($1::uuid, $2::uuid) IN ('owner', 'editor'), false)::bool AS editable
+`
+
+type PackEditableParams struct {
+	PackID pgtype.UUID
+	UserID pgtype.UUID
+}
+
+// Owners and editors can change the cards of a pack.
+func (q *Queries) PackEditable(ctx context.Context, arg PackEditableParams) (bool, error) {
+	row := q.db.QueryRow(ctx, packEditable, arg.PackID, arg.UserID)
+	var editable bool
+	err := row.Scan(&editable)
+	return editable, err
+}
+
+const packVisible = `-- name: PackVisible :one
+SELECT pack_visible($1::uuid, $2::uuid)::bool AS visible
+`
+
+type PackVisibleParams struct {
+	PackID pgtype.UUID
+	UserID pgtype.UUID
+}
+
+func (q *Queries) PackVisible(ctx context.Context, arg PackVisibleParams) (bool, error) {
+	row := q.db.QueryRow(ctx, packVisible, arg.PackID, arg.UserID)
+	var visible bool
+	err := row.Scan(&visible)
+	return visible, err
+}
+
+const purgeDeletedPacks = `-- name: PurgeDeletedPacks :execrows
+DELETE FROM packs WHERE deleted_at < $1::timestamptz
+`
+
+func (q *Queries) PurgeDeletedPacks(ctx context.Context, before pgtype.Timestamptz) (int64, error) {
+	result, err := q.db.Exec(ctx, purgeDeletedPacks, before)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
 const readPack = `-- name: ReadPack :one
-SELECT id, name, category, created_at, updated_at FROM packs WHERE id = $1
+SELECT id, name, created_at, updated_at, owner_id, category_id, direction, deleted_at, deleted_by, forked_from, description, source_lang, target_lang, cover_color, cover_icon, visibility, org_id FROM packs WHERE id = $1 AND deleted_at IS NULL
 `
 
 func (q *Queries) ReadPack(ctx context.Context, id pgtype.UUID) (Pack, error) {
@@ -86,35 +287,167 @@ func (q *Queries) ReadPack(ctx context.Context, id pgtype.UUID) (Pack, error) {
 	err := row.Scan(
 		&i.ID,
 		&i.Name,
-		&i.Category,
 		&i.CreatedAt,
 		&i.UpdatedAt,
+		&i.OwnerID,
+		&i.CategoryID,
+		&i.Direction,
+		&i.DeletedAt,
+		&i.DeletedBy,
+		&i.ForkedFrom,
+		&i.Description,
+		&i.SourceLang,
+		&i.TargetLang,
+		&i.CoverColor,
+		&i.CoverIcon,
+		&i.Visibility,
+		&i.OrgID,
 	)
 	return i, err
 }
 
+const restorePack = `-- name: RestorePack :one
+UPDATE packs
+SET deleted_at = NULL, deleted_by = NULL
+WHERE id = $1 AND deleted_at IS NOT NULL
+  AND (owner_id = $2 OR (owner_id IS NULL AND org_id IS NULL AND deleted_by = $2)
+       OR EXISTS (
+            SELECT 1 FROM pack_members m
+            WHERE m.pack_id = packs.id AND m.user_id = $2
+              AND m.role = 'owner' AND m.accepted_at IS NOT NULL
+          )
+       OR EXISTS (
+            SELECT 1 FROM organization_members om
+            WHERE om.org_id = packs.org_id AND om.user_id = $2 AND om.role = 'admin'
+              AND om.joined_at IS NOT NULL
+          ))
+RETURNING id, name, created_at, updated_at, owner_id, category_id, direction, deleted_at, deleted_by, forked_from, description, source_lang, target_lang, cover_color, cover_icon, visibility, org_id
+`
+
+type RestorePackParams struct {
+	ID     pgtype.UUID
+	UserID pgtype.UUID
+}
+
+func (q *Queries) RestorePack(ctx context.Context, arg RestorePackParams) (Pack, error) {
+	row := q.db.QueryRow(ctx, restorePack, arg.ID, arg.UserID)
+	var i Pack
+	err := row.Scan(
+		&i.ID,
+		&i.Name,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.OwnerID,
+		&i.CategoryID,
+		&i.Direction,
+		&i.DeletedAt,
+		&i.DeletedBy,
+		&i.ForkedFrom,
+		&i.Description,
+		&i.SourceLang,
+		&i.TargetLang,
+		&i.CoverColor,
+		&i.CoverIcon,
+		&i.Visibility,
+		&i.OrgID,
+	)
+	return i, err
+}
+
+const setPackDirection = `-- name: SetPackDirection :execrows
+UPDATE packs
+SET direction = $1
+WHERE id = $2 AND pack_role(id, $3::uuid) = 'owner'
+`
+
+type SetPackDirectionParams struct {
+	Direction string
+	ID        pgtype.UUID
+	OwnerID   pgtype.UUID
+}
+
+func (q *Queries) SetPackDirection(ctx context.Context, arg SetPackDirectionParams) (int64, error) {
+	result, err := q.db.Exec(ctx, setPackDirection, arg.Direction, arg.ID, arg.OwnerID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const softDeletePack = `-- name: SoftDeletePack :execrows
+UPDATE packs
+SET deleted_at = NOW(), deleted_by = $1
+WHERE id = $2 AND pack_role(id, $1::uuid) = 'owner'
+`
+
+type SoftDeletePackParams struct {
+	UserID pgtype.UUID
+	ID     pgtype.UUID
+}
+
+// Only owners can delete a pack; legacy packs without one by anybody.
+func (q *Queries) SoftDeletePack(ctx context.Context, arg SoftDeletePackParams) (int64, error) {
+	result, err := q.db.Exec(ctx, softDeletePack, arg.UserID, arg.ID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
 const updatePack = `-- name: UpdatePack :one
 UPDATE packs
-SET name = $1, category = $2
-WHERE id = $3
-RETURNING id, name, category, created_at, updated_at
+SET name = $1, category_id = $2, description = $3,
+    source_lang = $4, target_lang = $5,
+    cover_color = $6, cover_icon = $7, visibility = $8
+WHERE id = $9 AND pack_role(id, $10::uuid) = 'owner'
+RETURNING id, name, created_at, updated_at, owner_id, category_id, direction, deleted_at, deleted_by, forked_from, description, source_lang, target_lang, cover_color, cover_icon, visibility, org_id
 `
 
 type UpdatePackParams struct {
-	Name     string
-	Category pgtype.Text
-	ID       pgtype.UUID
+	Name        string
+	CategoryID  pgtype.UUID
+	Description string
+	SourceLang  pgtype.Text
+	TargetLang  pgtype.Text
+	CoverColor  pgtype.Text
+	CoverIcon   pgtype.Text
+	Visibility  string
+	ID          pgtype.UUID
+	UserID      pgtype.UUID
 }
 
 func (q *Queries) UpdatePack(ctx context.Context, arg UpdatePackParams) (Pack, error) {
-	row := q.db.QueryRow(ctx, updatePack, arg.Name, arg.Category, arg.ID)
+	row := q.db.QueryRow(ctx, updatePack,
+		arg.Name,
+		arg.CategoryID,
+		arg.Description,
+		arg.SourceLang,
+		arg.TargetLang,
+		arg.CoverColor,
+		arg.CoverIcon,
+		arg.Visibility,
+		arg.ID,
+		arg.UserID,
+	)
 	var i Pack
 	err := row.Scan(
 		&i.ID,
 		&i.Name,
-		&i.Category,
 		&i.CreatedAt,
 		&i.UpdatedAt,
+		&i.OwnerID,
+		&i.CategoryID,
+		&i.Direction,
+		&i.DeletedAt,
+		&i.DeletedBy,
+		&i.ForkedFrom,
+		&i.Description,
+		&i.SourceLang,
+		&i.TargetLang,
+		&i.CoverColor,
+		&i.CoverIcon,
+		&i.Visibility,
+		&i.OrgID,
 	)
 	return i, err
 }
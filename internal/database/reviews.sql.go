@@ -0,0 +1,299 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.26.0
+// source: reviews.sql
+
+package database
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const countReviewsSince = `-- name: CountReviewsSince :one
+SELECT COUNT(*) FILTER (WHERE new_card)     AS new_done,
+       COUNT(*) FILTER (WHERE NOT new_card) AS reviews_done
+FROM reviews
+WHERE user_id = $1 AND reviewed_at >= $2::timestamptz
+`
+
+type CountReviewsSinceParams struct {
+	UserID pgtype.UUID
+	Since  pgtype.Timestamptz
+}
+
+type CountReviewsSinceRow struct {
+	NewDone     int64
+	ReviewsDone int64
+}
+
+func (q *Queries) CountReviewsSince(ctx context.Context, arg CountReviewsSinceParams) (CountReviewsSinceRow, error) {
+	row := q.db.QueryRow(ctx, countReviewsSince, arg.UserID, arg.Since)
+	var i CountReviewsSinceRow
+	err := row.Scan(&i.NewDone, &i.ReviewsDone)
+	return i, err
+}
+
+const createReview = `-- name: CreateReview :exec
+INSERT INTO reviews (user_id, card_id, pack_id, direction, correct, new_card, interval_days, ease, duration_ms, reviewed_at)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+`
+
+type CreateReviewParams struct {
+	UserID       pgtype.UUID
+	CardID       pgtype.UUID
+	PackID       pgtype.UUID
+	Direction    string
+	Correct      bool
+	NewCard      bool
+	IntervalDays int32
+	Ease         float64
+	DurationMs   pgtype.Int4
+	ReviewedAt   pgtype.Timestamptz
+}
+
+func (q *Queries) CreateReview(ctx context.Context, arg CreateReviewParams) error {
+	_, err := q.db.Exec(ctx, createReview,
+		arg.UserID,
+		arg.CardID,
+		arg.PackID,
+		arg.Direction,
+		arg.Correct,
+		arg.NewCard,
+		arg.IntervalDays,
+		arg.Ease,
+		arg.DurationMs,
+		arg.ReviewedAt,
+	)
+	return err
+}
+
+const getCardProgress = `-- name: GetCardProgress :one
+SELECT c.id AS card_id, c.pack_id,
+       cp.reps, cp.lapses, cp.interval_days, cp.ease, cp.due_at
+FROM cards c
+LEFT JOIN card_progress cp
+       ON cp.card_id = c.id AND cp.user_id = $1::uuid AND cp.direction = $2::text
+WHERE c.id = $3 AND c.deleted_at IS NULL AND pack_visible(c.pack_id, $1::uuid)
+`
+
+type GetCardProgressParams struct {
+	UserID    pgtype.UUID
+	Direction string
+	CardID    pgtype.UUID
+}
+
+type GetCardProgressRow struct {
+	CardID       pgtype.UUID
+	PackID       pgtype.UUID
+	Reps         pgtype.Int4
+	Lapses       pgtype.Int4
+	IntervalDays pgtype.Int4
+	Ease         pgtype.Float8
+	DueAt        pgtype.Timestamptz
+}
+
+// Progress columns are NULL when the user has never reviewed the card.
+func (q *Queries) GetCardProgress(ctx context.Context, arg GetCardProgressParams) (GetCardProgressRow, error) {
+	row := q.db.QueryRow(ctx, getCardProgress, arg.UserID, arg.Direction, arg.CardID)
+	var i GetCardProgressRow
+	err := row.Scan(
+		&i.CardID,
+		&i.PackID,
+		&i.Reps,
+		&i.Lapses,
+		&i.IntervalDays,
+		&i.Ease,
+		&i.DueAt,
+	)
+	return i, err
+}
+
+const listDueReviewCards = `-- name: ListDueReviewCards :many
+SELECT c.id, c.pack_id, c.question, c.answer, c.rating,
+       (cp.reps = 0 AND cp.lapses > 0)::bool AS last_wrong,
+       c.card_type, c.content_format, cp.direction, cp.due_at, cp.interval_days
+FROM card_progress cp
+JOIN cards c ON c.id = cp.card_id
+JOIN packs p ON p.id = c.pack_id
+WHERE cp.user_id = $1::uuid
+  AND cp.due_at <= $2::timestamptz
+  AND c.deleted_at IS NULL
+  AND cp.direction = ANY(card_prompts(c.card_type, c.question, c.direction, p.direction))
+  AND pack_visible(c.pack_id, $1::uuid)
+ORDER BY cp.due_at, c.id
+LIMIT $3
+`
+
+type ListDueReviewCardsParams struct {
+	UserID    pgtype.UUID
+	Now       pgtype.Timestamptz
+	CardLimit int32
+}
+
+type ListDueReviewCardsRow struct {
+	ID            pgtype.UUID
+	PackID        pgtype.UUID
+	Question      string
+	Answer        string
+	Rating        pgtype.Int4
+	LastWrong     bool
+	CardType      string
+	ContentFormat string
+	Direction     string
+	DueAt         pgtype.Timestamptz
+	IntervalDays  int32
+}
+
+// Cards the user has already studied whose due time has come. Progress of
+// prompts the card no longer has (direction changed, cloze removed) is
+// skipped.
+func (q *Queries) ListDueReviewCards(ctx context.Context, arg ListDueReviewCardsParams) ([]ListDueReviewCardsRow, error) {
+	rows, err := q.db.Query(ctx, listDueReviewCards, arg.UserID, arg.Now, arg.CardLimit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListDueReviewCardsRow
+	for rows.Next() {
+		var i ListDueReviewCardsRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.PackID,
+			&i.Question,
+			&i.Answer,
+			&i.Rating,
+			&i.LastWrong,
+			&i.CardType,
+			&i.ContentFormat,
+			&i.Direction,
+			&i.DueAt,
+			&i.IntervalDays,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listNewQueueCards = `-- name: ListNewQueueCards :many
+SELECT q.id, q.pack_id, q.question, q.answer, q.rating, q.last_wrong, q.card_type, q.content_format, q.direction
+FROM (
+    SELECT c.id, c.pack_id, c.question, c.answer, c.rating, FALSE AS last_wrong, c.card_type, c.content_format,
+           d.direction::text AS direction,
+           ROW_NUMBER() OVER (
+               PARTITION BY c.pack_id
+               ORDER BY d.direction = 'reverse', c.created_at, c.id
+           ) AS pos
+    FROM cards c
+    JOIN packs p ON p.id = c.pack_id
+    CROSS JOIN LATERAL unnest(card_prompts(c.card_type, c.question, c.direction, p.direction)) AS d(direction)
+    WHERE c.deleted_at IS NULL AND p.deleted_at IS NULL
+      AND ((COALESCE(p.owner_id, p.org_id) IS NOT NULL AND pack_role(p.id, $1::uuid) IS NOT NULL) OR EXISTS (
+            SELECT 1 FROM subscriptions s
+            WHERE s.pack_id = p.id AND s.user_id = $1::uuid
+          ))
+      AND NOT EXISTS (
+            SELECT 1 FROM card_progress cp
+            WHERE cp.card_id = c.id AND cp.user_id = $1::uuid AND cp.direction = d.direction
+          )
+) q
+ORDER BY q.pos, q.pack_id
+LIMIT $2
+`
+
+type ListNewQueueCardsParams struct {
+	UserID    pgtype.UUID
+	CardLimit int32
+}
+
+type ListNewQueueCardsRow struct {
+	ID            pgtype.UUID
+	PackID        pgtype.UUID
+	Question      string
+	Answer        string
+	Rating        pgtype.Int4
+	LastWrong     bool
+	CardType      string
+	ContentFormat string
+	Direction     string
+}
+
+// Unseen card directions from packs the user has a role in or subscribes to.
+// Legacy packs without an owner give everybody a role and count only when
+// subscribed.
+// Ranking by the position inside each pack spreads the limit evenly across
+// packs; reverse prompts of a card rank after all forward ones.
+func (q *Queries) ListNewQueueCards(ctx context.Context, arg ListNewQueueCardsParams) ([]ListNewQueueCardsRow, error) {
+	rows, err := q.db.Query(ctx, listNewQueueCards, arg.UserID, arg.CardLimit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListNewQueueCardsRow
+	for rows.Next() {
+		var i ListNewQueueCardsRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.PackID,
+			&i.Question,
+			&i.Answer,
+			&i.Rating,
+			&i.LastWrong,
+			&i.CardType,
+			&i.ContentFormat,
+			&i.Direction,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const upsertCardProgress = `-- name: UpsertCardProgress :exec
+INSERT INTO card_progress (user_id, card_id, direction, reps, lapses, interval_days, ease, due_at, last_reviewed_at)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+ON CONFLICT (user_id, card_id, direction) DO UPDATE
+SET reps = EXCLUDED.reps,
+    lapses = EXCLUDED.lapses,
+    interval_days = EXCLUDED.interval_days,
+    ease = EXCLUDED.ease,
+    due_at = EXCLUDED.due_at,
+    last_reviewed_at = EXCLUDED.last_reviewed_at
+`
+
+type UpsertCardProgressParams struct {
+	UserID       pgtype.UUID
+	CardID       pgtype.UUID
+	Direction    string
+	Reps         int32
+	Lapses       int32
+	IntervalDays int32
+	Ease         float64
+	DueAt        pgtype.Timestamptz
+	ReviewedAt   pgtype.Timestamptz
+}
+
+func (q *Queries) UpsertCardProgress(ctx context.Context, arg UpsertCardProgressParams) error {
+	_, err := q.db.Exec(ctx, upsertCardProgress,
+		arg.UserID,
+		arg.CardID,
+		arg.Direction,
+		arg.Reps,
+		arg.Lapses,
+		arg.IntervalDays,
+		arg.Ease,
+		arg.DueAt,
+		arg.ReviewedAt,
+	)
+	return err
+}
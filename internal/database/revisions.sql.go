@@ -0,0 +1,279 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.26.0
+// source: revisions.sql
+
+package database
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const createCardRevision = `-- name: CreateCardRevision :exec
+INSERT INTO card_revisions (card_id, pack_id, action, before, after, changed_by)
+VALUES ($1, $2, $3, $4, $5, $6)
+`
+
+type CreateCardRevisionParams struct {
+	CardID    pgtype.UUID
+	PackID    pgtype.UUID
+	Action    string
+	Before    []byte
+	After     []byte
+	ChangedBy pgtype.UUID
+}
+
+func (q *Queries) CreateCardRevision(ctx context.Context, arg CreateCardRevisionParams) error {
+	_, err := q.db.Exec(ctx, createCardRevision,
+		arg.CardID,
+		arg.PackID,
+		arg.Action,
+		arg.Before,
+		arg.After,
+		arg.ChangedBy,
+	)
+	return err
+}
+
+const getCardRevision = `-- name: GetCardRevision :one
+SELECT id, card_id, pack_id, action, before, after, changed_by, created_at FROM card_revisions WHERE id = $1 AND card_id = $2
+`
+
+type GetCardRevisionParams struct {
+	ID     pgtype.UUID
+	CardID pgtype.UUID
+}
+
+func (q *Queries) GetCardRevision(ctx context.Context, arg GetCardRevisionParams) (CardRevision, error) {
+	row := q.db.QueryRow(ctx, getCardRevision, arg.ID, arg.CardID)
+	var i CardRevision
+	err := row.Scan(
+		&i.ID,
+		&i.CardID,
+		&i.PackID,
+		&i.Action,
+		&i.Before,
+		&i.After,
+		&i.ChangedBy,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const listCardRevisions = `-- name: ListCardRevisions :many
+SELECT r.id, r.action, r.before, r.after, r.changed_by, u.username, r.created_at
+FROM card_revisions r
+LEFT JOIN users u ON u.id = r.changed_by
+WHERE r.card_id = $1
+ORDER BY r.created_at DESC, r.id DESC
+`
+
+type ListCardRevisionsRow struct {
+	ID        pgtype.UUID
+	Action    string
+	Before    []byte
+	After     []byte
+	ChangedBy pgtype.UUID
+	Username  pgtype.Text
+	CreatedAt pgtype.Timestamptz
+}
+
+func (q *Queries) ListCardRevisions(ctx context.Context, cardID pgtype.UUID) ([]ListCardRevisionsRow, error) {
+	rows, err := q.db.Query(ctx, listCardRevisions, cardID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListCardRevisionsRow
+	for rows.Next() {
+		var i ListCardRevisionsRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.Action,
+			&i.Before,
+			&i.After,
+			&i.ChangedBy,
+			&i.Username,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listPackRevisions = `-- name: ListPackRevisions :many
+SELECT r.id, r.card_id, r.action, r.changed_by, u.username,
+       COALESCE(COALESCE(r.after, r.before)->>'question', '')::text AS question,
+       r.created_at
+FROM card_revisions r
+LEFT JOIN users u ON u.id = r.changed_by
+WHERE r.pack_id = $1
+  AND ($2::uuid IS NULL OR r.changed_by = $2::uuid)
+  AND ($3::uuid IS NULL
+       OR (r.created_at, r.id) < ($4::timestamptz, $3::uuid))
+ORDER BY r.created_at DESC, r.id DESC
+LIMIT $5
+`
+
+type ListPackRevisionsParams struct {
+	PackID     pgtype.UUID
+	ChangedBy  pgtype.UUID
+	CursorID   pgtype.UUID
+	CursorTime pgtype.Timestamptz
+	PageLimit  int32
+}
+
+type ListPackRevisionsRow struct {
+	ID        pgtype.UUID
+	CardID    pgtype.UUID
+	Action    string
+	ChangedBy pgtype.UUID
+	Username  pgtype.Text
+	Question  string
+	CreatedAt pgtype.Timestamptz
+}
+
+// Audit log of a pack, newest first and keyset-paginated on created_at.
+// The question is taken from the card as it was after the change, or
+// before it for a deletion.
+func (q *Queries) ListPackRevisions(ctx context.Context, arg ListPackRevisionsParams) ([]ListPackRevisionsRow, error) {
+	rows, err := q.db.Query(ctx, listPackRevisions,
+		arg.PackID,
+		arg.ChangedBy,
+		arg.CursorID,
+		arg.CursorTime,
+		arg.PageLimit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListPackRevisionsRow
+	for rows.Next() {
+		var i ListPackRevisionsRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.CardID,
+			&i.Action,
+			&i.ChangedBy,
+			&i.Username,
+			&i.Question,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listTrashCards = `-- name: ListTrashCards :many
+SELECT c.id, c.pack_id, p.name AS pack_name, c.question, c.card_type, c.deleted_at
+FROM cards c
+JOIN packs p ON p.id = c.pack_id
+WHERE c.deleted_at IS NOT NULL AND p.deleted_at IS NULL
+  AND (p.owner_id = $1 OR (p.owner_id IS NULL AND p.org_id IS NULL AND c.deleted_by = $1)
+       OR EXISTS (
+            SELECT 1 FROM pack_members m
+            WHERE m.pack_id = p.id AND m.user_id = $1
+              AND m.role IN ('owner', 'editor') AND m.accepted_at IS NOT NULL
+          )
+       OR EXISTS (
+            SELECT 1 FROM organization_members om
+            WHERE om.org_id = p.org_id AND om.user_id = $1 AND om.role = 'admin'
+              AND om.joined_at IS NOT NULL
+          ))
+ORDER BY c.deleted_at DESC
+`
+
+type ListTrashCardsRow struct {
+	ID        pgtype.UUID
+	PackID    pgtype.UUID
+	PackName  string
+	Question  string
+	CardType  string
+	DeletedAt pgtype.Timestamptz
+}
+
+// Cards in the trash from packs the user can still edit.
+func (q *Queries) ListTrashCards(ctx context.Context, userID pgtype.UUID) ([]ListTrashCardsRow, error) {
+	rows, err := q.db.Query(ctx, listTrashCards, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListTrashCardsRow
+	for rows.Next() {
+		var i ListTrashCardsRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.PackID,
+			&i.PackName,
+			&i.Question,
+			&i.CardType,
+			&i.DeletedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listTrashPacks = `-- name: ListTrashPacks :many
+SELECT p.id, p.name, p.deleted_at
+FROM packs p
+WHERE p.deleted_at IS NOT NULL
+  AND (p.owner_id = $1 OR (p.owner_id IS NULL AND p.org_id IS NULL AND p.deleted_by = $1)
+       OR EXISTS (
+            SELECT 1 FROM pack_members m
+            WHERE m.pack_id = p.id AND m.user_id = $1
+              AND m.role = 'owner' AND m.accepted_at IS NOT NULL
+          )
+       OR EXISTS (
+            SELECT 1 FROM organization_members om
+            WHERE om.org_id = p.org_id AND om.user_id = $1 AND om.role = 'admin'
+              AND om.joined_at IS NOT NULL
+          ))
+ORDER BY p.deleted_at DESC
+`
+
+type ListTrashPacksRow struct {
+	ID        pgtype.UUID
+	Name      string
+	DeletedAt pgtype.Timestamptz
+}
+
+// Packs the user deleted or owns that are in the trash.
+func (q *Queries) ListTrashPacks(ctx context.Context, userID pgtype.UUID) ([]ListTrashPacksRow, error) {
+	rows, err := q.db.Query(ctx, listTrashPacks, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListTrashPacksRow
+	for rows.Next() {
+		var i ListTrashPacksRow
+		if err := rows.Scan(&i.ID, &i.Name, &i.DeletedAt); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
@@ -0,0 +1,135 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.26.0
+// source: search.sql
+
+package database
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const searchCards = `-- name: SearchCards :many
+
+SELECT c.id, c.pack_id, p.name AS pack_name,
+       ts_headline('russian', c.question, websearch_to_tsquery('russian', $1::text),
+                   'StartSel=' || chr(57344) || ', StopSel=' || chr(57345) || ', HighlightAll=TRUE')::text AS question_snippet,
+       ts_headline('russian', c.answer, websearch_to_tsquery('russian', $1::text),
+                   'StartSel=' || chr(57344) || ', StopSel=' || chr(57345) ||
+                   ', MaxWords=25, MinWords=8, MaxFragments=2')::text AS answer_snippet,
+       ts_rank(setweight(to_tsvector('russian', c.question), 'A') ||
+               setweight(to_tsvector('russian', c.answer),   'B'),
+               websearch_to_tsquery('russian', $1::text))::real AS rank
+FROM cards c
+JOIN packs p ON p.id = c.pack_id
+WHERE (setweight(to_tsvector('russian', c.question), 'A') ||
+       setweight(to_tsvector('russian', c.answer),   'B'))
+      @@ websearch_to_tsquery('russian', $1::text)
+  AND c.deleted_at IS NULL
+  AND pack_listed(p.id, $2::uuid)
+ORDER BY rank DESC, c.id
+LIMIT $3
+`
+
+type SearchCardsParams struct {
+	Query       string
+	UserID      pgtype.UUID
+	ResultLimit int32
+}
+
+type SearchCardsRow struct {
+	ID              pgtype.UUID
+	PackID          pgtype.UUID
+	PackName        string
+	QuestionSnippet string
+	AnswerSnippet   string
+	Rank            float32
+}
+
+// Search queries rebuild the exact tsvector expressions of idx_cards_search
+// and idx_packs_search so the planner can use the GIN indexes.
+// Highlights are delimited with U+E000/U+E001 (chr(57344)/chr(57345)) and
+// turned into <mark> tags after HTML-escaping on the Go side. Results are
+// limited to listed packs, so unlisted packs of others stay out.
+func (q *Queries) SearchCards(ctx context.Context, arg SearchCardsParams) ([]SearchCardsRow, error) {
+	rows, err := q.db.Query(ctx, searchCards, arg.Query, arg.UserID, arg.ResultLimit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []SearchCardsRow
+	for rows.Next() {
+		var i SearchCardsRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.PackID,
+			&i.PackName,
+			&i.QuestionSnippet,
+			&i.AnswerSnippet,
+			&i.Rank,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const searchPacks = `-- name: SearchPacks :many
+SELECT p.id, p.name, cat.name AS category,
+       ts_headline('russian', p.name, websearch_to_tsquery('russian', $1::text),
+                   'StartSel=' || chr(57344) || ', StopSel=' || chr(57345) || ', HighlightAll=TRUE')::text AS name_snippet,
+       ts_rank(to_tsvector('russian', p.name),
+               websearch_to_tsquery('russian', $1::text))::real AS rank
+FROM packs p
+LEFT JOIN categories cat ON cat.id = p.category_id
+WHERE to_tsvector('russian', p.name) @@ websearch_to_tsquery('russian', $1::text)
+  AND pack_listed(p.id, $2::uuid)
+ORDER BY rank DESC, p.id
+LIMIT $3
+`
+
+type SearchPacksParams struct {
+	Query       string
+	UserID      pgtype.UUID
+	ResultLimit int32
+}
+
+type SearchPacksRow struct {
+	ID          pgtype.UUID
+	Name        string
+	Category    pgtype.Text
+	NameSnippet string
+	Rank        float32
+}
+
+func (q *Queries) SearchPacks(ctx context.Context, arg SearchPacksParams) ([]SearchPacksRow, error) {
+	rows, err := q.db.Query(ctx, searchPacks, arg.Query, arg.UserID, arg.ResultLimit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []SearchPacksRow
+	for rows.Next() {
+		var i SearchPacksRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.Name,
+			&i.Category,
+			&i.NameSnippet,
+			&i.Rank,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
@@ -0,0 +1,229 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.26.0
+// source: shares.sql
+
+package database
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const countPackCards = `-- name: CountPackCards :one
+SELECT COUNT(*) FROM cards WHERE pack_id = $1 AND deleted_at IS NULL
+`
+
+func (q *Queries) CountPackCards(ctx context.Context, packID pgtype.UUID) (int64, error) {
+	row := q.db.QueryRow(ctx, countPackCards, packID)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
+const createShareLink = `-- name: CreateShareLink :one
+INSERT INTO pack_share_links (pack_id, created_by, expires_at, max_uses)
+VALUES ($1, $2, $3, $4)
+RETURNING id, pack_id, created_by, expires_at, max_uses, use_count, revoked_at, created_at
+`
+
+type CreateShareLinkParams struct {
+	PackID    pgtype.UUID
+	CreatedBy pgtype.UUID
+	ExpiresAt pgtype.Timestamptz
+	MaxUses   pgtype.Int4
+}
+
+func (q *Queries) CreateShareLink(ctx context.Context, arg CreateShareLinkParams) (PackShareLink, error) {
+	row := q.db.QueryRow(ctx, createShareLink,
+		arg.PackID,
+		arg.CreatedBy,
+		arg.ExpiresAt,
+		arg.MaxUses,
+	)
+	var i PackShareLink
+	err := row.Scan(
+		&i.ID,
+		&i.PackID,
+		&i.CreatedBy,
+		&i.ExpiresAt,
+		&i.MaxUses,
+		&i.UseCount,
+		&i.RevokedAt,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const getActiveShareLink = `-- name: GetActiveShareLink :one
+SELECT id, pack_id, created_by, expires_at, max_uses, use_count, revoked_at, created_at FROM pack_share_links
+WHERE id = $1
+  AND revoked_at IS NULL
+  AND (expires_at IS NULL OR expires_at > NOW())
+  AND (max_uses IS NULL OR use_count < max_uses)
+`
+
+func (q *Queries) GetActiveShareLink(ctx context.Context, id pgtype.UUID) (PackShareLink, error) {
+	row := q.db.QueryRow(ctx, getActiveShareLink, id)
+	var i PackShareLink
+	err := row.Scan(
+		&i.ID,
+		&i.PackID,
+		&i.CreatedBy,
+		&i.ExpiresAt,
+		&i.MaxUses,
+		&i.UseCount,
+		&i.RevokedAt,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const listActiveShareLinks = `-- name: ListActiveShareLinks :many
+SELECT id, pack_id, created_by, expires_at, max_uses, use_count, revoked_at, created_at FROM pack_share_links
+WHERE pack_id = $1
+  AND revoked_at IS NULL
+  AND (expires_at IS NULL OR expires_at > NOW())
+  AND (max_uses IS NULL OR use_count < max_uses)
+ORDER BY created_at DESC
+`
+
+func (q *Queries) ListActiveShareLinks(ctx context.Context, packID pgtype.UUID) ([]PackShareLink, error) {
+	rows, err := q.db.Query(ctx, listActiveShareLinks, packID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []PackShareLink
+	for rows.Next() {
+		var i PackShareLink
+		if err := rows.Scan(
+			&i.ID,
+			&i.PackID,
+			&i.CreatedBy,
+			&i.ExpiresAt,
+			&i.MaxUses,
+			&i.UseCount,
+			&i.RevokedAt,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const previewPackCards = `-- name: PreviewPackCards :many
+SELECT id, question, answer, pack_id, rating, created_at, updated_at, last_wrong, direction, card_type, content_format, deleted_at, deleted_by, upstream_card_id, upstream_synced_at, upstream_hash FROM cards
+WHERE pack_id = $1 AND deleted_at IS NULL
+ORDER BY created_at, id
+LIMIT $2
+`
+
+type PreviewPackCardsParams struct {
+	PackID    pgtype.UUID
+	CardLimit int32
+}
+
+func (q *Queries) PreviewPackCards(ctx context.Context, arg PreviewPackCardsParams) ([]Card, error) {
+	rows, err := q.db.Query(ctx, previewPackCards, arg.PackID, arg.CardLimit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Card
+	for rows.Next() {
+		var i Card
+		if err := rows.Scan(
+			&i.ID,
+			&i.Question,
+			&i.Answer,
+			&i.PackID,
+			&i.Rating,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.LastWrong,
+			&i.Direction,
+			&i.CardType,
+			&i.ContentFormat,
+			&i.DeletedAt,
+			&i.DeletedBy,
+			&i.UpstreamCardID,
+			&i.UpstreamSyncedAt,
+			&i.UpstreamHash,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const refundShareLink = `-- name: RefundShareLink :execrows
+UPDATE pack_share_links
+SET use_count = use_count - 1
+WHERE id = $1 AND use_count > 0
+`
+
+// Gives back a use taken by UseShareLink when the redemption failed.
+func (q *Queries) RefundShareLink(ctx context.Context, id pgtype.UUID) (int64, error) {
+	result, err := q.db.Exec(ctx, refundShareLink, id)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const revokeShareLink = `-- name: RevokeShareLink :execrows
+UPDATE pack_share_links
+SET revoked_at = NOW()
+WHERE id = $1 AND pack_id = $2 AND revoked_at IS NULL
+`
+
+type RevokeShareLinkParams struct {
+	ID     pgtype.UUID
+	PackID pgtype.UUID
+}
+
+func (q *Queries) RevokeShareLink(ctx context.Context, arg RevokeShareLinkParams) (int64, error) {
+	result, err := q.db.Exec(ctx, revokeShareLink, arg.ID, arg.PackID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const useShareLink = `-- name: UseShareLink :one
+UPDATE pack_share_links
+SET use_count = use_count + 1
+WHERE id = $1
+  AND revoked_at IS NULL
+  AND (expires_at IS NULL OR expires_at > NOW())
+  AND (max_uses IS NULL OR use_count < max_uses)
+RETURNING id, pack_id, created_by, expires_at, max_uses, use_count, revoked_at, created_at
+`
+
+// Takes one use of a link; no row when it is no longer valid.
+func (q *Queries) UseShareLink(ctx context.Context, id pgtype.UUID) (PackShareLink, error) {
+	row := q.db.QueryRow(ctx, useShareLink, id)
+	var i PackShareLink
+	err := row.Scan(
+		&i.ID,
+		&i.PackID,
+		&i.CreatedBy,
+		&i.ExpiresAt,
+		&i.MaxUses,
+		&i.UseCount,
+		&i.RevokedAt,
+		&i.CreatedAt,
+	)
+	return i, err
+}
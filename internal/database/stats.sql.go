@@ -0,0 +1,457 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.26.0
+// source: stats.sql
+
+package database
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const cardMaturityByPack = `-- name: CardMaturityByPack :many
+SELECT p.id AS pack_id, p.name,
+       COUNT(*) FILTER (WHERE m.max_interval IS NULL)::bigint AS new_cards,
+       COUNT(*) FILTER (WHERE m.max_interval < $1::int)::bigint AS young_cards,
+       COUNT(*) FILTER (WHERE m.max_interval >= $1::int)::bigint AS mature_cards
+FROM packs p
+JOIN cards c ON c.pack_id = p.id AND c.deleted_at IS NULL
+CROSS JOIN LATERAL (
+    SELECT MAX(cp.interval_days) AS max_interval
+    FROM card_progress cp
+    WHERE cp.card_id = c.id AND cp.user_id = $2::uuid
+) m
+WHERE p.deleted_at IS NULL
+  AND (p.owner_id = $2::uuid
+       OR EXISTS (
+            SELECT 1 FROM subscriptions s
+            WHERE s.pack_id = p.id AND s.user_id = $2::uuid
+          )
+       OR EXISTS (
+            SELECT 1 FROM card_progress cp
+            JOIN cards pc ON pc.id = cp.card_id
+            WHERE pc.pack_id = p.id AND cp.user_id = $2::uuid
+          ))
+GROUP BY p.id, p.name
+ORDER BY p.name, p.id
+`
+
+type CardMaturityByPackParams struct {
+	MatureDays int32
+	UserID     pgtype.UUID
+}
+
+type CardMaturityByPackRow struct {
+	PackID      pgtype.UUID
+	Name        string
+	NewCards    int64
+	YoungCards  int64
+	MatureCards int64
+}
+
+// Cards of the packs the user owns, subscribes to or has studied, by the
+// longest interval of their prompts: new (never studied), young (under
+// @mature_days) and mature.
+func (q *Queries) CardMaturityByPack(ctx context.Context, arg CardMaturityByPackParams) ([]CardMaturityByPackRow, error) {
+	rows, err := q.db.Query(ctx, cardMaturityByPack, arg.MatureDays, arg.UserID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []CardMaturityByPackRow
+	for rows.Next() {
+		var i CardMaturityByPackRow
+		if err := rows.Scan(
+			&i.PackID,
+			&i.Name,
+			&i.NewCards,
+			&i.YoungCards,
+			&i.MatureCards,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const dueForecast = `-- name: DueForecast :many
+WITH due AS (
+    SELECT ((GREATEST(cp.due_at, NOW()) AT TIME ZONE $1::text)
+            - make_interval(hours => $2::int))::date AS day
+    FROM card_progress cp
+    JOIN cards c ON c.id = cp.card_id AND c.deleted_at IS NULL
+    JOIN packs p ON p.id = c.pack_id AND p.deleted_at IS NULL
+    WHERE cp.user_id = $4::uuid
+)
+SELECT d.day::date AS day, COUNT(due.day)::bigint AS due
+FROM generate_series(((NOW() AT TIME ZONE $1::text)
+                      - make_interval(hours => $2::int))::date::timestamp,
+                     (((NOW() AT TIME ZONE $1::text)
+                      - make_interval(hours => $2::int))::date + $3::int - 1)::timestamp,
+                     interval '1 day') AS d(day)
+LEFT JOIN due ON due.day = d.day::date
+GROUP BY d.day
+ORDER BY d.day
+`
+
+type DueForecastParams struct {
+	Tz           string
+	RolloverHour int32
+	Days         int32
+	UserID       pgtype.UUID
+}
+
+type DueForecastRow struct {
+	Day pgtype.Date
+	Due int64
+}
+
+// Prompts falling due on each of the next @days study days (see
+// ReviewsPerDay); overdue ones are counted for today.
+func (q *Queries) DueForecast(ctx context.Context, arg DueForecastParams) ([]DueForecastRow, error) {
+	rows, err := q.db.Query(ctx, dueForecast,
+		arg.Tz,
+		arg.RolloverHour,
+		arg.Days,
+		arg.UserID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []DueForecastRow
+	for rows.Next() {
+		var i DueForecastRow
+		if err := rows.Scan(&i.Day, &i.Due); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const exportReviews = `-- name: ExportReviews :many
+SELECT r.id, r.reviewed_at, r.card_id, r.pack_id,
+       COALESCE(p.name, '')::text AS pack_name,
+       COALESCE(c.question, '')::text AS question,
+       r.direction, r.correct, r.new_card, r.interval_days, r.ease, r.duration_ms
+FROM reviews r
+LEFT JOIN cards c ON c.id = r.card_id
+LEFT JOIN packs p ON p.id = r.pack_id
+WHERE r.user_id = $1
+  AND ($2::timestamptz IS NULL
+       OR (r.reviewed_at, r.id) > ($2::timestamptz, $3::uuid))
+ORDER BY r.reviewed_at, r.id
+LIMIT $4
+`
+
+type ExportReviewsParams struct {
+	UserID     pgtype.UUID
+	CursorTime pgtype.Timestamptz
+	CursorID   pgtype.UUID
+	PageLimit  int32
+}
+
+type ExportReviewsRow struct {
+	ID           pgtype.UUID
+	ReviewedAt   pgtype.Timestamptz
+	CardID       pgtype.UUID
+	PackID       pgtype.UUID
+	PackName     string
+	Question     string
+	Direction    string
+	Correct      bool
+	NewCard      bool
+	IntervalDays int32
+	Ease         float64
+	DurationMs   pgtype.Int4
+}
+
+// The review log of the user in keyset pages, oldest first.
+func (q *Queries) ExportReviews(ctx context.Context, arg ExportReviewsParams) ([]ExportReviewsRow, error) {
+	rows, err := q.db.Query(ctx, exportReviews,
+		arg.UserID,
+		arg.CursorTime,
+		arg.CursorID,
+		arg.PageLimit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ExportReviewsRow
+	for rows.Next() {
+		var i ExportReviewsRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.ReviewedAt,
+			&i.CardID,
+			&i.PackID,
+			&i.PackName,
+			&i.Question,
+			&i.Direction,
+			&i.Correct,
+			&i.NewCard,
+			&i.IntervalDays,
+			&i.Ease,
+			&i.DurationMs,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listStreakDays = `-- name: ListStreakDays :many
+SELECT user_id, day, goal_type, goal, met, frozen FROM streak_days
+WHERE user_id = $1 AND day >= $2::date
+ORDER BY day
+`
+
+type ListStreakDaysParams struct {
+	UserID  pgtype.UUID
+	FromDay pgtype.Date
+}
+
+func (q *Queries) ListStreakDays(ctx context.Context, arg ListStreakDaysParams) ([]StreakDay, error) {
+	rows, err := q.db.Query(ctx, listStreakDays, arg.UserID, arg.FromDay)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []StreakDay
+	for rows.Next() {
+		var i StreakDay
+		if err := rows.Scan(
+			&i.UserID,
+			&i.Day,
+			&i.GoalType,
+			&i.Goal,
+			&i.Met,
+			&i.Frozen,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const packCardAnalytics = `-- name: PackCardAnalytics :many
+SELECT c.id AS card_id, c.question, c.card_type,
+       r.attempts, r.failures,
+       COALESCE(r.avg_duration_ms, 0)::float8 AS avg_duration_ms,
+       r.learners,
+       COALESCE(cp.lapses, 0)::bigint AS lapses,
+       COALESCE(cp.max_lapses, 0)::int AS max_lapses
+FROM cards c
+CROSS JOIN LATERAL (
+    SELECT COUNT(*)::bigint AS attempts,
+           COUNT(*) FILTER (WHERE NOT rv.correct)::bigint AS failures,
+           AVG(rv.duration_ms)::float8 AS avg_duration_ms,
+           COUNT(DISTINCT rv.user_id)::bigint AS learners
+    FROM reviews rv
+    WHERE rv.card_id = c.id
+) r
+CROSS JOIN LATERAL (
+    SELECT SUM(p.lapses) AS lapses, MAX(p.lapses) AS max_lapses
+    FROM card_progress p
+    WHERE p.card_id = c.id
+) cp
+WHERE c.pack_id = $1 AND c.deleted_at IS NULL
+ORDER BY r.failures::float8 / NULLIF(r.attempts, 0) DESC NULLS LAST, r.failures DESC, c.created_at, c.id
+`
+
+type PackCardAnalyticsRow struct {
+	CardID        pgtype.UUID
+	Question      string
+	CardType      string
+	Attempts      int64
+	Failures      int64
+	AvgDurationMs float64
+	Learners      int64
+	Lapses        int64
+	MaxLapses     int32
+}
+
+// How the active cards of a pack fare across everybody studying it, the
+// most failed first. Lapses are wrong answers to cards that had been
+// learned, summed over users; max_lapses is the worst single user.
+func (q *Queries) PackCardAnalytics(ctx context.Context, packID pgtype.UUID) ([]PackCardAnalyticsRow, error) {
+	rows, err := q.db.Query(ctx, packCardAnalytics, packID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []PackCardAnalyticsRow
+	for rows.Next() {
+		var i PackCardAnalyticsRow
+		if err := rows.Scan(
+			&i.CardID,
+			&i.Question,
+			&i.CardType,
+			&i.Attempts,
+			&i.Failures,
+			&i.AvgDurationMs,
+			&i.Learners,
+			&i.Lapses,
+			&i.MaxLapses,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const progressSummary = `-- name: ProgressSummary :one
+SELECT COUNT(*)::bigint AS studied,
+       COALESCE(AVG(cp.interval_days), 0)::float8 AS average_interval,
+       COUNT(*) FILTER (WHERE cp.due_at <= NOW())::bigint AS due_now
+FROM card_progress cp
+JOIN cards c ON c.id = cp.card_id AND c.deleted_at IS NULL
+JOIN packs p ON p.id = c.pack_id AND p.deleted_at IS NULL
+WHERE cp.user_id = $1::uuid
+`
+
+type ProgressSummaryRow struct {
+	Studied         int64
+	AverageInterval float64
+	DueNow          int64
+}
+
+// Current state of the cards the user has studied.
+func (q *Queries) ProgressSummary(ctx context.Context, userID pgtype.UUID) (ProgressSummaryRow, error) {
+	row := q.db.QueryRow(ctx, progressSummary, userID)
+	var i ProgressSummaryRow
+	err := row.Scan(&i.Studied, &i.AverageInterval, &i.DueNow)
+	return i, err
+}
+
+const reviewsPerDay = `-- name: ReviewsPerDay :many
+WITH r AS (
+    SELECT ((reviews.reviewed_at AT TIME ZONE $3::text)
+            - make_interval(hours => $4::int))::date AS day,
+           reviews.correct, reviews.new_card, reviews.duration_ms
+    FROM reviews
+    WHERE reviews.user_id = $5::uuid
+      AND reviews.reviewed_at >= (($1::date)::timestamp
+            + make_interval(hours => $4::int)) AT TIME ZONE $3::text
+      AND reviews.reviewed_at < (($2::date + 1)::timestamp
+            + make_interval(hours => $4::int)) AT TIME ZONE $3::text
+)
+SELECT d.day::date AS day,
+       COUNT(r.day)::bigint AS reviews,
+       COUNT(r.day) FILTER (WHERE r.correct)::bigint AS correct,
+       COUNT(r.day) FILTER (WHERE r.new_card)::bigint AS new_cards,
+       COUNT(r.day) FILTER (WHERE NOT r.new_card)::bigint AS recalls,
+       COUNT(r.day) FILTER (WHERE NOT r.new_card AND r.correct)::bigint AS recalls_correct,
+       COALESCE(SUM(r.duration_ms), 0)::bigint AS time_ms
+FROM generate_series(($1::date)::timestamp, ($2::date)::timestamp, interval '1 day') AS d(day)
+LEFT JOIN r ON r.day = d.day::date
+GROUP BY d.day
+ORDER BY d.day
+`
+
+type ReviewsPerDayParams struct {
+	FromDay      pgtype.Date
+	ToDay        pgtype.Date
+	Tz           string
+	RolloverHour int32
+	UserID       pgtype.UUID
+}
+
+type ReviewsPerDayRow struct {
+	Day            pgtype.Date
+	Reviews        int64
+	Correct        int64
+	NewCards       int64
+	Recalls        int64
+	RecallsCorrect int64
+	TimeMs         int64
+}
+
+// One row per study day from @from_day to @to_day, days without reviews
+// included. A study day starts at @rollover_hour in time zone @tz. Recalls
+// are reviews of cards that were not new.
+func (q *Queries) ReviewsPerDay(ctx context.Context, arg ReviewsPerDayParams) ([]ReviewsPerDayRow, error) {
+	rows, err := q.db.Query(ctx, reviewsPerDay,
+		arg.FromDay,
+		arg.ToDay,
+		arg.Tz,
+		arg.RolloverHour,
+		arg.UserID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ReviewsPerDayRow
+	for rows.Next() {
+		var i ReviewsPerDayRow
+		if err := rows.Scan(
+			&i.Day,
+			&i.Reviews,
+			&i.Correct,
+			&i.NewCards,
+			&i.Recalls,
+			&i.RecallsCorrect,
+			&i.TimeMs,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const settleStreakDays = `-- name: SettleStreakDays :exec
+INSERT INTO streak_days (user_id, day, goal_type, goal, met, frozen)
+SELECT $1, d.day, $2, $3, ($4::bool[])[d.ord], ($5::bool[])[d.ord]
+FROM unnest($6::date[]) WITH ORDINALITY AS d(day, ord)
+ON CONFLICT (user_id, day) DO NOTHING
+`
+
+type SettleStreakDaysParams struct {
+	UserID   pgtype.UUID
+	GoalType string
+	Goal     int32
+	Met      []bool
+	Frozen   []bool
+	Days     []pgtype.Date
+}
+
+// A day is settled once, a concurrent request settling it again is ignored.
+func (q *Queries) SettleStreakDays(ctx context.Context, arg SettleStreakDaysParams) error {
+	_, err := q.db.Exec(ctx, settleStreakDays,
+		arg.UserID,
+		arg.GoalType,
+		arg.Goal,
+		arg.Met,
+		arg.Frozen,
+		arg.Days,
+	)
+	return err
+}
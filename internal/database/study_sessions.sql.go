@@ -0,0 +1,284 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.26.0
+// source: study_sessions.sql
+
+package database
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const advanceStudySession = `-- name: AdvanceStudySession :execrows
+UPDATE study_sessions
+SET queue = $1::text[],
+    correct_count = correct_count + $2::int,
+    wrong_count   = wrong_count   + $3::int,
+    expires_at = $4
+WHERE id = $5
+  AND status = 'active'
+  AND queue = $6::text[]
+`
+
+type AdvanceStudySessionParams struct {
+	NewQueue   []string
+	CorrectInc int32
+	WrongInc   int32
+	ExpiresAt  pgtype.Timestamptz
+	ID         pgtype.UUID
+	OldQueue   []string
+}
+
+// Optimistic update: fails when another request already moved the queue.
+func (q *Queries) AdvanceStudySession(ctx context.Context, arg AdvanceStudySessionParams) (int64, error) {
+	result, err := q.db.Exec(ctx, advanceStudySession,
+		arg.NewQueue,
+		arg.CorrectInc,
+		arg.WrongInc,
+		arg.ExpiresAt,
+		arg.ID,
+		arg.OldQueue,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const completeStudySession = `-- name: CompleteStudySession :one
+UPDATE study_sessions
+SET status = 'completed', completed_at = NOW()
+WHERE id = $1 AND user_id = $2 AND status = 'active'
+RETURNING id, user_id, pack_id, source, tags, status, queue, total_cards, correct_count, wrong_count, expires_at, completed_at, created_at, updated_at
+`
+
+type CompleteStudySessionParams struct {
+	ID     pgtype.UUID
+	UserID pgtype.UUID
+}
+
+func (q *Queries) CompleteStudySession(ctx context.Context, arg CompleteStudySessionParams) (StudySession, error) {
+	row := q.db.QueryRow(ctx, completeStudySession, arg.ID, arg.UserID)
+	var i StudySession
+	err := row.Scan(
+		&i.ID,
+		&i.UserID,
+		&i.PackID,
+		&i.Source,
+		&i.Tags,
+		&i.Status,
+		&i.Queue,
+		&i.TotalCards,
+		&i.CorrectCount,
+		&i.WrongCount,
+		&i.ExpiresAt,
+		&i.CompletedAt,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+	)
+	return i, err
+}
+
+const createStudySession = `-- name: CreateStudySession :one
+INSERT INTO study_sessions (user_id, pack_id, source, tags, queue, total_cards, expires_at)
+VALUES ($1, $2, $3, $4::text[], $5::text[], cardinality($5::text[]), $6)
+RETURNING id, user_id, pack_id, source, tags, status, queue, total_cards, correct_count, wrong_count, expires_at, completed_at, created_at, updated_at
+`
+
+type CreateStudySessionParams struct {
+	UserID    pgtype.UUID
+	PackID    pgtype.UUID
+	Source    string
+	Tags      []string
+	Queue     []string
+	ExpiresAt pgtype.Timestamptz
+}
+
+func (q *Queries) CreateStudySession(ctx context.Context, arg CreateStudySessionParams) (StudySession, error) {
+	row := q.db.QueryRow(ctx, createStudySession,
+		arg.UserID,
+		arg.PackID,
+		arg.Source,
+		arg.Tags,
+		arg.Queue,
+		arg.ExpiresAt,
+	)
+	var i StudySession
+	err := row.Scan(
+		&i.ID,
+		&i.UserID,
+		&i.PackID,
+		&i.Source,
+		&i.Tags,
+		&i.Status,
+		&i.Queue,
+		&i.TotalCards,
+		&i.CorrectCount,
+		&i.WrongCount,
+		&i.ExpiresAt,
+		&i.CompletedAt,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+	)
+	return i, err
+}
+
+const createStudySessionAnswer = `-- name: CreateStudySessionAnswer :exec
+INSERT INTO study_session_answers (session_id, card_id, direction, correct, duration_ms)
+VALUES ($1, $2, $3, $4, $5)
+`
+
+type CreateStudySessionAnswerParams struct {
+	SessionID  pgtype.UUID
+	CardID     pgtype.UUID
+	Direction  string
+	Correct    bool
+	DurationMs pgtype.Int4
+}
+
+func (q *Queries) CreateStudySessionAnswer(ctx context.Context, arg CreateStudySessionAnswerParams) error {
+	_, err := q.db.Exec(ctx, createStudySessionAnswer,
+		arg.SessionID,
+		arg.CardID,
+		arg.Direction,
+		arg.Correct,
+		arg.DurationMs,
+	)
+	return err
+}
+
+const expireStudySessions = `-- name: ExpireStudySessions :execrows
+UPDATE study_sessions
+SET status = 'expired'
+WHERE status = 'active' AND expires_at <= NOW()
+`
+
+func (q *Queries) ExpireStudySessions(ctx context.Context) (int64, error) {
+	result, err := q.db.Exec(ctx, expireStudySessions)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const findActiveStudySession = `-- name: FindActiveStudySession :one
+SELECT id, user_id, pack_id, source, tags, status, queue, total_cards, correct_count, wrong_count, expires_at, completed_at, created_at, updated_at FROM study_sessions
+WHERE user_id = $1
+  AND status = 'active'
+  AND expires_at > NOW()
+  AND source = $2
+  AND pack_id IS NOT DISTINCT FROM $3::uuid
+  AND tags = $4::text[]
+ORDER BY created_at DESC
+LIMIT 1
+`
+
+type FindActiveStudySessionParams struct {
+	UserID pgtype.UUID
+	Source string
+	PackID pgtype.UUID
+	Tags   []string
+}
+
+// An unexpired active session started from the same source, for resuming.
+func (q *Queries) FindActiveStudySession(ctx context.Context, arg FindActiveStudySessionParams) (StudySession, error) {
+	row := q.db.QueryRow(ctx, findActiveStudySession,
+		arg.UserID,
+		arg.Source,
+		arg.PackID,
+		arg.Tags,
+	)
+	var i StudySession
+	err := row.Scan(
+		&i.ID,
+		&i.UserID,
+		&i.PackID,
+		&i.Source,
+		&i.Tags,
+		&i.Status,
+		&i.Queue,
+		&i.TotalCards,
+		&i.CorrectCount,
+		&i.WrongCount,
+		&i.ExpiresAt,
+		&i.CompletedAt,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+	)
+	return i, err
+}
+
+const getStudySession = `-- name: GetStudySession :one
+SELECT id, user_id, pack_id, source, tags, status, queue, total_cards, correct_count, wrong_count, expires_at, completed_at, created_at, updated_at FROM study_sessions
+WHERE id = $1 AND user_id = $2
+`
+
+type GetStudySessionParams struct {
+	ID     pgtype.UUID
+	UserID pgtype.UUID
+}
+
+func (q *Queries) GetStudySession(ctx context.Context, arg GetStudySessionParams) (StudySession, error) {
+	row := q.db.QueryRow(ctx, getStudySession, arg.ID, arg.UserID)
+	var i StudySession
+	err := row.Scan(
+		&i.ID,
+		&i.UserID,
+		&i.PackID,
+		&i.Source,
+		&i.Tags,
+		&i.Status,
+		&i.Queue,
+		&i.TotalCards,
+		&i.CorrectCount,
+		&i.WrongCount,
+		&i.ExpiresAt,
+		&i.CompletedAt,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+	)
+	return i, err
+}
+
+const listActiveStudySessions = `-- name: ListActiveStudySessions :many
+SELECT id, user_id, pack_id, source, tags, status, queue, total_cards, correct_count, wrong_count, expires_at, completed_at, created_at, updated_at FROM study_sessions
+WHERE user_id = $1 AND status = 'active' AND expires_at > NOW()
+ORDER BY updated_at DESC
+`
+
+func (q *Queries) ListActiveStudySessions(ctx context.Context, userID pgtype.UUID) ([]StudySession, error) {
+	rows, err := q.db.Query(ctx, listActiveStudySessions, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []StudySession
+	for rows.Next() {
+		var i StudySession
+		if err := rows.Scan(
+			&i.ID,
+			&i.UserID,
+			&i.PackID,
+			&i.Source,
+			&i.Tags,
+			&i.Status,
+			&i.Queue,
+			&i.TotalCards,
+			&i.CorrectCount,
+			&i.WrongCount,
+			&i.ExpiresAt,
+			&i.CompletedAt,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
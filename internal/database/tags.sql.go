@@ -0,0 +1,390 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.26.0
+// source: tags.sql
+
+package database
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const createTag = `-- name: CreateTag :one
+INSERT INTO tags (owner_id, name)
+VALUES ($1, $2)
+RETURNING id, owner_id, name, created_at, updated_at
+`
+
+type CreateTagParams struct {
+	OwnerID pgtype.UUID
+	Name    string
+}
+
+func (q *Queries) CreateTag(ctx context.Context, arg CreateTagParams) (Tag, error) {
+	row := q.db.QueryRow(ctx, createTag, arg.OwnerID, arg.Name)
+	var i Tag
+	err := row.Scan(
+		&i.ID,
+		&i.OwnerID,
+		&i.Name,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+	)
+	return i, err
+}
+
+const deleteTag = `-- name: DeleteTag :execrows
+DELETE FROM tags WHERE id = $1 AND owner_id = $2
+`
+
+type DeleteTagParams struct {
+	ID      pgtype.UUID
+	OwnerID pgtype.UUID
+}
+
+func (q *Queries) DeleteTag(ctx context.Context, arg DeleteTagParams) (int64, error) {
+	result, err := q.db.Exec(ctx, deleteTag, arg.ID, arg.OwnerID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const listDueCardsByTags = `-- name: ListDueCardsByTags :many
+SELECT c.id, c.pack_id, c.question, c.answer, c.rating,
+       COALESCE(cp.reps = 0 AND cp.lapses > 0, FALSE)::bool AS last_wrong,
+       c.card_type, c.content_format, d.direction::text AS direction
+FROM cards c
+JOIN packs p ON p.id = c.pack_id
+CROSS JOIN LATERAL unnest(card_prompts(c.card_type, c.question, c.direction, p.direction)) AS d(direction)
+LEFT JOIN card_progress cp
+       ON cp.card_id = c.id AND cp.user_id = $1::uuid AND cp.direction = d.direction
+WHERE EXISTS (
+        SELECT 1 FROM card_tags ct
+        JOIN tags t ON t.id = ct.tag_id
+        WHERE ct.card_id = c.id
+          AND t.owner_id = $1::uuid
+          AND lower(t.name) = ANY($2::text[])
+      )
+  AND c.deleted_at IS NULL
+  AND pack_visible(c.pack_id, $1::uuid)
+  AND (cp.due_at IS NULL OR cp.due_at <= $3::timestamptz)
+ORDER BY cp.due_at ASC NULLS LAST, d.direction = 'reverse', c.created_at, c.id
+LIMIT $4
+`
+
+type ListDueCardsByTagsParams struct {
+	UserID    pgtype.UUID
+	TagNames  []string
+	Now       pgtype.Timestamptz
+	CardLimit int32
+}
+
+type ListDueCardsByTagsRow struct {
+	ID            pgtype.UUID
+	PackID        pgtype.UUID
+	Question      string
+	Answer        string
+	Rating        pgtype.Int4
+	LastWrong     bool
+	CardType      string
+	ContentFormat string
+	Direction     string
+}
+
+// Prompts of cards carrying any of the named tags that are due for the user
+// or were never studied, due ones first as in the daily queue.
+func (q *Queries) ListDueCardsByTags(ctx context.Context, arg ListDueCardsByTagsParams) ([]ListDueCardsByTagsRow, error) {
+	rows, err := q.db.Query(ctx, listDueCardsByTags,
+		arg.UserID,
+		arg.TagNames,
+		arg.Now,
+		arg.CardLimit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListDueCardsByTagsRow
+	for rows.Next() {
+		var i ListDueCardsByTagsRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.PackID,
+			&i.Question,
+			&i.Answer,
+			&i.Rating,
+			&i.LastWrong,
+			&i.CardType,
+			&i.ContentFormat,
+			&i.Direction,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listStudyCardsByTags = `-- name: ListStudyCardsByTags :many
+SELECT c.id, c.question, c.answer, c.pack_id, c.rating, c.created_at, c.updated_at, c.last_wrong, c.direction, c.card_type, c.content_format, c.deleted_at, c.deleted_by, c.upstream_card_id, c.upstream_synced_at, c.upstream_hash, card_prompts(c.card_type, c.question, c.direction, p.direction)::text[] AS directions,
+       card_last_wrong(c.id, $1::uuid)::bool AS user_last_wrong
+FROM cards c
+JOIN packs p ON p.id = c.pack_id
+WHERE EXISTS (
+        SELECT 1 FROM card_tags ct
+        JOIN tags t ON t.id = ct.tag_id
+        WHERE ct.card_id = c.id
+          AND t.owner_id = $1::uuid
+          AND lower(t.name) = ANY($2::text[])
+      )
+  AND c.deleted_at IS NULL
+  AND pack_visible(c.pack_id, $1::uuid)
+ORDER BY user_last_wrong DESC, c.created_at ASC
+LIMIT $3
+`
+
+type ListStudyCardsByTagsParams struct {
+	UserID    pgtype.UUID
+	TagNames  []string
+	CardLimit int32
+}
+
+type ListStudyCardsByTagsRow struct {
+	ID               pgtype.UUID
+	Question         string
+	Answer           string
+	PackID           pgtype.UUID
+	Rating           pgtype.Int4
+	CreatedAt        pgtype.Timestamptz
+	UpdatedAt        pgtype.Timestamptz
+	LastWrong        pgtype.Bool
+	Direction        pgtype.Text
+	CardType         string
+	ContentFormat    string
+	DeletedAt        pgtype.Timestamptz
+	DeletedBy        pgtype.UUID
+	UpstreamCardID   pgtype.UUID
+	UpstreamSyncedAt pgtype.Timestamptz
+	UpstreamHash     pgtype.Text
+	Directions       []string
+	UserLastWrong    bool
+}
+
+// Cards carrying any of the named tags, ordered like ListRepeatCards. The
+// tag quiz draws its questions and distractors from these.
+func (q *Queries) ListStudyCardsByTags(ctx context.Context, arg ListStudyCardsByTagsParams) ([]ListStudyCardsByTagsRow, error) {
+	rows, err := q.db.Query(ctx, listStudyCardsByTags, arg.UserID, arg.TagNames, arg.CardLimit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListStudyCardsByTagsRow
+	for rows.Next() {
+		var i ListStudyCardsByTagsRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.Question,
+			&i.Answer,
+			&i.PackID,
+			&i.Rating,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.LastWrong,
+			&i.Direction,
+			&i.CardType,
+			&i.ContentFormat,
+			&i.DeletedAt,
+			&i.DeletedBy,
+			&i.UpstreamCardID,
+			&i.UpstreamSyncedAt,
+			&i.UpstreamHash,
+			&i.Directions,
+			&i.UserLastWrong,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listTagCards = `-- name: ListTagCards :many
+SELECT c.id, c.question, c.answer, c.pack_id, c.rating, c.created_at, c.updated_at, c.last_wrong, c.direction, c.card_type, c.content_format, c.deleted_at, c.deleted_by, c.upstream_card_id, c.upstream_synced_at, c.upstream_hash
+FROM cards c
+JOIN card_tags ct ON ct.card_id = c.id
+JOIN tags t ON t.id = ct.tag_id
+WHERE t.id = $1 AND t.owner_id = $2::uuid
+  AND c.deleted_at IS NULL
+  AND pack_visible(c.pack_id, $2::uuid)
+ORDER BY c.created_at DESC
+`
+
+type ListTagCardsParams struct {
+	TagID  pgtype.UUID
+	UserID pgtype.UUID
+}
+
+func (q *Queries) ListTagCards(ctx context.Context, arg ListTagCardsParams) ([]Card, error) {
+	rows, err := q.db.Query(ctx, listTagCards, arg.TagID, arg.UserID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Card
+	for rows.Next() {
+		var i Card
+		if err := rows.Scan(
+			&i.ID,
+			&i.Question,
+			&i.Answer,
+			&i.PackID,
+			&i.Rating,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.LastWrong,
+			&i.Direction,
+			&i.CardType,
+			&i.ContentFormat,
+			&i.DeletedAt,
+			&i.DeletedBy,
+			&i.UpstreamCardID,
+			&i.UpstreamSyncedAt,
+			&i.UpstreamHash,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listTags = `-- name: ListTags :many
+SELECT t.id, t.name, t.created_at, COUNT(c.id) AS card_count
+FROM tags t
+LEFT JOIN card_tags ct ON ct.tag_id = t.id
+LEFT JOIN cards c ON c.id = ct.card_id AND c.deleted_at IS NULL
+WHERE t.owner_id = $1
+GROUP BY t.id
+ORDER BY lower(t.name)
+`
+
+type ListTagsRow struct {
+	ID        pgtype.UUID
+	Name      string
+	CreatedAt pgtype.Timestamptz
+	CardCount int64
+}
+
+func (q *Queries) ListTags(ctx context.Context, ownerID pgtype.UUID) ([]ListTagsRow, error) {
+	rows, err := q.db.Query(ctx, listTags, ownerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListTagsRow
+	for rows.Next() {
+		var i ListTagsRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.Name,
+			&i.CreatedAt,
+			&i.CardCount,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const renameTag = `-- name: RenameTag :one
+UPDATE tags
+SET name = $1
+WHERE id = $2 AND owner_id = $3
+RETURNING id, owner_id, name, created_at, updated_at
+`
+
+type RenameTagParams struct {
+	Name    string
+	ID      pgtype.UUID
+	OwnerID pgtype.UUID
+}
+
+func (q *Queries) RenameTag(ctx context.Context, arg RenameTagParams) (Tag, error) {
+	row := q.db.QueryRow(ctx, renameTag, arg.Name, arg.ID, arg.OwnerID)
+	var i Tag
+	err := row.Scan(
+		&i.ID,
+		&i.OwnerID,
+		&i.Name,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+	)
+	return i, err
+}
+
+const tagCards = `-- name: TagCards :execrows
+INSERT INTO card_tags (card_id, tag_id)
+SELECT c.id, t.id
+FROM cards c
+CROSS JOIN tags t
+WHERE c.id = ANY($1::uuid[])
+  AND t.id = ANY($2::uuid[])
+  AND t.owner_id = $3::uuid
+  AND c.deleted_at IS NULL
+  AND pack_visible(c.pack_id, $3::uuid)
+ON CONFLICT DO NOTHING
+`
+
+type TagCardsParams struct {
+	CardIds []pgtype.UUID
+	TagIds  []pgtype.UUID
+	UserID  pgtype.UUID
+}
+
+// Only the caller's tags and cards from packs they can see are linked.
+func (q *Queries) TagCards(ctx context.Context, arg TagCardsParams) (int64, error) {
+	result, err := q.db.Exec(ctx, tagCards, arg.CardIds, arg.TagIds, arg.UserID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const untagCards = `-- name: UntagCards :execrows
+DELETE FROM card_tags ct
+USING tags t
+WHERE ct.tag_id = t.id
+  AND t.owner_id = $1::uuid
+  AND ct.tag_id = ANY($2::uuid[])
+  AND ct.card_id = ANY($3::uuid[])
+`
+
+type UntagCardsParams struct {
+	UserID  pgtype.UUID
+	TagIds  []pgtype.UUID
+	CardIds []pgtype.UUID
+}
+
+func (q *Queries) UntagCards(ctx context.Context, arg UntagCardsParams) (int64, error) {
+	result, err := q.db.Exec(ctx, untagCards, arg.UserID, arg.TagIds, arg.CardIds)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
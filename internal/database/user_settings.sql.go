@@ -0,0 +1,114 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.26.0
+// source: user_settings.sql
+
+package database
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const getUserSettings = `-- name: GetUserSettings :one
+SELECT COALESCE(us.new_cards_per_day, 20)::int AS new_cards_per_day,
+       COALESCE(us.reviews_per_day, 200)::int  AS reviews_per_day,
+       COALESCE(us.timezone, 'UTC')::text      AS timezone,
+       COALESCE(us.day_rollover_hour, 0)::int  AS day_rollover_hour,
+       COALESCE(us.goal_type, 'cards')::text   AS goal_type,
+       COALESCE(us.daily_goal, 20)::int        AS daily_goal,
+       COALESCE(us.streak_freezes_per_week, 1)::int AS streak_freezes_per_week,
+       COALESCE(us.leaderboard_opt_out, FALSE)::bool AS leaderboard_opt_out
+FROM users u
+LEFT JOIN user_settings us ON us.user_id = u.id
+WHERE u.id = $1
+`
+
+type GetUserSettingsRow struct {
+	NewCardsPerDay       int32
+	ReviewsPerDay        int32
+	Timezone             string
+	DayRolloverHour      int32
+	GoalType             string
+	DailyGoal            int32
+	StreakFreezesPerWeek int32
+	LeaderboardOptOut    bool
+}
+
+// Falls back to the column defaults for users who never saved settings.
+func (q *Queries) GetUserSettings(ctx context.Context, id pgtype.UUID) (GetUserSettingsRow, error) {
+	row := q.db.QueryRow(ctx, getUserSettings, id)
+	var i GetUserSettingsRow
+	err := row.Scan(
+		&i.NewCardsPerDay,
+		&i.ReviewsPerDay,
+		&i.Timezone,
+		&i.DayRolloverHour,
+		&i.GoalType,
+		&i.DailyGoal,
+		&i.StreakFreezesPerWeek,
+		&i.LeaderboardOptOut,
+	)
+	return i, err
+}
+
+const upsertUserSettings = `-- name: UpsertUserSettings :one
+INSERT INTO user_settings (user_id, new_cards_per_day, reviews_per_day, timezone,
+                           day_rollover_hour, goal_type, daily_goal, streak_freezes_per_week,
+                           leaderboard_opt_out)
+VALUES ($1, $2, $3, $4,
+        $5, $6, $7, $8,
+        $9)
+ON CONFLICT (user_id) DO UPDATE
+SET new_cards_per_day = EXCLUDED.new_cards_per_day,
+    reviews_per_day   = EXCLUDED.reviews_per_day,
+    timezone          = EXCLUDED.timezone,
+    day_rollover_hour = EXCLUDED.day_rollover_hour,
+    goal_type         = EXCLUDED.goal_type,
+    daily_goal        = EXCLUDED.daily_goal,
+    streak_freezes_per_week = EXCLUDED.streak_freezes_per_week,
+    leaderboard_opt_out = EXCLUDED.leaderboard_opt_out
+RETURNING user_id, new_cards_per_day, reviews_per_day, created_at, updated_at, timezone, day_rollover_hour, goal_type, daily_goal, streak_freezes_per_week, leaderboard_opt_out
+`
+
+type UpsertUserSettingsParams struct {
+	UserID               pgtype.UUID
+	NewCardsPerDay       int32
+	ReviewsPerDay        int32
+	Timezone             string
+	DayRolloverHour      int32
+	GoalType             string
+	DailyGoal            int32
+	StreakFreezesPerWeek int32
+	LeaderboardOptOut    bool
+}
+
+func (q *Queries) UpsertUserSettings(ctx context.Context, arg UpsertUserSettingsParams) (UserSetting, error) {
+	row := q.db.QueryRow(ctx, upsertUserSettings,
+		arg.UserID,
+		arg.NewCardsPerDay,
+		arg.ReviewsPerDay,
+		arg.Timezone,
+		arg.DayRolloverHour,
+		arg.GoalType,
+		arg.DailyGoal,
+		arg.StreakFreezesPerWeek,
+		arg.LeaderboardOptOut,
+	)
+	var i UserSetting
+	err := row.Scan(
+		&i.UserID,
+		&i.NewCardsPerDay,
+		&i.ReviewsPerDay,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Timezone,
+		&i.DayRolloverHour,
+		&i.GoalType,
+		&i.DailyGoal,
+		&i.StreakFreezesPerWeek,
+		&i.LeaderboardOptOut,
+	)
+	return i, err
+}
@@ -12,18 +12,23 @@ import (
 )
 
 const addUserRating = `-- name: AddUserRating :exec
-UPDATE user_stats
-SET rating = rating + $1
-WHERE user_id = $2
+WITH event AS (
+    INSERT INTO rating_events (user_id, delta)
+    VALUES ($2, $1::int)
+)
+UPDATE user_stats us
+SET rating = us.rating + $1::int
+WHERE us.user_id = $2
 `
 
 type AddUserRatingParams struct {
-	Rating pgtype.Int4
+	Delta  int32
 	UserID pgtype.UUID
 }
 
+// Logs the change for the leaderboards as well.
 func (q *Queries) AddUserRating(ctx context.Context, arg AddUserRatingParams) error {
-	_, err := q.db.Exec(ctx, addUserRating, arg.Rating, arg.UserID)
+	_, err := q.db.Exec(ctx, addUserRating, arg.Delta, arg.UserID)
 	return err
 }
 
@@ -38,17 +43,21 @@ func (q *Queries) CreateUserStats(ctx context.Context, userID pgtype.UUID) error
 }
 
 const getUserStats = `-- name: GetUserStats :one
-SELECT rating, packs_created, packs_mastered
-FROM user_stats
-WHERE user_id = $1
+SELECT COALESCE(us.rating, 0)::int AS rating,
+       (SELECT COUNT(*) FROM packs p WHERE p.owner_id = $1 AND p.deleted_at IS NULL)::int AS packs_created,
+       (SELECT COUNT(*) FROM pack_masteries pm WHERE pm.user_id = $1)::int AS packs_mastered
+FROM users u
+LEFT JOIN user_stats us ON us.user_id = u.id
+WHERE u.id = $1
 `
 
 type GetUserStatsRow struct {
-	Rating        pgtype.Int4
-	PacksCreated  pgtype.Int4
-	PacksMastered pgtype.Int4
+	Rating        int32
+	PacksCreated  int32
+	PacksMastered int32
 }
 
+// Packs created are the packs the user owns, forks included.
 func (q *Queries) GetUserStats(ctx context.Context, userID pgtype.UUID) (GetUserStatsRow, error) {
 	row := q.db.QueryRow(ctx, getUserStats, userID)
 	var i GetUserStatsRow
@@ -56,24 +65,21 @@ func (q *Queries) GetUserStats(ctx context.Context, userID pgtype.UUID) (GetUser
 	return i, err
 }
 
-const incPacksCreated = `-- name: IncPacksCreated :exec
-UPDATE user_stats
-SET packs_created = packs_created + 1
-WHERE user_id = $1
+const markPackMastered = `-- name: MarkPackMastered :exec
+INSERT INTO pack_masteries (user_id, pack_id, card_count)
+SELECT $1, $2, COUNT(*)
+FROM cards c
+WHERE c.pack_id = $2 AND c.deleted_at IS NULL
+ON CONFLICT DO NOTHING
 `
 
-func (q *Queries) IncPacksCreated(ctx context.Context, userID pgtype.UUID) error {
-	_, err := q.db.Exec(ctx, incPacksCreated, userID)
-	return err
+type MarkPackMasteredParams struct {
+	UserID pgtype.UUID
+	PackID pgtype.UUID
 }
 
-const incPacksMastered = `-- name: IncPacksMastered :exec
-UPDATE user_stats
-SET packs_mastered = packs_mastered + 1
-WHERE user_id = $1
-`
-
-func (q *Queries) IncPacksMastered(ctx context.Context, userID pgtype.UUID) error {
-	_, err := q.db.Exec(ctx, incPacksMastered, userID)
+// Keeps the size of the pack at the time it was first mastered.
+func (q *Queries) MarkPackMastered(ctx context.Context, arg MarkPackMasteredParams) error {
+	_, err := q.db.Exec(ctx, markPackMastered, arg.UserID, arg.PackID)
 	return err
 }
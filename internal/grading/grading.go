@@ -0,0 +1,256 @@
+// Package grading checks a typed answer against the answer stored on a card.
+// Both sides are normalized (case, whitespace, diacritics, Cyrillic ё/е) and
+// a few typos are forgiven depending on the length of the answer.
+package grading
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// Separator splits a card answer into alternatives that are all accepted,
+// e.g. "colour | color".
+const Separator = "|"
+
+// Diff operations. Missing text is in the expected answer but was not
+// typed, extra text was typed but is not expected.
+const (
+	OpEqual   = "equal"
+	OpMissing = "missing"
+	OpExtra   = "extra"
+)
+
+const (
+	// Typed answers may always be this long, and twice as long as the
+	// longest alternative otherwise. Longer ones are not graded.
+	minTypedLimit = 256
+	// The diff aligns the two sides in a matrix of at most this many cells
+	// and gives up on a finer diff beyond.
+	maxDiffCells = 1 << 20
+)
+
+// Segment is a run of characters sharing the same diff operation.
+type Segment struct {
+	Op   string `json:"op"`
+	Text string `json:"text"`
+}
+
+// Result is the outcome of grading one answer.
+type Result struct {
+	Correct  bool      `json:"correct"`
+	Exact    bool      `json:"exact"`
+	Expected string    `json:"expected"`
+	Distance int       `json:"distance"`
+	Diff     []Segment `json:"diff"`
+}
+
+// Accepted returns the alternatives of a card answer.
+func Accepted(answer string) []string {
+	var out []string
+	for _, alt := range strings.Split(answer, Separator) {
+		if alt = collapse(alt); alt != "" {
+			out = append(out, alt)
+		}
+	}
+	return out
+}
+
+// TypedLimit is the longest typed answer, in characters, that is graded
+// against answer.
+func TypedLimit(answer string) int {
+	longest := 0
+	for _, alt := range Accepted(answer) {
+		longest = max(longest, utf8.RuneCountInString(alt))
+	}
+	return max(2*longest, minTypedLimit)
+}
+
+// TooLong reports whether typed is too long to be graded against answer.
+func TooLong(answer, typed string) bool {
+	return utf8.RuneCountInString(typed) > TypedLimit(answer)
+}
+
+// Tolerance is the number of edits forgiven in an answer of n characters.
+func Tolerance(n int) int {
+	switch {
+	case n <= 3:
+		return 0
+	case n <= 7:
+		return 1
+	case n <= 14:
+		return 2
+	default:
+		return 3
+	}
+}
+
+// Normalize folds s to the form answers are compared in.
+func Normalize(s string) string {
+	return string(fold([]rune(collapse(s))))
+}
+
+// Distance is the number of edits between the normalized forms of a and b.
+func Distance(a, b string) int {
+	return levenshtein([]rune(Normalize(a)), []rune(Normalize(b)))
+}
+
+// Grade compares typed with every accepted alternative of answer and
+// reports the closest one. Callers check TooLong first.
+func Grade(answer, typed string) Result {
+	typedRunes := []rune(collapse(typed))
+	typedFold := fold(typedRunes)
+
+	var best Result
+	var bestRunes []rune
+	bestDist := -1
+	for _, alt := range Accepted(answer) {
+		altRunes := []rune(alt)
+		dist := levenshtein(fold(altRunes), typedFold)
+		if bestDist >= 0 && dist >= bestDist {
+			continue
+		}
+		bestDist, bestRunes = dist, altRunes
+		best = Result{
+			Correct:  len(typedFold) > 0 && dist <= Tolerance(len(altRunes)),
+			Exact:    dist == 0,
+			Expected: alt,
+			Distance: dist,
+		}
+	}
+	if bestDist < 0 {
+		best = Result{Distance: len(typedRunes)}
+	}
+	best.Diff = diff(bestRunes, typedRunes)
+	return best
+}
+
+// collapse trims s and squeezes runs of whitespace into a single space.
+func collapse(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
+
+func fold(rs []rune) []rune {
+	out := make([]rune, len(rs))
+	for i, r := range rs {
+		out[i] = foldRune(r)
+	}
+	return out
+}
+
+// foldRune maps one character to its comparison form. The mapping is one to
+// one so the diff can be shown on the original text.
+func foldRune(r rune) rune {
+	r = unicode.ToLower(r)
+	switch r {
+	case 'ё':
+		return 'е'
+	case 'й':
+		// the breve distinguishes a separate Cyrillic letter
+		return r
+	}
+	if r < unicode.MaxASCII {
+		return r
+	}
+	decomposed := []rune(norm.NFD.String(string(r)))
+	for _, m := range decomposed[1:] {
+		if !unicode.Is(unicode.Mn, m) {
+			return r
+		}
+	}
+	return decomposed[0]
+}
+
+func levenshtein(a, b []rune) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)]
+}
+
+// diff aligns typed against expected and groups the edits into segments.
+// A substituted character shows up as an extra one followed by the missing
+// one. Sides too long to align are reported as replaced as a whole.
+func diff(expected, typed []rune) []Segment {
+	a, b := fold(expected), fold(typed)
+	if (len(a)+1)*(len(b)+1) > maxDiffCells {
+		var segments []Segment
+		switch {
+		case string(a) == string(b):
+			return []Segment{{Op: OpEqual, Text: string(typed)}}
+		case len(b) > 0:
+			segments = append(segments, Segment{Op: OpExtra, Text: string(typed)})
+		}
+		if len(a) > 0 {
+			segments = append(segments, Segment{Op: OpMissing, Text: string(expected)})
+		}
+		return segments
+	}
+	d := make([][]int, len(a)+1)
+	for i := range d {
+		d[i] = make([]int, len(b)+1)
+		d[i][0] = i
+	}
+	for j := range d[0] {
+		d[0][j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			d[i][j] = min(d[i-1][j]+1, d[i][j-1]+1, d[i-1][j-1]+cost)
+		}
+	}
+
+	// walk back from the end, collecting operations in reverse
+	type step struct {
+		op string
+		r  rune
+	}
+	var steps []step
+	i, j := len(a), len(b)
+	for i > 0 || j > 0 {
+		switch {
+		case i > 0 && j > 0 && a[i-1] == b[j-1] && d[i][j] == d[i-1][j-1]:
+			steps = append(steps, step{OpEqual, typed[j-1]})
+			i, j = i-1, j-1
+		case i > 0 && j > 0 && d[i][j] == d[i-1][j-1]+1:
+			steps = append(steps, step{OpMissing, expected[i-1]}, step{OpExtra, typed[j-1]})
+			i, j = i-1, j-1
+		case j > 0 && d[i][j] == d[i][j-1]+1:
+			steps = append(steps, step{OpExtra, typed[j-1]})
+			j--
+		default:
+			steps = append(steps, step{OpMissing, expected[i-1]})
+			i--
+		}
+	}
+
+	segments := []Segment{}
+	for k := len(steps) - 1; k >= 0; k-- {
+		st := steps[k]
+		if n := len(segments); n > 0 && segments[n-1].Op == st.op {
+			segments[n-1].Text += string(st.r)
+			continue
+		}
+		segments = append(segments, Segment{Op: st.op, Text: string(st.r)})
+	}
+	return segments
+}
@@ -0,0 +1,90 @@
+package media
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"os"
+	"strings"
+	"testing"
+)
+
+func keyOf(content string) string {
+	sum := sha256.Sum256([]byte(content))
+	return hex.EncodeToString(sum[:])
+}
+
+// testStore runs the BlobStore contract against s.
+func testStore(t *testing.T, s BlobStore) {
+	t.Helper()
+	ctx := context.Background()
+	content := "some blob"
+	key := keyOf(content)
+
+	if _, err := s.Open(ctx, key); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Open of a missing blob: err = %v, want ErrNotFound", err)
+	}
+	for i := 0; i < 2; i++ {
+		if err := s.Put(ctx, key, strings.NewReader(content), int64(len(content)), "image/png"); err != nil {
+			t.Fatalf("Put #%d: %v", i+1, err)
+		}
+	}
+
+	rc, err := s.Open(ctx, key)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	got, err := io.ReadAll(rc)
+	rc.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != content {
+		t.Errorf("Open = %q, want %q", got, content)
+	}
+
+	if err := s.Delete(ctx, key); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if _, err := s.Open(ctx, key); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Open after Delete: err = %v, want ErrNotFound", err)
+	}
+	if err := s.Delete(ctx, key); err != nil {
+		t.Errorf("Delete of a missing blob: %v", err)
+	}
+}
+
+func TestLocalStore(t *testing.T) {
+	s, err := NewLocalStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	testStore(t, s)
+}
+
+func TestLocalStoreKeepsExisting(t *testing.T) {
+	s, err := NewLocalStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	key := keyOf("a")
+	if err := s.Put(ctx, key, strings.NewReader("a"), 1, "image/png"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Put(ctx, key, strings.NewReader("b"), 1, "image/png"); err != nil {
+		t.Fatal(err)
+	}
+	got, err := os.ReadFile(s.path(key))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != "a" {
+		t.Errorf("second Put replaced the blob: %q", got)
+	}
+	if !strings.HasPrefix(s.path(key), s.Root) {
+		t.Errorf("path %q outside the root %q", s.path(key), s.Root)
+	}
+}
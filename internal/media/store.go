@@ -0,0 +1,20 @@
+// Package media stores card attachments. Blobs are content addressed: the
+// key of a blob is the hex SHA-256 of its bytes, so uploading the same file
+// twice stores it once.
+package media
+
+import (
+	"context"
+	"errors"
+	"io"
+)
+
+var ErrNotFound = errors.New("blob not found")
+
+// BlobStore keeps blobs by key. Put of an existing key is a no-op as far as
+// the caller can tell.
+type BlobStore interface {
+	Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error
+	Open(ctx context.Context, key string) (io.ReadCloser, error)
+	Delete(ctx context.Context, key string) error
+}
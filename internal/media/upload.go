@@ -0,0 +1,120 @@
+package media
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"regexp"
+)
+
+// Kinds of attachments.
+const (
+	KindImage = "image"
+	KindAudio = "audio"
+)
+
+// Size limits per kind.
+const (
+	MaxImageBytes = 5 << 20
+	MaxAudioBytes = 20 << 20
+	MaxBytes      = MaxAudioBytes
+)
+
+var (
+	ErrTooLarge    = errors.New("file is too large")
+	ErrUnsupported = errors.New("unsupported file type")
+)
+
+var keyPattern = regexp.MustCompile(`^[0-9a-f]{64}$`)
+
+// ValidKey reports whether key looks like a blob key.
+func ValidKey(key string) bool {
+	return keyPattern.MatchString(key)
+}
+
+// sniffed maps what http.DetectContentType reports to the type we serve.
+var sniffed = map[string]struct{ contentType, kind string }{
+	"image/png":       {"image/png", KindImage},
+	"image/jpeg":      {"image/jpeg", KindImage},
+	"image/gif":       {"image/gif", KindImage},
+	"image/webp":      {"image/webp", KindImage},
+	"audio/mpeg":      {"audio/mpeg", KindAudio},
+	"application/ogg": {"audio/ogg", KindAudio},
+	"audio/wave":      {"audio/wav", KindAudio},
+	"video/mp4":       {"audio/mp4", KindAudio},
+}
+
+// Detect sniffs the type of a file from its first bytes. The name and the
+// type the client claims are not trusted.
+func Detect(head []byte) (contentType, kind string, err error) {
+	t, ok := sniffed[http.DetectContentType(head)]
+	if !ok {
+		return "", "", ErrUnsupported
+	}
+	return t.contentType, t.kind, nil
+}
+
+// Limit is the size limit for a kind.
+func Limit(kind string) int64 {
+	if kind == KindImage {
+		return MaxImageBytes
+	}
+	return MaxAudioBytes
+}
+
+// Upload is a file spooled to a temporary file while being hashed.
+type Upload struct {
+	Key         string
+	Size        int64
+	ContentType string
+	Kind        string
+	file        *os.File
+}
+
+// Spool copies r to a temporary file, at most MaxBytes of it, and sniffs
+// its type. The caller must Close the upload.
+func Spool(r io.Reader) (*Upload, error) {
+	f, err := os.CreateTemp("", "dailycards-upload-*")
+	if err != nil {
+		return nil, err
+	}
+	u := &Upload{file: f}
+
+	h := sha256.New()
+	n, err := io.Copy(io.MultiWriter(f, h), io.LimitReader(r, MaxBytes+1))
+	if err != nil {
+		u.Close()
+		return nil, err
+	}
+	if n > MaxBytes {
+		u.Close()
+		return nil, ErrTooLarge
+	}
+	u.Key = hex.EncodeToString(h.Sum(nil))
+	u.Size = n
+
+	head := make([]byte, 512)
+	m, _ := f.ReadAt(head, 0)
+	if u.ContentType, u.Kind, err = Detect(head[:m]); err != nil {
+		u.Close()
+		return nil, err
+	}
+	if n > Limit(u.Kind) {
+		u.Close()
+		return nil, ErrTooLarge
+	}
+	return u, nil
+}
+
+// Reader reads the spooled file from the start.
+func (u *Upload) Reader() io.Reader {
+	return io.NewSectionReader(u.file, 0, u.Size)
+}
+
+func (u *Upload) Close() error {
+	u.file.Close()
+	return os.Remove(u.file.Name())
+}
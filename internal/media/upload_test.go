@@ -0,0 +1,117 @@
+package media
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"testing"
+)
+
+var pngHead = []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR")
+
+func TestDetect(t *testing.T) {
+	tests := []struct {
+		name      string
+		head      []byte
+		wantType  string
+		wantKind  string
+		wantError error
+	}{
+		{"png", pngHead, "image/png", KindImage, nil},
+		{"jpeg", []byte("\xff\xd8\xff\xe0\x00\x10JFIF"), "image/jpeg", KindImage, nil},
+		{"gif", []byte("GIF89a"), "image/gif", KindImage, nil},
+		{"mp3", []byte("ID3\x03\x00\x00\x00"), "audio/mpeg", KindAudio, nil},
+		{"ogg", []byte("OggS\x00\x02"), "audio/ogg", KindAudio, nil},
+		{"wav", []byte("RIFF\x24\x00\x00\x00WAVEfmt "), "audio/wav", KindAudio, nil},
+		{"html", []byte("<html><script>alert(1)</script>"), "", "", ErrUnsupported},
+		{"svg", []byte(`<svg xmlns="http://www.w3.org/2000/svg"></svg>`), "", "", ErrUnsupported},
+		{"text", []byte("hello"), "", "", ErrUnsupported},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ct, kind, err := Detect(tt.head)
+			if !errors.Is(err, tt.wantError) {
+				t.Fatalf("Detect error = %v, want %v", err, tt.wantError)
+			}
+			if ct != tt.wantType || kind != tt.wantKind {
+				t.Errorf("Detect = %q, %q, want %q, %q", ct, kind, tt.wantType, tt.wantKind)
+			}
+		})
+	}
+}
+
+func TestSpool(t *testing.T) {
+	content := append(append([]byte{}, pngHead...), "rest of the image"...)
+	up, err := Spool(bytes.NewReader(content))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer up.Close()
+
+	sum := sha256.Sum256(content)
+	if up.Key != hex.EncodeToString(sum[:]) || !ValidKey(up.Key) {
+		t.Errorf("key = %q, want the SHA-256 of the content", up.Key)
+	}
+	if up.Size != int64(len(content)) || up.ContentType != "image/png" || up.Kind != KindImage {
+		t.Errorf("upload = %d bytes %s %s", up.Size, up.ContentType, up.Kind)
+	}
+	got, err := io.ReadAll(up.Reader())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, content) {
+		t.Errorf("spooled content differs")
+	}
+}
+
+func TestSpoolLimits(t *testing.T) {
+	padded := func(head []byte, size int64) io.Reader {
+		return io.MultiReader(bytes.NewReader(head), io.LimitReader(zeros{}, size-int64(len(head))))
+	}
+	mp3 := []byte("ID3\x03\x00\x00\x00")
+	tests := []struct {
+		name    string
+		r       io.Reader
+		wantErr error
+	}{
+		{"image at the limit", padded(pngHead, MaxImageBytes), nil},
+		{"image over the limit", padded(pngHead, MaxImageBytes+1), ErrTooLarge},
+		{"audio over the image limit", padded(mp3, MaxImageBytes+1), nil},
+		{"audio over the limit", padded(mp3, MaxAudioBytes+1), ErrTooLarge},
+		{"unsupported", padded([]byte("plain text"), 100), ErrUnsupported},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			up, err := Spool(tt.r)
+			if err == nil {
+				up.Close()
+			}
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("Spool error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidKey(t *testing.T) {
+	sum := sha256.Sum256([]byte("x"))
+	for key, want := range map[string]bool{
+		hex.EncodeToString(sum[:]): true,
+		"../../etc/passwd":         false,
+		"":                         false,
+		"ABCDEF" + hex.EncodeToString(sum[:])[6:]: false,
+	} {
+		if got := ValidKey(key); got != want {
+			t.Errorf("ValidKey(%q) = %v, want %v", key, got, want)
+		}
+	}
+}
+
+type zeros struct{}
+
+func (zeros) Read(p []byte) (int, error) {
+	clear(p)
+	return len(p), nil
+}
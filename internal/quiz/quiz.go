@@ -0,0 +1,143 @@
+// Package quiz builds multiple-choice questions out of the cards of a pack
+// or tag. The wrong options are answers of other cards in the same set, so
+// no extra content has to be written for a quiz.
+package quiz
+
+import (
+	"math/rand/v2"
+	"slices"
+	"strings"
+	"unicode/utf8"
+
+	"dailycards/internal/grading"
+)
+
+const (
+	DefaultOptions = 4
+	MinOptions     = 2
+	MaxOptions     = 6
+)
+
+// Item is one prompt a question can be made of. Distractors are only taken
+// from items of the same Group, so reverse prompts are answered with other
+// questions rather than with answers.
+type Item struct {
+	Key      string
+	Group    string
+	Question string
+	Answer   string
+}
+
+// Question is a generated multiple-choice question. Options[Correct] is the
+// answer of the item. Answers with alternatives are shown by their first
+// one.
+type Question struct {
+	Item
+	Options []string
+	Correct int
+}
+
+// NewRand returns the generator Build expects. The same seed over the same
+// items always yields the same quiz.
+func NewRand(seed uint64) *rand.Rand {
+	return rand.New(rand.NewPCG(seed, seed^0x9e3779b97f4a7c15))
+}
+
+// Build picks up to n items in random order and gives each of them up to
+// options choices. An item is skipped when no distinct distractor exists
+// for it.
+func Build(items []Item, n, options int, rng *rand.Rand) []Question {
+	options = min(max(options, MinOptions), MaxOptions)
+
+	// the caller's order depends on review history, sort for reproducibility
+	items = slices.Clone(items)
+	slices.SortFunc(items, func(a, b Item) int { return strings.Compare(a.Key, b.Key) })
+
+	pools := map[string][]string{}
+	for _, it := range items {
+		pools[it.Group] = append(pools[it.Group], it.Answer)
+	}
+	for g, pool := range pools {
+		pools[g] = distinct(pool)
+	}
+
+	rng.Shuffle(len(items), func(i, j int) { items[i], items[j] = items[j], items[i] })
+
+	questions := []Question{}
+	for _, it := range items {
+		if len(questions) == n {
+			break
+		}
+		wrong := distractors(it.Answer, pools[it.Group], options-1, rng)
+		if len(wrong) == 0 {
+			continue
+		}
+		opts := append(wrong, it.Answer)
+		rng.Shuffle(len(opts), func(i, j int) { opts[i], opts[j] = opts[j], opts[i] })
+		q := Question{Item: it, Correct: slices.Index(opts, it.Answer)}
+		for _, o := range opts {
+			q.Options = append(q.Options, display(o))
+		}
+		questions = append(questions, q)
+	}
+	return questions
+}
+
+// distinct drops answers that normalize to one already seen.
+func distinct(answers []string) []string {
+	seen := map[string]bool{}
+	out := []string{}
+	for _, a := range answers {
+		key := grading.Normalize(a)
+		if key == "" || seen[key] {
+			continue
+		}
+		seen[key] = true
+		out = append(out, a)
+	}
+	return out
+}
+
+// distractors takes up to k random answers from pool that would not be
+// graded as the correct one, nor as each other.
+func distractors(answer string, pool []string, k int, rng *rand.Rand) []string {
+	candidates := slices.Clone(pool)
+	rng.Shuffle(len(candidates), func(i, j int) { candidates[i], candidates[j] = candidates[j], candidates[i] })
+
+	picked := []string{}
+	for _, cand := range candidates {
+		if len(picked) == k {
+			break
+		}
+		if nearDuplicate(answer, cand) {
+			continue
+		}
+		if slices.ContainsFunc(picked, func(p string) bool { return nearDuplicate(p, cand) }) {
+			continue
+		}
+		picked = append(picked, cand)
+	}
+	return picked
+}
+
+// nearDuplicate reports whether any alternative of b would be accepted as a
+// typed answer for a, or the other way round. That is the case when they
+// are within the tolerance of the longer one.
+func nearDuplicate(a, b string) bool {
+	for _, x := range grading.Accepted(a) {
+		for _, y := range grading.Accepted(b) {
+			n := max(utf8.RuneCountInString(x), utf8.RuneCountInString(y))
+			if grading.Distance(x, y) <= grading.Tolerance(n) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func display(answer string) string {
+	if alts := grading.Accepted(answer); len(alts) > 0 {
+		return alts[0]
+	}
+	return answer
+}
@@ -0,0 +1,169 @@
+package quiz
+
+import (
+	"reflect"
+	"strconv"
+	"testing"
+
+	"dailycards/internal/grading"
+)
+
+func testItems() []Item {
+	answers := []string{"apple", "pear", "plum", "cherry", "grape", "lemon", "melon", "peach"}
+	items := make([]Item, len(answers))
+	for i, a := range answers {
+		items[i] = Item{Key: strconv.Itoa(i), Group: "forward", Question: "q" + strconv.Itoa(i), Answer: a}
+	}
+	return items
+}
+
+func TestBuildSameSeedSameQuiz(t *testing.T) {
+	items := testItems()
+	a := Build(items, 5, 4, NewRand(42))
+	b := Build(items, 5, 4, NewRand(42))
+	if !reflect.DeepEqual(a, b) {
+		t.Fatalf("same seed gave different quizzes:\n%v\n%v", a, b)
+	}
+
+	// the order items come in must not matter
+	reversed := make([]Item, len(items))
+	for i, it := range items {
+		reversed[len(items)-1-i] = it
+	}
+	if c := Build(reversed, 5, 4, NewRand(42)); !reflect.DeepEqual(a, c) {
+		t.Fatalf("item order changed the quiz:\n%v\n%v", a, c)
+	}
+
+	if d := Build(items, 5, 4, NewRand(43)); reflect.DeepEqual(a, d) {
+		t.Errorf("different seeds gave the same quiz")
+	}
+}
+
+func TestBuildOptions(t *testing.T) {
+	for _, q := range Build(testItems(), 8, 4, NewRand(1)) {
+		if len(q.Options) != 4 {
+			t.Errorf("%s: %d options, want 4", q.Key, len(q.Options))
+		}
+		if q.Options[q.Correct] != q.Answer {
+			t.Errorf("%s: option %d is %q, want the answer %q", q.Key, q.Correct, q.Options[q.Correct], q.Answer)
+		}
+		seen := map[string]bool{}
+		for _, o := range q.Options {
+			if seen[o] {
+				t.Errorf("%s: option %q twice", q.Key, o)
+			}
+			seen[o] = true
+		}
+	}
+}
+
+func TestBuildClampsOptions(t *testing.T) {
+	for _, tt := range []struct{ options, want int }{{0, MinOptions}, {1, MinOptions}, {100, MaxOptions}} {
+		for _, q := range Build(testItems(), 1, tt.options, NewRand(1)) {
+			if len(q.Options) != tt.want {
+				t.Errorf("options=%d: got %d options, want %d", tt.options, len(q.Options), tt.want)
+			}
+		}
+	}
+}
+
+func TestBuildMoreQuestionsThanCards(t *testing.T) {
+	items := testItems()
+	questions := Build(items, 100, 4, NewRand(7))
+	if len(questions) != len(items) {
+		t.Fatalf("got %d questions, want one per card (%d)", len(questions), len(items))
+	}
+	seen := map[string]bool{}
+	for _, q := range questions {
+		if seen[q.Key] {
+			t.Errorf("card %s asked twice", q.Key)
+		}
+		seen[q.Key] = true
+	}
+}
+
+func TestBuildNoNearDuplicateDistractors(t *testing.T) {
+	items := []Item{
+		{Key: "1", Group: "forward", Answer: "colour | color"},
+		{Key: "2", Group: "forward", Answer: "Color"},
+		{Key: "3", Group: "forward", Answer: "colours"},
+		{Key: "4", Group: "forward", Answer: "ёлка"},
+		{Key: "5", Group: "forward", Answer: "елка"},
+		{Key: "6", Group: "forward", Answer: "house"},
+		{Key: "7", Group: "forward", Answer: "garden"},
+	}
+	for seed := uint64(0); seed < 20; seed++ {
+		for _, q := range Build(items, len(items), MaxOptions, NewRand(seed)) {
+			for i, o := range q.Options {
+				if i != q.Correct && nearDuplicate(q.Answer, o) {
+					t.Errorf("seed %d, %q: distractor %q would be accepted", seed, q.Answer, o)
+				}
+				for _, p := range q.Options[i+1:] {
+					if nearDuplicate(o, p) {
+						t.Errorf("seed %d, %q: options %q and %q are near duplicates", seed, q.Answer, o, p)
+					}
+				}
+			}
+		}
+	}
+}
+
+func TestNearDuplicate(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want bool
+	}{
+		{"apple", "apple", true},
+		{"apple", "aple", true},
+		{"Café", "cafe", true},
+		{"  big   dog ", "Big Dog", true},
+		{"abcd", "abc", true}, // accepted one way only
+		{"dog|hound", "Hound", true},
+		{"cat", "cut", false},
+		{"lemon", "melon", false},
+		{"abc", "abcdefgh", false},
+		{"ель", "ёль", true},
+		{"йод", "иод", false},
+	}
+	for _, tt := range tests {
+		if got := nearDuplicate(tt.a, tt.b); got != tt.want {
+			t.Errorf("nearDuplicate(%q, %q) = %v, want %v", tt.a, tt.b, got, tt.want)
+		}
+		if got := nearDuplicate(tt.b, tt.a); got != tt.want {
+			t.Errorf("nearDuplicate(%q, %q) = %v, want %v", tt.b, tt.a, got, tt.want)
+		}
+	}
+}
+
+// nearDuplicate must agree with grading a typed answer either way.
+func TestNearDuplicateMatchesGrade(t *testing.T) {
+	words := []string{"apple", "appel", "aple", "pear", "peer", "plum", "plume", "ab", "abc", "abcd",
+		"grapefruit", "grapefriut", "grape fruit", "Crème brûlée", "creme brulee", "ёлка", "елка"}
+	for _, a := range words {
+		for _, b := range words {
+			want := grading.Grade(a, b).Correct || grading.Grade(b, a).Correct
+			if got := nearDuplicate(a, b); got != want {
+				t.Errorf("nearDuplicate(%q, %q) = %v, grading says %v", a, b, got, want)
+			}
+		}
+	}
+}
+
+func TestBuildGroupsAndSkips(t *testing.T) {
+	items := []Item{
+		{Key: "1", Group: "forward", Answer: "one"},
+		{Key: "2", Group: "forward", Answer: "two"},
+		{Key: "3", Group: "reverse", Answer: "uno"},
+	}
+	questions := Build(items, 10, 4, NewRand(3))
+	if len(questions) != 2 {
+		t.Fatalf("got %d questions, want 2: the reverse prompt has no distractor", len(questions))
+	}
+	for _, q := range questions {
+		for _, o := range q.Options {
+			if o == "uno" {
+				t.Errorf("%s: distractor from another group", q.Key)
+			}
+		}
+	}
+}
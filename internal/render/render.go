@@ -0,0 +1,203 @@
+// Package render turns card text into HTML that is safe to insert into the
+// page. Markdown goes through goldmark and the result is sanitized with
+// bluemonday, so raw HTML in a card cannot inject scripts or handlers.
+package render
+
+import (
+	"bytes"
+	"html"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/microcosm-cc/bluemonday"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/extension"
+)
+
+// Content formats of a card.
+const (
+	Plain    = "plain"
+	Markdown = "markdown"
+)
+
+func ValidFormat(f string) bool {
+	return f == Plain || f == Markdown
+}
+
+// Options tune the markdown pass.
+type Options struct {
+	// Math leaves $...$ and $$...$$ untouched by markdown and wraps them
+	// into elements the client typesets (e.g. with KaTeX).
+	Math bool
+}
+
+var (
+	md = goldmark.New(goldmark.WithExtensions(
+		extension.Table,
+		extension.Strikethrough,
+		extension.Linkify,
+	))
+
+	policy = func() *bluemonday.Policy {
+		p := bluemonday.UGCPolicy()
+		p.AllowAttrs("class").Matching(regexp.MustCompile(`^language-[\w-]+$`)).OnElements("code")
+		p.RequireNoFollowOnLinks(true)
+		p.AddTargetBlankToFullyQualifiedLinks(true)
+		return p
+	}()
+)
+
+// HTML renders text written in format.
+func HTML(format, text string, opts Options) string {
+	if format != Markdown {
+		return strings.ReplaceAll(html.EscapeString(text), "\n", "<br>")
+	}
+
+	var formulas []formula
+	if opts.Math {
+		// nobody gets to forge a placeholder
+		text = strings.ReplaceAll(text, wordJoiner, "")
+		text, formulas = extractMath(text)
+	}
+
+	var buf bytes.Buffer
+	if err := md.Convert([]byte(text), &buf); err != nil {
+		return html.EscapeString(text)
+	}
+	out := policy.Sanitize(buf.String())
+	if len(formulas) > 0 {
+		out = restoreMath(out, formulas)
+	}
+	return out
+}
+
+// formula is a piece of math cut out of the text before markdown.
+type formula struct {
+	// Source is the formula as written, delimiters included.
+	Source string
+	HTML   string
+}
+
+const wordJoiner = "\u2060"
+
+var placeholderRe = regexp.MustCompile(wordJoiner + `MATH(\d+)` + wordJoiner)
+
+// placeholder survives markdown and sanitizing unchanged. U+2060 is
+// stripped from the input, so every placeholder is one of ours.
+func placeholder(i int) string {
+	return wordJoiner + "MATH" + strconv.Itoa(i) + wordJoiner
+}
+
+// restoreMath puts the formulas in place of their placeholders. Inside a
+// tag, such as in the title of a link, a formula is left as written: markup
+// has no place in an attribute. The sanitized HTML escapes < and > in
+// attribute values, so a tag is open when the last < comes after the last >.
+func restoreMath(out string, formulas []formula) string {
+	var b strings.Builder
+	last := 0
+	for _, m := range placeholderRe.FindAllStringSubmatchIndex(out, -1) {
+		i, err := strconv.Atoi(out[m[2]:m[3]])
+		if err != nil || i >= len(formulas) {
+			continue
+		}
+		b.WriteString(out[last:m[0]])
+		if strings.LastIndexByte(out[:m[0]], '<') > strings.LastIndexByte(out[:m[0]], '>') {
+			b.WriteString(html.EscapeString(formulas[i].Source))
+		} else {
+			b.WriteString(formulas[i].HTML)
+		}
+		last = m[1]
+	}
+	b.WriteString(out[last:])
+	return b.String()
+}
+
+// extractMath swaps formulas for placeholders and returns them already
+// rendered. Code spans and fenced blocks are copied verbatim, and an
+// escaped \$ is not a delimiter.
+func extractMath(text string) (string, []formula) {
+	var out strings.Builder
+	var formulas []formula
+	inFence := false
+
+	for _, line := range strings.SplitAfter(text, "\n") {
+		if strings.HasPrefix(strings.TrimSpace(line), "```") {
+			inFence = !inFence
+			out.WriteString(line)
+			continue
+		}
+		if inFence {
+			out.WriteString(line)
+			continue
+		}
+
+		for i := 0; i < len(line); {
+			switch {
+			case line[i] == '\\' && i+1 < len(line):
+				out.WriteString(line[i : i+2])
+				i += 2
+			case line[i] == '`':
+				end := strings.IndexByte(line[i+1:], '`')
+				if end < 0 {
+					out.WriteString(line[i:])
+					i = len(line)
+					break
+				}
+				out.WriteString(line[i : i+end+2])
+				i += end + 2
+			case strings.HasPrefix(line[i:], "$$"):
+				end := strings.Index(line[i+2:], "$$")
+				if end <= 0 {
+					out.WriteString("$$")
+					i += 2
+					break
+				}
+				tex := line[i+2 : i+2+end]
+				out.WriteString(placeholder(len(formulas)))
+				formulas = append(formulas, formula{
+					Source: line[i : i+end+4],
+					HTML:   `<span class="math math-display">\[` + html.EscapeString(tex) + `\]</span>`,
+				})
+				i += end + 4
+			case line[i] == '$':
+				end := strings.IndexByte(line[i+1:], '$')
+				tex := ""
+				if end > 0 {
+					tex = line[i+1 : i+1+end]
+				}
+				// "$5 and $6" is money, not a formula
+				if tex == "" || strings.TrimSpace(tex) != tex {
+					out.WriteByte('$')
+					i++
+					break
+				}
+				out.WriteString(placeholder(len(formulas)))
+				formulas = append(formulas, formula{
+					Source: line[i : i+end+2],
+					HTML:   `<span class="math math-inline">\(` + html.EscapeString(tex) + `\)</span>`,
+				})
+				i += end + 2
+			default:
+				out.WriteByte(line[i])
+				i++
+			}
+		}
+	}
+	return out.String(), formulas
+}
+
+var strict = bluemonday.StrictPolicy()
+
+// Text is what a reader sees of text written in format, without markup.
+// Typed answers are graded against it.
+func Text(format, text string) string {
+	if format != Markdown {
+		return text
+	}
+	var buf bytes.Buffer
+	if err := md.Convert([]byte(text), &buf); err != nil {
+		return text
+	}
+	return strings.TrimSpace(html.UnescapeString(strict.Sanitize(buf.String())))
+}
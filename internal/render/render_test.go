@@ -0,0 +1,155 @@
+package render
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestHTMLPlainIsEscaped(t *testing.T) {
+	got := HTML(Plain, "<b>a</b>\nb", Options{})
+	want := "&lt;b&gt;a&lt;/b&gt;<br>b"
+	if got != want {
+		t.Errorf("HTML(plain) = %q, want %q", got, want)
+	}
+}
+
+func TestHTMLSanitizes(t *testing.T) {
+	tests := []struct {
+		name, in, forbidden string
+	}{
+		{"script", "<script>alert(1)</script>", "<script"},
+		{"handler", `<img src="x.png" onerror="alert(1)">`, "onerror"},
+		{"javascript link", "[x](javascript:alert(1))", "javascript:"},
+		{"style", `<p style="color:red">x</p>`, "style="},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := HTML(Markdown, tt.in, Options{Math: true})
+			if strings.Contains(got, tt.forbidden) {
+				t.Errorf("HTML(%q) = %q, contains %q", tt.in, got, tt.forbidden)
+			}
+		})
+	}
+}
+
+func TestHTMLKeepsMarkdown(t *testing.T) {
+	got := HTML(Markdown, "**bold** and `code`\n\n[link](https://example.com)", Options{})
+	for _, want := range []string{"<strong>bold</strong>", "<code>code</code>", `href="https://example.com"`, `rel="nofollow noopener"`} {
+		if !strings.Contains(got, want) {
+			t.Errorf("HTML = %q, missing %q", got, want)
+		}
+	}
+}
+
+func TestHTMLMath(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want []string
+		not  []string
+	}{
+		{
+			name: "inline",
+			in:   "area $a_1 * b_2$ here",
+			want: []string{`<span class="math math-inline">\(a_1 * b_2\)</span>`},
+			not:  []string{"<em>"},
+		},
+		{
+			name: "display",
+			in:   "$$x < y$$",
+			want: []string{`<span class="math math-display">\[x &lt; y\]</span>`},
+		},
+		{
+			name: "money",
+			in:   "costs $5 and $6",
+			want: []string{"costs $5 and $6"},
+			not:  []string{"math"},
+		},
+		{
+			name: "escaped",
+			in:   `\$x$`,
+			not:  []string{"math"},
+		},
+		{
+			name: "code span",
+			in:   "`$x$`",
+			want: []string{"<code>$x$</code>"},
+			not:  []string{"math"},
+		},
+		{
+			name: "fenced code",
+			in:   "```\n$x$\n```",
+			want: []string{"$x$"},
+			not:  []string{"math"},
+		},
+		{
+			name: "in order",
+			in:   "$a$ then $b$",
+			want: []string{`\(a\)</span> then <span class="math math-inline">\(b\)`},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := HTML(Markdown, tt.in, Options{Math: true})
+			for _, w := range tt.want {
+				if !strings.Contains(got, w) {
+					t.Errorf("HTML(%q) = %q, missing %q", tt.in, got, w)
+				}
+			}
+			for _, n := range tt.not {
+				if strings.Contains(got, n) {
+					t.Errorf("HTML(%q) = %q, contains %q", tt.in, got, n)
+				}
+			}
+		})
+	}
+}
+
+func TestHTMLMathWithoutOption(t *testing.T) {
+	got := HTML(Markdown, "$x$", Options{})
+	if strings.Contains(got, "math") {
+		t.Errorf("HTML = %q, math rendered without the option", got)
+	}
+}
+
+func TestHTMLForgedPlaceholder(t *testing.T) {
+	in := placeholder(0) + " [t](https://example.com \"" + placeholder(0) + "\") $x$"
+	got := HTML(Markdown, in, Options{Math: true})
+	if strings.Contains(got, wordJoiner) {
+		t.Errorf("HTML = %q, placeholder left over", got)
+	}
+	if n := strings.Count(got, `class="math`); n != 1 {
+		t.Fatalf("HTML = %q, %d formulas, want 1", got, n)
+	}
+	if !strings.HasSuffix(strings.TrimSpace(got), `<span class="math math-inline">\(x\)</span></p>`) {
+		t.Errorf("HTML = %q, formula not at its place", got)
+	}
+}
+
+func TestHTMLMathInAttribute(t *testing.T) {
+	for _, in := range []string{
+		`![a $x$](https://example.com/a.png)`,
+		`<abbr title="$x$">a</abbr>`,
+	} {
+		got := HTML(Markdown, in, Options{Math: true})
+		if strings.Contains(got, "<span") || strings.Contains(got, wordJoiner) {
+			t.Errorf("HTML(%q) = %q, formula inside an attribute", in, got)
+		}
+	}
+}
+
+func TestText(t *testing.T) {
+	tests := []struct {
+		format, in, want string
+	}{
+		{Plain, "**a**", "**a**"},
+		{Markdown, "**a** _b_", "a b"},
+		{Markdown, "Tom &amp; Jerry", "Tom & Jerry"},
+		{Markdown, "[a](https://example.com) $x$", "a $x$"},
+	}
+	for _, tt := range tests {
+		if got := Text(tt.format, tt.in); got != tt.want {
+			t.Errorf("Text(%q, %q) = %q, want %q", tt.format, tt.in, got, tt.want)
+		}
+	}
+}
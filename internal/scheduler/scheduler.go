@@ -0,0 +1,84 @@
+// Package scheduler implements the spaced-repetition rules used to decide
+// when a card is shown again. It is a binary-grade variant of SM-2: the
+// client only tells whether the answer was correct.
+package scheduler
+
+import (
+	"math"
+	"time"
+)
+
+const (
+	DefaultEase = 2.5
+	MinEase     = 1.3
+
+	// failed cards come back within the same day
+	RelearnDelay = 10 * time.Minute
+)
+
+// State is the per-user scheduling state of one card.
+type State struct {
+	Reps         int
+	Lapses       int
+	IntervalDays int
+	Ease         float64
+}
+
+// New returns the state of a card the user has never reviewed.
+func New() State {
+	return State{Ease: DefaultEase}
+}
+
+// Review applies one answer to st and returns the new state together with
+// the moment the card becomes due again.
+func Review(st State, correct bool, now time.Time) (State, time.Time) {
+	if st.Ease == 0 {
+		st.Ease = DefaultEase
+	}
+
+	if !correct {
+		st.Reps = 0
+		st.Lapses++
+		st.IntervalDays = 0
+		st.Ease = math.Max(MinEase, st.Ease-0.2)
+		return st, now.Add(RelearnDelay)
+	}
+
+	st.Reps++
+	switch st.Reps {
+	case 1:
+		st.IntervalDays = 1
+	case 2:
+		st.IntervalDays = 6
+	default:
+		st.IntervalDays = int(math.Round(float64(st.IntervalDays) * st.Ease))
+	}
+	return st, now.AddDate(0, 0, st.IntervalDays)
+}
+
+// Interleave reorders items so that consecutive items come from different
+// groups where possible: it takes one item from each group in turn, keeping
+// the original order inside a group and the order of first appearance
+// between groups.
+func Interleave[T any, K comparable](items []T, group func(T) K) []T {
+	var keys []K
+	buckets := make(map[K][]T)
+	for _, it := range items {
+		k := group(it)
+		if _, ok := buckets[k]; !ok {
+			keys = append(keys, k)
+		}
+		buckets[k] = append(buckets[k], it)
+	}
+
+	out := make([]T, 0, len(items))
+	for len(out) < len(items) {
+		for _, k := range keys {
+			if b := buckets[k]; len(b) > 0 {
+				out = append(out, b[0])
+				buckets[k] = b[1:]
+			}
+		}
+	}
+	return out
+}
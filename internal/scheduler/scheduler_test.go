@@ -0,0 +1,98 @@
+package scheduler
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestReview(t *testing.T) {
+	now := time.Date(2026, 10, 19, 9, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name    string
+		st      State
+		correct bool
+		want    State
+		wantDue time.Time
+	}{
+		{
+			name:    "first correct answer",
+			st:      New(),
+			correct: true,
+			want:    State{Reps: 1, IntervalDays: 1, Ease: DefaultEase},
+			wantDue: now.AddDate(0, 0, 1),
+		},
+		{
+			name:    "second correct answer",
+			st:      State{Reps: 1, IntervalDays: 1, Ease: DefaultEase},
+			correct: true,
+			want:    State{Reps: 2, IntervalDays: 6, Ease: DefaultEase},
+			wantDue: now.AddDate(0, 0, 6),
+		},
+		{
+			name:    "interval grows by the ease",
+			st:      State{Reps: 2, IntervalDays: 6, Ease: DefaultEase},
+			correct: true,
+			want:    State{Reps: 3, IntervalDays: 15, Ease: DefaultEase},
+			wantDue: now.AddDate(0, 0, 15),
+		},
+		{
+			name:    "interval is rounded",
+			st:      State{Reps: 3, IntervalDays: 7, Ease: 1.5},
+			correct: true,
+			want:    State{Reps: 4, IntervalDays: 11, Ease: 1.5},
+			wantDue: now.AddDate(0, 0, 11),
+		},
+		{
+			name:    "zero ease means default",
+			st:      State{},
+			correct: true,
+			want:    State{Reps: 1, IntervalDays: 1, Ease: DefaultEase},
+			wantDue: now.AddDate(0, 0, 1),
+		},
+		{
+			name:    "lapse starts over and lowers the ease",
+			st:      State{Reps: 5, Lapses: 1, IntervalDays: 40, Ease: 2.5},
+			correct: false,
+			want:    State{Lapses: 2, Ease: 2.3},
+			wantDue: now.Add(RelearnDelay),
+		},
+		{
+			name:    "ease does not drop below the minimum",
+			st:      State{Reps: 2, IntervalDays: 6, Ease: 1.4},
+			correct: false,
+			want:    State{Lapses: 1, Ease: MinEase},
+			wantDue: now.Add(RelearnDelay),
+		},
+		{
+			name:    "correct after a lapse relearns from a day",
+			st:      State{Lapses: 2, Ease: 2.3},
+			correct: true,
+			want:    State{Reps: 1, Lapses: 2, IntervalDays: 1, Ease: 2.3},
+			wantDue: now.AddDate(0, 0, 1),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, due := Review(tt.st, tt.correct, now)
+			if got.Reps != tt.want.Reps || got.Lapses != tt.want.Lapses || got.IntervalDays != tt.want.IntervalDays {
+				t.Errorf("Review = %+v, want %+v", got, tt.want)
+			}
+			if diff := got.Ease - tt.want.Ease; diff > 1e-9 || diff < -1e-9 {
+				t.Errorf("ease = %v, want %v", got.Ease, tt.want.Ease)
+			}
+			if !due.Equal(tt.wantDue) {
+				t.Errorf("due = %v, want %v", due, tt.wantDue)
+			}
+		})
+	}
+}
+
+func TestInterleave(t *testing.T) {
+	items := []string{"a1", "a2", "a3", "b1", "c1", "b2"}
+	got := Interleave(items, func(s string) byte { return s[0] })
+	want := []string{"a1", "b1", "c1", "a2", "b2", "a3"}
+	if !slices.Equal(got, want) {
+		t.Errorf("Interleave = %v, want %v", got, want)
+	}
+}
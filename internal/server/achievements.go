@@ -0,0 +1,141 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/labstack/echo/v4"
+
+	db "dailycards/internal/database"
+)
+
+/* ------------------  ACHIEVEMENTS  ------------------ */
+
+// achievementFacts is what the rules decide on.
+type achievementFacts struct {
+	db.AchievementFactsRow
+	LongestStreak int
+}
+
+type achievement struct {
+	Key         string
+	Title       string
+	Description string
+	Earned      func(achievementFacts) bool
+}
+
+// achievements are the rules, in the order they are listed. Keys are
+// stored in user_achievements and must not change.
+var achievements = []achievement{
+	{
+		Key:         "first_pack",
+		Title:       "Author",
+		Description: "Create your first pack.",
+		Earned:      func(f achievementFacts) bool { return f.PacksCreated >= 1 },
+	},
+	{
+		Key:         "streak_7",
+		Title:       "On a roll",
+		Description: "Meet your daily goal 7 days in a row.",
+		Earned:      func(f achievementFacts) bool { return f.LongestStreak >= 7 },
+	},
+	{
+		Key:         "reviews_1000",
+		Title:       "Thousand cards",
+		Description: "Review 1000 cards.",
+		Earned:      func(f achievementFacts) bool { return f.Reviews >= 1000 },
+	},
+	{
+		Key:         "mastered_100",
+		Title:       "Master",
+		Description: "Master a pack of 100 cards or more.",
+		Earned:      func(f achievementFacts) bool { return f.LargestMasteredPack >= 100 },
+	},
+}
+
+func achievementJSON(a achievement, awardedAt pgtype.Timestamptz) map[string]interface{} {
+	out := map[string]interface{}{
+		"key":         a.Key,
+		"title":       a.Title,
+		"description": a.Description,
+		"earned":      awardedAt.Valid,
+		"awarded_at":  nil,
+	}
+	if awardedAt.Valid {
+		out["awarded_at"] = awardedAt.Time
+	}
+	return out
+}
+
+// awardAchievements evaluates the rules for the user and awards what they
+// earned. Awarding twice is a no-op, so it is safe to call after every
+// study session; only the achievements new to the user are returned.
+// Failures are logged, they never fail the session.
+func (s *Server) awardAchievements(c echo.Context, userID pgtype.UUID) []map[string]interface{} {
+	ctx := c.Request().Context()
+	facts := achievementFacts{}
+
+	var err error
+	facts.AchievementFactsRow, err = s.db.AchievementFacts(ctx, userID)
+	if err != nil {
+		c.Logger().Warn("failed to evaluate achievements:", err)
+		return nil
+	}
+	settings, err := s.db.GetUserSettings(ctx, userID)
+	if err != nil {
+		c.Logger().Warn("failed to evaluate achievements:", err)
+		return nil
+	}
+	streak, err := s.streakStatus(ctx, userID, settings, time.Now())
+	if err != nil {
+		c.Logger().Warn("failed to evaluate achievements:", err)
+		return nil
+	}
+	facts.LongestStreak = streak.Longest
+
+	awarded := make([]map[string]interface{}, 0)
+	for _, a := range achievements {
+		if !a.Earned(facts) {
+			continue
+		}
+		row, err := s.db.AwardAchievement(ctx, db.AwardAchievementParams{UserID: userID, Achievement: a.Key})
+		if errors.Is(err, pgx.ErrNoRows) {
+			continue
+		}
+		if err != nil {
+			c.Logger().Warn("failed to award achievement:", err)
+			continue
+		}
+		awarded = append(awarded, achievementJSON(a, row.AwardedAt))
+	}
+	return awarded
+}
+
+// MyAchievements lists every achievement, earned or not, after awarding
+// the ones the user has earned since the last session.
+func (s *Server) MyAchievements(c echo.Context) error {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+	}
+
+	s.awardAchievements(c, userID)
+
+	earned, err := s.db.ListUserAchievements(c.Request().Context(), userID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "db error: " + err.Error()})
+	}
+	awardedAt := make(map[string]pgtype.Timestamptz, len(earned))
+	for _, e := range earned {
+		awardedAt[e.Achievement] = e.AwardedAt
+	}
+
+	result := make([]map[string]interface{}, 0, len(achievements))
+	for _, a := range achievements {
+		result = append(result, achievementJSON(a, awardedAt[a.Key]))
+	}
+	return c.JSON(http.StatusOK, result)
+}
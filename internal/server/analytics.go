@@ -0,0 +1,97 @@
+package server
+
+import (
+	"net/http"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/labstack/echo/v4"
+
+	db "dailycards/internal/database"
+)
+
+/* ------------------  PACK ANALYTICS  ------------------ */
+
+const (
+	// A card is a leech once a single user has forgotten it this often...
+	leechLapses = 8
+	// ...or once at least half of this many attempts failed.
+	leechMinAttempts = 10
+	leechFailureRate = 0.5
+)
+
+// isLeech reports whether a card fails so often that it likely needs to
+// be rewritten.
+func isLeech(card db.PackCardAnalyticsRow) bool {
+	if card.MaxLapses >= leechLapses {
+		return true
+	}
+	return card.Attempts >= leechMinAttempts && float64(card.Failures)/float64(card.Attempts) >= leechFailureRate
+}
+
+// PackAnalytics shows the authors of a pack how its cards fare across all
+// users studying it, the most failed cards first, and flags leeches.
+// ?leeches=true lists only those.
+func (s *Server) PackAnalytics(c echo.Context) error {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+	}
+
+	var packID pgtype.UUID
+	if err := packID.Scan(c.Param("id")); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid pack id"})
+	}
+	onlyLeeches := c.QueryParam("leeches") == "true"
+
+	if ok, err := s.requirePackRole(c, packID, userID, roleOwner, roleEditor); !ok {
+		return err
+	}
+
+	cards, err := s.db.PackCardAnalytics(c.Request().Context(), packID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "db error: " + err.Error()})
+	}
+
+	var attempts, failures, leeches int64
+	result := make([]map[string]interface{}, 0, len(cards))
+	for _, card := range cards {
+		attempts += card.Attempts
+		failures += card.Failures
+		leech := isLeech(card)
+		if leech {
+			leeches++
+		}
+		if onlyLeeches && !leech {
+			continue
+		}
+
+		var avgDuration interface{}
+		if card.Attempts > 0 {
+			avgDuration = card.AvgDurationMs
+		}
+		result = append(result, map[string]interface{}{
+			"card_id":         card.CardID.String(),
+			"question":        card.Question,
+			"card_type":       card.CardType,
+			"attempts":        card.Attempts,
+			"failures":        card.Failures,
+			"failure_rate":    ratio(card.Failures, card.Attempts),
+			"avg_duration_ms": avgDuration,
+			"learners":        card.Learners,
+			"lapses":          card.Lapses,
+			"max_lapses":      card.MaxLapses,
+			"leech":           leech,
+		})
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"pack_id": packID.String(),
+		"summary": map[string]interface{}{
+			"cards":        len(cards),
+			"attempts":     attempts,
+			"failure_rate": ratio(failures, attempts),
+			"leeches":      leeches,
+		},
+		"cards": result,
+	})
+}
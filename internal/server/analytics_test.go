@@ -0,0 +1,29 @@
+package server
+
+import (
+	"testing"
+
+	db "dailycards/internal/database"
+)
+
+func TestIsLeech(t *testing.T) {
+	tests := []struct {
+		name string
+		card db.PackCardAnalyticsRow
+		want bool
+	}{
+		{"never studied", db.PackCardAnalyticsRow{}, false},
+		{"one user forgot it often", db.PackCardAnalyticsRow{Attempts: 8, Failures: 8, MaxLapses: 8}, true},
+		{"one lapse short", db.PackCardAnalyticsRow{Attempts: 7, Failures: 7, MaxLapses: 7}, false},
+		{"lapses spread over users", db.PackCardAnalyticsRow{Attempts: 40, Failures: 12, Lapses: 12, MaxLapses: 3}, false},
+		{"half of ten attempts failed", db.PackCardAnalyticsRow{Attempts: 10, Failures: 5}, true},
+		{"just under half failed", db.PackCardAnalyticsRow{Attempts: 11, Failures: 5}, false},
+		{"too few attempts to tell", db.PackCardAnalyticsRow{Attempts: 9, Failures: 9}, false},
+		{"every attempt failed", db.PackCardAnalyticsRow{Attempts: 20, Failures: 20}, true},
+	}
+	for _, tt := range tests {
+		if got := isLeech(tt.card); got != tt.want {
+			t.Errorf("%s: isLeech(%+v) = %v, want %v", tt.name, tt.card, got, tt.want)
+		}
+	}
+}
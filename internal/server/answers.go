@@ -0,0 +1,96 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/labstack/echo/v4"
+
+	db "dailycards/internal/database"
+	"dailycards/internal/grading"
+	"dailycards/internal/render"
+)
+
+/* ------------------  TYPED ANSWERS  ------------------ */
+
+// errAnswerTooLong is returned by gradeAnswer for answers not worth grading,
+// see grading.TooLong.
+var errAnswerTooLong = errors.New("answer is too long")
+
+type CheckAnswerRequest struct {
+	Answer    string `json:"answer"`
+	Direction string `json:"direction"`
+}
+
+// gradeAnswer grades typed against what the prompt expects: the answer
+// normally, the question for a reverse prompt, the hidden text for a cloze.
+func (s *Server) gradeAnswer(ctx context.Context, cardID pgtype.UUID, direction, typed string) (grading.Result, error) {
+	card, err := s.db.ReadCard(ctx, cardID)
+	if err != nil {
+		return grading.Result{}, err
+	}
+	expected := expectedAnswer(card, direction)
+	if grading.TooLong(expected, typed) {
+		return grading.Result{}, errAnswerTooLong
+	}
+	return grading.Grade(expected, typed), nil
+}
+
+func expectedAnswer(card db.Card, direction string) string {
+	_, answer := promptFromCard(card, direction).Sides()
+	return render.Text(card.ContentFormat, answer)
+}
+
+// CheckAnswer grades a typed answer without recording it, so the client can
+// show the diff before the results are submitted with FinishPack.
+func (s *Server) CheckAnswer(c echo.Context) error {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+	}
+
+	var packID, cardID pgtype.UUID
+	if err := packID.Scan(c.Param("pack_id")); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid pack_id"})
+	}
+	if err := cardID.Scan(c.Param("card_id")); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid card_id"})
+	}
+
+	var req CheckAnswerRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "cannot parse body: " + err.Error()})
+	}
+	if req.Direction == "" {
+		req.Direction = dirForward
+	}
+	if promptDirection(req.Direction) != req.Direction {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid direction"})
+	}
+
+	ctx := c.Request().Context()
+	visible, err := s.db.PackVisible(ctx, db.PackVisibleParams{PackID: packID, UserID: userID})
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "db error: " + err.Error()})
+	}
+	if !visible {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "pack not found"})
+	}
+
+	card, err := s.db.ReadCard(ctx, cardID)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "db error: " + err.Error()})
+	}
+	if err != nil || card.PackID != packID {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "card not found"})
+	}
+
+	expected := expectedAnswer(card, req.Direction)
+	if grading.TooLong(expected, req.Answer) {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": errAnswerTooLong.Error()})
+	}
+	return c.JSON(http.StatusOK, grading.Grade(expected, req.Answer))
+}
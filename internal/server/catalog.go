@@ -0,0 +1,354 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/labstack/echo/v4"
+
+	db "dailycards/internal/database"
+)
+
+/* ------------------  CATALOG  ------------------ */
+
+const maxReviewLen = 2000
+
+var catalogSortFields = map[string]sortField{
+	"popular": {kind: sortCount, defaultDesc: true},
+	"rating":  {kind: sortCount, defaultDesc: true},
+	"recent":  {kind: sortTime, defaultDesc: true},
+	"new":     {kind: sortTime, defaultDesc: true},
+	"name":    {kind: sortText},
+}
+
+// ListCatalog lists public packs of all users. Filters: ?q= (full text over
+// name and description), ?category=, ?source_lang=, ?target_lang=; sorted
+// by popular (subscribers, default), rating, recent activity, new or name.
+func (s *Server) ListCatalog(c echo.Context) error {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+	}
+
+	page, err := parsePageRequest(c, catalogSortFields, "popular")
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	cursorID, cursorName, cursorTime, cursorCount, err := page.cursorArgs()
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid cursor"})
+	}
+
+	params := db.ListCatalogParams{
+		UserID:      userID,
+		Query:       optionalText(strings.TrimSpace(c.QueryParam("q"))),
+		SourceLang:  optionalText(c.QueryParam("source_lang")),
+		TargetLang:  optionalText(c.QueryParam("target_lang")),
+		CursorID:    cursorID,
+		SortBy:      page.SortBy,
+		SortDesc:    page.Desc,
+		CursorName:  cursorName,
+		CursorTime:  cursorTime,
+		CursorCount: pgtype.Int8{Int64: cursorCount, Valid: page.Cursor != nil},
+		PageLimit:   page.Limit + 1,
+	}
+	if raw := c.QueryParam("category"); raw != "" {
+		category, err := s.lookupCategory(c.Request().Context(), userID, raw)
+		if err != nil {
+			if errors.Is(err, errCategoryNotFound) {
+				return c.JSON(http.StatusNotFound, map[string]string{"error": "category not found"})
+			}
+			return c.JSON(http.StatusInternalServerError, map[string]string{"error": "db error: " + err.Error()})
+		}
+		params.CategoryID = category.ID
+	}
+
+	packs, err := s.db.ListCatalog(c.Request().Context(), params)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "db error: " + err.Error()})
+	}
+
+	if len(packs) > int(page.Limit) {
+		packs = packs[:page.Limit]
+		last := packs[len(packs)-1]
+		cur := pageCursor{ID: last.ID.String()}
+		switch page.SortBy {
+		case "name":
+			cur.Value = last.Name
+		case "popular":
+			cur.Value = strconv.FormatInt(last.SubscriberCount, 10)
+		case "rating":
+			cur.Value = strconv.FormatInt(last.RatingScore, 10)
+		case "recent":
+			cur.Value = timeCursorValue(last.ActiveAt)
+		default:
+			cur.Value = timeCursorValue(last.CreatedAt)
+		}
+		setNextPage(c, cur)
+	}
+
+	result := make([]map[string]interface{}, 0, len(packs))
+	for _, p := range packs {
+		result = append(result, map[string]interface{}{
+			"id":               p.ID.String(),
+			"name":             p.Name,
+			"description":      p.Description,
+			"category":         p.Category,
+			"category_id":      p.CategoryID,
+			"owner":            map[string]interface{}{"id": p.OwnerID, "username": p.OwnerName},
+			"source_lang":      p.SourceLang,
+			"target_lang":      p.TargetLang,
+			"cover_color":      p.CoverColor,
+			"cover_icon":       p.CoverIcon,
+			"card_count":       p.CardCount,
+			"subscriber_count": p.SubscriberCount,
+			"rating_count":     p.RatingCount,
+			"rating_avg":       p.RatingAvg,
+			"subscribed":       p.Subscribed,
+			"created_at":       p.CreatedAt.Time,
+			"active_at":        p.ActiveAt.Time,
+		})
+	}
+	return c.JSON(http.StatusOK, result)
+}
+
+/* ------------------  SUBSCRIPTIONS  ------------------ */
+
+// Subscribe adds a pack the user can see (public, unlisted) to their
+// library: its cards join the daily queue.
+func (s *Server) Subscribe(c echo.Context) error {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+	}
+
+	var packID pgtype.UUID
+	if err := packID.Scan(c.Param("id")); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid pack id"})
+	}
+
+	ctx := c.Request().Context()
+	visible, err := s.db.PackVisible(ctx, db.PackVisibleParams{PackID: packID, UserID: userID})
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "db error: " + err.Error()})
+	}
+	if !visible {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "pack not found"})
+	}
+
+	n, err := s.db.Subscribe(ctx, db.SubscribeParams{UserID: userID, PackID: packID})
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "db error: " + err.Error()})
+	}
+	status := http.StatusOK
+	if n > 0 {
+		status = http.StatusCreated
+	}
+	return c.JSON(status, map[string]interface{}{"pack_id": packID.String(), "subscribed": true})
+}
+
+func (s *Server) Unsubscribe(c echo.Context) error {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+	}
+
+	var packID pgtype.UUID
+	if err := packID.Scan(c.Param("id")); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid pack id"})
+	}
+
+	n, err := s.db.Unsubscribe(c.Request().Context(), db.UnsubscribeParams{UserID: userID, PackID: packID})
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "db error: " + err.Error()})
+	}
+	if n == 0 {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "not subscribed"})
+	}
+	return c.NoContent(http.StatusNoContent)
+}
+
+/* ------------------  RATINGS  ------------------ */
+
+type RatePackRequest struct {
+	Stars  int    `json:"stars"`
+	Review string `json:"review"`
+}
+
+// ratablePack checks that the user can see the pack and is not one of its
+// authors: owners, editors and admins of the owning organization cannot
+// rate it. Packs without an owner belong to everybody and can be rated.
+func (s *Server) ratablePack(c echo.Context, userID, packID pgtype.UUID) (int, string) {
+	ctx := c.Request().Context()
+	visible, err := s.db.PackVisible(ctx, db.PackVisibleParams{PackID: packID, UserID: userID})
+	if err != nil {
+		return http.StatusInternalServerError, "db error: " + err.Error()
+	}
+	if !visible {
+		return http.StatusNotFound, "pack not found"
+	}
+	pack, err := s.db.ReadPack(ctx, packID)
+	if err != nil {
+		return http.StatusInternalServerError, "db error: " + err.Error()
+	}
+	if !pack.OwnerID.Valid && !pack.OrgID.Valid {
+		return http.StatusOK, ""
+	}
+	role, err := s.db.PackRole(ctx, db.PackRoleParams{PackID: packID, UserID: userID})
+	if err != nil {
+		return http.StatusInternalServerError, "db error: " + err.Error()
+	}
+	if role == roleOwner || role == roleEditor {
+		return http.StatusForbidden, "you cannot rate your own pack"
+	}
+	return http.StatusOK, ""
+}
+
+// RatePack sets the user's star rating of a pack, replacing an earlier one:
+// every user has a single vote per pack.
+func (s *Server) RatePack(c echo.Context) error {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+	}
+
+	var packID pgtype.UUID
+	if err := packID.Scan(c.Param("id")); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid pack id"})
+	}
+
+	var req RatePackRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "cannot parse body: " + err.Error()})
+	}
+	if req.Stars < 1 || req.Stars > 5 {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "stars must be between 1 and 5"})
+	}
+	req.Review = strings.TrimSpace(req.Review)
+	if utf8.RuneCountInString(req.Review) > maxReviewLen {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "review is limited to 2000 characters"})
+	}
+
+	if status, msg := s.ratablePack(c, userID, packID); status != http.StatusOK {
+		return c.JSON(status, map[string]string{"error": msg})
+	}
+
+	rating, err := s.db.UpsertPackRating(c.Request().Context(), db.UpsertPackRatingParams{
+		PackID: packID,
+		UserID: userID,
+		Stars:  int16(req.Stars),
+		Review: req.Review,
+	})
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "db error: " + err.Error()})
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"pack_id":    packID.String(),
+		"stars":      rating.Stars,
+		"review":     rating.Review,
+		"created_at": rating.CreatedAt.Time,
+		"updated_at": rating.UpdatedAt.Time,
+	})
+}
+
+func (s *Server) DeletePackRating(c echo.Context) error {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+	}
+
+	var packID pgtype.UUID
+	if err := packID.Scan(c.Param("id")); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid pack id"})
+	}
+
+	n, err := s.db.DeletePackRating(c.Request().Context(), db.DeletePackRatingParams{PackID: packID, UserID: userID})
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "db error: " + err.Error()})
+	}
+	if n == 0 {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "rating not found"})
+	}
+	return c.NoContent(http.StatusNoContent)
+}
+
+var ratingSortFields = map[string]sortField{
+	"updated_at": {kind: sortTime, defaultDesc: true},
+}
+
+// ListPackRatings returns the star distribution of a pack and its reviews,
+// newest first.
+func (s *Server) ListPackRatings(c echo.Context) error {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+	}
+
+	var packID pgtype.UUID
+	if err := packID.Scan(c.Param("id")); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid pack id"})
+	}
+
+	page, err := parsePageRequest(c, ratingSortFields, "updated_at")
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	cursorID, _, cursorTime, _, err := page.cursorArgs()
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid cursor"})
+	}
+
+	ctx := c.Request().Context()
+	visible, err := s.db.PackVisible(ctx, db.PackVisibleParams{PackID: packID, UserID: userID})
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "db error: " + err.Error()})
+	}
+	if !visible {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "pack not found"})
+	}
+
+	summary, err := s.db.PackRatingSummary(ctx, packID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "db error: " + err.Error()})
+	}
+	ratings, err := s.db.ListPackRatings(ctx, db.ListPackRatingsParams{
+		PackID:     packID,
+		CursorID:   cursorID,
+		CursorTime: cursorTime,
+		PageLimit:  page.Limit + 1,
+	})
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "db error: " + err.Error()})
+	}
+	if len(ratings) > int(page.Limit) {
+		ratings = ratings[:page.Limit]
+		last := ratings[len(ratings)-1]
+		setNextPage(c, pageCursor{ID: last.UserID.String(), Value: timeCursorValue(last.UpdatedAt)})
+	}
+
+	reviews := make([]map[string]interface{}, 0, len(ratings))
+	for _, r := range ratings {
+		reviews = append(reviews, map[string]interface{}{
+			"user":       map[string]string{"id": r.UserID.String(), "username": r.Username},
+			"stars":      r.Stars,
+			"review":     r.Review,
+			"created_at": r.CreatedAt.Time,
+			"updated_at": r.UpdatedAt.Time,
+		})
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"rating_count": summary.RatingCount,
+		"rating_avg":   summary.RatingAvg,
+		"distribution": map[string]int64{
+			"1": summary.Stars1, "2": summary.Stars2, "3": summary.Stars3,
+			"4": summary.Stars4, "5": summary.Stars5,
+		},
+		"reviews": reviews,
+	})
+}
@@ -0,0 +1,308 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/labstack/echo/v4"
+
+	db "dailycards/internal/database"
+)
+
+/* ------------------  CATEGORIES  ------------------ */
+
+var (
+	errCategoryNotFound  = errors.New("category not found")
+	errCategoryForbidden = errors.New("category belongs to another user")
+)
+
+type CategoryRequest struct {
+	Name     string `json:"name"`
+	Slug     string `json:"slug"`
+	ParentID string `json:"parent_id"`
+}
+
+func categoryJSON(cat db.Category) map[string]interface{} {
+	out := map[string]interface{}{
+		"id":         cat.ID.String(),
+		"name":       cat.Name,
+		"slug":       cat.Slug,
+		"parent_id":  nil,
+		"global":     !cat.OwnerID.Valid,
+		"created_at": cat.CreatedAt.Time,
+	}
+	if cat.ParentID.Valid {
+		out["parent_id"] = cat.ParentID.String()
+	}
+	return out
+}
+
+// lookupCategory finds a category visible to the user by id or by slug.
+func (s *Server) lookupCategory(ctx context.Context, userID pgtype.UUID, idOrSlug string) (db.Category, error) {
+	var (
+		cat db.Category
+		err error
+		id  pgtype.UUID
+	)
+	if id.Scan(idOrSlug) == nil {
+		cat, err = s.db.ReadCategory(ctx, db.ReadCategoryParams{ID: id, UserID: userID})
+	} else {
+		cat, err = s.db.FindCategoryBySlug(ctx, db.FindCategoryBySlugParams{Name: idOrSlug, UserID: userID})
+	}
+	if errors.Is(err, pgx.ErrNoRows) {
+		return cat, errCategoryNotFound
+	}
+	return cat, err
+}
+
+// resolveCategory picks the category for a new pack: an explicit id must
+// exist, a free-text name is matched by slug and created in the user's
+// scope when nothing matches.
+func (s *Server) resolveCategory(ctx context.Context, userID pgtype.UUID, id, name string) (db.Category, error) {
+	if id != "" {
+		var catID pgtype.UUID
+		if err := catID.Scan(id); err != nil {
+			return db.Category{}, errCategoryNotFound
+		}
+		return s.lookupCategory(ctx, userID, id)
+	}
+
+	cat, err := s.db.FindCategoryBySlug(ctx, db.FindCategoryBySlugParams{Name: name, UserID: userID})
+	if err == nil {
+		return cat, nil
+	}
+	if !errors.Is(err, pgx.ErrNoRows) {
+		return cat, err
+	}
+	return s.db.CreateCategory(ctx, db.CreateCategoryParams{
+		Name:      name,
+		OwnerID:   userID,
+		CreatedBy: userID,
+	})
+}
+
+// categoryParent validates the requested parent: it must be visible to the
+// user.
+func (s *Server) categoryParent(ctx context.Context, userID pgtype.UUID, raw string) (pgtype.UUID, error) {
+	if raw == "" {
+		return pgtype.UUID{}, nil
+	}
+	parent, err := s.lookupCategory(ctx, userID, raw)
+	if err != nil {
+		return pgtype.UUID{}, err
+	}
+	return parent.ID, nil
+}
+
+func (s *Server) ListCategories(c echo.Context) error {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+	}
+
+	params := db.ListCategoriesParams{UserID: userID}
+	if raw := c.QueryParam("parent_id"); raw != "" {
+		if err := params.ParentID.Scan(raw); err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid parent_id"})
+		}
+	}
+
+	cats, err := s.db.ListCategories(c.Request().Context(), params)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "db error: " + err.Error()})
+	}
+
+	out := make([]map[string]interface{}, 0, len(cats))
+	for _, cat := range cats {
+		out = append(out, categoryJSON(cat))
+	}
+	return c.JSON(http.StatusOK, out)
+}
+
+// CreateCategory adds a personal category. Global categories are seeded by
+// the schema and cannot be created through the API.
+func (s *Server) CreateCategory(c echo.Context) error {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+	}
+
+	var req CategoryRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "cannot parse body: " + err.Error()})
+	}
+	if req.Name == "" {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "name must not be empty"})
+	}
+
+	ctx := c.Request().Context()
+	parentID, err := s.categoryParent(ctx, userID, req.ParentID)
+	if err != nil {
+		if errors.Is(err, errCategoryNotFound) {
+			return c.JSON(http.StatusNotFound, map[string]string{"error": "parent category not found"})
+		}
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	cat, err := s.db.CreateCategory(ctx, db.CreateCategoryParams{
+		Name:      req.Name,
+		Slug:      req.Slug,
+		ParentID:  parentID,
+		OwnerID:   userID,
+		CreatedBy: userID,
+	})
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+			return c.JSON(http.StatusConflict, map[string]string{"error": "category with this slug already exists"})
+		}
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "db error: " + err.Error()})
+	}
+
+	return c.JSON(http.StatusCreated, categoryJSON(cat))
+}
+
+func (s *Server) ReadCategory(c echo.Context) error {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+	}
+
+	cat, err := s.lookupCategory(c.Request().Context(), userID, c.Param("id"))
+	if err != nil {
+		return categoryError(c, err)
+	}
+
+	return c.JSON(http.StatusOK, categoryJSON(cat))
+}
+
+// editableCategory loads a category the user is allowed to change: only
+// their own personal categories. Global ones come with the schema.
+func (s *Server) editableCategory(ctx context.Context, userID pgtype.UUID, rawID string) (db.Category, error) {
+	var id pgtype.UUID
+	if err := id.Scan(rawID); err != nil {
+		return db.Category{}, errCategoryNotFound
+	}
+
+	cat, err := s.db.ReadCategory(ctx, db.ReadCategoryParams{ID: id, UserID: userID})
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return cat, errCategoryNotFound
+		}
+		return cat, err
+	}
+	if cat.OwnerID != userID {
+		return cat, errCategoryForbidden
+	}
+	return cat, nil
+}
+
+func categoryError(c echo.Context, err error) error {
+	switch {
+	case errors.Is(err, errCategoryNotFound):
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "category not found"})
+	case errors.Is(err, errCategoryForbidden):
+		return c.JSON(http.StatusForbidden, map[string]string{"error": "category belongs to another user"})
+	default:
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "db error: " + err.Error()})
+	}
+}
+
+func (s *Server) UpdateCategory(c echo.Context) error {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+	}
+
+	cat, err := s.editableCategory(c.Request().Context(), userID, c.Param("id"))
+	if err != nil {
+		return categoryError(c, err)
+	}
+
+	var req CategoryRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "cannot parse body: " + err.Error()})
+	}
+	if req.Name == "" {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "name must not be empty"})
+	}
+
+	ctx := c.Request().Context()
+	parentID, err := s.categoryParent(ctx, userID, req.ParentID)
+	if err != nil {
+		if errors.Is(err, errCategoryNotFound) {
+			return c.JSON(http.StatusNotFound, map[string]string{"error": "parent category not found"})
+		}
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	if parentID.Valid {
+		cycle, err := s.db.IsCategoryDescendant(ctx, db.IsCategoryDescendantParams{ID: cat.ID, Candidate: parentID})
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{"error": "db error: " + err.Error()})
+		}
+		if cycle {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "category cannot be nested under itself"})
+		}
+	}
+
+	updated, err := s.db.UpdateCategory(ctx, db.UpdateCategoryParams{
+		ID:       cat.ID,
+		Name:     req.Name,
+		Slug:     req.Slug,
+		ParentID: parentID,
+	})
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+			return c.JSON(http.StatusConflict, map[string]string{"error": "category with this slug already exists"})
+		}
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "db error: " + err.Error()})
+	}
+
+	return c.JSON(http.StatusOK, categoryJSON(updated))
+}
+
+func (s *Server) DeleteCategory(c echo.Context) error {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+	}
+
+	cat, err := s.editableCategory(c.Request().Context(), userID, c.Param("id"))
+	if err != nil {
+		return categoryError(c, err)
+	}
+
+	ctx := c.Request().Context()
+	children, err := s.db.CountCategoryChildren(ctx, cat.ID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "db error: " + err.Error()})
+	}
+	if children > 0 {
+		return c.JSON(http.StatusConflict, map[string]string{"error": "category has subcategories"})
+	}
+
+	if err := s.db.DeleteCategory(ctx, cat.ID); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "db error: " + err.Error()})
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+func (s *Server) ListCategoryPacks(c echo.Context) error {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+	}
+
+	cat, err := s.lookupCategory(c.Request().Context(), userID, c.Param("id"))
+	if err != nil {
+		return categoryError(c, err)
+	}
+
+	return s.listPacks(c, userID, cat.ID)
+}
@@ -0,0 +1,445 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"slices"
+	"unicode/utf8"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/labstack/echo/v4"
+
+	db "dailycards/internal/database"
+)
+
+/* ------------------  FORKS  ------------------ */
+
+var (
+	errForkNotFound    = errors.New("pack not found")
+	errNoUpstream      = errors.New("pack has no upstream the user can see")
+	errPackNameTooLong = errors.New("pack name is too long")
+	errPackNameTaken   = errors.New("you already have a pack with this name, choose another one")
+)
+
+type ForkPackRequest struct {
+	// Name defaults to the name of the upstream pack.
+	Name       string `json:"name,omitempty"`
+	Category   string `json:"category,omitempty"`
+	CategoryID string `json:"category_id,omitempty"`
+}
+
+// ForkPack copies a visible pack and its cards into a new pack owned by the
+// user. The copy remembers where it came from, see PackUpstream.
+func (s *Server) ForkPack(c echo.Context) error {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+	}
+
+	var upstreamID pgtype.UUID
+	if err := upstreamID.Scan(c.Param("id")); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid pack id"})
+	}
+
+	var req ForkPackRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "cannot parse body: " + err.Error()})
+	}
+
+	ctx := c.Request().Context()
+	visible, err := s.db.PackVisible(ctx, db.PackVisibleParams{PackID: upstreamID, UserID: userID})
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "db error: " + err.Error()})
+	}
+	if !visible {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "pack not found"})
+	}
+	upstream, err := s.db.ReadPack(ctx, upstreamID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "db error: " + err.Error()})
+	}
+
+	pack, cards, err := s.forkPack(ctx, userID, upstream, req)
+	if err != nil {
+		return forkPackError(c, err)
+	}
+
+	out := packJSON(pack)
+	out["cards"] = len(cards)
+	return c.JSON(http.StatusCreated, out)
+}
+
+// forkPack copies upstream and its cards into a new pack of userID in one
+// transaction, so a failed fork leaves nothing behind. The caller has
+// checked that the user may read upstream.
+func (s *Server) forkPack(ctx context.Context, userID pgtype.UUID, upstream db.Pack, req ForkPackRequest) (db.Pack, []db.Card, error) {
+	if req.Name == "" {
+		req.Name = upstream.Name
+	}
+	if utf8.RuneCountInString(req.Name) > maxPackNameLen {
+		return db.Pack{}, nil, errPackNameTooLong
+	}
+
+	params := db.ForkPackParams{Name: req.Name, OwnerID: userID, UpstreamID: upstream.ID}
+	if req.Category != "" || req.CategoryID != "" {
+		category, err := s.resolveCategory(ctx, userID, req.CategoryID, req.Category)
+		if err != nil {
+			return db.Pack{}, nil, err
+		}
+		params.CategoryID = category.ID
+	}
+
+	var pack db.Pack
+	var cards []db.Card
+	err := s.inTx(ctx, func(tx *Server) error {
+		var err error
+		pack, err = tx.db.ForkPack(ctx, params)
+		if err != nil {
+			var pgErr *pgconn.PgError
+			if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+				return errPackNameTaken
+			}
+			return err
+		}
+		cards, err = tx.copyUpstreamCards(ctx, userID, pack.ID, upstream.ID, nil)
+		return err
+	})
+	if err != nil {
+		return db.Pack{}, nil, err
+	}
+	return pack, cards, nil
+}
+
+func forkPackError(c echo.Context, err error) error {
+	switch {
+	case errors.Is(err, errPackNameTooLong):
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	case errors.Is(err, errPackNameTaken):
+		return c.JSON(http.StatusConflict, map[string]string{"error": err.Error()})
+	case errors.Is(err, errCategoryNotFound):
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "category not found"})
+	}
+	return c.JSON(http.StatusInternalServerError, map[string]string{"error": "db error: " + err.Error()})
+}
+
+// copyUpstreamCards copies upstream cards (all of them when ids is nil)
+// into the fork together with their attachments, and logs the creation of
+// every copy.
+func (s *Server) copyUpstreamCards(ctx context.Context, userID, packID, upstreamID pgtype.UUID, ids []pgtype.UUID) ([]db.Card, error) {
+	cards, err := s.db.CopyUpstreamCards(ctx, db.CopyUpstreamCardsParams{
+		PackID:     packID,
+		UpstreamID: upstreamID,
+		CardIds:    ids,
+	})
+	if err != nil || len(cards) == 0 {
+		return cards, err
+	}
+
+	copied := make([]pgtype.UUID, 0, len(cards))
+	for _, card := range cards {
+		copied = append(copied, card.ID)
+	}
+	if err := s.db.CopyUpstreamMedia(ctx, copied); err != nil {
+		return nil, err
+	}
+	attached, err := s.db.ListCardMedia(ctx, copied)
+	if err != nil {
+		return nil, err
+	}
+	byCard := map[pgtype.UUID][]string{}
+	for _, a := range attached {
+		byCard[a.CardID] = append(byCard[a.CardID], a.Hash)
+	}
+	for _, card := range cards {
+		after := exportCardOf(card, byCard[card.ID])
+		if err := s.recordRevision(ctx, card, revisionCreate, nil, &after, userID); err != nil {
+			return nil, err
+		}
+	}
+	return cards, nil
+}
+
+// loadFork returns a fork the user can edit. Its upstream must still be
+// visible to the user.
+func (s *Server) loadFork(ctx context.Context, userID, packID pgtype.UUID) (db.Pack, error) {
+	editable, err := s.db.PackEditable(ctx, db.PackEditableParams{PackID: packID, UserID: userID})
+	if err != nil {
+		return db.Pack{}, err
+	}
+	if !editable {
+		return db.Pack{}, errForkNotFound
+	}
+	pack, err := s.db.ReadPack(ctx, packID)
+	if err != nil {
+		return db.Pack{}, err
+	}
+	if !pack.ForkedFrom.Valid {
+		return db.Pack{}, errNoUpstream
+	}
+	visible, err := s.db.PackVisible(ctx, db.PackVisibleParams{PackID: pack.ForkedFrom, UserID: userID})
+	if err != nil {
+		return db.Pack{}, err
+	}
+	if !visible {
+		return db.Pack{}, errNoUpstream
+	}
+	return pack, nil
+}
+
+func forkError(c echo.Context, err error) error {
+	switch {
+	case errors.Is(err, errForkNotFound):
+		return c.JSON(http.StatusNotFound, map[string]string{"error": err.Error()})
+	case errors.Is(err, errNoUpstream):
+		return c.JSON(http.StatusConflict, map[string]string{"error": err.Error()})
+	}
+	return c.JSON(http.StatusInternalServerError, map[string]string{"error": "db error: " + err.Error()})
+}
+
+// PackUpstream shows what changed upstream since the fork was made or last
+// pulled: new cards, cards whose content differs, and cards removed there.
+func (s *Server) PackUpstream(c echo.Context) error {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+	}
+
+	var packID pgtype.UUID
+	if err := packID.Scan(c.Param("id")); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid pack id"})
+	}
+
+	ctx := c.Request().Context()
+	pack, err := s.loadFork(ctx, userID, packID)
+	if err != nil {
+		return forkError(c, err)
+	}
+	upstream, err := s.db.ReadPack(ctx, pack.ForkedFrom)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "db error: " + err.Error()})
+	}
+
+	added, err := s.db.ListUpstreamAdded(ctx, db.ListUpstreamAddedParams{PackID: packID, UpstreamID: upstream.ID})
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "db error: " + err.Error()})
+	}
+	changed, err := s.db.ListUpstreamChanged(ctx, db.ListUpstreamChangedParams{PackID: packID, UpstreamID: upstream.ID})
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "db error: " + err.Error()})
+	}
+	removed, err := s.db.ListUpstreamRemoved(ctx, db.ListUpstreamRemovedParams{PackID: packID, UpstreamID: upstream.ID})
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "db error: " + err.Error()})
+	}
+
+	addedList := make([]map[string]interface{}, 0, len(added))
+	for _, card := range added {
+		addedList = append(addedList, cardJSON(card))
+	}
+	changedList := make([]map[string]interface{}, 0, len(changed))
+	for _, r := range changed {
+		local := db.Card{
+			ID: r.ID, PackID: r.PackID, Question: r.Question, Answer: r.Answer,
+			Rating: r.Rating, LastWrong: r.LastWrong, Direction: r.Direction,
+			CardType: r.CardType, ContentFormat: r.ContentFormat,
+		}
+		remote := db.Card{
+			ID: r.UpstreamCardID, PackID: upstream.ID, Question: r.UpstreamQuestion, Answer: r.UpstreamAnswer,
+			Direction: r.UpstreamDirection, CardType: r.UpstreamCardType, ContentFormat: r.UpstreamContentFormat,
+		}
+		changedList = append(changedList, map[string]interface{}{
+			"card_id":          r.ID.String(),
+			"upstream_card_id": r.UpstreamCardID.String(),
+			"local":            cardJSON(local),
+			"upstream":         cardJSON(remote),
+			"updated_at":       r.UpstreamUpdatedAt.Time,
+		})
+	}
+	removedList := make([]map[string]interface{}, 0, len(removed))
+	for _, card := range removed {
+		removedList = append(removedList, cardJSON(card))
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"upstream": map[string]string{"id": upstream.ID.String(), "name": upstream.Name},
+		"added":    addedList,
+		"changed":  changedList,
+		"removed":  removedList,
+	})
+}
+
+// PullUpstreamRequest picks the upstream changes to take over. Add lists
+// upstream card ids, the others list cards of the fork. Ignored changes are
+// marked as synced without touching the card.
+type PullUpstreamRequest struct {
+	Add    []string `json:"add"`
+	Update []string `json:"update"`
+	Remove []string `json:"remove"`
+	Ignore []string `json:"ignore"`
+}
+
+// PullUpstream applies the selected changes listed by PackUpstream. Ids that
+// are not (or no longer) part of the diff are skipped.
+func (s *Server) PullUpstream(c echo.Context) error {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+	}
+
+	var packID pgtype.UUID
+	if err := packID.Scan(c.Param("id")); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid pack id"})
+	}
+
+	var req PullUpstreamRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "cannot parse body: " + err.Error()})
+	}
+	addIDs, err := parseUUIDs(req.Add)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid card id"})
+	}
+	updateIDs, err := parseUUIDs(req.Update)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid card id"})
+	}
+	removeIDs, err := parseUUIDs(req.Remove)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid card id"})
+	}
+	ignoreIDs, err := parseUUIDs(req.Ignore)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid card id"})
+	}
+
+	ctx := c.Request().Context()
+	pack, err := s.loadFork(ctx, userID, packID)
+	if err != nil {
+		return forkError(c, err)
+	}
+
+	result := map[string]int{"added": 0, "updated": 0, "removed": 0, "ignored": 0}
+	err = s.inTx(ctx, func(tx *Server) error {
+		return tx.pullUpstream(ctx, userID, pack, pullSelection{addIDs, updateIDs, removeIDs, ignoreIDs}, result)
+	})
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "db error: " + err.Error()})
+	}
+
+	return c.JSON(http.StatusOK, result)
+}
+
+// pullSelection is a parsed PullUpstreamRequest.
+type pullSelection struct {
+	add, update, remove, ignore []pgtype.UUID
+}
+
+// pullUpstream applies sel to the fork and counts what was done in result.
+// Run it in a transaction: a failed pull changes nothing.
+func (s *Server) pullUpstream(ctx context.Context, userID pgtype.UUID, pack db.Pack, sel pullSelection, result map[string]int) error {
+	if len(sel.add) > 0 {
+		cards, err := s.copyUpstreamCards(ctx, userID, pack.ID, pack.ForkedFrom, sel.add)
+		if err != nil {
+			return err
+		}
+		result["added"] = len(cards)
+	}
+
+	if len(sel.update) > 0 || len(sel.ignore) > 0 {
+		changed, err := s.db.ListUpstreamChanged(ctx, db.ListUpstreamChangedParams{PackID: pack.ID, UpstreamID: pack.ForkedFrom})
+		if err != nil {
+			return err
+		}
+		byCard := map[pgtype.UUID]db.ListUpstreamChangedRow{}
+		for _, r := range changed {
+			byCard[r.ID] = r
+		}
+
+		var synced []pgtype.UUID
+		for _, id := range sel.update {
+			r, ok := byCard[id]
+			if !ok {
+				continue
+			}
+			err := s.pullCard(ctx, userID, r)
+			if errors.Is(err, pgx.ErrNoRows) {
+				continue
+			}
+			if err != nil {
+				return err
+			}
+			synced = append(synced, id)
+			result["updated"]++
+		}
+		for _, id := range sel.ignore {
+			if _, ok := byCard[id]; ok {
+				synced = append(synced, id)
+				result["ignored"]++
+			}
+		}
+		if len(synced) > 0 {
+			if err := s.db.MarkUpstreamSynced(ctx, synced); err != nil {
+				return err
+			}
+		}
+	}
+
+	if len(sel.remove) > 0 {
+		removed, err := s.db.ListUpstreamRemoved(ctx, db.ListUpstreamRemovedParams{PackID: pack.ID, UpstreamID: pack.ForkedFrom})
+		if err != nil {
+			return err
+		}
+		for _, card := range removed {
+			if !slices.Contains(sel.remove, card.ID) {
+				continue
+			}
+			before, err := s.cardSnapshot(ctx, card)
+			if err != nil {
+				return err
+			}
+			n, err := s.db.SoftDeleteCard(ctx, db.SoftDeleteCardParams{ID: card.ID, DeletedBy: userID})
+			if err != nil {
+				return err
+			}
+			if n == 0 {
+				continue
+			}
+			if err := s.recordRevision(ctx, card, revisionDelete, &before, nil, userID); err != nil {
+				return err
+			}
+			result["removed"]++
+		}
+	}
+	return nil
+}
+
+// pullCard overwrites a copy with the current content of its upstream card.
+// pgx.ErrNoRows means one of the two was deleted in the meantime.
+func (s *Server) pullCard(ctx context.Context, userID pgtype.UUID, r db.ListUpstreamChangedRow) error {
+	local := db.Card{
+		ID: r.ID, PackID: r.PackID, Question: r.Question, Answer: r.Answer,
+		Direction: r.Direction, CardType: r.CardType, ContentFormat: r.ContentFormat,
+	}
+	before, err := s.cardSnapshot(ctx, local)
+	if err != nil {
+		return err
+	}
+	upstream, err := s.db.ReadCard(ctx, r.UpstreamCardID)
+	if err != nil {
+		return err
+	}
+	content, err := s.cardSnapshot(ctx, upstream)
+	if err != nil {
+		return err
+	}
+
+	card, err := s.applyCardContent(ctx, r.ID, content, true)
+	if err != nil {
+		return err
+	}
+	after := exportCardOf(card, content.Media)
+	return s.recordRevision(ctx, card, revisionUpdate, &before, &after, userID)
+}
@@ -0,0 +1,141 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/labstack/echo/v4"
+
+	db "dailycards/internal/database"
+)
+
+/* ------------------  FRIENDS  ------------------ */
+
+func friendshipJSON(f db.Friendship) map[string]interface{} {
+	return map[string]interface{}{
+		"user_id":     f.UserID.String(),
+		"friend_id":   f.FriendID.String(),
+		"accepted":    f.AcceptedAt.Valid,
+		"accepted_at": f.AcceptedAt.Time,
+		"created_at":  f.CreatedAt.Time,
+	}
+}
+
+// ListFriends lists the friends of the user and the requests pending
+// either way.
+func (s *Server) ListFriends(c echo.Context) error {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+	}
+
+	friends, err := s.db.ListFriends(c.Request().Context(), userID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "db error: " + err.Error()})
+	}
+	result := make([]map[string]interface{}, 0, len(friends))
+	for _, f := range friends {
+		result = append(result, map[string]interface{}{
+			"user_id":     f.UserID.String(),
+			"username":    f.Username,
+			"status":      f.Status,
+			"accepted_at": f.AcceptedAt.Time,
+			"created_at":  f.CreatedAt.Time,
+		})
+	}
+	return c.JSON(http.StatusOK, result)
+}
+
+// RequestFriend asks another user, by username, to be friends. Asking a
+// user who already asked accepts their request.
+func (s *Server) RequestFriend(c echo.Context) error {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+	}
+
+	var req struct {
+		Username string `json:"username"`
+	}
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "cannot parse body: " + err.Error()})
+	}
+	if req.Username == "" {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "username is required"})
+	}
+
+	ctx := c.Request().Context()
+	other, err := s.db.GetUserByUsername(ctx, req.Username)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "user not found"})
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "db error: " + err.Error()})
+	}
+	if other.ID == userID {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "cannot befriend yourself"})
+	}
+
+	accepted, err := s.db.AcceptFriendRequest(ctx, db.AcceptFriendRequestParams{RequesterID: other.ID, UserID: userID})
+	if err == nil {
+		return c.JSON(http.StatusOK, friendshipJSON(accepted))
+	}
+	if !errors.Is(err, pgx.ErrNoRows) {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "db error: " + err.Error()})
+	}
+
+	friendship, err := s.db.RequestFriend(ctx, db.RequestFriendParams{UserID: userID, FriendID: other.ID})
+	if errors.Is(err, pgx.ErrNoRows) {
+		return c.JSON(http.StatusConflict, map[string]string{"error": "already friends or asked"})
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "db error: " + err.Error()})
+	}
+	return c.JSON(http.StatusCreated, friendshipJSON(friendship))
+}
+
+// AcceptFriend accepts the friend request of :user_id.
+func (s *Server) AcceptFriend(c echo.Context) error {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+	}
+
+	var requesterID pgtype.UUID
+	if err := requesterID.Scan(c.Param("user_id")); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid user id"})
+	}
+
+	friendship, err := s.db.AcceptFriendRequest(c.Request().Context(), db.AcceptFriendRequestParams{RequesterID: requesterID, UserID: userID})
+	if errors.Is(err, pgx.ErrNoRows) {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "friend request not found"})
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "db error: " + err.Error()})
+	}
+	return c.JSON(http.StatusOK, friendshipJSON(friendship))
+}
+
+// RemoveFriend unfriends :user_id, or withdraws or declines a request.
+func (s *Server) RemoveFriend(c echo.Context) error {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+	}
+
+	var otherID pgtype.UUID
+	if err := otherID.Scan(c.Param("user_id")); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid user id"})
+	}
+
+	n, err := s.db.DeleteFriendship(c.Request().Context(), db.DeleteFriendshipParams{UserID: userID, OtherID: otherID})
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "db error: " + err.Error()})
+	}
+	if n == 0 {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "friend not found"})
+	}
+	return c.NoContent(http.StatusNoContent)
+}
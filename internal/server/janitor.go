@@ -0,0 +1,54 @@
+package server
+
+import (
+	"context"
+	"time"
+)
+
+/* ------------------  HOUSEKEEPING  ------------------ */
+
+const janitorInterval = 5 * time.Minute
+
+// runJanitor performs periodic housekeeping until ctx is cancelled.
+func (s *Server) runJanitor(ctx context.Context, every time.Duration) {
+	ticker := time.NewTicker(every)
+	defer ticker.Stop()
+
+	for {
+		s.housekeeping(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *Server) housekeeping(ctx context.Context) {
+	log := s.srv.Logger
+
+	if n, err := s.db.ExpireStudySessions(ctx); err != nil {
+		log.Warn("failed to expire study sessions:", err)
+	} else if n > 0 {
+		log.Infof("expired %d abandoned study sessions", n)
+	}
+
+	if packs, cards, err := s.purgeTrash(ctx); err != nil {
+		log.Warn("failed to purge the trash:", err)
+	} else if packs+cards > 0 {
+		log.Infof("purged %d packs and %d cards from the trash", packs, cards)
+	}
+
+	if n, err := s.collectMedia(ctx); err != nil {
+		log.Warn("failed to collect orphaned media:", err)
+	} else if n > 0 {
+		log.Infof("removed %d orphaned media files", n)
+	}
+
+	if n, err := s.snapshotLastWeek(ctx, time.Now()); err != nil {
+		log.Warn("failed to snapshot the weekly leaderboard:", err)
+	} else if n > 0 {
+		log.Infof("snapshotted the weekly leaderboard of %d users", n)
+	}
+}
@@ -0,0 +1,192 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/labstack/echo/v4"
+
+	db "dailycards/internal/database"
+)
+
+/* ------------------  LEADERBOARDS  ------------------ */
+
+// Leaderboard scopes and periods.
+const (
+	scopeGlobal  = "global"
+	scopeOrg     = "org"
+	scopeFriends = "friends"
+
+	periodWeek  = "week"
+	periodMonth = "month"
+	periodAll   = "all"
+)
+
+const defaultLeaderboardSize = 50
+
+// weekStart is the Monday, UTC, of the week t falls in.
+func weekStart(t time.Time) time.Time {
+	t = t.UTC()
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	return day.AddDate(0, 0, -(int(day.Weekday())+6)%7)
+}
+
+// snapshotLastWeek freezes the global ranking of the last completed week.
+func (s *Server) snapshotLastWeek(ctx context.Context, now time.Time) (int64, error) {
+	week := weekStart(now).AddDate(0, 0, -7)
+	return s.db.SnapshotWeeklyLeaderboard(ctx, pgtype.Date{Time: week, Valid: true})
+}
+
+func leaderboardEntry(userID pgtype.UUID, username string, score, rank int32, me pgtype.UUID) map[string]interface{} {
+	return map[string]interface{}{
+		"user_id":  userID.String(),
+		"username": username,
+		"score":    score,
+		"rank":     rank,
+		"me":       userID == me,
+	}
+}
+
+// Leaderboard ranks users by the rating points they earned.
+//
+//	?scope=global|org|friends  everybody, members of ?org=, or the user and their friends
+//	?period=week|month|all     the current week or month (UTC), or the rating itself
+//	?week=YYYY-MM-DD           a past week, from its snapshot
+//	?limit=                    size of the board; the user's own row is always added
+func (s *Server) Leaderboard(c echo.Context) error {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+	}
+
+	limit := defaultLeaderboardSize
+	if raw := c.QueryParam("limit"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n <= 0 {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid limit"})
+		}
+		limit = min(n, maxPageLimit)
+	}
+
+	var orgID, friendsOf pgtype.UUID
+	scope := c.QueryParam("scope")
+	switch scope {
+	case "", scopeGlobal:
+		scope = scopeGlobal
+	case scopeOrg:
+		if err := orgID.Scan(c.QueryParam("org")); err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "org is required for the org scope"})
+		}
+		if ok, err := s.requireOrgRole(c, orgID, userID, orgRoleAdmin, orgRoleMember); !ok {
+			return err
+		}
+	case scopeFriends:
+		friendsOf = userID
+	default:
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "scope must be global, org or friends"})
+	}
+
+	now := time.Now()
+	period := c.QueryParam("period")
+	var since pgtype.Timestamptz
+	switch period {
+	case "", periodWeek:
+		period = periodWeek
+		since = pgtype.Timestamptz{Time: weekStart(now), Valid: true}
+	case periodMonth:
+		t := now.UTC()
+		since = pgtype.Timestamptz{Time: time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC), Valid: true}
+	case periodAll:
+	default:
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "period must be week, month or all"})
+	}
+
+	ctx := c.Request().Context()
+	resp := map[string]interface{}{"scope": scope, "period": period}
+	if orgID.Valid {
+		resp["org_id"] = orgID.String()
+	}
+	var board []map[string]interface{}
+	var entrants int32
+
+	if raw := c.QueryParam("week"); raw != "" {
+		if period != periodWeek {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "week only goes with period=week"})
+		}
+		t, err := time.Parse(dateLayout, raw)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid week, expected YYYY-MM-DD"})
+		}
+		if weekStart(t).After(weekStart(now)) {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "week is in the future"})
+		}
+		since.Time = weekStart(t)
+	}
+	resp["from"] = since.Time
+	if period == periodAll {
+		resp["from"] = nil
+	}
+
+	if period == periodWeek && since.Time.Before(weekStart(now)) {
+		// Past weeks are read from their snapshot, taken now if the janitor
+		// has not got round to it.
+		week := pgtype.Date{Time: since.Time, Valid: true}
+		if _, err := s.db.SnapshotWeeklyLeaderboard(ctx, week); err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{"error": "db error: " + err.Error()})
+		}
+		rows, err := s.db.WeeklyLeaderboardSnapshot(ctx, db.WeeklyLeaderboardSnapshotParams{
+			WeekStart: week,
+			OrgID:     orgID,
+			FriendsOf: friendsOf,
+			UserID:    userID,
+			PageLimit: int32(limit),
+		})
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{"error": "db error: " + err.Error()})
+		}
+		for _, r := range rows {
+			entrants = r.Entrants
+			board = append(board, leaderboardEntry(r.UserID, r.Username, r.Score, r.Rank, userID))
+		}
+		resp["to"] = since.Time.AddDate(0, 0, 7)
+		resp["final"] = true
+	} else {
+		rows, err := s.db.Leaderboard(ctx, db.LeaderboardParams{
+			Since:     since,
+			Until:     pgtype.Timestamptz{Time: now, Valid: true},
+			OrgID:     orgID,
+			FriendsOf: friendsOf,
+			UserID:    userID,
+			PageLimit: int32(limit),
+		})
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{"error": "db error: " + err.Error()})
+		}
+		for _, r := range rows {
+			entrants = r.Entrants
+			board = append(board, leaderboardEntry(r.UserID, r.Username, r.Score, r.Rank, userID))
+		}
+		resp["to"] = now
+		resp["final"] = false
+	}
+
+	// The user's own row comes last when they rank below the top.
+	var me interface{}
+	entries := make([]map[string]interface{}, 0, len(board))
+	for i, e := range board {
+		if e["me"] == true {
+			me = e
+			if i >= limit {
+				continue
+			}
+		}
+		entries = append(entries, e)
+	}
+	resp["entries"] = entries
+	resp["me"] = me
+	resp["entrants"] = entrants
+	return c.JSON(http.StatusOK, resp)
+}
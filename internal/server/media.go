@@ -0,0 +1,428 @@
+package server
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/labstack/echo/v4"
+
+	db "dailycards/internal/database"
+	"dailycards/internal/media"
+)
+
+/* ------------------  MEDIA  ------------------ */
+
+// Uploads that were never attached to a card are collected after this long.
+const orphanMediaGrace = 24 * time.Hour
+
+func mediaURL(hash string) string {
+	return "/api/media/" + hash
+}
+
+func mediaJSON(hash, contentType, kind string, size int64) map[string]interface{} {
+	return map[string]interface{}{
+		"hash":         hash,
+		"url":          mediaURL(hash),
+		"content_type": contentType,
+		"kind":         kind,
+		"size":         size,
+	}
+}
+
+// withMedia adds the attachments of every item (keyed by its "id") as
+// "media".
+func (s *Server) withMedia(ctx context.Context, items []map[string]interface{}) error {
+	ids := make([]pgtype.UUID, 0, len(items))
+	for _, it := range items {
+		ids = append(ids, uuidFromString(it["id"].(string)))
+	}
+	rows, err := s.db.ListCardMedia(ctx, ids)
+	if err != nil {
+		return err
+	}
+
+	byCard := map[string][]map[string]interface{}{}
+	for _, r := range rows {
+		id := r.CardID.String()
+		byCard[id] = append(byCard[id], mediaJSON(r.Hash, r.ContentType, r.Kind, r.SizeBytes))
+	}
+	for _, it := range items {
+		list := byCard[it["id"].(string)]
+		if list == nil {
+			list = []map[string]interface{}{}
+		}
+		it["media"] = list
+	}
+	return nil
+}
+
+var errMediaNotFound = errors.New("unknown media")
+
+// checkMedia makes sure every hash was uploaded and drops repeats. The
+// media found get a fresh grace period so the janitor keeps them until they
+// are attached.
+func (s *Server) checkMedia(ctx context.Context, hashes []string) ([]string, error) {
+	unique := make([]string, 0, len(hashes))
+	for _, h := range hashes {
+		if !media.ValidKey(h) {
+			return nil, errMediaNotFound
+		}
+		if !slices.Contains(unique, h) {
+			unique = append(unique, h)
+		}
+	}
+	if len(unique) == 0 {
+		return unique, nil
+	}
+	n, err := s.db.TouchMedia(ctx, unique)
+	if err != nil {
+		return nil, err
+	}
+	if n != int64(len(unique)) {
+		return nil, errMediaNotFound
+	}
+	return unique, nil
+}
+
+// UploadMedia takes a multipart "file". The type is sniffed from the
+// content; only images and audio are accepted.
+func (s *Server) UploadMedia(c echo.Context) error {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+	}
+
+	fh, err := c.FormFile("file")
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "file is required"})
+	}
+	if fh.Size > media.MaxBytes {
+		return c.JSON(http.StatusRequestEntityTooLarge, map[string]string{"error": media.ErrTooLarge.Error()})
+	}
+	src, err := fh.Open()
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "cannot read file"})
+	}
+	defer src.Close()
+
+	up, err := media.Spool(src)
+	switch {
+	case errors.Is(err, media.ErrTooLarge):
+		return c.JSON(http.StatusRequestEntityTooLarge, map[string]string{"error": err.Error()})
+	case errors.Is(err, media.ErrUnsupported):
+		return c.JSON(http.StatusUnsupportedMediaType, map[string]string{"error": "only images (png, jpeg, gif, webp) and audio (mp3, ogg, wav, m4a) are accepted"})
+	case err != nil:
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "upload failed: " + err.Error()})
+	}
+	defer up.Close()
+
+	// the lock keeps collectMedia from deleting the blob between Put, which
+	// does nothing for content already stored, and the row restarting its
+	// grace period
+	ctx := c.Request().Context()
+	var m db.Medium
+	var storageErr error
+	err = s.inTx(ctx, func(tx *Server) error {
+		if err := tx.db.LockMedia(ctx, up.Key); err != nil {
+			return err
+		}
+		if storageErr = tx.cfg.Media.Put(ctx, up.Key, up.Reader(), up.Size, up.ContentType); storageErr != nil {
+			return storageErr
+		}
+		var err error
+		m, err = tx.db.CreateMedia(ctx, db.CreateMediaParams{
+			Hash:        up.Key,
+			ContentType: up.ContentType,
+			Kind:        up.Kind,
+			SizeBytes:   up.Size,
+			UploadedBy:  userID,
+		})
+		return err
+	})
+	if storageErr != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "storage error: " + storageErr.Error()})
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "db error: " + err.Error()})
+	}
+
+	return c.JSON(http.StatusCreated, mediaJSON(m.Hash, m.ContentType, m.Kind, m.SizeBytes))
+}
+
+// GetMedia serves a blob to its uploader and to the users who can see a card
+// it is attached to. Content never changes under its hash, so it may be
+// cached forever.
+func (s *Server) GetMedia(c echo.Context) error {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+	}
+
+	hash := c.Param("hash")
+	if !media.ValidKey(hash) {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "media not found"})
+	}
+
+	ctx := c.Request().Context()
+	visible, err := s.db.MediaVisible(ctx, db.MediaVisibleParams{Hash: hash, UserID: userID})
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "db error: " + err.Error()})
+	}
+	if !visible {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "media not found"})
+	}
+	if c.Request().Header.Get("If-None-Match") == `"`+hash+`"` {
+		return c.NoContent(http.StatusNotModified)
+	}
+
+	m, err := s.db.GetMedia(ctx, hash)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return c.JSON(http.StatusNotFound, map[string]string{"error": "media not found"})
+		}
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "db error: " + err.Error()})
+	}
+
+	blob, err := s.cfg.Media.Open(ctx, hash)
+	if err != nil {
+		if errors.Is(err, media.ErrNotFound) {
+			return c.JSON(http.StatusNotFound, map[string]string{"error": "media not found"})
+		}
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "storage error: " + err.Error()})
+	}
+	defer blob.Close()
+
+	h := c.Response().Header()
+	h.Set("ETag", `"`+hash+`"`)
+	h.Set("Cache-Control", "private, max-age=31536000, immutable")
+	h.Set("X-Content-Type-Options", "nosniff")
+	return c.Stream(http.StatusOK, m.ContentType, blob)
+}
+
+type CardMediaRequest struct {
+	Media []string `json:"media"`
+}
+
+// SetCardMedia replaces the list of attachments of a card.
+func (s *Server) SetCardMedia(c echo.Context) error {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+	}
+
+	packID, cardID, err := parseCardParams(c)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	var req CardMediaRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "cannot parse body: " + err.Error()})
+	}
+
+	ctx := c.Request().Context()
+	card, found, err := s.editableCard(ctx, userID, packID, cardID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "db error: " + err.Error()})
+	}
+	if !found || card.DeletedAt.Valid {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "card not found"})
+	}
+	before, err := s.cardSnapshot(ctx, card)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "db error: " + err.Error()})
+	}
+
+	hashes, err := s.checkMedia(ctx, req.Media)
+	if err != nil {
+		if errors.Is(err, errMediaNotFound) {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "unknown media"})
+		}
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "db error: " + err.Error()})
+	}
+	if err := s.db.ReplaceCardMedia(ctx, db.ReplaceCardMediaParams{CardID: cardID, Hashes: hashes}); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "db error: " + err.Error()})
+	}
+	after := exportCardOf(card, hashes)
+	if err := s.recordRevision(ctx, card, revisionUpdate, &before, &after, userID); err != nil {
+		c.Logger().Warn("failed to record card revision:", err)
+	}
+
+	out := cardJSON(card)
+	if err := s.withMedia(ctx, []map[string]interface{}{out}); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "db error: " + err.Error()})
+	}
+	return c.JSON(http.StatusOK, out)
+}
+
+/* ------------------  EXPORT  ------------------ */
+
+type exportCard struct {
+	Question      string   `json:"question"`
+	Answer        string   `json:"answer"`
+	CardType      string   `json:"card_type"`
+	ContentFormat string   `json:"content_format"`
+	Direction     *string  `json:"direction"`
+	Media         []string `json:"media"`
+}
+
+type exportMedia struct {
+	Hash        string `json:"hash"`
+	File        string `json:"file"`
+	ContentType string `json:"content_type"`
+}
+
+type exportPack struct {
+	Name       string        `json:"name"`
+	Direction  string        `json:"direction"`
+	ExportedAt time.Time     `json:"exported_at"`
+	Cards      []exportCard  `json:"cards"`
+	Media      []exportMedia `json:"media"`
+}
+
+// ExportPack streams a zip with pack.json and every attachment under
+// media/<hash>.
+func (s *Server) ExportPack(c echo.Context) error {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+	}
+
+	var packID pgtype.UUID
+	if err := packID.Scan(c.Param("pack_id")); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid pack_id"})
+	}
+
+	ctx := c.Request().Context()
+	visible, err := s.db.PackVisible(ctx, db.PackVisibleParams{PackID: packID, UserID: userID})
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "db error: " + err.Error()})
+	}
+	if !visible {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "pack not found"})
+	}
+
+	pack, err := s.db.ReadPack(ctx, packID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "db error: " + err.Error()})
+	}
+	cards, err := s.db.ListPackCards(ctx, packID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "db error: " + err.Error()})
+	}
+	files, err := s.db.ListPackMedia(ctx, packID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "db error: " + err.Error()})
+	}
+	ids := make([]pgtype.UUID, 0, len(cards))
+	for _, card := range cards {
+		ids = append(ids, card.ID)
+	}
+	attached, err := s.db.ListCardMedia(ctx, ids)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "db error: " + err.Error()})
+	}
+
+	byCard := map[pgtype.UUID][]string{}
+	for _, a := range attached {
+		byCard[a.CardID] = append(byCard[a.CardID], a.Hash)
+	}
+	manifest := exportPack{
+		Name:       pack.Name,
+		Direction:  pack.Direction,
+		ExportedAt: time.Now().UTC(),
+		Cards:      make([]exportCard, 0, len(cards)),
+		Media:      make([]exportMedia, 0, len(files)),
+	}
+	for _, card := range cards {
+		manifest.Cards = append(manifest.Cards, exportCardOf(card, byCard[card.ID]))
+	}
+	for _, f := range files {
+		manifest.Media = append(manifest.Media, exportMedia{Hash: f.Hash, File: "media/" + f.Hash, ContentType: f.ContentType})
+	}
+
+	h := c.Response().Header()
+	h.Set(echo.HeaderContentType, "application/zip")
+	h.Set(echo.HeaderContentDisposition, fmt.Sprintf("attachment; filename=%q", "pack-"+packID.String()+".zip"))
+	c.Response().WriteHeader(http.StatusOK)
+
+	// the status is already sent, failures below can only be logged
+	zw := zip.NewWriter(c.Response())
+	w, err := zw.Create("pack.json")
+	if err == nil {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		err = enc.Encode(manifest)
+	}
+	for _, f := range files {
+		if err != nil {
+			break
+		}
+		err = s.copyBlob(ctx, zw, f)
+	}
+	if err == nil {
+		err = zw.Close()
+	}
+	if err != nil {
+		c.Logger().Warn("pack export failed:", err)
+	}
+	return nil
+}
+
+func (s *Server) copyBlob(ctx context.Context, zw *zip.Writer, m db.Medium) error {
+	blob, err := s.cfg.Media.Open(ctx, m.Hash)
+	if err != nil {
+		return err
+	}
+	defer blob.Close()
+
+	// media is already compressed
+	w, err := zw.CreateHeader(&zip.FileHeader{Name: "media/" + m.Hash, Method: zip.Store})
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(w, blob)
+	return err
+}
+
+// collectMedia removes media no card refers to anymore. Each hash is
+// deleted under the lock UploadMedia takes, and its row is only gone once
+// the blob is, so a blob that fails to delete is tried again next time.
+func (s *Server) collectMedia(ctx context.Context) (int, error) {
+	before := pgtype.Timestamptz{Time: time.Now().Add(-orphanMediaGrace), Valid: true}
+	hashes, err := s.db.ListOrphanMedia(ctx, before)
+	if err != nil {
+		return 0, err
+	}
+	removed := 0
+	var firstErr error
+	for _, h := range hashes {
+		err := s.inTx(ctx, func(tx *Server) error {
+			if err := tx.db.LockMedia(ctx, h); err != nil {
+				return err
+			}
+			n, err := tx.db.DeleteOrphanMedium(ctx, db.DeleteOrphanMediumParams{Hash: h, Before: before})
+			if err != nil || n == 0 {
+				return err
+			}
+			if err := tx.cfg.Media.Delete(ctx, h); err != nil {
+				return err
+			}
+			removed++
+			return nil
+		})
+		if err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return removed, firstErr
+}
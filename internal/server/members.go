@@ -0,0 +1,359 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/labstack/echo/v4"
+
+	db "dailycards/internal/database"
+)
+
+/* ------------------  PACK MEMBERS  ------------------ */
+
+// Roles in a pack. Owners manage the pack and its members, editors change
+// its cards and viewers can study it whatever its visibility.
+const (
+	roleOwner  = "owner"
+	roleEditor = "editor"
+	roleViewer = "viewer"
+)
+
+func validRole(r string) bool {
+	return r == roleOwner || r == roleEditor || r == roleViewer
+}
+
+// requirePackRole checks that userID has one of roles in packID. When not,
+// the response has been written and ok is false: 404 for those who have no
+// role at all, 403 for the others.
+func (s *Server) requirePackRole(c echo.Context, packID, userID pgtype.UUID, roles ...string) (ok bool, err error) {
+	role, err := s.db.PackRole(c.Request().Context(), db.PackRoleParams{PackID: packID, UserID: userID})
+	if err != nil {
+		return false, c.JSON(http.StatusInternalServerError, map[string]string{"error": "db error: " + err.Error()})
+	}
+	if role == "" {
+		return false, c.JSON(http.StatusNotFound, map[string]string{"error": "pack not found"})
+	}
+	for _, r := range roles {
+		if role == r {
+			return true, nil
+		}
+	}
+	return false, c.JSON(http.StatusForbidden, map[string]string{"error": "your role in this pack does not allow this"})
+}
+
+func packMemberJSON(m db.ListPackMembersRow) map[string]interface{} {
+	out := map[string]interface{}{
+		"user_id":     m.UserID.String(),
+		"username":    m.Username,
+		"role":        m.Role,
+		"invited_by":  m.InvitedBy,
+		"invited_at":  m.InvitedAt.Time,
+		"accepted_at": nil,
+	}
+	if m.AcceptedAt.Valid {
+		out["accepted_at"] = m.AcceptedAt.Time
+	}
+	return out
+}
+
+// ListPackMembers lists the owner, members and pending invitations of a
+// pack to everybody who has a role in it.
+func (s *Server) ListPackMembers(c echo.Context) error {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+	}
+
+	var packID pgtype.UUID
+	if err := packID.Scan(c.Param("id")); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid pack id"})
+	}
+	if ok, err := s.requirePackRole(c, packID, userID, roleOwner, roleEditor, roleViewer); !ok {
+		return err
+	}
+
+	members, err := s.db.ListPackMembers(c.Request().Context(), packID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "db error: " + err.Error()})
+	}
+	result := make([]map[string]interface{}, 0, len(members))
+	for _, m := range members {
+		result = append(result, packMemberJSON(m))
+	}
+	return c.JSON(http.StatusOK, result)
+}
+
+type InvitePackMemberRequest struct {
+	Username string `json:"username"`
+	Role     string `json:"role"` // owner, editor or viewer (default)
+}
+
+// InvitePackMember invites a user into a pack. The role applies once they
+// accept through AcceptInvitation.
+func (s *Server) InvitePackMember(c echo.Context) error {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+	}
+
+	var packID pgtype.UUID
+	if err := packID.Scan(c.Param("id")); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid pack id"})
+	}
+
+	var req InvitePackMemberRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "cannot parse body: " + err.Error()})
+	}
+	if req.Role == "" {
+		req.Role = roleViewer
+	}
+	if !validRole(req.Role) {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "role must be owner, editor or viewer"})
+	}
+	if req.Username == "" {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "username is required"})
+	}
+
+	if ok, err := s.requirePackRole(c, packID, userID, roleOwner); !ok {
+		return err
+	}
+
+	ctx := c.Request().Context()
+	invitee, err := s.db.GetUserByUsername(ctx, req.Username)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "user not found"})
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "db error: " + err.Error()})
+	}
+	pack, err := s.db.ReadPack(ctx, packID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "db error: " + err.Error()})
+	}
+	if pack.OwnerID == invitee.ID {
+		return c.JSON(http.StatusConflict, map[string]string{"error": "user already owns this pack"})
+	}
+
+	member, err := s.db.InvitePackMember(ctx, db.InvitePackMemberParams{
+		PackID:    packID,
+		UserID:    invitee.ID,
+		Role:      req.Role,
+		InvitedBy: userID,
+	})
+	if errors.Is(err, pgx.ErrNoRows) {
+		return c.JSON(http.StatusConflict, map[string]string{"error": "user is already a member or invited"})
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "db error: " + err.Error()})
+	}
+
+	return c.JSON(http.StatusCreated, map[string]interface{}{
+		"user_id":    member.UserID.String(),
+		"username":   invitee.Username,
+		"role":       member.Role,
+		"invited_at": member.CreatedAt.Time,
+	})
+}
+
+// UpdatePackMember changes the role of a member or invitation.
+func (s *Server) UpdatePackMember(c echo.Context) error {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+	}
+
+	var packID, memberID pgtype.UUID
+	if err := packID.Scan(c.Param("id")); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid pack id"})
+	}
+	if err := memberID.Scan(c.Param("user_id")); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid user id"})
+	}
+
+	var req struct {
+		Role string `json:"role"`
+	}
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "cannot parse body: " + err.Error()})
+	}
+	if !validRole(req.Role) {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "role must be owner, editor or viewer"})
+	}
+
+	if ok, err := s.requirePackRole(c, packID, userID, roleOwner); !ok {
+		return err
+	}
+
+	member, err := s.db.SetPackMemberRole(c.Request().Context(), db.SetPackMemberRoleParams{
+		PackID: packID,
+		UserID: memberID,
+		Role:   req.Role,
+	})
+	if errors.Is(err, pgx.ErrNoRows) {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "member not found"})
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "db error: " + err.Error()})
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"user_id": member.UserID.String(),
+		"role":    member.Role,
+	})
+}
+
+// RemovePackMember takes a member or invitation out of a pack. Owners can
+// remove anybody but the user who owns the pack; members can leave.
+func (s *Server) RemovePackMember(c echo.Context) error {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+	}
+
+	var packID, memberID pgtype.UUID
+	if err := packID.Scan(c.Param("id")); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid pack id"})
+	}
+	if err := memberID.Scan(c.Param("user_id")); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid user id"})
+	}
+
+	if memberID != userID {
+		if ok, err := s.requirePackRole(c, packID, userID, roleOwner); !ok {
+			return err
+		}
+	}
+
+	n, err := s.db.RemovePackMember(c.Request().Context(), db.RemovePackMemberParams{PackID: packID, UserID: memberID})
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "db error: " + err.Error()})
+	}
+	if n == 0 {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "member not found"})
+	}
+	return c.NoContent(http.StatusNoContent)
+}
+
+// ListInvitations lists the pack invitations the user has not answered.
+func (s *Server) ListInvitations(c echo.Context) error {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+	}
+
+	invitations, err := s.db.ListInvitations(c.Request().Context(), userID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "db error: " + err.Error()})
+	}
+	result := make([]map[string]interface{}, 0, len(invitations))
+	for _, inv := range invitations {
+		result = append(result, map[string]interface{}{
+			"pack_id":    inv.PackID.String(),
+			"pack_name":  inv.PackName,
+			"role":       inv.Role,
+			"invited_by": map[string]interface{}{"id": inv.InvitedBy, "username": inv.InvitedByUsername},
+			"invited_at": inv.CreatedAt.Time,
+		})
+	}
+	return c.JSON(http.StatusOK, result)
+}
+
+func (s *Server) AcceptInvitation(c echo.Context) error {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+	}
+
+	var packID pgtype.UUID
+	if err := packID.Scan(c.Param("pack_id")); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid pack id"})
+	}
+
+	member, err := s.db.AcceptPackInvitation(c.Request().Context(), db.AcceptPackInvitationParams{PackID: packID, UserID: userID})
+	if errors.Is(err, pgx.ErrNoRows) {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "invitation not found"})
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "db error: " + err.Error()})
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"pack_id":     member.PackID.String(),
+		"role":        member.Role,
+		"accepted_at": member.AcceptedAt.Time,
+	})
+}
+
+/* ------------------  AUDIT  ------------------ */
+
+var auditSortFields = map[string]sortField{
+	"created_at": {kind: sortTime, defaultDesc: true},
+}
+
+// PackAudit lists who created, changed, deleted or restored which card of
+// a pack, newest first. ?user= narrows it to the changes of one user.
+func (s *Server) PackAudit(c echo.Context) error {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+	}
+
+	var packID pgtype.UUID
+	if err := packID.Scan(c.Param("id")); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid pack id"})
+	}
+
+	page, err := parsePageRequest(c, auditSortFields, "created_at")
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	if !page.Desc {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "the audit log is only sorted newest first"})
+	}
+	cursorID, _, cursorTime, _, err := page.cursorArgs()
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid cursor"})
+	}
+	params := db.ListPackRevisionsParams{
+		PackID:     packID,
+		CursorID:   cursorID,
+		CursorTime: cursorTime,
+		PageLimit:  page.Limit + 1,
+	}
+	if raw := c.QueryParam("user"); raw != "" {
+		if err := params.ChangedBy.Scan(raw); err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid user"})
+		}
+	}
+
+	if ok, err := s.requirePackRole(c, packID, userID, roleOwner, roleEditor); !ok {
+		return err
+	}
+
+	revisions, err := s.db.ListPackRevisions(c.Request().Context(), params)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "db error: " + err.Error()})
+	}
+	if len(revisions) > int(page.Limit) {
+		revisions = revisions[:page.Limit]
+		last := revisions[len(revisions)-1]
+		setNextPage(c, pageCursor{ID: last.ID.String(), Value: timeCursorValue(last.CreatedAt)})
+	}
+
+	result := make([]map[string]interface{}, 0, len(revisions))
+	for _, r := range revisions {
+		result = append(result, map[string]interface{}{
+			"id":         r.ID.String(),
+			"card_id":    r.CardID.String(),
+			"action":     r.Action,
+			"question":   r.Question,
+			"changed_by": map[string]interface{}{"id": r.ChangedBy, "username": r.Username},
+			"created_at": r.CreatedAt.Time,
+		})
+	}
+	return c.JSON(http.StatusOK, result)
+}
@@ -0,0 +1,672 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/labstack/echo/v4"
+
+	db "dailycards/internal/database"
+)
+
+/* ------------------  ORGANIZATIONS  ------------------ */
+
+// Roles in an organization. Admins manage its members, packs and
+// assignments; members study the packs of the organization.
+const (
+	orgRoleAdmin  = "admin"
+	orgRoleMember = "member"
+)
+
+const maxOrgNameLen = 100
+
+func validOrgRole(r string) bool {
+	return r == orgRoleAdmin || r == orgRoleMember
+}
+
+// requireOrgRole checks that userID has one of roles in orgID, like
+// requirePackRole does for packs.
+func (s *Server) requireOrgRole(c echo.Context, orgID, userID pgtype.UUID, roles ...string) (ok bool, err error) {
+	role, err := s.db.OrgRole(c.Request().Context(), db.OrgRoleParams{OrgID: orgID, UserID: userID})
+	if err != nil {
+		return false, c.JSON(http.StatusInternalServerError, map[string]string{"error": "db error: " + err.Error()})
+	}
+	if role == "" {
+		return false, c.JSON(http.StatusNotFound, map[string]string{"error": "organization not found"})
+	}
+	for _, r := range roles {
+		if role == r {
+			return true, nil
+		}
+	}
+	return false, c.JSON(http.StatusForbidden, map[string]string{"error": "only admins of the organization can do this"})
+}
+
+func orgName(raw string) (string, error) {
+	name := strings.TrimSpace(raw)
+	if name == "" {
+		return "", errors.New("name must not be empty")
+	}
+	if utf8.RuneCountInString(name) > maxOrgNameLen {
+		return "", errors.New("organization name is too long")
+	}
+	return name, nil
+}
+
+// CreateOrganization creates an organization with the user as its admin.
+func (s *Server) CreateOrganization(c echo.Context) error {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+	}
+
+	var req struct {
+		Name string `json:"name"`
+	}
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "cannot parse body: " + err.Error()})
+	}
+	name, err := orgName(req.Name)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	org, err := s.db.CreateOrganization(c.Request().Context(), db.CreateOrganizationParams{Name: name, CreatedBy: userID})
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+			return c.JSON(http.StatusConflict, map[string]string{"error": "organization with this name already exists"})
+		}
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "db error: " + err.Error()})
+	}
+
+	return c.JSON(http.StatusCreated, map[string]interface{}{
+		"id":         org.ID.String(),
+		"name":       org.Name,
+		"role":       orgRoleAdmin,
+		"created_at": org.CreatedAt.Time,
+	})
+}
+
+// ListOrganizations lists the organizations the user belongs to.
+func (s *Server) ListOrganizations(c echo.Context) error {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+	}
+
+	orgs, err := s.db.ListUserOrganizations(c.Request().Context(), userID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "db error: " + err.Error()})
+	}
+	result := make([]map[string]interface{}, 0, len(orgs))
+	for _, o := range orgs {
+		result = append(result, map[string]interface{}{
+			"id":           o.ID.String(),
+			"name":         o.Name,
+			"role":         o.Role,
+			"member_count": o.MemberCount,
+			"joined_at":    o.JoinedAt.Time,
+		})
+	}
+	return c.JSON(http.StatusOK, result)
+}
+
+// GetOrganization returns an organization and its members. Admins also see
+// the user_stats counters of every member.
+func (s *Server) GetOrganization(c echo.Context) error {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+	}
+
+	var orgID pgtype.UUID
+	if err := orgID.Scan(c.Param("id")); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid organization id"})
+	}
+
+	ctx := c.Request().Context()
+	role, err := s.db.OrgRole(ctx, db.OrgRoleParams{OrgID: orgID, UserID: userID})
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "db error: " + err.Error()})
+	}
+	if role == "" {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "organization not found"})
+	}
+	org, err := s.db.ReadOrganization(ctx, orgID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "db error: " + err.Error()})
+	}
+	members, err := s.db.ListOrgMembers(ctx, orgID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "db error: " + err.Error()})
+	}
+
+	memberList := make([]map[string]interface{}, 0, len(members))
+	for _, m := range members {
+		member := map[string]interface{}{
+			"user_id":   m.UserID.String(),
+			"username":  m.Username,
+			"role":      m.Role,
+			"joined_at": m.JoinedAt.Time,
+		}
+		if role == orgRoleAdmin {
+			member["stats"] = map[string]int32{
+				"rating":         m.Rating,
+				"packs_created":  m.PacksCreated,
+				"packs_mastered": m.PacksMastered,
+			}
+		}
+		memberList = append(memberList, member)
+	}
+
+	out := map[string]interface{}{
+		"id":         org.ID.String(),
+		"name":       org.Name,
+		"role":       role,
+		"members":    memberList,
+		"created_at": org.CreatedAt.Time,
+		"updated_at": org.UpdatedAt.Time,
+	}
+	if role == orgRoleAdmin {
+		pending, err := s.db.ListPendingOrgMembers(ctx, orgID)
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{"error": "db error: " + err.Error()})
+		}
+		invited := make([]map[string]interface{}, 0, len(pending))
+		for _, p := range pending {
+			invited = append(invited, map[string]interface{}{
+				"user_id":    p.UserID.String(),
+				"username":   p.Username,
+				"role":       p.Role,
+				"invited_at": p.CreatedAt.Time,
+			})
+		}
+		out["invited"] = invited
+	}
+	return c.JSON(http.StatusOK, out)
+}
+
+func (s *Server) RenameOrganization(c echo.Context) error {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+	}
+
+	var orgID pgtype.UUID
+	if err := orgID.Scan(c.Param("id")); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid organization id"})
+	}
+
+	var req struct {
+		Name string `json:"name"`
+	}
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "cannot parse body: " + err.Error()})
+	}
+	name, err := orgName(req.Name)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	if ok, err := s.requireOrgRole(c, orgID, userID, orgRoleAdmin); !ok {
+		return err
+	}
+
+	org, err := s.db.RenameOrganization(c.Request().Context(), db.RenameOrganizationParams{ID: orgID, Name: name})
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+			return c.JSON(http.StatusConflict, map[string]string{"error": "organization with this name already exists"})
+		}
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "db error: " + err.Error()})
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"id":         org.ID.String(),
+		"name":       org.Name,
+		"updated_at": org.UpdatedAt.Time,
+	})
+}
+
+// InviteOrgMember invites a user to an organization by username. They
+// become a member once they accept, see AcceptOrgInvitation.
+func (s *Server) InviteOrgMember(c echo.Context) error {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+	}
+
+	var orgID pgtype.UUID
+	if err := orgID.Scan(c.Param("id")); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid organization id"})
+	}
+
+	var req struct {
+		Username string `json:"username"`
+		Role     string `json:"role"` // admin or member (default)
+	}
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "cannot parse body: " + err.Error()})
+	}
+	if req.Role == "" {
+		req.Role = orgRoleMember
+	}
+	if !validOrgRole(req.Role) {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "role must be admin or member"})
+	}
+	if req.Username == "" {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "username is required"})
+	}
+
+	if ok, err := s.requireOrgRole(c, orgID, userID, orgRoleAdmin); !ok {
+		return err
+	}
+
+	ctx := c.Request().Context()
+	user, err := s.db.GetUserByUsername(ctx, req.Username)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "user not found"})
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "db error: " + err.Error()})
+	}
+
+	member, err := s.db.InviteOrgMember(ctx, db.InviteOrgMemberParams{
+		OrgID:     orgID,
+		UserID:    user.ID,
+		Role:      req.Role,
+		InvitedBy: userID,
+	})
+	if errors.Is(err, pgx.ErrNoRows) {
+		return c.JSON(http.StatusConflict, map[string]string{"error": "user is already a member or invited"})
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "db error: " + err.Error()})
+	}
+
+	return c.JSON(http.StatusCreated, map[string]interface{}{
+		"user_id":    member.UserID.String(),
+		"username":   user.Username,
+		"role":       member.Role,
+		"accepted":   false,
+		"invited_at": member.CreatedAt.Time,
+	})
+}
+
+// ListOrgInvitations lists the organizations the user is invited to.
+func (s *Server) ListOrgInvitations(c echo.Context) error {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+	}
+
+	invitations, err := s.db.ListOrgInvitations(c.Request().Context(), userID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "db error: " + err.Error()})
+	}
+	result := make([]map[string]interface{}, 0, len(invitations))
+	for _, inv := range invitations {
+		result = append(result, map[string]interface{}{
+			"org_id":     inv.OrgID.String(),
+			"org_name":   inv.OrgName,
+			"role":       inv.Role,
+			"invited_by": map[string]interface{}{"id": inv.InvitedBy, "username": inv.InvitedByUsername},
+			"invited_at": inv.CreatedAt.Time,
+		})
+	}
+	return c.JSON(http.StatusOK, result)
+}
+
+// AcceptOrgInvitation joins the organization the user was invited to.
+// Declining is leaving, see RemoveOrgMember.
+func (s *Server) AcceptOrgInvitation(c echo.Context) error {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+	}
+
+	var orgID pgtype.UUID
+	if err := orgID.Scan(c.Param("id")); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid organization id"})
+	}
+
+	member, err := s.db.AcceptOrgInvitation(c.Request().Context(), db.AcceptOrgInvitationParams{OrgID: orgID, UserID: userID})
+	if errors.Is(err, pgx.ErrNoRows) {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "invitation not found"})
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "db error: " + err.Error()})
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"org_id":    member.OrgID.String(),
+		"role":      member.Role,
+		"joined_at": member.JoinedAt.Time,
+	})
+}
+
+func (s *Server) UpdateOrgMember(c echo.Context) error {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+	}
+
+	var orgID, memberID pgtype.UUID
+	if err := orgID.Scan(c.Param("id")); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid organization id"})
+	}
+	if err := memberID.Scan(c.Param("user_id")); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid user id"})
+	}
+
+	var req struct {
+		Role string `json:"role"`
+	}
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "cannot parse body: " + err.Error()})
+	}
+	if !validOrgRole(req.Role) {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "role must be admin or member"})
+	}
+
+	if ok, err := s.requireOrgRole(c, orgID, userID, orgRoleAdmin); !ok {
+		return err
+	}
+
+	member, err := s.db.SetOrgMemberRole(c.Request().Context(), db.SetOrgMemberRoleParams{
+		OrgID:  orgID,
+		UserID: memberID,
+		Role:   req.Role,
+	})
+	if errors.Is(err, pgx.ErrNoRows) {
+		return s.orgMemberMissing(c, orgID, memberID)
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "db error: " + err.Error()})
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"user_id": member.UserID.String(),
+		"role":    member.Role,
+	})
+}
+
+// RemoveOrgMember takes a user out of an organization. Admins can remove
+// anybody, members can leave.
+func (s *Server) RemoveOrgMember(c echo.Context) error {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+	}
+
+	var orgID, memberID pgtype.UUID
+	if err := orgID.Scan(c.Param("id")); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid organization id"})
+	}
+	if err := memberID.Scan(c.Param("user_id")); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid user id"})
+	}
+
+	if memberID != userID {
+		if ok, err := s.requireOrgRole(c, orgID, userID, orgRoleAdmin); !ok {
+			return err
+		}
+	}
+
+	n, err := s.db.RemoveOrgMember(c.Request().Context(), db.RemoveOrgMemberParams{OrgID: orgID, UserID: memberID})
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "db error: " + err.Error()})
+	}
+	if n == 0 {
+		return s.orgMemberMissing(c, orgID, memberID)
+	}
+	return c.NoContent(http.StatusNoContent)
+}
+
+// orgMemberMissing explains why a member could not be changed: either they
+// are not a member or they are the last admin.
+func (s *Server) orgMemberMissing(c echo.Context, orgID, memberID pgtype.UUID) error {
+	role, err := s.db.OrgRole(c.Request().Context(), db.OrgRoleParams{OrgID: orgID, UserID: memberID})
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "db error: " + err.Error()})
+	}
+	if role == "" {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "member not found"})
+	}
+	return c.JSON(http.StatusConflict, map[string]string{"error": "an organization needs at least one admin"})
+}
+
+/* ------------------  ASSIGNMENTS  ------------------ */
+
+type AssignPackRequest struct {
+	PackID string `json:"pack_id"`
+	// UserIDs are the members to assign; empty assigns every member.
+	UserIDs []string   `json:"user_ids,omitempty"`
+	DueAt   *time.Time `json:"due_at,omitempty"`
+}
+
+// AssignPack asks members of an organization to study a pack of the
+// organization, or a public one, and subscribes them to it.
+func (s *Server) AssignPack(c echo.Context) error {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+	}
+
+	var orgID pgtype.UUID
+	if err := orgID.Scan(c.Param("id")); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid organization id"})
+	}
+
+	var req AssignPackRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "cannot parse body: " + err.Error()})
+	}
+	params := db.AssignPackParams{OrgID: orgID, AssignedBy: userID}
+	if err := params.PackID.Scan(req.PackID); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid pack_id"})
+	}
+	if len(req.UserIDs) > 0 {
+		params.UserIds = make([]pgtype.UUID, len(req.UserIDs))
+		for i, raw := range req.UserIDs {
+			if err := params.UserIds[i].Scan(raw); err != nil {
+				return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid user id " + raw})
+			}
+		}
+	}
+	if req.DueAt != nil {
+		params.DueAt = pgtype.Timestamptz{Time: *req.DueAt, Valid: true}
+	}
+
+	if ok, err := s.requireOrgRole(c, orgID, userID, orgRoleAdmin); !ok {
+		return err
+	}
+
+	ctx := c.Request().Context()
+	pack, err := s.db.ReadPack(ctx, params.PackID)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "db error: " + err.Error()})
+	}
+	if err != nil || (pack.OrgID != orgID && pack.Visibility != visibilityPublic) {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "pack not found"})
+	}
+
+	assigned, err := s.db.AssignPack(ctx, params)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "db error: " + err.Error()})
+	}
+
+	result := make([]map[string]interface{}, 0, len(assigned))
+	done := make(map[pgtype.UUID]bool, len(assigned))
+	for _, a := range assigned {
+		done[a.UserID] = true
+		out := map[string]interface{}{
+			"id":          a.ID.String(),
+			"pack_id":     a.PackID.String(),
+			"user_id":     a.UserID.String(),
+			"due_at":      nil,
+			"assigned_at": a.CreatedAt.Time,
+		}
+		if a.DueAt.Valid {
+			out["due_at"] = a.DueAt.Time
+		}
+		result = append(result, out)
+	}
+	skipped := []string{}
+	for i, id := range params.UserIds {
+		if !done[id] {
+			skipped = append(skipped, req.UserIDs[i])
+		}
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"assignments": result,
+		"not_members": skipped,
+	})
+}
+
+func (s *Server) DeleteAssignment(c echo.Context) error {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+	}
+
+	var orgID, assignmentID pgtype.UUID
+	if err := orgID.Scan(c.Param("id")); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid organization id"})
+	}
+	if err := assignmentID.Scan(c.Param("assignment_id")); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid assignment id"})
+	}
+
+	if ok, err := s.requireOrgRole(c, orgID, userID, orgRoleAdmin); !ok {
+		return err
+	}
+
+	n, err := s.db.DeleteAssignment(c.Request().Context(), db.DeleteAssignmentParams{ID: assignmentID, OrgID: orgID})
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "db error: " + err.Error()})
+	}
+	if n == 0 {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "assignment not found"})
+	}
+	return c.NoContent(http.StatusNoContent)
+}
+
+// assignmentJSON reports how far a member got with an assignment. It is
+// complete once every card of the pack has been studied at least once.
+func assignmentJSON(a db.ListAssignmentProgressRow, now time.Time) map[string]interface{} {
+	completed := a.CardCount > 0 && a.StudiedCount >= a.CardCount
+	var percent int64
+	if a.CardCount > 0 {
+		percent = min(100, a.StudiedCount*100/a.CardCount)
+	}
+	out := map[string]interface{}{
+		"id":              a.ID.String(),
+		"org":             map[string]string{"id": a.OrgID.String(), "name": a.OrgName},
+		"pack":            map[string]string{"id": a.PackID.String(), "name": a.PackName},
+		"card_count":      a.CardCount,
+		"studied_count":   a.StudiedCount,
+		"percent":         percent,
+		"completed":       completed,
+		"overdue":         !completed && a.DueAt.Valid && a.DueAt.Time.Before(now),
+		"due_at":          nil,
+		"last_studied_at": nil,
+		"assigned_at":     a.CreatedAt.Time,
+	}
+	if a.DueAt.Valid {
+		out["due_at"] = a.DueAt.Time
+	}
+	if a.LastStudiedAt.Valid {
+		out["last_studied_at"] = a.LastStudiedAt.Time
+	}
+	return out
+}
+
+// OrgProgress is the admin view of an organization: every member with
+// their user_stats counters and the completion of their assignments.
+func (s *Server) OrgProgress(c echo.Context) error {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+	}
+
+	var orgID pgtype.UUID
+	if err := orgID.Scan(c.Param("id")); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid organization id"})
+	}
+
+	if ok, err := s.requireOrgRole(c, orgID, userID, orgRoleAdmin); !ok {
+		return err
+	}
+
+	ctx := c.Request().Context()
+	members, err := s.db.ListOrgMembers(ctx, orgID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "db error: " + err.Error()})
+	}
+	assignments, err := s.db.ListAssignmentProgress(ctx, db.ListAssignmentProgressParams{OrgID: orgID})
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "db error: " + err.Error()})
+	}
+
+	now := time.Now()
+	byUser := make(map[pgtype.UUID][]map[string]interface{})
+	for _, a := range assignments {
+		byUser[a.UserID] = append(byUser[a.UserID], assignmentJSON(a, now))
+	}
+
+	result := make([]map[string]interface{}, 0, len(members))
+	for _, m := range members {
+		list := byUser[m.UserID]
+		completed := 0
+		for _, a := range list {
+			if a["completed"].(bool) {
+				completed++
+			}
+		}
+		if list == nil {
+			list = []map[string]interface{}{}
+		}
+		result = append(result, map[string]interface{}{
+			"user_id":  m.UserID.String(),
+			"username": m.Username,
+			"role":     m.Role,
+			"stats": map[string]int32{
+				"rating":         m.Rating,
+				"packs_created":  m.PacksCreated,
+				"packs_mastered": m.PacksMastered,
+			},
+			"assigned":    len(list),
+			"completed":   completed,
+			"assignments": list,
+		})
+	}
+	return c.JSON(http.StatusOK, result)
+}
+
+// MyAssignments lists the packs the user was assigned in any organization.
+func (s *Server) MyAssignments(c echo.Context) error {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+	}
+
+	assignments, err := s.db.ListAssignmentProgress(c.Request().Context(), db.ListAssignmentProgressParams{UserID: userID})
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "db error: " + err.Error()})
+	}
+	now := time.Now()
+	result := make([]map[string]interface{}, 0, len(assignments))
+	for _, a := range assignments {
+		result = append(result, assignmentJSON(a, now))
+	}
+	return c.JSON(http.StatusOK, result)
+}
@@ -0,0 +1,262 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/labstack/echo/v4"
+	"golang.org/x/text/language"
+
+	db "dailycards/internal/database"
+)
+
+/* ------------------  PACK METADATA  ------------------ */
+
+// Pack visibility. Unlisted packs can be studied by anybody who knows the
+// id but only public ones are listed.
+const (
+	visibilityPrivate  = "private"
+	visibilityUnlisted = "unlisted"
+	visibilityPublic   = "public"
+)
+
+const (
+	maxPackNameLen        = 100
+	maxPackDescriptionLen = 2000
+	maxCoverIconLen       = 32
+)
+
+var coverColorRe = regexp.MustCompile(`^#[0-9a-f]{6}$`)
+
+func validVisibility(v string) bool {
+	return v == visibilityPrivate || v == visibilityUnlisted || v == visibilityPublic
+}
+
+// PackMeta is the descriptive part of a pack. Empty strings leave a field
+// unset; languages are BCP 47 tags such as "en" or "pt-BR".
+type PackMeta struct {
+	Description string `json:"description,omitempty"`
+	SourceLang  string `json:"source_lang,omitempty"`
+	TargetLang  string `json:"target_lang,omitempty"`
+	CoverColor  string `json:"cover_color,omitempty"` // "#rrggbb"
+	CoverIcon   string `json:"cover_icon,omitempty"`  // icon name or emoji
+	Visibility  string `json:"visibility,omitempty"`  // private (default), unlisted, public
+}
+
+// normalize validates m and brings it into the stored form.
+func (m *PackMeta) normalize() error {
+	m.Description = strings.TrimSpace(m.Description)
+	if utf8.RuneCountInString(m.Description) > maxPackDescriptionLen {
+		return errors.New("description is limited to 2000 characters")
+	}
+
+	for _, lang := range []*string{&m.SourceLang, &m.TargetLang} {
+		if *lang == "" {
+			continue
+		}
+		tag, err := language.Parse(*lang)
+		if err != nil {
+			return errors.New("invalid language " + *lang)
+		}
+		*lang = tag.String()
+	}
+
+	m.CoverColor = strings.ToLower(m.CoverColor)
+	if m.CoverColor != "" && !coverColorRe.MatchString(m.CoverColor) {
+		return errors.New("cover_color must look like #1e90ff")
+	}
+	m.CoverIcon = strings.TrimSpace(m.CoverIcon)
+	if utf8.RuneCountInString(m.CoverIcon) > maxCoverIconLen {
+		return errors.New("cover_icon is limited to 32 characters")
+	}
+
+	if m.Visibility == "" {
+		m.Visibility = visibilityPrivate
+	}
+	if !validVisibility(m.Visibility) {
+		return errors.New("visibility must be private, unlisted or public")
+	}
+	return nil
+}
+
+func optionalText(s string) pgtype.Text {
+	return pgtype.Text{String: s, Valid: s != ""}
+}
+
+func packMetaOf(pack db.Pack) PackMeta {
+	return PackMeta{
+		Description: pack.Description,
+		SourceLang:  pack.SourceLang.String,
+		TargetLang:  pack.TargetLang.String,
+		CoverColor:  pack.CoverColor.String,
+		CoverIcon:   pack.CoverIcon.String,
+		Visibility:  pack.Visibility,
+	}
+}
+
+func packJSON(pack db.Pack) map[string]interface{} {
+	return map[string]interface{}{
+		"id":          pack.ID.String(),
+		"name":        pack.Name,
+		"category_id": pack.CategoryID,
+		"owner_id":    pack.OwnerID,
+		"org_id":      pack.OrgID,
+		"direction":   pack.Direction,
+		"description": pack.Description,
+		"source_lang": pack.SourceLang,
+		"target_lang": pack.TargetLang,
+		"cover_color": pack.CoverColor,
+		"cover_icon":  pack.CoverIcon,
+		"visibility":  pack.Visibility,
+		"forked_from": pack.ForkedFrom,
+		"created_at":  pack.CreatedAt.Time,
+		"updated_at":  pack.UpdatedAt.Time,
+	}
+}
+
+// GetPack returns one pack the user can see.
+func (s *Server) GetPack(c echo.Context) error {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+	}
+
+	var packID pgtype.UUID
+	if err := packID.Scan(c.Param("id")); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid pack id"})
+	}
+
+	ctx := c.Request().Context()
+	visible, err := s.db.PackVisible(ctx, db.PackVisibleParams{PackID: packID, UserID: userID})
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "db error: " + err.Error()})
+	}
+	if !visible {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "pack not found"})
+	}
+	pack, err := s.db.ReadPack(ctx, packID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "db error: " + err.Error()})
+	}
+	role, err := s.db.PackRole(ctx, db.PackRoleParams{PackID: packID, UserID: userID})
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "db error: " + err.Error()})
+	}
+
+	out := packJSON(pack)
+	out["role"] = role
+	return c.JSON(http.StatusOK, out)
+}
+
+// UpdatePackRequest changes only the fields that are present. An empty
+// string clears an optional field.
+type UpdatePackRequest struct {
+	Name        *string `json:"name"`
+	Category    string  `json:"category"`
+	CategoryID  string  `json:"category_id"`
+	Description *string `json:"description"`
+	SourceLang  *string `json:"source_lang"`
+	TargetLang  *string `json:"target_lang"`
+	CoverColor  *string `json:"cover_color"`
+	CoverIcon   *string `json:"cover_icon"`
+	Visibility  *string `json:"visibility"`
+}
+
+// UpdatePack renames a pack, moves it to another category or changes its
+// metadata. Only owners can do that.
+func (s *Server) UpdatePack(c echo.Context) error {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+	}
+
+	var packID pgtype.UUID
+	if err := packID.Scan(c.Param("id")); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid pack id"})
+	}
+
+	var req UpdatePackRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "cannot parse body: " + err.Error()})
+	}
+
+	if ok, err := s.requirePackRole(c, packID, userID, roleOwner); !ok {
+		return err
+	}
+	ctx := c.Request().Context()
+	pack, err := s.db.ReadPack(ctx, packID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "db error: " + err.Error()})
+	}
+
+	name := pack.Name
+	if req.Name != nil {
+		name = strings.TrimSpace(*req.Name)
+		if name == "" {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "name must not be empty"})
+		}
+		if utf8.RuneCountInString(name) > maxPackNameLen {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "pack name is too long"})
+		}
+	}
+
+	categoryID := pack.CategoryID
+	if req.Category != "" || req.CategoryID != "" {
+		category, err := s.resolveCategory(ctx, userID, req.CategoryID, req.Category)
+		if err != nil {
+			if errors.Is(err, errCategoryNotFound) {
+				return c.JSON(http.StatusNotFound, map[string]string{"error": "category not found"})
+			}
+			return c.JSON(http.StatusInternalServerError, map[string]string{"error": "db error: " + err.Error()})
+		}
+		categoryID = category.ID
+	}
+
+	meta := packMetaOf(pack)
+	for dst, src := range map[*string]*string{
+		&meta.Description: req.Description,
+		&meta.SourceLang:  req.SourceLang,
+		&meta.TargetLang:  req.TargetLang,
+		&meta.CoverColor:  req.CoverColor,
+		&meta.CoverIcon:   req.CoverIcon,
+		&meta.Visibility:  req.Visibility,
+	} {
+		if src != nil {
+			*dst = *src
+		}
+	}
+	if err := meta.normalize(); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	pack, err = s.db.UpdatePack(ctx, db.UpdatePackParams{
+		ID:          packID,
+		UserID:      userID,
+		Name:        name,
+		CategoryID:  categoryID,
+		Description: meta.Description,
+		SourceLang:  optionalText(meta.SourceLang),
+		TargetLang:  optionalText(meta.TargetLang),
+		CoverColor:  optionalText(meta.CoverColor),
+		CoverIcon:   optionalText(meta.CoverIcon),
+		Visibility:  meta.Visibility,
+	})
+	if err != nil {
+		var pgErr *pgconn.PgError
+		switch {
+		case errors.Is(err, pgx.ErrNoRows):
+			return c.JSON(http.StatusNotFound, map[string]string{"error": "pack not found"})
+		case errors.As(err, &pgErr) && pgErr.Code == "23505":
+			return c.JSON(http.StatusConflict, map[string]string{"error": "pack with this name already exists"})
+		}
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "db error: " + err.Error()})
+	}
+
+	return c.JSON(http.StatusOK, packJSON(pack))
+}
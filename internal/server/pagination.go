@@ -0,0 +1,154 @@
+package server
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/labstack/echo/v4"
+)
+
+/* ------------------  PAGINATION  ------------------ */
+
+const (
+	defaultPageLimit = 100
+	maxPageLimit     = 500
+)
+
+// sortKind describes how the value of a sortable field is carried in a cursor.
+type sortKind int
+
+const (
+	sortText sortKind = iota
+	sortTime
+	sortCount
+)
+
+type sortField struct {
+	kind        sortKind
+	defaultDesc bool
+}
+
+// pageRequest is the parsed form of ?cursor=&limit=&sort=&order=.
+type pageRequest struct {
+	Limit  int32
+	SortBy string
+	Desc   bool
+	Cursor *pageCursor
+	kind   sortKind
+}
+
+// pageCursor points at the last row of the previous page.
+type pageCursor struct {
+	Value string `json:"v"`
+	ID    string `json:"id"`
+}
+
+func parsePageRequest(c echo.Context, fields map[string]sortField, defaultSort string) (pageRequest, error) {
+	req := pageRequest{Limit: defaultPageLimit, SortBy: defaultSort}
+
+	if raw := c.QueryParam("limit"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n <= 0 {
+			return req, errors.New("invalid limit")
+		}
+		if n > maxPageLimit {
+			n = maxPageLimit
+		}
+		req.Limit = int32(n)
+	}
+
+	if raw := c.QueryParam("sort"); raw != "" {
+		req.SortBy = raw
+	}
+	field, ok := fields[req.SortBy]
+	if !ok {
+		return req, errors.New("invalid sort field")
+	}
+	req.kind = field.kind
+	req.Desc = field.defaultDesc
+
+	switch c.QueryParam("order") {
+	case "":
+	case "asc":
+		req.Desc = false
+	case "desc":
+		req.Desc = true
+	default:
+		return req, errors.New("invalid order, expected asc or desc")
+	}
+
+	if raw := c.QueryParam("cursor"); raw != "" {
+		cur, err := decodeCursor(raw)
+		if err != nil {
+			return req, errors.New("invalid cursor")
+		}
+		req.Cursor = &cur
+	}
+
+	return req, nil
+}
+
+func encodeCursor(cur pageCursor) string {
+	b, _ := json.Marshal(cur)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func decodeCursor(raw string) (pageCursor, error) {
+	var cur pageCursor
+	b, err := base64.RawURLEncoding.DecodeString(raw)
+	if err != nil {
+		return cur, err
+	}
+	if err := json.Unmarshal(b, &cur); err != nil {
+		return cur, err
+	}
+	return cur, nil
+}
+
+// cursorArgs converts the cursor into the typed cursor_* query arguments.
+// Only the argument matching the sort kind is filled in.
+func (p pageRequest) cursorArgs() (id pgtype.UUID, name pgtype.Text, at pgtype.Timestamptz, count int64, err error) {
+	if p.Cursor == nil {
+		return
+	}
+	if err = id.Scan(p.Cursor.ID); err != nil {
+		return
+	}
+	switch p.kind {
+	case sortText:
+		name = pgtype.Text{String: p.Cursor.Value, Valid: true}
+	case sortTime:
+		var t time.Time
+		if t, err = time.Parse(time.RFC3339Nano, p.Cursor.Value); err != nil {
+			return
+		}
+		at = pgtype.Timestamptz{Time: t, Valid: true}
+	case sortCount:
+		count, err = strconv.ParseInt(p.Cursor.Value, 10, 64)
+	}
+	return
+}
+
+func timeCursorValue(t pgtype.Timestamptz) string {
+	return t.Time.UTC().Format(time.RFC3339Nano)
+}
+
+// setNextPage advertises the next page both as an RFC 8288 Link header and
+// as X-Next-Cursor, so the response body keeps being a plain array.
+func setNextPage(c echo.Context, cur pageCursor) {
+	token := encodeCursor(cur)
+
+	u := *c.Request().URL
+	q := u.Query()
+	q.Set("cursor", token)
+	u.RawQuery = q.Encode()
+
+	next := url.URL{Path: u.Path, RawQuery: u.RawQuery}
+	c.Response().Header().Set("Link", "<"+next.String()+`>; rel="next"`)
+	c.Response().Header().Set("X-Next-Cursor", token)
+}
@@ -0,0 +1,325 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/labstack/echo/v4"
+
+	"dailycards/internal/cloze"
+	db "dailycards/internal/database"
+)
+
+/* ------------------  PROMPTS  ------------------ */
+
+// Study directions. A pack or card set to "both" yields one forward and one
+// reverse prompt, each scheduled on its own.
+const (
+	dirForward = "forward"
+	dirReverse = "reverse"
+	dirBoth    = "both"
+)
+
+// Card types. A cloze card has no direction: each cloze number is a prompt
+// of its own, named "c1", "c2", ... wherever a direction is expected.
+const (
+	cardBasic = "basic"
+	cardCloze = "cloze"
+)
+
+func validDirection(d string) bool {
+	return d == dirForward || d == dirReverse || d == dirBoth
+}
+
+// clozeOrd extracts N from a "cN" prompt direction.
+func clozeOrd(d string) (int, bool) {
+	raw, found := strings.CutPrefix(d, "c")
+	if !found {
+		return 0, false
+	}
+	ord, err := strconv.Atoi(raw)
+	return ord, err == nil && ord > 0
+}
+
+// promptDirection is d when it names a single prompt of a card, forward
+// otherwise.
+func promptDirection(d string) string {
+	if _, ok := clozeOrd(d); ok || d == dirReverse {
+		return d
+	}
+	return dirForward
+}
+
+// studyPrompt is one reviewable side of a card.
+type studyPrompt struct {
+	CardID        pgtype.UUID
+	PackID        pgtype.UUID
+	Question      string
+	Answer        string
+	Rating        int32
+	LastWrong     bool
+	CardType      string
+	ContentFormat string
+	Direction     string
+}
+
+func promptFromCard(card db.Card, direction string) studyPrompt {
+	return studyPrompt{
+		CardID:        card.ID,
+		PackID:        card.PackID,
+		Question:      card.Question,
+		Answer:        card.Answer,
+		Rating:        card.Rating.Int32,
+		LastWrong:     card.LastWrong.Bool,
+		CardType:      card.CardType,
+		ContentFormat: card.ContentFormat,
+		Direction:     direction,
+	}
+}
+
+// promptKey identifies a prompt inside a session queue.
+func promptKey(cardID pgtype.UUID, direction string) string {
+	return cardID.String() + ":" + direction
+}
+
+// parsePromptKey accepts "<card_id>:<direction>" and a bare card id, which
+// is what sessions started before directions existed contain.
+func parsePromptKey(key string) (pgtype.UUID, string, error) {
+	raw, direction, found := strings.Cut(key, ":")
+	if !found {
+		direction = dirForward
+	}
+	var id pgtype.UUID
+	if err := id.Scan(raw); err != nil {
+		return id, "", err
+	}
+	if promptDirection(direction) != direction {
+		return id, "", errors.New("invalid prompt direction")
+	}
+	return id, direction, nil
+}
+
+func (p studyPrompt) Key() string {
+	return promptKey(p.CardID, p.Direction)
+}
+
+// Sides returns what the prompt asks and what it expects: a reverse prompt
+// asks the answer, a cloze prompt blanks out its deletions.
+func (p studyPrompt) Sides() (question, answer string) {
+	if p.CardType == cardCloze {
+		ord, _ := clozeOrd(p.Direction)
+		note, err := cloze.Parse(p.Question)
+		if err != nil {
+			return p.Question, p.Answer
+		}
+		return note.Render(ord)
+	}
+	if p.Direction == dirReverse {
+		return p.Answer, p.Question
+	}
+	return p.Question, p.Answer
+}
+
+// JSON renders the prompt the way the client shows it, raw and as HTML. The
+// notes of a cloze card come as "extra".
+func (p studyPrompt) JSON() map[string]interface{} {
+	question, answer := p.Sides()
+	out := map[string]interface{}{
+		"id":             p.CardID.String(),
+		"key":            p.Key(),
+		"pack_id":        p.PackID.String(),
+		"card_type":      p.CardType,
+		"content_format": p.ContentFormat,
+		"direction":      p.Direction,
+		"question":       question,
+		"answer":         answer,
+		"question_html":  renderHTML(p.ContentFormat, question),
+		"answer_html":    renderHTML(p.ContentFormat, answer),
+		"rating":         p.Rating,
+		"last_wrong":     p.LastWrong,
+	}
+	if p.CardType == cardCloze && p.Answer != "" {
+		out["extra"] = p.Answer
+		out["extra_html"] = renderHTML(p.ContentFormat, p.Answer)
+	}
+	return out
+}
+
+// expandPrompts creates a prompt for every direction of every card. All
+// reverse prompts come last so both sides of a card are not asked back to
+// back.
+func expandPrompts(cards []studyPrompt, directions [][]string) []studyPrompt {
+	var forward, reverse []studyPrompt
+	for i, card := range cards {
+		for _, d := range directions[i] {
+			p := card
+			p.Direction = d
+			if d == dirReverse {
+				reverse = append(reverse, p)
+			} else {
+				forward = append(forward, p)
+			}
+		}
+	}
+	return append(forward, reverse...)
+}
+
+func repeatPrompts(rows []db.ListRepeatCardsRow) []studyPrompt {
+	cards := make([]studyPrompt, 0, len(rows))
+	directions := make([][]string, 0, len(rows))
+	for _, r := range rows {
+		cards = append(cards, studyPrompt{
+			CardID:        r.ID,
+			PackID:        r.PackID,
+			Question:      r.Question,
+			Answer:        r.Answer,
+			Rating:        r.Rating.Int32,
+			LastWrong:     r.UserLastWrong,
+			CardType:      r.CardType,
+			ContentFormat: r.ContentFormat,
+		})
+		directions = append(directions, r.Directions)
+	}
+	return expandPrompts(cards, directions)
+}
+
+func tagPrompts(rows []db.ListStudyCardsByTagsRow) []studyPrompt {
+	cards := make([]studyPrompt, 0, len(rows))
+	directions := make([][]string, 0, len(rows))
+	for _, r := range rows {
+		cards = append(cards, studyPrompt{
+			CardID:        r.ID,
+			PackID:        r.PackID,
+			Question:      r.Question,
+			Answer:        r.Answer,
+			Rating:        r.Rating.Int32,
+			LastWrong:     r.UserLastWrong,
+			CardType:      r.CardType,
+			ContentFormat: r.ContentFormat,
+		})
+		directions = append(directions, r.Directions)
+	}
+	return expandPrompts(cards, directions)
+}
+
+func dueTagPrompts(rows []db.ListDueCardsByTagsRow) []studyPrompt {
+	prompts := make([]studyPrompt, 0, len(rows))
+	for _, r := range rows {
+		prompts = append(prompts, studyPrompt{
+			CardID:        r.ID,
+			PackID:        r.PackID,
+			Question:      r.Question,
+			Answer:        r.Answer,
+			Rating:        r.Rating.Int32,
+			LastWrong:     r.LastWrong,
+			CardType:      r.CardType,
+			ContentFormat: r.ContentFormat,
+			Direction:     r.Direction,
+		})
+	}
+	return prompts
+}
+
+/* ------------------  DIRECTION SETTINGS  ------------------ */
+
+type DirectionRequest struct {
+	Direction *string `json:"direction"`
+}
+
+func (s *Server) SetPackDirection(c echo.Context) error {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+	}
+
+	var packID pgtype.UUID
+	if err := packID.Scan(c.Param("id")); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid pack id"})
+	}
+
+	var req DirectionRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "cannot parse body: " + err.Error()})
+	}
+	if req.Direction == nil || !validDirection(*req.Direction) {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "direction must be forward, reverse or both"})
+	}
+
+	n, err := s.db.SetPackDirection(c.Request().Context(), db.SetPackDirectionParams{
+		Direction: *req.Direction,
+		ID:        packID,
+		OwnerID:   userID,
+	})
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "db error: " + err.Error()})
+	}
+	if n == 0 {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "pack not found"})
+	}
+
+	return c.JSON(http.StatusOK, map[string]string{"direction": *req.Direction})
+}
+
+// SetCardDirection overrides the pack direction for one card. A null
+// direction makes the card follow its pack again.
+func (s *Server) SetCardDirection(c echo.Context) error {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+	}
+
+	packID, cardID, err := parseCardParams(c)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	var req DirectionRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "cannot parse body: " + err.Error()})
+	}
+	direction := pgtype.Text{}
+	if req.Direction != nil {
+		if !validDirection(*req.Direction) {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "direction must be forward, reverse, both or null"})
+		}
+		direction = pgtype.Text{String: *req.Direction, Valid: true}
+	}
+
+	ctx := c.Request().Context()
+	card, err := s.db.ReadCard(ctx, cardID)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "db error: " + err.Error()})
+	}
+	if err != nil || card.PackID != packID {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "card not found"})
+	}
+	before, err := s.cardSnapshot(ctx, card)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "db error: " + err.Error()})
+	}
+
+	n, err := s.db.SetCardDirection(ctx, db.SetCardDirectionParams{
+		Direction: direction,
+		ID:        cardID,
+		PackID:    packID,
+		OwnerID:   userID,
+	})
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "db error: " + err.Error()})
+	}
+	if n == 0 {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "card not found"})
+	}
+
+	after := before
+	after.Direction = req.Direction
+	if err := s.recordRevision(ctx, card, revisionUpdate, &before, &after, userID); err != nil {
+		c.Logger().Warn("failed to record card revision:", err)
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{"direction": req.Direction})
+}
@@ -0,0 +1,178 @@
+package server
+
+import (
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/labstack/echo/v4"
+
+	db "dailycards/internal/database"
+	"dailycards/internal/quiz"
+)
+
+/* ------------------  QUIZ  ------------------ */
+
+const (
+	defaultQuizSize = 20
+	maxQuizSize     = 100
+)
+
+type quizParams struct {
+	N       int
+	Options int
+	Seed    uint64
+}
+
+// parseQuizParams reads ?type=mcq&n=&options=&seed=. Without a seed a random
+// one is chosen; it is returned with the quiz so it can be replayed.
+func parseQuizParams(c echo.Context) (quizParams, string) {
+	p := quizParams{N: defaultQuizSize, Options: quiz.DefaultOptions, Seed: rand.Uint64()}
+
+	if t := c.QueryParam("type"); t != "" && t != "mcq" {
+		return p, "unsupported quiz type"
+	}
+	if raw := c.QueryParam("n"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n <= 0 {
+			return p, "invalid n"
+		}
+		p.N = min(n, maxQuizSize)
+	}
+	if raw := c.QueryParam("options"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < quiz.MinOptions || n > quiz.MaxOptions {
+			return p, "options must be between 2 and 6"
+		}
+		p.Options = n
+	}
+	if raw := c.QueryParam("seed"); raw != "" {
+		seed, err := strconv.ParseUint(raw, 10, 64)
+		if err != nil {
+			return p, "invalid seed"
+		}
+		p.Seed = seed
+	}
+	return p, ""
+}
+
+// quizJSON turns prompts into the quiz response. Answers are submitted like
+// any other typed answer (FinishPack, /study/finish) with the chosen option
+// as "answer", so they are graded and scheduled as usual.
+func quizJSON(prompts []studyPrompt, p quizParams) map[string]interface{} {
+	byKey := make(map[string]studyPrompt, len(prompts))
+	items := make([]quiz.Item, 0, len(prompts))
+	for _, pr := range prompts {
+		byKey[pr.Key()] = pr
+		question, answer := pr.Sides()
+		group := pr.Direction
+		if pr.CardType == cardCloze {
+			group = cardCloze
+		}
+		items = append(items, quiz.Item{
+			Key:      pr.Key(),
+			Group:    group,
+			Question: question,
+			Answer:   answer,
+		})
+	}
+
+	questions := quiz.Build(items, p.N, p.Options, quiz.NewRand(p.Seed))
+	out := make([]map[string]interface{}, 0, len(questions))
+	for _, q := range questions {
+		pr := byKey[q.Key]
+		optionsHTML := make([]string, 0, len(q.Options))
+		for _, o := range q.Options {
+			optionsHTML = append(optionsHTML, renderHTML(pr.ContentFormat, o))
+		}
+		out = append(out, map[string]interface{}{
+			"id":             pr.CardID.String(),
+			"key":            q.Key,
+			"pack_id":        pr.PackID.String(),
+			"direction":      pr.Direction,
+			"content_format": pr.ContentFormat,
+			"question":       q.Question,
+			"question_html":  renderHTML(pr.ContentFormat, q.Question),
+			"options":        q.Options,
+			"options_html":   optionsHTML,
+			"correct_option": q.Correct,
+		})
+	}
+
+	return map[string]interface{}{
+		"type":      "mcq",
+		"seed":      strconv.FormatUint(p.Seed, 10),
+		"questions": out,
+	}
+}
+
+func (s *Server) sendQuiz(c echo.Context, prompts []studyPrompt, p quizParams) error {
+	out := quizJSON(prompts, p)
+	if err := s.withMedia(c.Request().Context(), out["questions"].([]map[string]interface{})); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "db error: " + err.Error()})
+	}
+	return c.JSON(http.StatusOK, out)
+}
+
+// PackQuiz generates a multiple-choice quiz from the cards of one pack.
+func (s *Server) PackQuiz(c echo.Context) error {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+	}
+
+	var packID pgtype.UUID
+	if err := packID.Scan(c.Param("pack_id")); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid pack_id"})
+	}
+	params, msg := parseQuizParams(c)
+	if msg != "" {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": msg})
+	}
+
+	ctx := c.Request().Context()
+	visible, err := s.db.PackVisible(ctx, db.PackVisibleParams{PackID: packID, UserID: userID})
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "db error: " + err.Error()})
+	}
+	if !visible {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "pack not found"})
+	}
+
+	rows, err := s.db.ListRepeatCards(ctx, db.ListRepeatCardsParams{PackID: packID, UserID: userID})
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "db error: " + err.Error()})
+	}
+
+	return s.sendQuiz(c, repeatPrompts(rows), params)
+}
+
+// TagQuiz generates a quiz from the cards carrying any of ?tags=.
+func (s *Server) TagQuiz(c echo.Context) error {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+	}
+
+	names := normalizeTags(strings.Split(c.QueryParam("tags"), ","))
+	if len(names) == 0 {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "tags must not be empty"})
+	}
+	params, msg := parseQuizParams(c)
+	if msg != "" {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": msg})
+	}
+
+	rows, err := s.db.ListStudyCardsByTags(c.Request().Context(), db.ListStudyCardsByTagsParams{
+		UserID:    userID,
+		TagNames:  names,
+		CardLimit: maxStudyLimit,
+	})
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "db error: " + err.Error()})
+	}
+
+	return s.sendQuiz(c, tagPrompts(rows), params)
+}
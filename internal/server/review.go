@@ -0,0 +1,387 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/labstack/echo/v4"
+
+	db "dailycards/internal/database"
+	"dailycards/internal/scheduler"
+)
+
+/* ------------------  DAILY REVIEW  ------------------ */
+
+// maxReviewDuration caps the time the client reports for one answer, so a
+// card left open does not count as hours of study.
+const maxReviewDuration = 10 * time.Minute
+
+// reviewDuration is the duration logged for an answer: unknown when the
+// client sends none or a negative one, at most maxReviewDuration.
+func reviewDuration(durationMs *int32) pgtype.Int4 {
+	if durationMs == nil || *durationMs < 0 {
+		return pgtype.Int4{}
+	}
+	return pgtype.Int4{Int32: min(*durationMs, int32(maxReviewDuration/time.Millisecond)), Valid: true}
+}
+
+// recordReview schedules the card for the user and appends the answer to the
+// review log. Cards outside the packs the user can see are ignored, which
+// recorded reports.
+func (s *Server) recordReview(ctx context.Context, userID, cardID pgtype.UUID, direction string, correct bool, durationMs *int32, now time.Time) (recorded bool, err error) {
+	prog, err := s.db.GetCardProgress(ctx, db.GetCardProgressParams{UserID: userID, CardID: cardID, Direction: direction})
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return false, nil
+		}
+		return false, err
+	}
+
+	isNew := !prog.Reps.Valid
+	st := scheduler.New()
+	if !isNew {
+		st = scheduler.State{
+			Reps:         int(prog.Reps.Int32),
+			Lapses:       int(prog.Lapses.Int32),
+			IntervalDays: int(prog.IntervalDays.Int32),
+			Ease:         prog.Ease.Float64,
+		}
+	}
+	st, due := scheduler.Review(st, correct, now)
+
+	reviewedAt := pgtype.Timestamptz{Time: now, Valid: true}
+	if err := s.db.UpsertCardProgress(ctx, db.UpsertCardProgressParams{
+		UserID:       userID,
+		CardID:       cardID,
+		Direction:    direction,
+		Reps:         int32(st.Reps),
+		Lapses:       int32(st.Lapses),
+		IntervalDays: int32(st.IntervalDays),
+		Ease:         st.Ease,
+		DueAt:        pgtype.Timestamptz{Time: due, Valid: true},
+		ReviewedAt:   reviewedAt,
+	}); err != nil {
+		return false, err
+	}
+
+	err = s.db.CreateReview(ctx, db.CreateReviewParams{
+		UserID:       userID,
+		CardID:       cardID,
+		PackID:       prog.PackID,
+		Direction:    direction,
+		Correct:      correct,
+		NewCard:      isNew,
+		IntervalDays: int32(st.IntervalDays),
+		Ease:         st.Ease,
+		DurationMs:   reviewDuration(durationMs),
+		ReviewedAt:   reviewedAt,
+	})
+	return err == nil, err
+}
+
+// studyDayStart is the moment the current study day began: the last time
+// the clock in loc showed rolloverHour.
+func studyDayStart(now time.Time, loc *time.Location, rolloverHour int) time.Time {
+	local := now.In(loc)
+	y, m, d := local.Date()
+	start := time.Date(y, m, d, rolloverHour, 0, 0, 0, loc)
+	if start.After(local) {
+		start = time.Date(y, m, d-1, rolloverHour, 0, 0, 0, loc)
+	}
+	return start
+}
+
+// studyDay is the calendar date of the study day that began at start,
+// as a midnight UTC time like the days of ReviewsPerDay.
+func studyDay(start time.Time) time.Time {
+	y, m, d := start.Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+}
+
+// userLocation loads the time zone of the settings, falling back to UTC
+// for a zone this server does not know.
+func userLocation(settings db.GetUserSettingsRow) *time.Location {
+	loc, err := time.LoadLocation(settings.Timezone)
+	if err != nil {
+		return time.UTC
+	}
+	return loc
+}
+
+type queueItem struct {
+	studyPrompt
+	New   bool
+	DueAt *time.Time
+}
+
+func (it queueItem) JSON() map[string]interface{} {
+	out := it.studyPrompt.JSON()
+	out["new"] = it.New
+	if it.DueAt != nil {
+		out["due_at"] = *it.DueAt
+	}
+	return out
+}
+
+// dailyQueue assembles today's cards across all packs of the user: due
+// reviews and unseen cards, each capped by what is left of the daily limits,
+// interleaved so that neighbouring cards come from different packs.
+func (s *Server) dailyQueue(ctx context.Context, userID pgtype.UUID, now time.Time) ([]queueItem, map[string]int64, error) {
+	settings, err := s.db.GetUserSettings(ctx, userID)
+	if err != nil {
+		return nil, nil, err
+	}
+	done, err := s.db.CountReviewsSince(ctx, db.CountReviewsSinceParams{
+		UserID: userID,
+		Since:  pgtype.Timestamptz{Time: studyDayStart(now, userLocation(settings), int(settings.DayRolloverHour)), Valid: true},
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	newLeft := max(0, int64(settings.NewCardsPerDay)-done.NewDone)
+	reviewsLeft := max(0, int64(settings.ReviewsPerDay)-done.ReviewsDone)
+
+	var items []queueItem
+	if reviewsLeft > 0 {
+		due, err := s.db.ListDueReviewCards(ctx, db.ListDueReviewCardsParams{
+			UserID:    userID,
+			Now:       pgtype.Timestamptz{Time: now, Valid: true},
+			CardLimit: int32(reviewsLeft),
+		})
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, card := range due {
+			dueAt := card.DueAt.Time
+			items = append(items, queueItem{
+				studyPrompt: studyPrompt{
+					CardID:        card.ID,
+					PackID:        card.PackID,
+					Question:      card.Question,
+					Answer:        card.Answer,
+					Rating:        card.Rating.Int32,
+					LastWrong:     card.LastWrong,
+					CardType:      card.CardType,
+					ContentFormat: card.ContentFormat,
+					Direction:     card.Direction,
+				},
+				DueAt: &dueAt,
+			})
+		}
+	}
+	if newLeft > 0 {
+		fresh, err := s.db.ListNewQueueCards(ctx, db.ListNewQueueCardsParams{
+			UserID:    userID,
+			CardLimit: int32(newLeft),
+		})
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, card := range fresh {
+			items = append(items, queueItem{
+				studyPrompt: studyPrompt{
+					CardID:        card.ID,
+					PackID:        card.PackID,
+					Question:      card.Question,
+					Answer:        card.Answer,
+					Rating:        card.Rating.Int32,
+					LastWrong:     card.LastWrong,
+					CardType:      card.CardType,
+					ContentFormat: card.ContentFormat,
+					Direction:     card.Direction,
+				},
+				New: true,
+			})
+		}
+	}
+
+	items = scheduler.Interleave(items, func(it queueItem) string { return it.PackID.String() })
+
+	limits := map[string]int64{
+		"new_limit":     int64(settings.NewCardsPerDay),
+		"new_done":      done.NewDone,
+		"reviews_limit": int64(settings.ReviewsPerDay),
+		"reviews_done":  done.ReviewsDone,
+	}
+	return items, limits, nil
+}
+
+func (s *Server) ReviewToday(c echo.Context) error {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+	}
+
+	items, limits, err := s.dailyQueue(c.Request().Context(), userID, time.Now())
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "db error: " + err.Error()})
+	}
+
+	cards := make([]map[string]interface{}, 0, len(items))
+	for _, it := range items {
+		cards = append(cards, it.JSON())
+	}
+	if err := s.withMedia(c.Request().Context(), cards); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "db error: " + err.Error()})
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"cards":  cards,
+		"limits": limits,
+	})
+}
+
+// SubmitReviewToday takes answers for cards of any packs, in the same shape
+// as FinishPack.
+func (s *Server) SubmitReviewToday(c echo.Context) error {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+	}
+
+	var body struct {
+		Stats []studyResult `json:"stats"`
+	}
+	if err := c.Bind(&body); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid body"})
+	}
+
+	s.applyStudyResults(c, userID, body.Stats)
+	s.awardAchievements(c, userID)
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+/* ------------------  SETTINGS  ------------------ */
+
+type SettingsRequest struct {
+	NewCardsPerDay *int32 `json:"new_cards_per_day"`
+	ReviewsPerDay  *int32 `json:"reviews_per_day"`
+	// Timezone is an IANA zone such as "Europe/Berlin"; the study day
+	// starts at DayRolloverHour there.
+	Timezone        *string `json:"timezone"`
+	DayRolloverHour *int32  `json:"day_rollover_hour"`
+	// GoalType is "cards" or "minutes", DailyGoal how many of them.
+	GoalType             *string `json:"goal_type"`
+	DailyGoal            *int32  `json:"daily_goal"`
+	StreakFreezesPerWeek *int32  `json:"streak_freezes_per_week"`
+	// LeaderboardOptOut keeps the user off every leaderboard.
+	LeaderboardOptOut *bool `json:"leaderboard_opt_out"`
+}
+
+func settingsJSON(s db.GetUserSettingsRow) map[string]interface{} {
+	return map[string]interface{}{
+		"new_cards_per_day":       s.NewCardsPerDay,
+		"reviews_per_day":         s.ReviewsPerDay,
+		"timezone":                s.Timezone,
+		"day_rollover_hour":       s.DayRolloverHour,
+		"goal_type":               s.GoalType,
+		"daily_goal":              s.DailyGoal,
+		"streak_freezes_per_week": s.StreakFreezesPerWeek,
+		"leaderboard_opt_out":     s.LeaderboardOptOut,
+	}
+}
+
+func (s *Server) GetSettings(c echo.Context) error {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+	}
+
+	settings, err := s.db.GetUserSettings(c.Request().Context(), userID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "db error: " + err.Error()})
+	}
+
+	return c.JSON(http.StatusOK, settingsJSON(settings))
+}
+
+func (s *Server) UpdateSettings(c echo.Context) error {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+	}
+
+	var req SettingsRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "cannot parse body: " + err.Error()})
+	}
+
+	ctx := c.Request().Context()
+	current, err := s.db.GetUserSettings(ctx, userID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "db error: " + err.Error()})
+	}
+	// the days that are over are settled with the goal they were studied for
+	if _, err := s.streakStatus(ctx, userID, current, time.Now()); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "db error: " + err.Error()})
+	}
+
+	for dst, src := range map[*int32]*int32{
+		&current.NewCardsPerDay:       req.NewCardsPerDay,
+		&current.ReviewsPerDay:        req.ReviewsPerDay,
+		&current.DayRolloverHour:      req.DayRolloverHour,
+		&current.DailyGoal:            req.DailyGoal,
+		&current.StreakFreezesPerWeek: req.StreakFreezesPerWeek,
+	} {
+		if src != nil {
+			*dst = *src
+		}
+	}
+	if req.Timezone != nil {
+		current.Timezone = *req.Timezone
+	}
+	if req.GoalType != nil {
+		current.GoalType = *req.GoalType
+	}
+	if req.LeaderboardOptOut != nil {
+		current.LeaderboardOptOut = *req.LeaderboardOptOut
+	}
+
+	switch {
+	case current.NewCardsPerDay < 0 || current.ReviewsPerDay < 0:
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "limits must not be negative"})
+	case current.DayRolloverHour < 0 || current.DayRolloverHour > 23:
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "day_rollover_hour must be between 0 and 23"})
+	case current.GoalType != goalCards && current.GoalType != goalMinutes:
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "goal_type must be cards or minutes"})
+	case current.DailyGoal < 1 || current.DailyGoal > 1440:
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "daily_goal must be between 1 and 1440"})
+	case current.StreakFreezesPerWeek < 0 || current.StreakFreezesPerWeek > maxStreakFreezes:
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "streak_freezes_per_week must be between 0 and 3"})
+	}
+	if _, err := time.LoadLocation(current.Timezone); err != nil || current.Timezone == "" || current.Timezone == "Local" {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "unknown timezone"})
+	}
+
+	saved, err := s.db.UpsertUserSettings(ctx, db.UpsertUserSettingsParams{
+		UserID:               userID,
+		NewCardsPerDay:       current.NewCardsPerDay,
+		ReviewsPerDay:        current.ReviewsPerDay,
+		Timezone:             current.Timezone,
+		DayRolloverHour:      current.DayRolloverHour,
+		GoalType:             current.GoalType,
+		DailyGoal:            current.DailyGoal,
+		StreakFreezesPerWeek: current.StreakFreezesPerWeek,
+		LeaderboardOptOut:    current.LeaderboardOptOut,
+	})
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "db error: " + err.Error()})
+	}
+
+	return c.JSON(http.StatusOK, settingsJSON(db.GetUserSettingsRow{
+		NewCardsPerDay:       saved.NewCardsPerDay,
+		ReviewsPerDay:        saved.ReviewsPerDay,
+		Timezone:             saved.Timezone,
+		DayRolloverHour:      saved.DayRolloverHour,
+		GoalType:             saved.GoalType,
+		DailyGoal:            saved.DailyGoal,
+		StreakFreezesPerWeek: saved.StreakFreezesPerWeek,
+		LeaderboardOptOut:    saved.LeaderboardOptOut,
+	}))
+}
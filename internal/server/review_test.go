@@ -0,0 +1,28 @@
+package server
+
+import (
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+func TestReviewDuration(t *testing.T) {
+	ms := func(n int32) *int32 { return &n }
+	tests := []struct {
+		name string
+		in   *int32
+		want pgtype.Int4
+	}{
+		{"missing", nil, pgtype.Int4{}},
+		{"negative", ms(-1), pgtype.Int4{}},
+		{"zero", ms(0), pgtype.Int4{Int32: 0, Valid: true}},
+		{"typical", ms(4500), pgtype.Int4{Int32: 4500, Valid: true}},
+		{"at the cap", ms(600000), pgtype.Int4{Int32: 600000, Valid: true}},
+		{"a whole day", ms(86400000), pgtype.Int4{Int32: 600000, Valid: true}},
+	}
+	for _, tt := range tests {
+		if got := reviewDuration(tt.in); got != tt.want {
+			t.Errorf("%s: reviewDuration = %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+}
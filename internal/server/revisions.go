@@ -0,0 +1,464 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/labstack/echo/v4"
+
+	db "dailycards/internal/database"
+)
+
+/* ------------------  REVISIONS  ------------------ */
+
+// Actions of a card revision.
+const (
+	revisionCreate  = "create"
+	revisionUpdate  = "update"
+	revisionDelete  = "delete"
+	revisionRestore = "restore"
+)
+
+// exportCardOf is the content of card with the given attachments, in the
+// form it is exported and kept in the revision log.
+func exportCardOf(card db.Card, hashes []string) exportCard {
+	ec := exportCard{
+		Question:      card.Question,
+		Answer:        card.Answer,
+		CardType:      card.CardType,
+		ContentFormat: card.ContentFormat,
+		Media:         hashes,
+	}
+	if card.Direction.Valid {
+		ec.Direction = &card.Direction.String
+	}
+	if ec.Media == nil {
+		ec.Media = []string{}
+	}
+	return ec
+}
+
+// cardSnapshot loads the attachments of card and returns its content.
+func (s *Server) cardSnapshot(ctx context.Context, card db.Card) (exportCard, error) {
+	rows, err := s.db.ListCardMedia(ctx, []pgtype.UUID{card.ID})
+	if err != nil {
+		return exportCard{}, err
+	}
+	hashes := make([]string, 0, len(rows))
+	for _, r := range rows {
+		hashes = append(hashes, r.Hash)
+	}
+	return exportCardOf(card, hashes), nil
+}
+
+// recordRevision logs a change of card made by userID. before is nil when
+// the card did not exist or was in the trash, after is nil when it was
+// deleted.
+func (s *Server) recordRevision(ctx context.Context, card db.Card, action string, before, after *exportCard, userID pgtype.UUID) error {
+	params := db.CreateCardRevisionParams{
+		CardID:    card.ID,
+		PackID:    card.PackID,
+		Action:    action,
+		ChangedBy: userID,
+	}
+	var err error
+	if before != nil {
+		if params.Before, err = json.Marshal(before); err != nil {
+			return err
+		}
+	}
+	if after != nil {
+		if params.After, err = json.Marshal(after); err != nil {
+			return err
+		}
+	}
+	return s.db.CreateCardRevision(ctx, params)
+}
+
+// editableCard loads a card of packID that userID may change. Cards in the
+// trash are found too; found is false for anything else.
+func (s *Server) editableCard(ctx context.Context, userID, packID, cardID pgtype.UUID) (card db.Card, found bool, err error) {
+	editable, err := s.db.PackEditable(ctx, db.PackEditableParams{PackID: packID, UserID: userID})
+	if err != nil || !editable {
+		return db.Card{}, false, err
+	}
+	card, err = s.db.ReadCardAny(ctx, cardID)
+	if errors.Is(err, pgx.ErrNoRows) || (err == nil && card.PackID != packID) {
+		return db.Card{}, false, nil
+	}
+	if err != nil {
+		return db.Card{}, false, err
+	}
+	return card, true, nil
+}
+
+// parseCardParams reads the pack_id and card_id path parameters.
+func parseCardParams(c echo.Context) (packID, cardID pgtype.UUID, err error) {
+	if err = packID.Scan(c.Param("pack_id")); err != nil {
+		return packID, cardID, errors.New("invalid pack_id")
+	}
+	if err = cardID.Scan(c.Param("card_id")); err != nil {
+		return packID, cardID, errors.New("invalid card_id")
+	}
+	return packID, cardID, nil
+}
+
+// UpdateCard replaces the content of a card. Media is left alone when the
+// request has none; send an empty list to remove all attachments.
+func (s *Server) UpdateCard(c echo.Context) error {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+	}
+	packID, cardID, err := parseCardParams(c)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	var req CreateCardRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "cannot parse body: " + err.Error()})
+	}
+	if err := validateCard(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	ctx := c.Request().Context()
+	card, found, err := s.editableCard(ctx, userID, packID, cardID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "db error: " + err.Error()})
+	}
+	if !found || card.DeletedAt.Valid {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "card not found"})
+	}
+	before, err := s.cardSnapshot(ctx, card)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "db error: " + err.Error()})
+	}
+
+	hashes := before.Media
+	if req.Media != nil {
+		hashes, err = s.checkMedia(ctx, req.Media)
+		if err != nil {
+			if errors.Is(err, errMediaNotFound) {
+				return c.JSON(http.StatusBadRequest, map[string]string{"error": "unknown media"})
+			}
+			return c.JSON(http.StatusInternalServerError, map[string]string{"error": "db error: " + err.Error()})
+		}
+	}
+
+	var direction *string
+	if req.Direction != "" {
+		direction = &req.Direction
+	}
+	card, err = s.applyCardContent(ctx, cardID, exportCard{
+		Question:      req.Question,
+		Answer:        req.Answer,
+		CardType:      req.CardType,
+		ContentFormat: req.ContentFormat,
+		Direction:     direction,
+		Media:         hashes,
+	}, req.Media != nil)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return c.JSON(http.StatusNotFound, map[string]string{"error": "card not found"})
+		}
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "db error: " + err.Error()})
+	}
+
+	after := exportCardOf(card, hashes)
+	if err := s.recordRevision(ctx, card, revisionUpdate, &before, &after, userID); err != nil {
+		c.Logger().Warn("failed to record card revision:", err)
+	}
+
+	out := cardJSON(card)
+	if err := s.withMedia(ctx, []map[string]interface{}{out}); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "db error: " + err.Error()})
+	}
+	return c.JSON(http.StatusOK, out)
+}
+
+// applyCardContent writes content to a card that is not in the trash, and
+// its attachments as well when withMedia is set.
+func (s *Server) applyCardContent(ctx context.Context, cardID pgtype.UUID, content exportCard, withMedia bool) (db.Card, error) {
+	params := db.UpdateCardParams{
+		ID:            cardID,
+		Question:      content.Question,
+		Answer:        content.Answer,
+		CardType:      content.CardType,
+		ContentFormat: content.ContentFormat,
+	}
+	if content.Direction != nil {
+		params.Direction = pgtype.Text{String: *content.Direction, Valid: true}
+	}
+	card, err := s.db.UpdateCard(ctx, params)
+	if err != nil {
+		return db.Card{}, err
+	}
+	if withMedia {
+		if err := s.db.ReplaceCardMedia(ctx, db.ReplaceCardMediaParams{CardID: cardID, Hashes: content.Media}); err != nil {
+			return db.Card{}, err
+		}
+	}
+	return card, nil
+}
+
+// CardHistory lists the revisions of a card, newest first. Anybody who can
+// see the pack can read the history, also of a card in the trash.
+func (s *Server) CardHistory(c echo.Context) error {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+	}
+	packID, cardID, err := parseCardParams(c)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	ctx := c.Request().Context()
+	visible, err := s.db.PackVisible(ctx, db.PackVisibleParams{PackID: packID, UserID: userID})
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "db error: " + err.Error()})
+	}
+	card, err := s.db.ReadCardAny(ctx, cardID)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "db error: " + err.Error()})
+	}
+	if !visible || err != nil || card.PackID != packID {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "card not found"})
+	}
+
+	revisions, err := s.db.ListCardRevisions(ctx, cardID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "db error: " + err.Error()})
+	}
+
+	result := make([]map[string]interface{}, 0, len(revisions))
+	for _, r := range revisions {
+		item := map[string]interface{}{
+			"id":         r.ID.String(),
+			"action":     r.Action,
+			"before":     rawSnapshot(r.Before),
+			"after":      rawSnapshot(r.After),
+			"changed_by": nil,
+			"created_at": r.CreatedAt.Time,
+		}
+		if r.ChangedBy.Valid {
+			item["changed_by"] = map[string]string{"id": r.ChangedBy.String(), "username": r.Username.String}
+		}
+		result = append(result, item)
+	}
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"card_id":   cardID.String(),
+		"deleted":   card.DeletedAt.Valid,
+		"revisions": result,
+	})
+}
+
+// rawSnapshot passes stored JSON through, a missing snapshot becomes null.
+func rawSnapshot(b []byte) json.RawMessage {
+	if b == nil {
+		return json.RawMessage("null")
+	}
+	return b
+}
+
+type RestoreCardRequest struct {
+	// RevisionID selects the state to go back to. Without it a card is
+	// taken out of the trash as it was deleted.
+	RevisionID string `json:"revision_id,omitempty"`
+}
+
+// RestoreCard takes a card out of the trash and/or rolls its content back
+// to a revision: the state right after it, or right before it for a delete.
+func (s *Server) RestoreCard(c echo.Context) error {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+	}
+	packID, cardID, err := parseCardParams(c)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	var req RestoreCardRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "cannot parse body: " + err.Error()})
+	}
+
+	ctx := c.Request().Context()
+	card, found, err := s.editableCard(ctx, userID, packID, cardID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "db error: " + err.Error()})
+	}
+	if !found {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "card not found"})
+	}
+	wasDeleted := card.DeletedAt.Valid
+
+	var target *exportCard
+	if req.RevisionID != "" {
+		var revID pgtype.UUID
+		if err := revID.Scan(req.RevisionID); err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid revision_id"})
+		}
+		rev, err := s.db.GetCardRevision(ctx, db.GetCardRevisionParams{ID: revID, CardID: cardID})
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return c.JSON(http.StatusNotFound, map[string]string{"error": "revision not found"})
+			}
+			return c.JSON(http.StatusInternalServerError, map[string]string{"error": "db error: " + err.Error()})
+		}
+		state := rev.After
+		if state == nil {
+			state = rev.Before
+		}
+		target = &exportCard{}
+		if err := json.Unmarshal(state, target); err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{"error": "broken revision: " + err.Error()})
+		}
+	} else if !wasDeleted {
+		return c.JSON(http.StatusConflict, map[string]string{"error": "card is not in the trash"})
+	}
+
+	// the restore, the content and the revision are written together
+	err = s.inTx(ctx, func(tx *Server) error {
+		var before *exportCard
+		var err error
+		if !wasDeleted {
+			snap, err := tx.cardSnapshot(ctx, card)
+			if err != nil {
+				return err
+			}
+			before = &snap
+		} else if card, err = tx.db.RestoreCard(ctx, cardID); err != nil {
+			return err
+		}
+
+		if target != nil {
+			if target.Media, err = tx.checkMedia(ctx, target.Media); err != nil {
+				return err
+			}
+			if card, err = tx.applyCardContent(ctx, cardID, *target, true); err != nil {
+				return err
+			}
+		}
+
+		after, err := tx.cardSnapshot(ctx, card)
+		if err != nil {
+			return err
+		}
+		return tx.recordRevision(ctx, card, revisionRestore, before, &after, userID)
+	})
+	switch {
+	case errors.Is(err, pgx.ErrNoRows):
+		return c.JSON(http.StatusConflict, map[string]string{"error": "card is not in the trash"})
+	case errors.Is(err, errMediaNotFound):
+		return c.JSON(http.StatusConflict, map[string]string{"error": "attachments of this revision no longer exist"})
+	case err != nil:
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "db error: " + err.Error()})
+	}
+
+	out := cardJSON(card)
+	if err := s.withMedia(ctx, []map[string]interface{}{out}); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "db error: " + err.Error()})
+	}
+	return c.JSON(http.StatusOK, out)
+}
+
+/* ------------------  TRASH  ------------------ */
+
+// RestorePack takes a pack out of the trash together with its cards.
+func (s *Server) RestorePack(c echo.Context) error {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+	}
+
+	var packID pgtype.UUID
+	if err := packID.Scan(c.Param("id")); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid pack id"})
+	}
+
+	pack, err := s.db.RestorePack(c.Request().Context(), db.RestorePackParams{ID: packID, UserID: userID})
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return c.JSON(http.StatusNotFound, map[string]string{"error": "pack not found in the trash"})
+		}
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+			return c.JSON(http.StatusConflict, map[string]string{"error": "another pack has taken this name, rename it first"})
+		}
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "db error: " + err.Error()})
+	}
+
+	return c.JSON(http.StatusOK, packJSON(pack))
+}
+
+// purgeAt is when an item deleted at deletedAt leaves the trash for good.
+func (s *Server) purgeAt(deletedAt pgtype.Timestamptz) time.Time {
+	return deletedAt.Time.Add(s.cfg.TrashRetention)
+}
+
+// ListTrash lists the deleted packs and cards the user can restore. Cards
+// of a pack in the trash come back with the pack and are not listed.
+func (s *Server) ListTrash(c echo.Context) error {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+	}
+
+	ctx := c.Request().Context()
+	packs, err := s.db.ListTrashPacks(ctx, userID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "db error: " + err.Error()})
+	}
+	cards, err := s.db.ListTrashCards(ctx, userID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "db error: " + err.Error()})
+	}
+
+	packList := make([]map[string]interface{}, 0, len(packs))
+	for _, p := range packs {
+		packList = append(packList, map[string]interface{}{
+			"id":         p.ID.String(),
+			"name":       p.Name,
+			"deleted_at": p.DeletedAt.Time,
+			"purge_at":   s.purgeAt(p.DeletedAt),
+		})
+	}
+	cardList := make([]map[string]interface{}, 0, len(cards))
+	for _, card := range cards {
+		cardList = append(cardList, map[string]interface{}{
+			"id":         card.ID.String(),
+			"pack_id":    card.PackID.String(),
+			"pack_name":  card.PackName,
+			"question":   card.Question,
+			"card_type":  card.CardType,
+			"deleted_at": card.DeletedAt.Time,
+			"purge_at":   s.purgeAt(card.DeletedAt),
+		})
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"packs": packList,
+		"cards": cardList,
+	})
+}
+
+// purgeTrash deletes packs and cards that have been in the trash longer
+// than the retention period, along with their history.
+func (s *Server) purgeTrash(ctx context.Context) (packs, cards int64, err error) {
+	before := pgtype.Timestamptz{Time: time.Now().Add(-s.cfg.TrashRetention), Valid: true}
+	if packs, err = s.db.PurgeDeletedPacks(ctx, before); err != nil {
+		return 0, 0, err
+	}
+	cards, err = s.db.PurgeDeletedCards(ctx, before)
+	return packs, cards, err
+}
@@ -0,0 +1,97 @@
+package server
+
+import (
+	"html"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+
+	db "dailycards/internal/database"
+)
+
+/* ------------------  SEARCH  ------------------ */
+
+const (
+	defaultSearchLimit = 20
+	maxSearchLimit     = 100
+)
+
+// highlightMarks replaces the private-use delimiters emitted by ts_headline
+// with <mark> tags once the rest of the snippet has been escaped.
+var highlightMarks = strings.NewReplacer("\ue000", "<mark>", "\ue001", "</mark>")
+
+func highlight(snippet string) string {
+	return highlightMarks.Replace(html.EscapeString(snippet))
+}
+
+func (s *Server) Search(c echo.Context) error {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+	}
+
+	query := strings.TrimSpace(c.QueryParam("q"))
+	if query == "" {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "q must not be empty"})
+	}
+
+	limit := defaultSearchLimit
+	if raw := c.QueryParam("limit"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n <= 0 {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid limit"})
+		}
+		limit = min(n, maxSearchLimit)
+	}
+
+	ctx := c.Request().Context()
+
+	packs, err := s.db.SearchPacks(ctx, db.SearchPacksParams{
+		Query:       query,
+		UserID:      userID,
+		ResultLimit: int32(limit),
+	})
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "db error: " + err.Error()})
+	}
+
+	cards, err := s.db.SearchCards(ctx, db.SearchCardsParams{
+		Query:       query,
+		UserID:      userID,
+		ResultLimit: int32(limit),
+	})
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "db error: " + err.Error()})
+	}
+
+	packResults := make([]map[string]interface{}, 0, len(packs))
+	for _, p := range packs {
+		packResults = append(packResults, map[string]interface{}{
+			"id":       p.ID.String(),
+			"name":     p.Name,
+			"category": p.Category.String,
+			"snippet":  highlight(p.NameSnippet),
+			"rank":     p.Rank,
+		})
+	}
+
+	cardResults := make([]map[string]interface{}, 0, len(cards))
+	for _, card := range cards {
+		cardResults = append(cardResults, map[string]interface{}{
+			"id":               card.ID.String(),
+			"pack_id":          card.PackID.String(),
+			"pack_name":        card.PackName,
+			"question_snippet": highlight(card.QuestionSnippet),
+			"answer_snippet":   highlight(card.AnswerSnippet),
+			"rank":             card.Rank,
+		})
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"query": query,
+		"packs": packResults,
+		"cards": cardResults,
+	})
+}
@@ -1,29 +1,64 @@
 package server
 
 import (
+	"context"
 	"errors"
 	"net/http"
+	"strconv"
+	"time"
+	"unicode/utf8"
 
 	"github.com/gorilla/sessions"
-	"github.com/jackc/pgx"
 	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/jackc/pgx/v5/pgxpool"
 	echoSession "github.com/labstack/echo-contrib/session"
 	"github.com/labstack/echo/v4"
 	"github.com/labstack/echo/v4/middleware"
 	"golang.org/x/crypto/bcrypt"
 
+	"dailycards/internal/cloze"
 	db "dailycards/internal/database"
+	"dailycards/internal/media"
+	"dailycards/internal/render"
 )
 
 type Server struct {
 	srv    *echo.Echo
+	pool   *pgxpool.Pool
 	db     *db.Queries
 	secret string
+	cfg    Config
 }
 
-func New(q *db.Queries, secret string) *Server {
-	return &Server{srv: echo.New(), db: q, secret: secret}
+// Config holds the tunables read from the environment.
+type Config struct {
+	Secret          string
+	StudySessionTTL time.Duration
+	// TrashRetention is how long deleted packs and cards can be restored.
+	TrashRetention time.Duration
+	Media          media.BlobStore
+}
+
+func New(pool *pgxpool.Pool, cfg Config) *Server {
+	return &Server{srv: echo.New(), pool: pool, db: db.New(pool), secret: cfg.Secret, cfg: cfg}
+}
+
+// inTx runs fn with a copy of the server whose queries are bound to one
+// transaction, which is committed when fn succeeds and rolled back
+// otherwise. The helpers of the server can be used as they are inside fn.
+func (s *Server) inTx(ctx context.Context, fn func(tx *Server) error) error {
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+	txs := *s
+	txs.db = s.db.WithTx(tx)
+	if err := fn(&txs); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
 }
 
 func (s *Server) Setup() {
@@ -39,8 +74,9 @@ func (s *Server) Setup() {
 	
 	s.srv.Use(middleware.CORSWithConfig(middleware.CORSConfig{
 		AllowOrigins:     []string{"http://localhost:8080"},
-		AllowMethods:     []string{echo.GET, echo.POST, echo.DELETE},
+		AllowMethods:     []string{echo.GET, echo.POST, echo.PUT, echo.DELETE},
 		AllowHeaders:     []string{echo.HeaderContentType},
+		ExposeHeaders:    []string{"Link", "X-Next-Cursor"},
 		AllowCredentials: true,
 	}))
 
@@ -56,23 +92,111 @@ func (s *Server) Setup() {
 	api.POST("/login", s.HandleLogin)
 	api.POST("/logout", s.HandleLogout)
 	api.GET("/me", s.HandleMe)
+	api.GET("/shared/:token", s.PreviewShared)
 
 	auth := api.Group("")
 	auth.Use(s.SessionAuth)
 	auth.POST("/packs", s.CreatePack)
 	auth.GET("/packs", s.ListPacks)
+	auth.GET("/packs/:id", s.GetPack)
+	auth.PUT("/packs/:id", s.UpdatePack)
 	auth.DELETE("/packs/:id", s.DeletePack)
+	auth.PUT("/packs/:id/direction", s.SetPackDirection)
+	auth.POST("/packs/:id/fork", s.ForkPack)
+	auth.POST("/packs/:id/subscribe", s.Subscribe)
+	auth.DELETE("/packs/:id/subscribe", s.Unsubscribe)
+	auth.PUT("/packs/:id/rating", s.RatePack)
+	auth.DELETE("/packs/:id/rating", s.DeletePackRating)
+	auth.GET("/packs/:id/ratings", s.ListPackRatings)
+	auth.GET("/catalog", s.ListCatalog)
+	auth.GET("/packs/:id/members", s.ListPackMembers)
+	auth.POST("/packs/:id/members", s.InvitePackMember)
+	auth.PUT("/packs/:id/members/:user_id", s.UpdatePackMember)
+	auth.DELETE("/packs/:id/members/:user_id", s.RemovePackMember)
+	auth.GET("/packs/:id/audit", s.PackAudit)
+	auth.GET("/packs/:id/analytics", s.PackAnalytics)
+	auth.GET("/invitations", s.ListInvitations)
+	auth.POST("/orgs", s.CreateOrganization)
+	auth.GET("/orgs", s.ListOrganizations)
+	auth.GET("/orgs/:id", s.GetOrganization)
+	auth.PUT("/orgs/:id", s.RenameOrganization)
+	auth.POST("/orgs/:id/members", s.InviteOrgMember)
+	auth.GET("/orgs/invitations", s.ListOrgInvitations)
+	auth.POST("/orgs/:id/accept", s.AcceptOrgInvitation)
+	auth.PUT("/orgs/:id/members/:user_id", s.UpdateOrgMember)
+	auth.DELETE("/orgs/:id/members/:user_id", s.RemoveOrgMember)
+	auth.POST("/orgs/:id/assignments", s.AssignPack)
+	auth.DELETE("/orgs/:id/assignments/:assignment_id", s.DeleteAssignment)
+	auth.GET("/orgs/:id/progress", s.OrgProgress)
+	auth.GET("/assignments", s.MyAssignments)
+	auth.GET("/friends", s.ListFriends)
+	auth.POST("/friends", s.RequestFriend)
+	auth.POST("/friends/:user_id/accept", s.AcceptFriend)
+	auth.DELETE("/friends/:user_id", s.RemoveFriend)
+	auth.GET("/leaderboards", s.Leaderboard)
+	auth.GET("/me/achievements", s.MyAchievements)
+	auth.POST("/invitations/:pack_id/accept", s.AcceptInvitation)
+	auth.POST("/packs/:id/share", s.CreateShareLink)
+	auth.GET("/packs/:id/shares", s.ListShareLinks)
+	auth.DELETE("/packs/:id/shares/:share_id", s.RevokeShareLink)
+	auth.POST("/shared/:token/redeem", s.RedeemShared)
+	auth.GET("/packs/:id/upstream", s.PackUpstream)
+	auth.POST("/packs/:id/upstream/pull", s.PullUpstream)
 	auth.POST("/packs/:pack_id/cards", s.CreateCard)
 	auth.GET("/packs/:pack_id/cards", s.ListCards)
 	auth.GET( "/packs/:pack_id/repeat", s.RepeatPack)
-	auth.POST("/packs/:pack_id/finish", s.FinishPack)
-	auth.GET( "/stats",               s.UserStats)
+	auth.POST("/packs/:pack_id/finish", s.FinishPack, middleware.BodyLimit("1M"))
+	auth.POST("/packs/:pack_id/cards/:card_id/check", s.CheckAnswer, middleware.BodyLimit("64K"))
+	auth.GET("/packs/:pack_id/quiz", s.PackQuiz)
+	auth.GET("/stats", s.Stats)
+	auth.GET("/stats/activity", s.Activity)
+	auth.GET("/stats/reviews.csv", s.ExportReviews)
 	auth.GET("/user_stats",  s.UserStats)
+	auth.GET("/search", s.Search)
+	auth.GET("/categories", s.ListCategories)
+	auth.POST("/categories", s.CreateCategory)
+	auth.GET("/categories/:id", s.ReadCategory)
+	auth.PUT("/categories/:id", s.UpdateCategory)
+	auth.DELETE("/categories/:id", s.DeleteCategory)
+	auth.GET("/categories/:id/packs", s.ListCategoryPacks)
+	auth.GET("/tags", s.ListTags)
+	auth.POST("/tags", s.CreateTag)
+	auth.POST("/tags/bulk", s.BulkTagCards)
+	auth.PUT("/tags/:id", s.RenameTag)
+	auth.DELETE("/tags/:id", s.DeleteTag)
+	auth.GET("/tags/:id/cards", s.ListTagCards)
+	auth.GET("/study", s.StudyByTags)
+	auth.POST("/study/finish", s.FinishStudy, middleware.BodyLimit("1M"))
+	auth.GET("/quiz", s.TagQuiz)
+	auth.GET("/review/today", s.ReviewToday)
+	auth.POST("/review/today", s.SubmitReviewToday, middleware.BodyLimit("1M"))
+	auth.GET("/settings", s.GetSettings)
+	auth.PUT("/settings", s.UpdateSettings)
+	auth.POST("/sessions", s.StartStudySession)
+	auth.GET("/sessions", s.ListStudySessions)
+	auth.GET("/sessions/:id", s.GetStudySession)
+	auth.POST("/sessions/:id/answers", s.AnswerStudySession, middleware.BodyLimit("64K"))
+	auth.POST("/sessions/:id/complete", s.CompleteStudySession)
+	auth.PUT("/packs/:pack_id/cards/:card_id", s.UpdateCard)
 	auth.DELETE("/packs/:pack_id/cards/:card_id", s.DeleteCard)
+	auth.GET("/packs/:pack_id/cards/:card_id/history", s.CardHistory)
+	auth.POST("/packs/:pack_id/cards/:card_id/restore", s.RestoreCard)
+	auth.POST("/packs/:id/restore", s.RestorePack)
+	auth.GET("/trash", s.ListTrash)
+	auth.PUT("/packs/:pack_id/cards/:card_id/media", s.SetCardMedia)
+	auth.GET("/packs/:pack_id/export", s.ExportPack)
+	auth.POST("/media", s.UploadMedia, middleware.BodyLimit("25M"))
+	auth.GET("/media/:hash", s.GetMedia)
+	auth.PUT("/packs/:pack_id/cards/:card_id/direction", s.SetCardDirection)
 }
 
 func (s *Server) Serve() error {
 	s.srv.Debug = true
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go s.runJanitor(ctx, janitorInterval)
+
 	return s.srv.Start(":8080")
 }
 
@@ -134,8 +258,14 @@ func (s *Server) CreateUser(c echo.Context) error {
 /* ------------------  PACKS  ------------------ */
 
 type CreatePackRequest struct {
-	Name     string `json:"name"`
-	Category string `json:"category"`
+	Name       string `json:"name"`
+	Category   string `json:"category"`
+	CategoryID string `json:"category_id"`
+	Direction  string `json:"direction"`
+	// OrgID creates the pack in an organization the user is an admin of
+	// instead of owning it personally.
+	OrgID string `json:"org_id,omitempty"`
+	PackMeta
 }
 
 func (s *Server) CreatePack(c echo.Context) error {
@@ -145,22 +275,67 @@ func (s *Server) CreatePack(c echo.Context) error {
             "error": "failed to read data: " + err.Error(),
         })
     }
-    if req.Name == "" || req.Category == "" {
+    if req.Name == "" || (req.Category == "" && req.CategoryID == "") {
         return c.JSON(http.StatusBadRequest, map[string]string{
             "error": "name and category must not be empty",
         })
     }
+    if utf8.RuneCountInString(req.Name) > maxPackNameLen {
+        return c.JSON(http.StatusBadRequest, map[string]string{
+            "error": "pack name is too long",
+        })
+    }
 
-    sess, _ := echoSession.Get("session", c)
-    uidStr, ok := sess.Values["user_id"].(string)
-    if !ok || uidStr == "" {
+    if req.Direction == "" {
+        req.Direction = dirForward
+    }
+    if !validDirection(req.Direction) {
+        return c.JSON(http.StatusBadRequest, map[string]string{
+            "error": "direction must be forward, reverse or both",
+        })
+    }
+    if err := req.PackMeta.normalize(); err != nil {
+        return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+    }
+
+    userID, ok := currentUserID(c)
+    if !ok {
         return c.JSON(http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
     }
-    userID := uuidFromString(uidStr)
+
+    ownerID, orgID := userID, pgtype.UUID{}
+    if req.OrgID != "" {
+        if err := orgID.Scan(req.OrgID); err != nil {
+            return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid org_id"})
+        }
+        if ok, err := s.requireOrgRole(c, orgID, userID, orgRoleAdmin); !ok {
+            return err
+        }
+        ownerID = pgtype.UUID{}
+    }
+
+    category, err := s.resolveCategory(c.Request().Context(), userID, req.CategoryID, req.Category)
+    if err != nil {
+        if errors.Is(err, errCategoryNotFound) {
+            return c.JSON(http.StatusNotFound, map[string]string{"error": "category not found"})
+        }
+        return c.JSON(http.StatusInternalServerError, map[string]string{
+            "error": "db error: " + err.Error(),
+        })
+    }
 
     pack, err := s.db.CreatePack(c.Request().Context(), db.CreatePackParams{
-        Name:     req.Name,
-        Category: pgtype.Text{String: req.Category, Valid: true},
+        Name:       req.Name,
+        CategoryID: category.ID,
+        OwnerID:    ownerID,
+        OrgID:      orgID,
+        Direction:  req.Direction,
+        Description: req.Description,
+        SourceLang:  optionalText(req.SourceLang),
+        TargetLang:  optionalText(req.TargetLang),
+        CoverColor:  optionalText(req.CoverColor),
+        CoverIcon:   optionalText(req.CoverIcon),
+        Visibility:  req.Visibility,
     })
     if err != nil {
         var pgErr *pgconn.PgError
@@ -174,27 +349,129 @@ func (s *Server) CreatePack(c echo.Context) error {
         })
     }
 
-    if err := s.db.IncPacksCreated(c.Request().Context(), userID); err != nil {
-        c.Logger().Warn("failed to increment packs_created:", err)
-    }
+    out := packJSON(pack)
+    out["message"] = "pack successfully created"
+    out["category"] = category.Name
+    return c.JSON(http.StatusCreated, out)
+}
 
-    return c.JSON(http.StatusCreated, map[string]string{
-        "message":  "pack successfully created",
-        "id":       pack.ID.String(),
-        "category": pack.Category.String,
-    })
+var packSortFields = map[string]sortField{
+	"name":       {kind: sortText},
+	"created_at": {kind: sortTime, defaultDesc: true},
+	"updated_at": {kind: sortTime, defaultDesc: true},
+	"due_count":  {kind: sortCount, defaultDesc: true},
 }
 
 func (s *Server) ListPacks(c echo.Context) error {
-    packs, err := s.db.ListPacks(c.Request().Context())
-    if err != nil {
-        return c.JSON(http.StatusInternalServerError, map[string]string{"error": "db error: " + err.Error()})
-    }
-    return c.JSON(http.StatusOK, packs)
+	userID, ok := currentUserID(c)
+	if !ok {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+	}
+
+	var categoryID pgtype.UUID
+	if raw := c.QueryParam("category"); raw != "" {
+		category, err := s.lookupCategory(c.Request().Context(), userID, raw)
+		if err != nil {
+			if errors.Is(err, errCategoryNotFound) {
+				return c.JSON(http.StatusNotFound, map[string]string{"error": "category not found"})
+			}
+			return c.JSON(http.StatusInternalServerError, map[string]string{"error": "db error: " + err.Error()})
+		}
+		categoryID = category.ID
+	}
+
+	return s.listPacks(c, userID, categoryID)
+}
+
+// listPacks serves one page of packs, optionally limited to a category subtree.
+func (s *Server) listPacks(c echo.Context, userID, categoryID pgtype.UUID) error {
+
+	page, err := parsePageRequest(c, packSortFields, "created_at")
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	cursorID, cursorName, cursorTime, cursorCount, err := page.cursorArgs()
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid cursor"})
+	}
+
+	params := db.ListPacksParams{
+		UserID:      userID,
+		CursorID:    cursorID,
+		SortBy:      page.SortBy,
+		SortDesc:    page.Desc,
+		CursorName:  cursorName,
+		CursorTime:  cursorTime,
+		CursorCount: pgtype.Int8{Int64: cursorCount, Valid: page.Cursor != nil},
+		PageLimit:   page.Limit + 1,
+		CategoryID:  categoryID,
+	}
+	switch owner := c.QueryParam("owner"); owner {
+	case "":
+	case "me":
+		params.OwnerID = userID
+	default:
+		if err := params.OwnerID.Scan(owner); err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid owner"})
+		}
+	}
+	if org := c.QueryParam("org"); org != "" {
+		if err := params.OrgID.Scan(org); err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid org"})
+		}
+	}
+	if v := c.QueryParam("visibility"); v != "" {
+		if !validVisibility(v) {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid visibility"})
+		}
+		params.Visibility = pgtype.Text{String: v, Valid: true}
+	}
+	params.SourceLang = optionalText(c.QueryParam("source_lang"))
+	params.TargetLang = optionalText(c.QueryParam("target_lang"))
+	if raw := c.QueryParam("subscribed"); raw != "" {
+		subscribed, err := strconv.ParseBool(raw)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid subscribed flag"})
+		}
+		params.Subscribed = pgtype.Bool{Bool: subscribed, Valid: true}
+	}
+
+	packs, err := s.db.ListPacks(c.Request().Context(), params)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "db error: " + err.Error()})
+	}
+
+	if len(packs) > int(page.Limit) {
+		packs = packs[:page.Limit]
+		last := packs[len(packs)-1]
+		cur := pageCursor{ID: last.ID.String()}
+		switch page.SortBy {
+		case "name":
+			cur.Value = last.Name
+		case "updated_at":
+			cur.Value = timeCursorValue(last.UpdatedAt)
+		case "due_count":
+			cur.Value = strconv.FormatInt(last.DueCount, 10)
+		default:
+			cur.Value = timeCursorValue(last.CreatedAt)
+		}
+		setNextPage(c, cur)
+	}
+	if packs == nil {
+		packs = []db.ListPacksRow{}
+	}
+
+	return c.JSON(http.StatusOK, packs)
 }
 
 
+// DeletePack moves the pack to the trash, see RestorePack.
 func (s *Server) DeletePack(c echo.Context) error {
+    userID, ok := currentUserID(c)
+    if !ok {
+        return c.JSON(http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+    }
+
     idParam := c.Param("id")
     if idParam == "" {
         return c.JSON(http.StatusBadRequest, map[string]string{
@@ -209,24 +486,73 @@ func (s *Server) DeletePack(c echo.Context) error {
         })
     }
 
-    if err := s.db.DeletePack(c.Request().Context(), packID); err != nil {
+    if ok, err := s.requirePackRole(c, packID, userID, roleOwner); !ok {
+        return err
+    }
+
+    n, err := s.db.SoftDeletePack(c.Request().Context(), db.SoftDeletePackParams{ID: packID, UserID: userID})
+    if err != nil {
         return c.JSON(http.StatusInternalServerError, map[string]string{
             "error": "db error: " + err.Error(),
         })
     }
+    if n == 0 {
+        return c.JSON(http.StatusNotFound, map[string]string{"error": "pack not found"})
+    }
 
     return c.NoContent(http.StatusNoContent)
 }
 
 /* ------------------  CARDS  ------------------ */
 
+const (
+	maxQuestionLen = 4000
+	maxAnswerLen   = 10000
+)
+
+// renderHTML renders card text for the client. Markdown keeps LaTeX
+// formulas for client-side typesetting.
+func renderHTML(format, text string) string {
+	return render.HTML(format, text, render.Options{Math: true})
+}
+
+// cardJSON is a card with its raw text and the rendered HTML of both sides.
+func cardJSON(card db.Card) map[string]interface{} {
+	return map[string]interface{}{
+		"id":             card.ID.String(),
+		"pack_id":        card.PackID.String(),
+		"question":       card.Question,
+		"answer":         card.Answer,
+		"question_html":  renderHTML(card.ContentFormat, card.Question),
+		"answer_html":    renderHTML(card.ContentFormat, card.Answer),
+		"content_format": card.ContentFormat,
+		"card_type":      card.CardType,
+		"direction":      card.Direction.String,
+		"rating":         card.Rating.Int32,
+		"last_wrong":     card.LastWrong.Bool,
+	}
+}
+
 type CreateCardRequest struct {
-	Question string `json:"question"`
-	Answer   string `json:"answer"`
-	Rating   *int32 `json:"rating,omitempty"`
+	Question  string `json:"question"`
+	Answer    string `json:"answer"`
+	Rating    *int32 `json:"rating,omitempty"`
+	Direction string `json:"direction,omitempty"` // empty inherits the pack direction
+	// CardType is "basic" (default) or "cloze". A cloze card keeps its
+	// {{c1::...}} text in Question; Answer is optional extra notes.
+	CardType string `json:"card_type,omitempty"`
+	// ContentFormat is "plain" (default) or "markdown".
+	ContentFormat string `json:"content_format,omitempty"`
+	// Media lists hashes of uploaded attachments.
+	Media []string `json:"media,omitempty"`
 }
 
 func (s *Server) CreateCard(c echo.Context) error {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+	}
+
 	packIDStr := c.Param("pack_id")
 	var packID pgtype.UUID
 
@@ -240,10 +566,11 @@ func (s *Server) CreateCard(c echo.Context) error {
 			"error": "cannot parse body: " + err.Error(),
 		})
 	}
-	if req.Question == "" || req.Answer == "" {
-		return c.JSON(http.StatusBadRequest, map[string]string{
-			"error": "question and answer are required",
-		})
+	if err := validateCard(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	if ok, err := s.requirePackRole(c, packID, userID, roleOwner, roleEditor); !ok {
+		return err
 	}
 
 	rating := pgtype.Int4{Int32: 0, Valid: true}
@@ -251,25 +578,101 @@ func (s *Server) CreateCard(c echo.Context) error {
 		rating = pgtype.Int4{Int32: *req.Rating, Valid: true}
 	}
 
-	card, err := s.db.CreateCard(c.Request().Context(), db.CreateCardParams{
-		Question: req.Question,
-		Answer:   req.Answer,
-		PackID:   packID,
-		Rating:   rating,
+	// the card, its attachments and its first revision are written together
+	ctx := c.Request().Context()
+	var card db.Card
+	err := s.inTx(ctx, func(tx *Server) error {
+		hashes, err := tx.checkMedia(ctx, req.Media)
+		if err != nil {
+			return err
+		}
+		card, err = tx.db.CreateCard(ctx, db.CreateCardParams{
+			Question:      req.Question,
+			Answer:        req.Answer,
+			PackID:        packID,
+			Rating:        rating,
+			Direction:     pgtype.Text{String: req.Direction, Valid: req.Direction != ""},
+			CardType:      req.CardType,
+			ContentFormat: req.ContentFormat,
+		})
+		if err != nil {
+			return err
+		}
+		if len(hashes) > 0 {
+			if err := tx.db.ReplaceCardMedia(ctx, db.ReplaceCardMediaParams{CardID: card.ID, Hashes: hashes}); err != nil {
+				return err
+			}
+		}
+		after := exportCardOf(card, hashes)
+		return tx.recordRevision(ctx, card, revisionCreate, nil, &after, userID)
 	})
 	if err != nil {
 		var pgErr *pgconn.PgError
-		if errors.As(err, &pgErr) && pgErr.Code == "23503" {
+		switch {
+		case errors.Is(err, errMediaNotFound):
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "unknown media"})
+		case errors.As(err, &pgErr) && pgErr.Code == "23503":
 			return c.JSON(http.StatusNotFound, map[string]string{"error": "pack not found"})
 		}
-		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "db error: " + err.Error()})
+	}
+
+	out := cardJSON(card)
+	if err := s.withMedia(ctx, []map[string]interface{}{out}); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "db error: " + err.Error()})
+	}
+
+	return c.JSON(http.StatusCreated, out)
+}
+
+// validateCard fills in the defaults of req and checks its content.
+func validateCard(req *CreateCardRequest) error {
+	if req.ContentFormat == "" {
+		req.ContentFormat = render.Plain
+	}
+	if !render.ValidFormat(req.ContentFormat) {
+		return errors.New("content_format must be plain or markdown")
+	}
+	if utf8.RuneCountInString(req.Question) > maxQuestionLen || utf8.RuneCountInString(req.Answer) > maxAnswerLen {
+		return errors.New("question is limited to 4000 and answer to 10000 characters")
 	}
 
-	return c.JSON(http.StatusCreated, card)
+	switch req.CardType {
+	case "", cardBasic:
+		req.CardType = cardBasic
+		if req.Question == "" || req.Answer == "" {
+			return errors.New("question and answer are required")
+		}
+		if req.Direction != "" && !validDirection(req.Direction) {
+			return errors.New("direction must be forward, reverse or both")
+		}
+	case cardCloze:
+		if _, err := cloze.Parse(req.Question); err != nil {
+			return err
+		}
+		if req.Direction != "" {
+			return errors.New("cloze cards have no direction")
+		}
+	default:
+		return errors.New("card_type must be basic or cloze")
+	}
+	return nil
 }
 
 
+var cardSortFields = map[string]sortField{
+	"question":   {kind: sortText},
+	"created_at": {kind: sortTime, defaultDesc: true},
+	"updated_at": {kind: sortTime, defaultDesc: true},
+	"rating":     {kind: sortCount},
+}
+
 func (s *Server) ListCards(c echo.Context) error {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+	}
+
 	packIDParam := c.Param("pack_id")
 	var packID pgtype.UUID
 
@@ -279,34 +682,87 @@ func (s *Server) ListCards(c echo.Context) error {
 		})
 	}
 
-	cards, err := s.db.ListCardsByPack(c.Request().Context(), packID)
+	page, err := parsePageRequest(c, cardSortFields, "created_at")
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	cursorID, cursorName, cursorTime, cursorCount, err := page.cursorArgs()
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid cursor"})
+	}
+
+	ctx := c.Request().Context()
+	visible, err := s.db.PackVisible(ctx, db.PackVisibleParams{PackID: packID, UserID: userID})
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "db error: " + err.Error()})
+	}
+	if !visible {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "pack not found"})
+	}
+
+	params := db.ListCardsByPackParams{
+		PackID:      packID,
+		UserID:      userID,
+		CursorID:    cursorID,
+		SortBy:      page.SortBy,
+		SortDesc:    page.Desc,
+		CursorName:  cursorName,
+		CursorTime:  cursorTime,
+		CursorCount: pgtype.Int4{Int32: int32(cursorCount), Valid: page.Cursor != nil},
+		PageLimit:   page.Limit + 1,
+	}
+	if raw := c.QueryParam("due"); raw != "" {
+		due, err := strconv.ParseBool(raw)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid due flag"})
+		}
+		params.Due = pgtype.Bool{Bool: due, Valid: true}
+	}
+
+	cards, err := s.db.ListCardsByPack(ctx, params)
 	if err != nil {
 		return c.JSON(http.StatusInternalServerError, map[string]string{
 			"error": "db error: " + err.Error(),
 		})
 	}
 
+	if len(cards) > int(page.Limit) {
+		cards = cards[:page.Limit]
+		last := cards[len(cards)-1].Card
+		cur := pageCursor{ID: last.ID.String()}
+		switch page.SortBy {
+		case "question":
+			cur.Value = last.Question
+		case "updated_at":
+			cur.Value = timeCursorValue(last.UpdatedAt)
+		case "rating":
+			cur.Value = strconv.Itoa(int(last.Rating.Int32))
+		default:
+			cur.Value = timeCursorValue(last.CreatedAt)
+		}
+		setNextPage(c, cur)
+	}
+
 	result := make([]map[string]interface{}, 0, len(cards))
-	for _, card := range cards {
-		result = append(result, map[string]interface{}{
-			"id":       card.ID.String(),
-			"question": card.Question,
-			"answer":   card.Answer,
-			"rating":   card.Rating.Int32,
-		})
+	for _, r := range cards {
+		out := cardJSON(r.Card)
+		out["last_wrong"] = r.UserLastWrong
+		result = append(result, out)
+	}
+	if err := s.withMedia(ctx, result); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "db error: " + err.Error()})
 	}
 
 	return c.JSON(http.StatusOK, result)
 }
 
+// DeleteCard moves the card to the trash, see RestoreCard.
 func (s *Server) DeleteCard(c echo.Context) error {
-    sess, _ := echoSession.Get("session", c)
-    uid, ok := sess.Values["user_id"].(string)
-    if !ok || uid == "" {
+    userID, ok := currentUserID(c)
+    if !ok {
         return c.JSON(http.StatusUnauthorized, map[string]string{"error":"unauthorized"})
     }
 
-
     packIDParam := c.Param("pack_id")
     cardIDParam := c.Param("card_id")
 
@@ -319,9 +775,29 @@ func (s *Server) DeleteCard(c echo.Context) error {
         return c.JSON(http.StatusBadRequest, map[string]string{"error":"invalid card_id"})
     }
 
-    if err := s.db.DeleteCard(c.Request().Context(), cardID); err != nil {
+    ctx := c.Request().Context()
+    card, found, err := s.editableCard(ctx, userID, packID, cardID)
+    if err != nil {
+        return c.JSON(http.StatusInternalServerError, map[string]string{"error":"db error: "+err.Error()})
+    }
+    if !found {
+        return c.JSON(http.StatusNotFound, map[string]string{"error":"card not found"})
+    }
+    before, err := s.cardSnapshot(ctx, card)
+    if err != nil {
+        return c.JSON(http.StatusInternalServerError, map[string]string{"error":"db error: "+err.Error()})
+    }
+
+    n, err := s.db.SoftDeleteCard(ctx, db.SoftDeleteCardParams{ID: cardID, DeletedBy: userID})
+    if err != nil {
         return c.JSON(http.StatusInternalServerError, map[string]string{"error":"db error: "+err.Error()})
     }
+    if n == 0 {
+        return c.JSON(http.StatusNotFound, map[string]string{"error":"card not found"})
+    }
+    if err := s.recordRevision(ctx, card, revisionDelete, &before, nil, userID); err != nil {
+        c.Logger().Warn("failed to record card revision:", err)
+    }
 
     return c.NoContent(http.StatusNoContent)
 }
@@ -374,11 +850,27 @@ func (s *Server) HandleMe(c echo.Context) error {
 		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "invalid session"})
 	}
 
-	user, err := s.db.GetUserByID(c.Request().Context(), uid)
+	ctx := c.Request().Context()
+	user, err := s.db.GetUserByID(ctx, uid)
 	if err != nil {
 		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "db error"})
 	}
-	return c.JSON(http.StatusOK, map[string]string{"username": user.Username})
+	out := map[string]interface{}{"username": user.Username}
+
+	// the streak is a nicety here, the client must still learn who is
+	// logged in when it cannot be computed
+	settings, err := s.db.GetUserSettings(ctx, uid)
+	if err == nil {
+		var streak streakStatus
+		if streak, err = s.streakStatus(ctx, uid, settings, time.Now()); err == nil {
+			out["goal"] = streak.goalJSON()
+			out["streak"] = streak.streakJSON()
+		}
+	}
+	if err != nil {
+		c.Logger().Warn("failed to compute streak:", err)
+	}
+	return c.JSON(http.StatusOK, out)
 }
 
 func (s *Server) HandleLogout(c echo.Context) error {
@@ -393,71 +885,161 @@ func (s *Server) HandleLogout(c echo.Context) error {
 // Интервальное повторение
 
 func (s *Server) RepeatPack(c echo.Context) error {
+    userID, ok := currentUserID(c)
+    if !ok {
+        return c.JSON(http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+    }
+
     var pid pgtype.UUID
     if err := pid.Scan(c.Param("pack_id")); err != nil {
         return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid pack_id"})
     }
 
-    rows, err := s.db.ListRepeatCards(c.Request().Context(), pid)
+    ctx := c.Request().Context()
+    visible, err := s.db.PackVisible(ctx, db.PackVisibleParams{PackID: pid, UserID: userID})
     if err != nil {
         return c.JSON(http.StatusInternalServerError, map[string]string{"error": "db error: " + err.Error()})
     }
+    if !visible {
+        return c.JSON(http.StatusNotFound, map[string]string{"error": "pack not found"})
+    }
 
-    out := make([]map[string]interface{}, 0, len(rows))
-    for _, card := range rows {
-        out = append(out, map[string]interface{}{
-            "id":         card.ID.String(),
-            "question":   card.Question,
-            "answer":     card.Answer,
-            "rating":     card.Rating.Int32,  
-            "last_wrong": card.LastWrong.Bool, 
-        })
+    rows, err := s.db.ListRepeatCards(ctx, db.ListRepeatCardsParams{PackID: pid, UserID: userID})
+    if err != nil {
+        return c.JSON(http.StatusInternalServerError, map[string]string{"error": "db error: " + err.Error()})
+    }
+
+    prompts := repeatPrompts(rows)
+    out := make([]map[string]interface{}, 0, len(prompts))
+    for _, p := range prompts {
+        out = append(out, p.JSON())
+    }
+    if err := s.withMedia(ctx, out); err != nil {
+        return c.JSON(http.StatusInternalServerError, map[string]string{"error": "db error: " + err.Error()})
     }
 
     return c.JSON(http.StatusOK, out)
 }
 
+// studyResult is one answered card as reported by the client.
+type studyResult struct {
+	CardID     string `json:"card_id"`
+	Direction  string `json:"direction,omitempty"`
+	Correct    bool   `json:"correct"`
+	DurationMs *int32 `json:"duration_ms,omitempty"`
+	// Answer is the typed answer. When present the server grades it and
+	// Correct is ignored.
+	Answer *string `json:"answer,omitempty"`
+}
+
+// uniqueResults keeps the first answer to every prompt of a card, so a
+// request repeating a card counts it once.
+func uniqueResults(results []studyResult) []studyResult {
+	type prompt struct {
+		card      pgtype.UUID
+		direction string
+	}
+	seen := make(map[prompt]bool, len(results))
+	out := make([]studyResult, 0, len(results))
+	for _, st := range results {
+		key := prompt{uuidFromString(st.CardID), promptDirection(st.Direction)}
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		out = append(out, st)
+	}
+	return out
+}
+
+// applyStudyResults schedules the answered cards for the user, which also
+// puts wrong ones first in the user's next repetition, and moves the user's rating by +1/-1 per answer. Answers to
+// cards the user cannot see are dropped, and so are repeated answers to the
+// same prompt. It reports the cards answered correctly and whether all
+// answers were correct.
+func (s *Server) applyStudyResults(c echo.Context, userID pgtype.UUID, results []studyResult) (correct map[pgtype.UUID]bool, allCorrect bool) {
+	ctx := c.Request().Context()
+	now := time.Now()
+
+	delta := 0
+	correct = make(map[pgtype.UUID]bool)
+	allCorrect = true
+	for _, st := range uniqueResults(results) {
+		cardID := uuidFromString(st.CardID)
+		direction := promptDirection(st.Direction)
+		if st.Answer != nil {
+			grade, err := s.gradeAnswer(ctx, cardID, direction, *st.Answer)
+			if err != nil {
+				c.Logger().Warn("failed to grade answer:", err)
+				continue
+			}
+			st.Correct = grade.Correct
+		}
+		// only answers that made it into the review log count
+		recorded, err := s.recordReview(ctx, userID, cardID, direction, st.Correct, st.DurationMs, now)
+		if err != nil {
+			c.Logger().Warn("failed to record review:", err)
+		}
+		if !recorded {
+			continue
+		}
+		if st.Correct {
+			delta++
+			correct[cardID] = true
+		} else {
+			delta--
+			allCorrect = false
+		}
+	}
+
+	if delta != 0 {
+		_ = s.db.AddUserRating(ctx, db.AddUserRatingParams{
+			Delta:  int32(delta),
+			UserID: userID,
+		})
+	}
+
+	return correct, allCorrect
+}
+
 func (s *Server) FinishPack(c echo.Context) error {
-    sess, _ := echoSession.Get("session", c)
-    uidStr, ok := sess.Values["user_id"].(string)
-    if !ok || uidStr == "" {
+    userID, ok := currentUserID(c)
+    if !ok {
         return c.JSON(http.StatusUnauthorized, map[string]string{"error":"unauthorized"})
     }
 
     var body struct {
-        Stats []struct {
-            CardID  string `json:"card_id"`
-            Correct bool   `json:"correct"`
-        } `json:"stats"`
+        Stats []studyResult `json:"stats"`
+    }
+    var packID pgtype.UUID
+    if err := packID.Scan(c.Param("pack_id")); err != nil {
+        return c.JSON(http.StatusBadRequest, map[string]string{"error":"invalid pack_id"})
     }
     if err := c.Bind(&body); err != nil {
         return c.JSON(http.StatusBadRequest, map[string]string{"error":"invalid body"})
     }
 
-    delta := 0
-    allCorrect := true
-    for _, st := range body.Stats {
-        _ = s.db.MarkCardWrong(c.Request().Context(), db.MarkCardWrongParams{
-            LastWrong: pgtype.Bool{Bool: !st.Correct, Valid: true},
-            ID:        uuidFromString(st.CardID),
-        })
-
-        if st.Correct {
-            delta++
-        } else {
-            delta--
-            allCorrect = false
+    // a pack counts as mastered once, however often it is finished, and
+    // only when every card of it was answered without a mistake
+    correct, allCorrect := s.applyStudyResults(c, userID, body.Stats)
+    if allCorrect && len(correct) > 0 {
+        ctx := c.Request().Context()
+        cards, err := s.db.ListRepeatCards(ctx, db.ListRepeatCardsParams{PackID: packID, UserID: userID})
+        if err != nil {
+            c.Logger().Warn("failed to mark pack mastered:", err)
+        }
+        mastered := err == nil && len(cards) > 0
+        for _, card := range cards {
+            mastered = mastered && correct[card.ID]
+        }
+        if mastered {
+            err := s.db.MarkPackMastered(ctx, db.MarkPackMasteredParams{UserID: userID, PackID: packID})
+            if err != nil {
+                c.Logger().Warn("failed to mark pack mastered:", err)
+            }
         }
     }
-
-    _ = s.db.AddUserRating(c.Request().Context(), db.AddUserRatingParams{
-        Rating: pgtype.Int4{Int32: int32(delta), Valid: true},
-        UserID: uuidFromString(uidStr),
-    })
-
-    if allCorrect {
-        _ = s.db.IncPacksMastered(c.Request().Context(), uuidFromString(uidStr))
-    }
+    s.awardAchievements(c, userID)
 
     return c.NoContent(http.StatusNoContent)
 }
@@ -471,22 +1053,15 @@ func (s *Server) UserStats(c echo.Context) error {
 
     statsRow, err := s.db.GetUserStats(c.Request().Context(), uuidFromString(uidRaw))
     if err != nil {
-        if errors.Is(err, pgx.ErrNoRows) {
-            return c.JSON(http.StatusOK, map[string]interface{}{
-                "rating":         0,
-                "packs_created":  0,
-                "packs_mastered": 0,
-            })
-        }
         return c.JSON(http.StatusInternalServerError, map[string]string{
             "error": "db error: " + err.Error(),
         })
     }
 
     return c.JSON(http.StatusOK, map[string]interface{}{
-        "rating":         statsRow.Rating.Int32,
-        "packs_created":  statsRow.PacksCreated.Int32,
-        "packs_mastered": statsRow.PacksMastered.Int32,
+        "rating":         statsRow.Rating,
+        "packs_created":  statsRow.PacksCreated,
+        "packs_mastered": statsRow.PacksMastered,
     })
 }
 
@@ -495,3 +1070,14 @@ func uuidFromString(s string) pgtype.UUID {
     _ = u.Scan(s) 
     return u
 }
+
+// currentUserID returns the id of the logged-in user stored in the session.
+func currentUserID(c echo.Context) (pgtype.UUID, bool) {
+	sess, _ := echoSession.Get("session", c)
+	uidStr, ok := sess.Values["user_id"].(string)
+	if !ok || uidStr == "" {
+		return pgtype.UUID{}, false
+	}
+	uid := uuidFromString(uidStr)
+	return uid, uid.Valid
+}
@@ -0,0 +1,28 @@
+package server
+
+import "testing"
+
+func TestUniqueResults(t *testing.T) {
+	const card = "0b6c7e1a-3f1e-4c5e-9b1a-2d3c4e5f6a7b"
+	const other = "1b6c7e1a-3f1e-4c5e-9b1a-2d3c4e5f6a7b"
+	results := []studyResult{
+		{CardID: card, Correct: false},
+		{CardID: card, Correct: true},
+		{CardID: card, Direction: dirForward, Correct: true},
+		{CardID: "0B6C7E1A-3F1E-4C5E-9B1A-2D3C4E5F6A7B", Correct: true},
+		{CardID: card, Direction: dirReverse, Correct: true},
+		{CardID: card, Direction: "c1", Correct: true},
+		{CardID: card, Direction: "c1", Correct: true},
+		{CardID: other, Correct: true},
+	}
+	got := uniqueResults(results)
+	want := []studyResult{results[0], results[4], results[5], results[7]}
+	if len(got) != len(want) {
+		t.Fatalf("uniqueResults kept %d answers, want %d: %+v", len(got), len(want), got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("answer %d = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
@@ -0,0 +1,466 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/labstack/echo/v4"
+
+	db "dailycards/internal/database"
+	"dailycards/internal/grading"
+)
+
+/* ------------------  STUDY SESSIONS  ------------------ */
+
+// A failed card is shown again after this many other cards (or at the end
+// of a shorter queue).
+const requeueGap = 3
+
+type StartSessionRequest struct {
+	PackID  string   `json:"pack_id"`
+	Tags    []string `json:"tags"`
+	Restart bool     `json:"restart"`
+}
+
+type AnswerRequest struct {
+	CardID     string `json:"card_id"`
+	Direction  string `json:"direction"`
+	Correct    bool   `json:"correct"`
+	DurationMs *int32 `json:"duration_ms,omitempty"`
+	// Answer switches to server-side grading, see studyResult.
+	Answer *string `json:"answer,omitempty"`
+}
+
+func normalizeTags(raw []string) []string {
+	tags := []string{}
+	for _, t := range raw {
+		if t = strings.ToLower(strings.TrimSpace(t)); t != "" {
+			tags = append(tags, t)
+		}
+	}
+	slices.Sort(tags)
+	return slices.Compact(tags)
+}
+
+func sessionExpired(sess db.StudySession, now time.Time) bool {
+	return sess.Status == "expired" || (sess.Status == "active" && !sess.ExpiresAt.Time.After(now))
+}
+
+func sessionSummaryJSON(sess db.StudySession) map[string]interface{} {
+	out := map[string]interface{}{
+		"id":         sess.ID.String(),
+		"source":     sess.Source,
+		"pack_id":    nil,
+		"tags":       sess.Tags,
+		"status":     sess.Status,
+		"total":      sess.TotalCards,
+		"remaining":  len(sess.Queue),
+		"correct":    sess.CorrectCount,
+		"wrong":      sess.WrongCount,
+		"expires_at": sess.ExpiresAt.Time,
+		"created_at": sess.CreatedAt.Time,
+	}
+	if sess.PackID.Valid {
+		out["pack_id"] = sess.PackID.String()
+	}
+	if sess.CompletedAt.Valid {
+		out["completed_at"] = sess.CompletedAt.Time
+	}
+	return out
+}
+
+// sessionView renders the session together with the prompt to show next.
+// Cards deleted while the session was open are dropped from the queue.
+func (s *Server) sessionView(ctx context.Context, sess db.StudySession) (map[string]interface{}, error) {
+	var current map[string]interface{}
+	for sess.Status == "active" && len(sess.Queue) > 0 {
+		cardID, direction, err := parsePromptKey(sess.Queue[0])
+		if err == nil {
+			var card db.Card
+			card, err = s.db.ReadCard(ctx, cardID)
+			if err == nil {
+				p := promptFromCard(card, direction)
+				if p.LastWrong, err = s.db.CardLastWrong(ctx, db.CardLastWrongParams{CardID: cardID, UserID: sess.UserID}); err != nil {
+					return nil, err
+				}
+				current = p.JSON()
+				if err := s.withMedia(ctx, []map[string]interface{}{current}); err != nil {
+					return nil, err
+				}
+				break
+			}
+		}
+		if !errors.Is(err, pgx.ErrNoRows) && cardID.Valid {
+			return nil, err
+		}
+		rest := sess.Queue[1:]
+		if _, err := s.db.AdvanceStudySession(ctx, db.AdvanceStudySessionParams{
+			ID:        sess.ID,
+			OldQueue:  sess.Queue,
+			NewQueue:  rest,
+			ExpiresAt: sess.ExpiresAt,
+		}); err != nil {
+			return nil, err
+		}
+		sess.Queue = rest
+	}
+
+	out := sessionSummaryJSON(sess)
+	out["current"] = current
+	return out, nil
+}
+
+// loadSession fetches one of the user's sessions, writing the error response
+// itself when the session cannot be used.
+func (s *Server) loadSession(c echo.Context, userID pgtype.UUID) (db.StudySession, bool) {
+	var id pgtype.UUID
+	if err := id.Scan(c.Param("id")); err != nil {
+		_ = c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid session id"})
+		return db.StudySession{}, false
+	}
+
+	sess, err := s.db.GetStudySession(c.Request().Context(), db.GetStudySessionParams{ID: id, UserID: userID})
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			_ = c.JSON(http.StatusNotFound, map[string]string{"error": "session not found"})
+		} else {
+			_ = c.JSON(http.StatusInternalServerError, map[string]string{"error": "db error: " + err.Error()})
+		}
+		return sess, false
+	}
+	return sess, true
+}
+
+// sessionCards builds the initial queue of prompt keys for the requested source.
+func (s *Server) sessionCards(ctx context.Context, userID pgtype.UUID, source string, packID pgtype.UUID, tags []string) ([]string, error) {
+	queue := []string{}
+	switch source {
+	case "pack":
+		cards, err := s.db.ListRepeatCards(ctx, db.ListRepeatCardsParams{PackID: packID, UserID: userID})
+		if err != nil {
+			return nil, err
+		}
+		for _, p := range repeatPrompts(cards) {
+			queue = append(queue, p.Key())
+		}
+	case "tags":
+		cards, err := s.db.ListDueCardsByTags(ctx, db.ListDueCardsByTagsParams{
+			UserID:    userID,
+			TagNames:  tags,
+			Now:       pgtype.Timestamptz{Time: time.Now(), Valid: true},
+			CardLimit: maxStudyLimit,
+		})
+		if err != nil {
+			return nil, err
+		}
+		for _, p := range dueTagPrompts(cards) {
+			queue = append(queue, p.Key())
+		}
+	default:
+		items, _, err := s.dailyQueue(ctx, userID, time.Now())
+		if err != nil {
+			return nil, err
+		}
+		for _, it := range items {
+			queue = append(queue, it.Key())
+		}
+	}
+	return queue, nil
+}
+
+// StartStudySession opens a session for a pack, a set of tags or (with an
+// empty body) today's review queue. An unfinished session for the same
+// source is resumed unless restart is set.
+func (s *Server) StartStudySession(c echo.Context) error {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+	}
+
+	var req StartSessionRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "cannot parse body: " + err.Error()})
+	}
+
+	ctx := c.Request().Context()
+	tags := normalizeTags(req.Tags)
+	source := "daily"
+	var packID pgtype.UUID
+	switch {
+	case req.PackID != "":
+		if err := packID.Scan(req.PackID); err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid pack_id"})
+		}
+		visible, err := s.db.PackVisible(ctx, db.PackVisibleParams{PackID: packID, UserID: userID})
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{"error": "db error: " + err.Error()})
+		}
+		if !visible {
+			return c.JSON(http.StatusNotFound, map[string]string{"error": "pack not found"})
+		}
+		source = "pack"
+		tags = []string{}
+	case len(tags) > 0:
+		source = "tags"
+	}
+
+	if !req.Restart {
+		sess, err := s.db.FindActiveStudySession(ctx, db.FindActiveStudySessionParams{
+			UserID: userID,
+			Source: source,
+			PackID: packID,
+			Tags:   tags,
+		})
+		if err == nil {
+			view, err := s.sessionView(ctx, sess)
+			if err != nil {
+				return c.JSON(http.StatusInternalServerError, map[string]string{"error": "db error: " + err.Error()})
+			}
+			return c.JSON(http.StatusOK, view)
+		}
+		if !errors.Is(err, pgx.ErrNoRows) {
+			return c.JSON(http.StatusInternalServerError, map[string]string{"error": "db error: " + err.Error()})
+		}
+	}
+
+	queue, err := s.sessionCards(ctx, userID, source, packID, tags)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "db error: " + err.Error()})
+	}
+
+	sess, err := s.db.CreateStudySession(ctx, db.CreateStudySessionParams{
+		UserID:    userID,
+		PackID:    packID,
+		Source:    source,
+		Tags:      tags,
+		Queue:     queue,
+		ExpiresAt: pgtype.Timestamptz{Time: time.Now().Add(s.cfg.StudySessionTTL), Valid: true},
+	})
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "db error: " + err.Error()})
+	}
+
+	view, err := s.sessionView(ctx, sess)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "db error: " + err.Error()})
+	}
+	return c.JSON(http.StatusCreated, view)
+}
+
+func (s *Server) ListStudySessions(c echo.Context) error {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+	}
+
+	sessions, err := s.db.ListActiveStudySessions(c.Request().Context(), userID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "db error: " + err.Error()})
+	}
+
+	out := make([]map[string]interface{}, 0, len(sessions))
+	for _, sess := range sessions {
+		out = append(out, sessionSummaryJSON(sess))
+	}
+	return c.JSON(http.StatusOK, out)
+}
+
+func (s *Server) GetStudySession(c echo.Context) error {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+	}
+
+	sess, ok := s.loadSession(c, userID)
+	if !ok {
+		return nil
+	}
+	if sessionExpired(sess, time.Now()) {
+		return c.JSON(http.StatusGone, map[string]string{"error": "session expired"})
+	}
+
+	view, err := s.sessionView(c.Request().Context(), sess)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "db error: " + err.Error()})
+	}
+	return c.JSON(http.StatusOK, view)
+}
+
+// AnswerStudySession records the answer for the current card. The card id
+// must match the head of the queue, so a retried request cannot be counted
+// twice; on mismatch the current state is returned with 409.
+func (s *Server) AnswerStudySession(c echo.Context) error {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+	}
+
+	sess, ok := s.loadSession(c, userID)
+	if !ok {
+		return nil
+	}
+
+	var req AnswerRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "cannot parse body: " + err.Error()})
+	}
+
+	ctx := c.Request().Context()
+	now := time.Now()
+	if sessionExpired(sess, now) {
+		return c.JSON(http.StatusGone, map[string]string{"error": "session expired"})
+	}
+	if sess.Status != "active" {
+		return c.JSON(http.StatusConflict, map[string]string{"error": "session is already completed"})
+	}
+
+	conflict := func(msg string) error {
+		view, err := s.sessionView(ctx, sess)
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{"error": "db error: " + err.Error()})
+		}
+		return c.JSON(http.StatusConflict, map[string]interface{}{"error": msg, "session": view})
+	}
+
+	if len(sess.Queue) == 0 {
+		return conflict("no cards left in this session")
+	}
+	head := sess.Queue[0]
+	headID, headDirection, err := parsePromptKey(head)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "corrupt session queue"})
+	}
+	direction := req.Direction
+	if direction == "" {
+		direction = dirForward
+	}
+	if req.CardID != headID.String() || direction != headDirection {
+		return conflict("answer does not match the current card")
+	}
+
+	var grade *grading.Result
+	if req.Answer != nil {
+		g, err := s.gradeAnswer(ctx, headID, direction, *req.Answer)
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return conflict("card was deleted")
+			}
+			if errors.Is(err, errAnswerTooLong) {
+				return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+			}
+			return c.JSON(http.StatusInternalServerError, map[string]string{"error": "db error: " + err.Error()})
+		}
+		grade = &g
+		req.Correct = g.Correct
+	}
+
+	rest := slices.Clone(sess.Queue[1:])
+	params := db.AdvanceStudySessionParams{
+		ID:        sess.ID,
+		OldQueue:  sess.Queue,
+		ExpiresAt: pgtype.Timestamptz{Time: now.Add(s.cfg.StudySessionTTL), Valid: true},
+	}
+	if req.Correct {
+		params.CorrectInc = 1
+	} else {
+		params.WrongInc = 1
+		rest = slices.Insert(rest, min(requeueGap, len(rest)), head)
+	}
+	params.NewQueue = rest
+
+	n, err := s.db.AdvanceStudySession(ctx, params)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "db error: " + err.Error()})
+	}
+	if n == 0 {
+		if sess, ok = s.loadSession(c, userID); !ok {
+			return nil
+		}
+		return conflict("session was updated by another request")
+	}
+
+	duration := pgtype.Int4{}
+	if req.DurationMs != nil && *req.DurationMs >= 0 {
+		duration = pgtype.Int4{Int32: *req.DurationMs, Valid: true}
+	}
+	if err := s.db.CreateStudySessionAnswer(ctx, db.CreateStudySessionAnswerParams{
+		SessionID:  sess.ID,
+		CardID:     headID,
+		Direction:  direction,
+		Correct:    req.Correct,
+		DurationMs: duration,
+	}); err != nil {
+		c.Logger().Warn("failed to store session answer:", err)
+	}
+	s.applyStudyResults(c, userID, []studyResult{{
+		CardID:     req.CardID,
+		Direction:  direction,
+		Correct:    req.Correct,
+		DurationMs: req.DurationMs,
+	}})
+
+	sess.Queue = rest
+	sess.CorrectCount += params.CorrectInc
+	sess.WrongCount += params.WrongInc
+	sess.ExpiresAt = params.ExpiresAt
+
+	view, err := s.sessionView(ctx, sess)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "db error: " + err.Error()})
+	}
+	result := map[string]interface{}{
+		"card_id":   req.CardID,
+		"direction": direction,
+		"correct":   req.Correct,
+	}
+	if grade != nil {
+		result["grade"] = grade
+	}
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"result":  result,
+		"session": view,
+	})
+}
+
+// CompleteStudySession closes the session. A pack session finished without
+// a single mistake counts the pack as mastered, as FinishPack does. The
+// summary lists the achievements the session earned.
+func (s *Server) CompleteStudySession(c echo.Context) error {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+	}
+
+	sess, ok := s.loadSession(c, userID)
+	if !ok {
+		return nil
+	}
+	if sessionExpired(sess, time.Now()) {
+		return c.JSON(http.StatusGone, map[string]string{"error": "session expired"})
+	}
+
+	ctx := c.Request().Context()
+	done, err := s.db.CompleteStudySession(ctx, db.CompleteStudySessionParams{ID: sess.ID, UserID: userID})
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return c.JSON(http.StatusConflict, map[string]string{"error": "session is already completed"})
+		}
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "db error: " + err.Error()})
+	}
+
+	if done.Source == "pack" && len(done.Queue) == 0 && done.TotalCards > 0 && done.WrongCount == 0 {
+		if err := s.db.MarkPackMastered(ctx, db.MarkPackMasteredParams{UserID: userID, PackID: done.PackID}); err != nil {
+			c.Logger().Warn("failed to mark pack mastered:", err)
+		}
+	}
+
+	summary := sessionSummaryJSON(done)
+	summary["achievements"] = s.awardAchievements(c, userID)
+	return c.JSON(http.StatusOK, summary)
+}
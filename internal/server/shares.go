@@ -0,0 +1,350 @@
+package server
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/labstack/echo/v4"
+
+	db "dailycards/internal/database"
+)
+
+/* ------------------  SHARE LINKS  ------------------ */
+
+// Number of cards shown by the preview of a shared pack.
+const sharePreviewCards = 10
+
+// shareToken signs the id of a share link with the server secret, so a
+// token cannot be guessed from another one.
+func (s *Server) shareToken(id pgtype.UUID) string {
+	enc := base64.RawURLEncoding
+	return enc.EncodeToString(id.Bytes[:]) + "." + enc.EncodeToString(s.shareMAC(id.Bytes[:]))
+}
+
+func (s *Server) shareMAC(id []byte) []byte {
+	mac := hmac.New(sha256.New, []byte(s.secret))
+	mac.Write([]byte("share:"))
+	mac.Write(id)
+	return mac.Sum(nil)[:16]
+}
+
+// parseShareToken returns the link id of a token with a valid signature.
+func (s *Server) parseShareToken(token string) (pgtype.UUID, bool) {
+	rawID, rawMAC, ok := strings.Cut(token, ".")
+	if !ok {
+		return pgtype.UUID{}, false
+	}
+	id, err := base64.RawURLEncoding.DecodeString(rawID)
+	if err != nil || len(id) != 16 {
+		return pgtype.UUID{}, false
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(rawMAC)
+	if err != nil || !hmac.Equal(sig, s.shareMAC(id)) {
+		return pgtype.UUID{}, false
+	}
+	var out pgtype.UUID
+	copy(out.Bytes[:], id)
+	out.Valid = true
+	return out, true
+}
+
+func (s *Server) shareLinkJSON(link db.PackShareLink) map[string]interface{} {
+	token := s.shareToken(link.ID)
+	out := map[string]interface{}{
+		"id":         link.ID.String(),
+		"pack_id":    link.PackID.String(),
+		"token":      token,
+		"url":        "/shared/" + token,
+		"expires_at": nil,
+		"max_uses":   nil,
+		"use_count":  link.UseCount,
+		"created_at": link.CreatedAt.Time,
+	}
+	if link.ExpiresAt.Valid {
+		out["expires_at"] = link.ExpiresAt.Time
+	}
+	if link.MaxUses.Valid {
+		out["max_uses"] = link.MaxUses.Int32
+	}
+	return out
+}
+
+type CreateShareLinkRequest struct {
+	// ExpiresAt is optional; the link never expires without it.
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	// MaxUses limits how often the link can be redeemed, SingleUse is a
+	// shorthand for 1.
+	MaxUses   *int32 `json:"max_uses,omitempty"`
+	SingleUse bool   `json:"single_use,omitempty"`
+}
+
+// CreateShareLink mints a link to a pack that works whatever its
+// visibility. Only those who can edit the pack can share it.
+func (s *Server) CreateShareLink(c echo.Context) error {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+	}
+
+	var packID pgtype.UUID
+	if err := packID.Scan(c.Param("id")); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid pack id"})
+	}
+
+	var req CreateShareLinkRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "cannot parse body: " + err.Error()})
+	}
+	params := db.CreateShareLinkParams{PackID: packID, CreatedBy: userID}
+	if req.ExpiresAt != nil {
+		if !req.ExpiresAt.After(time.Now()) {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "expires_at must be in the future"})
+		}
+		params.ExpiresAt = pgtype.Timestamptz{Time: *req.ExpiresAt, Valid: true}
+	}
+	switch {
+	case req.SingleUse && req.MaxUses != nil && *req.MaxUses != 1:
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "single_use contradicts max_uses"})
+	case req.SingleUse:
+		params.MaxUses = pgtype.Int4{Int32: 1, Valid: true}
+	case req.MaxUses != nil:
+		if *req.MaxUses < 1 {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "max_uses must be positive"})
+		}
+		params.MaxUses = pgtype.Int4{Int32: *req.MaxUses, Valid: true}
+	}
+
+	ctx := c.Request().Context()
+	editable, err := s.db.PackEditable(ctx, db.PackEditableParams{PackID: packID, UserID: userID})
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "db error: " + err.Error()})
+	}
+	if !editable {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "pack not found"})
+	}
+
+	link, err := s.db.CreateShareLink(ctx, params)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "db error: " + err.Error()})
+	}
+	return c.JSON(http.StatusCreated, s.shareLinkJSON(link))
+}
+
+// ListShareLinks lists the links of a pack that can still be redeemed.
+func (s *Server) ListShareLinks(c echo.Context) error {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+	}
+
+	var packID pgtype.UUID
+	if err := packID.Scan(c.Param("id")); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid pack id"})
+	}
+
+	ctx := c.Request().Context()
+	editable, err := s.db.PackEditable(ctx, db.PackEditableParams{PackID: packID, UserID: userID})
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "db error: " + err.Error()})
+	}
+	if !editable {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "pack not found"})
+	}
+
+	links, err := s.db.ListActiveShareLinks(ctx, packID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "db error: " + err.Error()})
+	}
+	result := make([]map[string]interface{}, 0, len(links))
+	for _, link := range links {
+		result = append(result, s.shareLinkJSON(link))
+	}
+	return c.JSON(http.StatusOK, result)
+}
+
+func (s *Server) RevokeShareLink(c echo.Context) error {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+	}
+
+	var packID, linkID pgtype.UUID
+	if err := packID.Scan(c.Param("id")); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid pack id"})
+	}
+	if err := linkID.Scan(c.Param("share_id")); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid share id"})
+	}
+
+	ctx := c.Request().Context()
+	editable, err := s.db.PackEditable(ctx, db.PackEditableParams{PackID: packID, UserID: userID})
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "db error: " + err.Error()})
+	}
+	if !editable {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "pack not found"})
+	}
+
+	n, err := s.db.RevokeShareLink(ctx, db.RevokeShareLinkParams{ID: linkID, PackID: packID})
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "db error: " + err.Error()})
+	}
+	if n == 0 {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "share link not found"})
+	}
+	return c.NoContent(http.StatusNoContent)
+}
+
+// sharedPack resolves a token to its pack. A bad signature and a link that
+// is revoked, expired or used up look the same to the caller.
+func (s *Server) sharedPack(c echo.Context) (db.PackShareLink, db.Pack, bool, error) {
+	id, ok := s.parseShareToken(c.Param("token"))
+	if !ok {
+		return db.PackShareLink{}, db.Pack{}, false, nil
+	}
+	ctx := c.Request().Context()
+	link, err := s.db.GetActiveShareLink(ctx, id)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return db.PackShareLink{}, db.Pack{}, false, nil
+	}
+	if err != nil {
+		return db.PackShareLink{}, db.Pack{}, false, err
+	}
+	pack, err := s.db.ReadPack(ctx, link.PackID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return db.PackShareLink{}, db.Pack{}, false, nil
+	}
+	if err != nil {
+		return db.PackShareLink{}, db.Pack{}, false, err
+	}
+	return link, pack, true, nil
+}
+
+// PreviewShared shows a shared pack with its first cards. It needs no login
+// and does not use the link up.
+func (s *Server) PreviewShared(c echo.Context) error {
+	link, pack, ok, err := s.sharedPack(c)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "db error: " + err.Error()})
+	}
+	if !ok {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "share link is invalid or expired"})
+	}
+
+	ctx := c.Request().Context()
+	count, err := s.db.CountPackCards(ctx, pack.ID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "db error: " + err.Error()})
+	}
+	cards, err := s.db.PreviewPackCards(ctx, db.PreviewPackCardsParams{PackID: pack.ID, CardLimit: sharePreviewCards})
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "db error: " + err.Error()})
+	}
+	sample := make([]map[string]interface{}, 0, len(cards))
+	for _, card := range cards {
+		sample = append(sample, cardJSON(card))
+	}
+	if err := s.withMedia(ctx, sample); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "db error: " + err.Error()})
+	}
+
+	out := packJSON(pack)
+	out["card_count"] = count
+	out["cards"] = sample
+	out["expires_at"] = nil
+	if link.ExpiresAt.Valid {
+		out["expires_at"] = link.ExpiresAt.Time
+	}
+	return c.JSON(http.StatusOK, out)
+}
+
+// RedeemSharedRequest picks what to do with a shared pack: "subscribe" to
+// it, or "fork" it into an own copy (Name and Category as for ForkPack).
+type RedeemSharedRequest struct {
+	Action string `json:"action"`
+	ForkPackRequest
+}
+
+// RedeemShared uses one redemption of a link. Subscribing to a pack the
+// user already has is free.
+func (s *Server) RedeemShared(c echo.Context) error {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+	}
+
+	var req RedeemSharedRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "cannot parse body: " + err.Error()})
+	}
+	if req.Action != "subscribe" && req.Action != "fork" {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "action must be subscribe or fork"})
+	}
+
+	link, pack, ok, err := s.sharedPack(c)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "db error: " + err.Error()})
+	}
+	if !ok {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "share link is invalid or expired"})
+	}
+
+	ctx := c.Request().Context()
+	if req.Action == "subscribe" {
+		visible, err := s.db.PackVisible(ctx, db.PackVisibleParams{PackID: pack.ID, UserID: userID})
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{"error": "db error: " + err.Error()})
+		}
+		if !visible {
+			if ok, err := s.useShareLink(c, link); !ok {
+				return err
+			}
+		}
+		if _, err := s.db.Subscribe(ctx, db.SubscribeParams{UserID: userID, PackID: pack.ID}); err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{"error": "db error: " + err.Error()})
+		}
+		return c.JSON(http.StatusOK, map[string]interface{}{"pack_id": pack.ID.String(), "subscribed": true})
+	}
+
+	// check what can be checked before a single-use link is spent
+	if req.Name == "" {
+		req.Name = pack.Name
+	}
+	if len([]rune(req.Name)) > maxPackNameLen {
+		return forkPackError(c, errPackNameTooLong)
+	}
+	if ok, err := s.useShareLink(c, link); !ok {
+		return err
+	}
+	fork, cards, err := s.forkPack(ctx, userID, pack, req.ForkPackRequest)
+	if err != nil {
+		if _, rerr := s.db.RefundShareLink(ctx, link.ID); rerr != nil {
+			c.Logger().Warn("failed to refund share link:", rerr)
+		}
+		return forkPackError(c, err)
+	}
+
+	out := packJSON(fork)
+	out["cards"] = len(cards)
+	return c.JSON(http.StatusCreated, out)
+}
+
+// useShareLink takes one use of link. When that fails the response has
+// been written and ok is false.
+func (s *Server) useShareLink(c echo.Context, link db.PackShareLink) (ok bool, err error) {
+	_, err = s.db.UseShareLink(c.Request().Context(), link.ID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return false, c.JSON(http.StatusGone, map[string]string{"error": "share link has been used up"})
+	}
+	if err != nil {
+		return false, c.JSON(http.StatusInternalServerError, map[string]string{"error": "db error: " + err.Error()})
+	}
+	return true, nil
+}
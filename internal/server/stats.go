@@ -0,0 +1,368 @@
+package server
+
+import (
+	"encoding/csv"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/labstack/echo/v4"
+
+	db "dailycards/internal/database"
+)
+
+/* ------------------  STATS  ------------------ */
+
+const (
+	// A card is mature once one of its prompts has an interval of this
+	// many days.
+	matureIntervalDays = 21
+
+	defaultStatsDays    = 30
+	maxStatsDays        = 366
+	defaultForecastDays = 30
+	maxForecastDays     = 365
+
+	// Reviews are exported in batches of this many rows.
+	exportBatchSize = 1000
+)
+
+// Activity buckets.
+const (
+	bucketDay  = "day"
+	bucketWeek = "week"
+)
+
+const dateLayout = "2006-01-02"
+
+// statsRange is the parsed form of ?from=&to=&tz=. Days are study days
+// in tz, both ends included; the default is the last 30 days in the time
+// zone of the user.
+type statsRange struct {
+	From, To time.Time
+	TZ       *time.Location
+	Rollover int32
+}
+
+func parseStatsRange(c echo.Context, settings db.GetUserSettingsRow) (statsRange, error) {
+	r := statsRange{TZ: userLocation(settings), Rollover: settings.DayRolloverHour}
+	if raw := c.QueryParam("tz"); raw != "" {
+		loc, err := time.LoadLocation(raw)
+		if err != nil {
+			return r, errors.New("invalid tz")
+		}
+		r.TZ = loc
+	}
+
+	r.To = studyDay(studyDayStart(time.Now(), r.TZ, int(r.Rollover)))
+	if raw := c.QueryParam("to"); raw != "" {
+		t, err := time.Parse(dateLayout, raw)
+		if err != nil {
+			return r, errors.New("invalid to, expected YYYY-MM-DD")
+		}
+		r.To = t
+	}
+	r.From = r.To.AddDate(0, 0, 1-defaultStatsDays)
+	if raw := c.QueryParam("from"); raw != "" {
+		t, err := time.Parse(dateLayout, raw)
+		if err != nil {
+			return r, errors.New("invalid from, expected YYYY-MM-DD")
+		}
+		r.From = t
+	}
+
+	if r.From.After(r.To) {
+		return r, errors.New("from must not be after to")
+	}
+	if r.To.Sub(r.From) >= maxStatsDays*24*time.Hour {
+		return r, errors.New("date range is limited to 366 days")
+	}
+	return r, nil
+}
+
+func (r statsRange) params(userID pgtype.UUID) db.ReviewsPerDayParams {
+	return db.ReviewsPerDayParams{
+		UserID:       userID,
+		FromDay:      pgtype.Date{Time: r.From, Valid: true},
+		ToDay:        pgtype.Date{Time: r.To, Valid: true},
+		Tz:           r.TZ.String(),
+		RolloverHour: r.Rollover,
+	}
+}
+
+// ratio is part/total, or nil when there is nothing to divide.
+func ratio(part, total int64) interface{} {
+	if total == 0 {
+		return nil
+	}
+	return float64(part) / float64(total)
+}
+
+// Stats reports what the user did in a date range, computed from the
+// review log, along with the current state of their cards and a forecast
+// of the reviews to come and the daily goal and streak. ?forecast= sets the
+// number of days forecast.
+func (s *Server) Stats(c echo.Context) error {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+	}
+
+	ctx := c.Request().Context()
+	settings, err := s.db.GetUserSettings(ctx, userID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "db error: " + err.Error()})
+	}
+	rng, err := parseStatsRange(c, settings)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	forecastDays := defaultForecastDays
+	if raw := c.QueryParam("forecast"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n <= 0 || n > maxForecastDays {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "forecast must be between 1 and 365 days"})
+		}
+		forecastDays = n
+	}
+
+	counters, err := s.db.GetUserStats(ctx, userID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "db error: " + err.Error()})
+	}
+	days, err := s.db.ReviewsPerDay(ctx, rng.params(userID))
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "db error: " + err.Error()})
+	}
+	packs, err := s.db.CardMaturityByPack(ctx, db.CardMaturityByPackParams{UserID: userID, MatureDays: matureIntervalDays})
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "db error: " + err.Error()})
+	}
+	progress, err := s.db.ProgressSummary(ctx, userID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "db error: " + err.Error()})
+	}
+	forecast, err := s.db.DueForecast(ctx, db.DueForecastParams{
+		UserID:       userID,
+		Tz:           rng.TZ.String(),
+		RolloverHour: rng.Rollover,
+		Days:         int32(forecastDays),
+	})
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "db error: " + err.Error()})
+	}
+	streak, err := s.streakStatus(ctx, userID, settings, time.Now())
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "db error: " + err.Error()})
+	}
+
+	var total db.ReviewsPerDayRow
+	perDay := make([]map[string]interface{}, 0, len(days))
+	for _, d := range days {
+		total.Reviews += d.Reviews
+		total.Correct += d.Correct
+		total.NewCards += d.NewCards
+		total.Recalls += d.Recalls
+		total.RecallsCorrect += d.RecallsCorrect
+		total.TimeMs += d.TimeMs
+		perDay = append(perDay, map[string]interface{}{
+			"date":      d.Day.Time.Format(dateLayout),
+			"reviews":   d.Reviews,
+			"correct":   d.Correct,
+			"new_cards": d.NewCards,
+			"retention": ratio(d.RecallsCorrect, d.Recalls),
+			"time_ms":   d.TimeMs,
+		})
+	}
+
+	var cards struct{ New, Young, Mature int64 }
+	packList := make([]map[string]interface{}, 0, len(packs))
+	for _, p := range packs {
+		cards.New += p.NewCards
+		cards.Young += p.YoungCards
+		cards.Mature += p.MatureCards
+		packList = append(packList, map[string]interface{}{
+			"pack_id": p.PackID.String(),
+			"name":    p.Name,
+			"new":     p.NewCards,
+			"young":   p.YoungCards,
+			"mature":  p.MatureCards,
+		})
+	}
+
+	forecastList := make([]map[string]interface{}, 0, len(forecast))
+	for _, f := range forecast {
+		forecastList = append(forecastList, map[string]interface{}{
+			"date": f.Day.Time.Format(dateLayout),
+			"due":  f.Due,
+		})
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"rating":         counters.Rating,
+		"packs_created":  counters.PacksCreated,
+		"packs_mastered": counters.PacksMastered,
+		"from":           rng.From.Format(dateLayout),
+		"to":             rng.To.Format(dateLayout),
+		"tz":             rng.TZ.String(),
+		"summary": map[string]interface{}{
+			"reviews":   total.Reviews,
+			"correct":   total.Correct,
+			"new_cards": total.NewCards,
+			"retention": ratio(total.RecallsCorrect, total.Recalls),
+			"time_ms":   total.TimeMs,
+		},
+		"per_day": perDay,
+		"cards": map[string]interface{}{
+			"new":                   cards.New,
+			"young":                 cards.Young,
+			"mature":                cards.Mature,
+			"average_interval_days": progress.AverageInterval,
+			"due_now":               progress.DueNow,
+		},
+		"packs":    packList,
+		"forecast": forecastList,
+		"goal":     streak.goalJSON(),
+		"streak":   streak.streakJSON(),
+	})
+}
+
+// Activity is the review activity of the user as a time series for
+// heatmaps and trend charts: reviews, the share answered correctly and the
+// time spent per study day, or per week starting on Monday with
+// ?bucket=week. Takes ?from=&to=&tz= like Stats; every bucket in the range
+// is present, empty ones with zeros.
+func (s *Server) Activity(c echo.Context) error {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+	}
+
+	bucket := c.QueryParam("bucket")
+	switch bucket {
+	case "":
+		bucket = bucketDay
+	case bucketDay, bucketWeek:
+	default:
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "bucket must be day or week"})
+	}
+
+	ctx := c.Request().Context()
+	settings, err := s.db.GetUserSettings(ctx, userID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "db error: " + err.Error()})
+	}
+	rng, err := parseStatsRange(c, settings)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	days, err := s.db.ReviewsPerDay(ctx, rng.params(userID))
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "db error: " + err.Error()})
+	}
+
+	// Days come in order, so a week is complete once the next one starts.
+	var buckets []db.ReviewsPerDayRow
+	for _, d := range days {
+		if bucket == bucketWeek {
+			d.Day.Time = weekStart(d.Day.Time)
+		}
+		if n := len(buckets); n > 0 && buckets[n-1].Day.Time.Equal(d.Day.Time) {
+			buckets[n-1].Reviews += d.Reviews
+			buckets[n-1].Correct += d.Correct
+			buckets[n-1].TimeMs += d.TimeMs
+			continue
+		}
+		buckets = append(buckets, d)
+	}
+
+	series := make([]map[string]interface{}, 0, len(buckets))
+	for _, b := range buckets {
+		series = append(series, map[string]interface{}{
+			"date":          b.Day.Time.Format(dateLayout),
+			"reviews":       b.Reviews,
+			"correct":       b.Correct,
+			"correct_ratio": ratio(b.Correct, b.Reviews),
+			"time_ms":       b.TimeMs,
+		})
+	}
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"from":   rng.From.Format(dateLayout),
+		"to":     rng.To.Format(dateLayout),
+		"tz":     rng.TZ.String(),
+		"bucket": bucket,
+		"series": series,
+	})
+}
+
+// ExportReviews streams the whole review log of the user as CSV, oldest
+// review first.
+func (s *Server) ExportReviews(c echo.Context) error {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+	}
+
+	ctx := c.Request().Context()
+	params := db.ExportReviewsParams{UserID: userID, PageLimit: exportBatchSize}
+	rows, err := s.db.ExportReviews(ctx, params)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "db error: " + err.Error()})
+	}
+
+	res := c.Response()
+	res.Header().Set(echo.HeaderContentType, "text/csv; charset=utf-8")
+	res.Header().Set(echo.HeaderContentDisposition, `attachment; filename="reviews.csv"`)
+	res.WriteHeader(http.StatusOK)
+
+	w := csv.NewWriter(res)
+	_ = w.Write([]string{
+		"reviewed_at", "card_id", "pack_id", "pack_name", "question", "direction",
+		"correct", "new_card", "interval_days", "ease", "duration_ms",
+	})
+	for len(rows) > 0 {
+		for _, r := range rows {
+			record := []string{
+				r.ReviewedAt.Time.UTC().Format(time.RFC3339Nano),
+				"", "", r.PackName, r.Question, r.Direction,
+				strconv.FormatBool(r.Correct),
+				strconv.FormatBool(r.NewCard),
+				strconv.Itoa(int(r.IntervalDays)),
+				strconv.FormatFloat(r.Ease, 'f', -1, 64),
+				"",
+			}
+			if r.CardID.Valid {
+				record[1] = r.CardID.String()
+			}
+			if r.PackID.Valid {
+				record[2] = r.PackID.String()
+			}
+			if r.DurationMs.Valid {
+				record[10] = strconv.Itoa(int(r.DurationMs.Int32))
+			}
+			if err := w.Write(record); err != nil {
+				return err
+			}
+		}
+		w.Flush()
+		if err := w.Error(); err != nil {
+			return err
+		}
+		res.Flush()
+		if len(rows) < exportBatchSize {
+			break
+		}
+
+		last := rows[len(rows)-1]
+		params.CursorTime, params.CursorID = last.ReviewedAt, last.ID
+		if rows, err = s.db.ExportReviews(ctx, params); err != nil {
+			// The status is sent already; cut the file short.
+			c.Logger().Warn("failed to export reviews:", err)
+			return nil
+		}
+	}
+	w.Flush()
+	return w.Error()
+}
@@ -0,0 +1,172 @@
+package server
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+
+	db "dailycards/internal/database"
+)
+
+/* ------------------  STREAKS & GOALS  ------------------ */
+
+// Kinds of daily goal.
+const (
+	goalCards   = "cards"
+	goalMinutes = "minutes"
+)
+
+const (
+	maxStreakFreezes = 3
+	// Streaks are computed over this many study days back from today.
+	streakHistoryDays = 730
+)
+
+// streakStatus is where the user stands with their daily goal and streak.
+type streakStatus struct {
+	GoalType string
+	Goal     int64
+	// Progress is today's progress towards Goal, in cards or minutes.
+	Progress int64
+	TodayMet bool
+
+	Current int
+	Longest int
+	// FrozenDays are the missed days a freeze covered in the current streak.
+	FrozenDays  int
+	FreezesLeft int
+}
+
+func (st streakStatus) goalJSON() map[string]interface{} {
+	return map[string]interface{}{
+		"type":     st.GoalType,
+		"target":   st.Goal,
+		"progress": st.Progress,
+		"met":      st.TodayMet,
+	}
+}
+
+func (st streakStatus) streakJSON() map[string]interface{} {
+	return map[string]interface{}{
+		"current":      st.Current,
+		"longest":      st.Longest,
+		"frozen_days":  st.FrozenDays,
+		"freezes_left": st.FreezesLeft,
+		"today_met":    st.TodayMet,
+	}
+}
+
+// goalProgress is what a day contributed to the goal.
+func goalProgress(goalType string, day db.ReviewsPerDayRow) int64 {
+	if goalType == goalMinutes {
+		return day.TimeMs / int64(time.Minute/time.Millisecond)
+	}
+	return day.Reviews
+}
+
+func isoWeek(t time.Time) int {
+	y, w := t.ISOWeek()
+	return y*100 + w
+}
+
+// computeStreak walks the settled days in history, then settles the study
+// days that are over and follow them, the last day being today. A day with
+// the goal met extends the streak. A missed day uses one of the freezes of
+// its ISO week if the streak is running and one is left, and breaks the
+// streak otherwise. Today does not break it while in progress. Settled days
+// keep their outcome, whatever the goal and freezes are now.
+func computeStreak(history []db.StreakDay, days []db.ReviewsPerDayRow, goalType string, goal int64, freezesPerWeek int) (streakStatus, []db.StreakDay) {
+	st := streakStatus{GoalType: goalType, Goal: goal}
+	used := make(map[int]int)
+	walk := func(day db.StreakDay) {
+		switch {
+		case day.Met:
+			st.Current++
+			st.Longest = max(st.Longest, st.Current)
+		case day.Frozen:
+			used[isoWeek(day.Day.Time)]++
+			st.FrozenDays++
+		default:
+			st.Current = 0
+			st.FrozenDays = 0
+		}
+	}
+
+	var last time.Time
+	for _, day := range history {
+		walk(day)
+		last = day.Day.Time
+	}
+
+	var settled []db.StreakDay
+	for i, day := range days {
+		today := i == len(days)-1
+		met := goalProgress(goalType, day) >= goal
+		if today {
+			st.Progress = goalProgress(goalType, day)
+			st.TodayMet = met
+			st.FreezesLeft = max(0, freezesPerWeek-used[isoWeek(day.Day.Time)])
+		}
+		if len(history) > 0 && !day.Day.Time.After(last) {
+			continue
+		}
+		if today {
+			if met {
+				st.Current++
+				st.Longest = max(st.Longest, st.Current)
+			}
+			continue
+		}
+		frozen := !met && st.Current > 0 && used[isoWeek(day.Day.Time)] < freezesPerWeek
+		sd := db.StreakDay{Day: day.Day, GoalType: goalType, Goal: int32(goal), Met: met, Frozen: frozen}
+		walk(sd)
+		settled = append(settled, sd)
+	}
+	return st, settled
+}
+
+// streakStatus computes the goal and streak of userID from the settled
+// streak days and the review log, settling the days that are over.
+func (s *Server) streakStatus(ctx context.Context, userID pgtype.UUID, settings db.GetUserSettingsRow, now time.Time) (streakStatus, error) {
+	loc := userLocation(settings)
+	today := studyDay(studyDayStart(now, loc, int(settings.DayRolloverHour)))
+	from := today.AddDate(0, 0, 1-streakHistoryDays)
+	history, err := s.db.ListStreakDays(ctx, db.ListStreakDaysParams{
+		UserID:  userID,
+		FromDay: pgtype.Date{Time: from, Valid: true},
+	})
+	if err != nil {
+		return streakStatus{}, err
+	}
+	if len(history) > 0 {
+		from = history[len(history)-1].Day.Time.AddDate(0, 0, 1)
+		if from.After(today) {
+			from = today
+		}
+	}
+	days, err := s.db.ReviewsPerDay(ctx, db.ReviewsPerDayParams{
+		UserID:       userID,
+		FromDay:      pgtype.Date{Time: from, Valid: true},
+		ToDay:        pgtype.Date{Time: today, Valid: true},
+		Tz:           loc.String(),
+		RolloverHour: settings.DayRolloverHour,
+	})
+	if err != nil {
+		return streakStatus{}, err
+	}
+
+	st, settled := computeStreak(history, days, settings.GoalType, int64(settings.DailyGoal), int(settings.StreakFreezesPerWeek))
+	if len(settled) > 0 {
+		params := db.SettleStreakDaysParams{UserID: userID, GoalType: settings.GoalType, Goal: settings.DailyGoal}
+		for _, day := range settled {
+			params.Days = append(params.Days, day.Day)
+			params.Met = append(params.Met, day.Met)
+			params.Frozen = append(params.Frozen, day.Frozen)
+		}
+		if err := s.db.SettleStreakDays(ctx, params); err != nil {
+			return streakStatus{}, err
+		}
+	}
+	return st, nil
+}
@@ -0,0 +1,243 @@
+package server
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+
+	db "dailycards/internal/database"
+)
+
+// studyDays builds consecutive days from start, the last one being today.
+// Each entry is the number of reviews of that day.
+func studyDays(start string, reviews ...int64) []db.ReviewsPerDayRow {
+	day, err := time.Parse(time.DateOnly, start)
+	if err != nil {
+		panic(err)
+	}
+	days := make([]db.ReviewsPerDayRow, len(reviews))
+	for i, n := range reviews {
+		days[i] = db.ReviewsPerDayRow{
+			Day:     pgtype.Date{Time: day.AddDate(0, 0, i), Valid: true},
+			Reviews: n,
+			TimeMs:  n * int64(time.Minute/time.Millisecond),
+		}
+	}
+	return days
+}
+
+func TestComputeStreak(t *testing.T) {
+	// 2026-10-05 is a Monday, 2026-10-10 the Saturday of the same ISO week.
+	const monday, saturday = "2026-10-05", "2026-10-10"
+	tests := []struct {
+		name    string
+		days    []db.ReviewsPerDayRow
+		freezes int
+		want    streakStatus
+	}{
+		{
+			name:    "no history",
+			freezes: 1,
+			want:    streakStatus{},
+		},
+		{
+			name:    "goal met every day",
+			days:    studyDays(monday, 5, 7, 5),
+			freezes: 1,
+			want:    streakStatus{Progress: 5, TodayMet: true, Current: 3, Longest: 3, FreezesLeft: 1},
+		},
+		{
+			name:    "today in progress keeps the streak",
+			days:    studyDays(monday, 5, 5, 2),
+			freezes: 1,
+			want:    streakStatus{Progress: 2, Current: 2, Longest: 2, FreezesLeft: 1},
+		},
+		{
+			name:    "missed day without freezes breaks the streak",
+			days:    studyDays(monday, 5, 5, 0, 5),
+			freezes: 0,
+			want:    streakStatus{Progress: 5, TodayMet: true, Current: 1, Longest: 2},
+		},
+		{
+			name:    "freeze covers a missed day",
+			days:    studyDays(monday, 5, 5, 4, 5),
+			freezes: 1,
+			want:    streakStatus{Progress: 5, TodayMet: true, Current: 3, Longest: 3, FrozenDays: 1},
+		},
+		{
+			name:    "one freeze per week",
+			days:    studyDays(monday, 5, 5, 0, 0, 5),
+			freezes: 1,
+			want:    streakStatus{Progress: 5, TodayMet: true, Current: 1, Longest: 2},
+		},
+		{
+			name:    "freezes of consecutive ISO weeks",
+			days:    studyDays(saturday, 5, 0, 0, 5),
+			freezes: 1,
+			want:    streakStatus{Progress: 5, TodayMet: true, Current: 2, Longest: 2, FrozenDays: 2},
+		},
+		{
+			name:    "no freeze spent before a streak starts",
+			days:    studyDays(monday, 0, 0, 5),
+			freezes: 2,
+			want:    streakStatus{Progress: 5, TodayMet: true, Current: 1, Longest: 1, FreezesLeft: 2},
+		},
+		{
+			name:    "break resets the frozen days",
+			days:    studyDays(monday, 5, 0, 5, 0, 0, 5, 5),
+			freezes: 1,
+			want:    streakStatus{Progress: 5, TodayMet: true, Current: 2, Longest: 2},
+		},
+		{
+			name:    "longest streak is kept",
+			days:    studyDays(monday, 5, 5, 5, 5, 0, 5, 1),
+			freezes: 0,
+			want:    streakStatus{Progress: 1, Current: 1, Longest: 4},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.want.GoalType, tt.want.Goal = goalCards, 5
+			if got, _ := computeStreak(nil, tt.days, goalCards, 5, tt.freezes); got != tt.want {
+				t.Errorf("computeStreak =\n%+v, want\n%+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestComputeStreakMinutes(t *testing.T) {
+	days := studyDays("2026-10-05", 10, 10)
+	days[1].TimeMs-- // a millisecond short of ten minutes
+	got, _ := computeStreak(nil, days, goalMinutes, 10, 0)
+	if got.Current != 1 || got.TodayMet || got.Progress != 9 {
+		t.Errorf("computeStreak = %+v, want yesterday counted and today at 9 of 10 minutes", got)
+	}
+}
+
+// settledDays builds consecutive settled days from start: 'm' met, 'f'
+// frozen and '-' missed.
+func settledDays(start, outcomes string) []db.StreakDay {
+	day, err := time.Parse(time.DateOnly, start)
+	if err != nil {
+		panic(err)
+	}
+	days := make([]db.StreakDay, len(outcomes))
+	for i, o := range outcomes {
+		days[i] = db.StreakDay{
+			Day:      pgtype.Date{Time: day.AddDate(0, 0, i), Valid: true},
+			GoalType: goalCards,
+			Goal:     5,
+			Met:      o == 'm',
+			Frozen:   o == 'f',
+		}
+	}
+	return days
+}
+
+func TestComputeStreakSettledHistory(t *testing.T) {
+	const monday, wednesday = "2026-10-05", "2026-10-07"
+	tests := []struct {
+		name    string
+		history []db.StreakDay
+		days    []db.ReviewsPerDayRow
+		goal    int64
+		freezes int
+		want    streakStatus
+		settled string
+	}{
+		{
+			name:    "lower goal does not repair a missed day",
+			history: settledDays(monday, "m-"),
+			days:    studyDays(wednesday, 1, 1),
+			goal:    1,
+			freezes: 0,
+			want:    streakStatus{Progress: 1, TodayMet: true, Current: 2, Longest: 2},
+			settled: "m",
+		},
+		{
+			name:    "more freezes do not repair a broken streak",
+			history: settledDays(monday, "mm--"),
+			days:    studyDays("2026-10-09", 5),
+			goal:    5,
+			freezes: 3,
+			want:    streakStatus{Progress: 5, TodayMet: true, Current: 1, Longest: 2, FreezesLeft: 3},
+		},
+		{
+			name:    "settled freezes count against the week",
+			history: settledDays(monday, "mf"),
+			days:    studyDays(wednesday, 0, 5),
+			goal:    5,
+			freezes: 2,
+			want:    streakStatus{Progress: 5, TodayMet: true, Current: 2, Longest: 2, FrozenDays: 2},
+			settled: "f",
+		},
+		{
+			name:    "settled days are not settled again",
+			history: settledDays(monday, "mm"),
+			days:    studyDays("2026-10-06", 0, 5),
+			goal:    5,
+			freezes: 0,
+			want:    streakStatus{Progress: 5, TodayMet: true, Current: 3, Longest: 3},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.want.GoalType, tt.want.Goal = goalCards, tt.goal
+			got, settled := computeStreak(tt.history, tt.days, goalCards, tt.goal, tt.freezes)
+			if got != tt.want {
+				t.Errorf("computeStreak =\n%+v, want\n%+v", got, tt.want)
+			}
+			var outcomes string
+			for _, day := range settled {
+				switch {
+				case day.Met:
+					outcomes += "m"
+				case day.Frozen:
+					outcomes += "f"
+				default:
+					outcomes += "-"
+				}
+				if day.Goal != int32(tt.goal) {
+					t.Errorf("day %s settled with goal %d, want %d", day.Day.Time.Format(time.DateOnly), day.Goal, tt.goal)
+				}
+			}
+			if outcomes != tt.settled {
+				t.Errorf("settled %q, want %q", outcomes, tt.settled)
+			}
+		})
+	}
+}
+
+func TestStudyDay(t *testing.T) {
+	berlin := time.FixedZone("CEST", 2*60*60)
+	newYork := time.FixedZone("EDT", -4*60*60)
+	now := time.Date(2026, 10, 19, 2, 30, 0, 0, time.UTC)
+	tests := []struct {
+		loc          *time.Location
+		rolloverHour int
+		want         string
+	}{
+		{time.UTC, 0, "2026-10-19"},
+		{time.UTC, 4, "2026-10-18"},
+		{berlin, 4, "2026-10-19"},
+		{berlin, 5, "2026-10-18"},
+		{newYork, 0, "2026-10-18"},
+		{newYork, 23, "2026-10-17"},
+	}
+	for _, tt := range tests {
+		start := studyDayStart(now, tt.loc, tt.rolloverHour)
+		if start.After(now) || now.Sub(start) >= 24*time.Hour {
+			t.Errorf("%s rollover %d: study day starts at %v, not within the day before %v", tt.loc, tt.rolloverHour, start, now)
+		}
+		if got := studyDay(start).Format(time.DateOnly); got != tt.want {
+			t.Errorf("%s rollover %d: study day %s, want %s", tt.loc, tt.rolloverHour, got, tt.want)
+		}
+	}
+}
+
+func TestUserLocationFallsBackToUTC(t *testing.T) {
+	if loc := userLocation(db.GetUserSettingsRow{Timezone: "Nowhere/Atlantis"}); loc != time.UTC {
+		t.Errorf("userLocation = %v, want UTC", loc)
+	}
+}
@@ -0,0 +1,311 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/labstack/echo/v4"
+
+	db "dailycards/internal/database"
+)
+
+/* ------------------  TAGS  ------------------ */
+
+const (
+	maxTagNameLen     = 50
+	defaultStudyLimit = 100
+	maxStudyLimit     = 500
+)
+
+type TagRequest struct {
+	Name string `json:"name"`
+}
+
+func tagName(raw string) (string, bool) {
+	name := strings.TrimSpace(raw)
+	return name, name != "" && len([]rune(name)) <= maxTagNameLen && !strings.Contains(name, ",")
+}
+
+func (s *Server) ListTags(c echo.Context) error {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+	}
+
+	tags, err := s.db.ListTags(c.Request().Context(), userID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "db error: " + err.Error()})
+	}
+
+	out := make([]map[string]interface{}, 0, len(tags))
+	for _, t := range tags {
+		out = append(out, map[string]interface{}{
+			"id":         t.ID.String(),
+			"name":       t.Name,
+			"card_count": t.CardCount,
+		})
+	}
+	return c.JSON(http.StatusOK, out)
+}
+
+func (s *Server) CreateTag(c echo.Context) error {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+	}
+
+	var req TagRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "cannot parse body: " + err.Error()})
+	}
+	name, valid := tagName(req.Name)
+	if !valid {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "tag name must be 1-50 characters without commas"})
+	}
+
+	tag, err := s.db.CreateTag(c.Request().Context(), db.CreateTagParams{OwnerID: userID, Name: name})
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+			return c.JSON(http.StatusConflict, map[string]string{"error": "tag with this name already exists"})
+		}
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "db error: " + err.Error()})
+	}
+
+	return c.JSON(http.StatusCreated, map[string]interface{}{
+		"id":   tag.ID.String(),
+		"name": tag.Name,
+	})
+}
+
+func (s *Server) RenameTag(c echo.Context) error {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+	}
+
+	var tagID pgtype.UUID
+	if err := tagID.Scan(c.Param("id")); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid tag id"})
+	}
+
+	var req TagRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "cannot parse body: " + err.Error()})
+	}
+	name, valid := tagName(req.Name)
+	if !valid {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "tag name must be 1-50 characters without commas"})
+	}
+
+	tag, err := s.db.RenameTag(c.Request().Context(), db.RenameTagParams{ID: tagID, OwnerID: userID, Name: name})
+	if err != nil {
+		var pgErr *pgconn.PgError
+		switch {
+		case errors.Is(err, pgx.ErrNoRows):
+			return c.JSON(http.StatusNotFound, map[string]string{"error": "tag not found"})
+		case errors.As(err, &pgErr) && pgErr.Code == "23505":
+			return c.JSON(http.StatusConflict, map[string]string{"error": "tag with this name already exists"})
+		}
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "db error: " + err.Error()})
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"id":   tag.ID.String(),
+		"name": tag.Name,
+	})
+}
+
+func (s *Server) DeleteTag(c echo.Context) error {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+	}
+
+	var tagID pgtype.UUID
+	if err := tagID.Scan(c.Param("id")); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid tag id"})
+	}
+
+	n, err := s.db.DeleteTag(c.Request().Context(), db.DeleteTagParams{ID: tagID, OwnerID: userID})
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "db error: " + err.Error()})
+	}
+	if n == 0 {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "tag not found"})
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+func (s *Server) ListTagCards(c echo.Context) error {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+	}
+
+	var tagID pgtype.UUID
+	if err := tagID.Scan(c.Param("id")); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid tag id"})
+	}
+
+	cards, err := s.db.ListTagCards(c.Request().Context(), db.ListTagCardsParams{TagID: tagID, UserID: userID})
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "db error: " + err.Error()})
+	}
+
+	out := make([]map[string]interface{}, 0, len(cards))
+	for _, card := range cards {
+		out = append(out, cardJSON(card))
+	}
+	if err := s.withMedia(c.Request().Context(), out); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "db error: " + err.Error()})
+	}
+	return c.JSON(http.StatusOK, out)
+}
+
+type BulkTagRequest struct {
+	CardIDs []string `json:"card_ids"`
+	Add     []string `json:"add"`
+	Remove  []string `json:"remove"`
+}
+
+func parseUUIDs(raw []string) ([]pgtype.UUID, error) {
+	out := make([]pgtype.UUID, 0, len(raw))
+	for _, r := range raw {
+		var id pgtype.UUID
+		if err := id.Scan(r); err != nil {
+			return nil, err
+		}
+		out = append(out, id)
+	}
+	return out, nil
+}
+
+// BulkTagCards attaches the "add" tags to and detaches the "remove" tags from
+// every listed card in one request.
+func (s *Server) BulkTagCards(c echo.Context) error {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+	}
+
+	var req BulkTagRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "cannot parse body: " + err.Error()})
+	}
+	if len(req.CardIDs) == 0 || len(req.Add)+len(req.Remove) == 0 {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "card_ids and add or remove are required"})
+	}
+
+	cardIDs, err := parseUUIDs(req.CardIDs)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid card id"})
+	}
+	addIDs, err := parseUUIDs(req.Add)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid tag id"})
+	}
+	removeIDs, err := parseUUIDs(req.Remove)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid tag id"})
+	}
+
+	ctx := c.Request().Context()
+	var added, removed int64
+	if len(addIDs) > 0 {
+		added, err = s.db.TagCards(ctx, db.TagCardsParams{CardIds: cardIDs, TagIds: addIDs, UserID: userID})
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{"error": "db error: " + err.Error()})
+		}
+	}
+	if len(removeIDs) > 0 {
+		removed, err = s.db.UntagCards(ctx, db.UntagCardsParams{CardIds: cardIDs, TagIds: removeIDs, UserID: userID})
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{"error": "db error: " + err.Error()})
+		}
+	}
+
+	return c.JSON(http.StatusOK, map[string]int64{
+		"added":   added,
+		"removed": removed,
+	})
+}
+
+/* ------------------  STUDY BY TAGS  ------------------ */
+
+// StudyByTags builds a repetition session from the due and new cards of any
+// pack carrying one of the ?tags= (comma separated names). The result has
+// the RepeatPack shape.
+func (s *Server) StudyByTags(c echo.Context) error {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+	}
+
+	var names []string
+	for _, raw := range strings.Split(c.QueryParam("tags"), ",") {
+		if name := strings.ToLower(strings.TrimSpace(raw)); name != "" {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "tags must not be empty"})
+	}
+
+	limit := defaultStudyLimit
+	if raw := c.QueryParam("limit"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n <= 0 {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid limit"})
+		}
+		limit = min(n, maxStudyLimit)
+	}
+
+	rows, err := s.db.ListDueCardsByTags(c.Request().Context(), db.ListDueCardsByTagsParams{
+		UserID:    userID,
+		TagNames:  names,
+		Now:       pgtype.Timestamptz{Time: time.Now(), Valid: true},
+		CardLimit: int32(limit),
+	})
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "db error: " + err.Error()})
+	}
+
+	prompts := dueTagPrompts(rows)
+	out := make([]map[string]interface{}, 0, len(prompts))
+	for _, p := range prompts {
+		out = append(out, p.JSON())
+	}
+	if err := s.withMedia(c.Request().Context(), out); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "db error: " + err.Error()})
+	}
+	return c.JSON(http.StatusOK, out)
+}
+
+// FinishStudy accepts the same body as FinishPack for a tag session. Cards
+// come from several packs, so no pack is counted as mastered.
+func (s *Server) FinishStudy(c echo.Context) error {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+	}
+
+	var body struct {
+		Stats []studyResult `json:"stats"`
+	}
+	if err := c.Bind(&body); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid body"})
+	}
+
+	s.applyStudyResults(c, userID, body.Stats)
+	s.awardAchievements(c, userID)
+
+	return c.NoContent(http.StatusNoContent)
+}
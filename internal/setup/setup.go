@@ -1,12 +1,27 @@
 package setup
 
-import "os"
+import (
+	"context"
+	"os"
+	"time"
+
+	"dailycards/internal/media"
+)
 
 type EnvData struct {
 	SECRET            string
 	POSTGRES_USER     string
 	POSTGRES_PASSWORD string
 	POSTGRES_DB       string
+	STUDY_SESSION_TTL string
+	TRASH_RETENTION   string
+	MEDIA_STORE       string
+	MEDIA_DIR         string
+	S3_ENDPOINT       string
+	S3_ACCESS_KEY     string
+	S3_SECRET_KEY     string
+	S3_BUCKET         string
+	S3_USE_SSL        string
 }
 
 func SetupEnv() *EnvData {
@@ -15,5 +30,43 @@ func SetupEnv() *EnvData {
 		POSTGRES_USER: os.Getenv("POSTGRES_USER"),
 		POSTGRES_PASSWORD: os.Getenv("POSTGRES_PASSWORD"),
 		POSTGRES_DB: os.Getenv("POSTGRES_DB"),
+		STUDY_SESSION_TTL: os.Getenv("STUDY_SESSION_TTL"),
+		TRASH_RETENTION: os.Getenv("TRASH_RETENTION"),
+		MEDIA_STORE: os.Getenv("MEDIA_STORE"),
+		MEDIA_DIR: os.Getenv("MEDIA_DIR"),
+		S3_ENDPOINT: os.Getenv("S3_ENDPOINT"),
+		S3_ACCESS_KEY: os.Getenv("S3_ACCESS_KEY"),
+		S3_SECRET_KEY: os.Getenv("S3_SECRET_KEY"),
+		S3_BUCKET: os.Getenv("S3_BUCKET"),
+		S3_USE_SSL: os.Getenv("S3_USE_SSL"),
 	}
-}
\ No newline at end of file
+}
+
+// Duration parses a Go duration such as "90m", falling back to def when the
+// variable is unset or malformed.
+func Duration(raw string, def time.Duration) time.Duration {
+	d, err := time.ParseDuration(raw)
+	if err != nil || d <= 0 {
+		return def
+	}
+	return d
+}
+// MediaStore opens the blob store selected by MEDIA_STORE: "s3" for an
+// S3-compatible service, anything else for the local directory MEDIA_DIR.
+func MediaStore(ctx context.Context, env *EnvData) (media.BlobStore, error) {
+	if env.MEDIA_STORE == "s3" {
+		return media.NewS3Store(ctx, media.S3Config{
+			Endpoint:  env.S3_ENDPOINT,
+			AccessKey: env.S3_ACCESS_KEY,
+			SecretKey: env.S3_SECRET_KEY,
+			Bucket:    env.S3_BUCKET,
+			UseSSL:    env.S3_USE_SSL == "true",
+		})
+	}
+
+	dir := env.MEDIA_DIR
+	if dir == "" {
+		dir = "media"
+	}
+	return media.NewLocalStore(dir)
+}
@@ -114,4 +114,887 @@ FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
 
 CREATE TRIGGER set_updated_at_logs
 BEFORE UPDATE ON logs
-FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
\ No newline at end of file
+FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
+
+-- pack ownership
+ALTER TABLE packs
+  ADD COLUMN IF NOT EXISTS owner_id UUID
+      REFERENCES users(id)
+      ON DELETE SET NULL;
+
+CREATE INDEX IF NOT EXISTS idx_packs_owner_id   ON packs(owner_id);
+CREATE INDEX IF NOT EXISTS idx_packs_created_at ON packs(created_at, id);
+CREATE INDEX IF NOT EXISTS idx_cards_pack_created_at ON cards(pack_id, created_at, id);
+
+-- full-text search
+-- The "russian" configuration stems Cyrillic words with the Russian
+-- snowball stemmer and ASCII words with the English one, which covers
+-- both languages our decks are written in.
+CREATE INDEX IF NOT EXISTS idx_cards_search ON cards USING GIN (
+    (setweight(to_tsvector('russian', question), 'A') ||
+     setweight(to_tsvector('russian', answer),   'B'))
+);
+CREATE INDEX IF NOT EXISTS idx_packs_search ON packs USING GIN (
+    to_tsvector('russian', name)
+);
+
+-- categories
+-- owner_id NULL means a global category visible to everybody.
+CREATE TABLE IF NOT EXISTS categories (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    name VARCHAR(60) NOT NULL,
+    slug VARCHAR(80) NOT NULL,
+    parent_id UUID
+        REFERENCES categories(id)
+        ON DELETE RESTRICT,
+    owner_id UUID
+        REFERENCES users(id)
+        ON DELETE CASCADE,
+    created_by UUID
+        REFERENCES users(id)
+        ON DELETE SET NULL,
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    updated_at TIMESTAMPTZ DEFAULT NOW(),
+    CHECK (parent_id <> id)
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_categories_owner_slug
+    ON categories(owner_id, slug) NULLS NOT DISTINCT;
+CREATE INDEX IF NOT EXISTS idx_categories_parent_id ON categories(parent_id);
+
+CREATE TRIGGER set_updated_at_categories
+BEFORE UPDATE ON categories
+FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
+
+CREATE OR REPLACE FUNCTION slugify(value TEXT)
+RETURNS TEXT AS $$
+    SELECT trim(BOTH '-' FROM regexp_replace(lower(trim(value)), '[^[:alnum:]]+', '-', 'g'));
+$$ LANGUAGE sql IMMUTABLE;
+
+ALTER TABLE packs
+  ADD COLUMN IF NOT EXISTS category_id UUID
+      REFERENCES categories(id)
+      ON DELETE SET NULL;
+
+CREATE INDEX IF NOT EXISTS idx_packs_category_id ON packs(category_id);
+
+-- move free-text packs.category values into global categories,
+-- "Math", "math " and "MATH" collapse into one row
+INSERT INTO categories (name, slug)
+SELECT DISTINCT ON (slugify(category)) trim(category), slugify(category)
+FROM packs
+WHERE category IS NOT NULL AND slugify(category) <> ''
+ORDER BY slugify(category), created_at
+ON CONFLICT DO NOTHING;
+
+UPDATE packs p
+SET category_id = c.id
+FROM categories c
+WHERE p.category_id IS NULL
+  AND p.category IS NOT NULL
+  AND c.owner_id IS NULL
+  AND c.slug = slugify(p.category);
+
+ALTER TABLE packs DROP COLUMN IF EXISTS category;
+
+-- pack_visible reports whether user $2 may read pack $1: their own packs,
+-- packs they subscribe to and legacy packs without an owner.
+CREATE OR REPLACE FUNCTION pack_visible(UUID, UUID)
+RETURNS BOOLEAN AS $$
+    SELECT EXISTS (
+        SELECT 1 FROM packs p
+        WHERE p.id = $1
+          AND (p.owner_id IS NULL OR p.owner_id = $2 OR EXISTS (
+                SELECT 1 FROM subscriptions s
+                WHERE s.pack_id = p.id AND s.user_id = $2
+              ))
+    );
+$$ LANGUAGE sql STABLE;
+
+-- tags (personal, many-to-many with cards)
+CREATE TABLE IF NOT EXISTS tags (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    owner_id UUID NOT NULL
+        REFERENCES users(id)
+        ON DELETE CASCADE,
+    name VARCHAR(50) NOT NULL,
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    updated_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS card_tags (
+    card_id UUID NOT NULL
+        REFERENCES cards(id)
+        ON DELETE CASCADE,
+    tag_id UUID NOT NULL
+        REFERENCES tags(id)
+        ON DELETE CASCADE,
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    PRIMARY KEY (card_id, tag_id)
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_tags_owner_name ON tags(owner_id, lower(name));
+CREATE INDEX IF NOT EXISTS idx_card_tags_tag_id ON card_tags(tag_id);
+
+CREATE TRIGGER set_updated_at_tags
+BEFORE UPDATE ON tags
+FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
+
+-- per-user scheduling state of a card
+CREATE TABLE IF NOT EXISTS card_progress (
+    user_id UUID NOT NULL
+        REFERENCES users(id)
+        ON DELETE CASCADE,
+    card_id UUID NOT NULL
+        REFERENCES cards(id)
+        ON DELETE CASCADE,
+    reps          INT NOT NULL DEFAULT 0 CHECK (reps >= 0),
+    lapses        INT NOT NULL DEFAULT 0 CHECK (lapses >= 0),
+    interval_days INT NOT NULL DEFAULT 0 CHECK (interval_days >= 0),
+    ease          DOUBLE PRECISION NOT NULL DEFAULT 2.5,
+    due_at        TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    last_reviewed_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    updated_at TIMESTAMPTZ DEFAULT NOW(),
+    PRIMARY KEY (user_id, card_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_card_progress_due ON card_progress(user_id, due_at);
+CREATE INDEX IF NOT EXISTS idx_card_progress_card_id ON card_progress(card_id);
+
+CREATE TRIGGER set_updated_at_card_progress
+BEFORE UPDATE ON card_progress
+FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
+
+-- append-only review log, kept when the card or pack is deleted
+CREATE TABLE IF NOT EXISTS reviews (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    user_id UUID NOT NULL
+        REFERENCES users(id)
+        ON DELETE CASCADE,
+    card_id UUID
+        REFERENCES cards(id)
+        ON DELETE SET NULL,
+    pack_id UUID
+        REFERENCES packs(id)
+        ON DELETE SET NULL,
+    correct       BOOLEAN NOT NULL,
+    new_card      BOOLEAN NOT NULL DEFAULT FALSE,
+    interval_days INT NOT NULL DEFAULT 0,
+    ease          DOUBLE PRECISION NOT NULL DEFAULT 2.5,
+    duration_ms   INT CHECK (duration_ms >= 0),
+    reviewed_at   TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_reviews_user_reviewed_at ON reviews(user_id, reviewed_at);
+CREATE INDEX IF NOT EXISTS idx_reviews_card_id ON reviews(card_id);
+CREATE INDEX IF NOT EXISTS idx_reviews_pack_id ON reviews(pack_id);
+
+-- per-user study preferences
+CREATE TABLE IF NOT EXISTS user_settings (
+    user_id UUID PRIMARY KEY
+        REFERENCES users(id)
+        ON DELETE CASCADE,
+    new_cards_per_day INT NOT NULL DEFAULT 20  CHECK (new_cards_per_day >= 0),
+    reviews_per_day   INT NOT NULL DEFAULT 200 CHECK (reviews_per_day   >= 0),
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    updated_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+CREATE TRIGGER set_updated_at_user_settings
+BEFORE UPDATE ON user_settings
+FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
+
+-- server-side study sessions
+-- queue holds the cards still to be shown, its first element is the
+-- current card; failed cards are put back into it.
+CREATE TABLE IF NOT EXISTS study_sessions (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    user_id UUID NOT NULL
+        REFERENCES users(id)
+        ON DELETE CASCADE,
+    pack_id UUID
+        REFERENCES packs(id)
+        ON DELETE CASCADE,
+    source TEXT NOT NULL CHECK (source IN ('pack', 'tags', 'daily')),
+    tags   TEXT[] NOT NULL DEFAULT '{}',
+    status TEXT NOT NULL DEFAULT 'active'
+        CHECK (status IN ('active', 'completed', 'expired')),
+    queue         UUID[] NOT NULL DEFAULT '{}',
+    total_cards   INT NOT NULL DEFAULT 0,
+    correct_count INT NOT NULL DEFAULT 0,
+    wrong_count   INT NOT NULL DEFAULT 0,
+    expires_at   TIMESTAMPTZ NOT NULL,
+    completed_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    updated_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS study_session_answers (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    session_id UUID NOT NULL
+        REFERENCES study_sessions(id)
+        ON DELETE CASCADE,
+    card_id UUID
+        REFERENCES cards(id)
+        ON DELETE SET NULL,
+    correct     BOOLEAN NOT NULL,
+    duration_ms INT CHECK (duration_ms >= 0),
+    answered_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_study_sessions_user_status ON study_sessions(user_id, status);
+CREATE INDEX IF NOT EXISTS idx_study_sessions_expires_at  ON study_sessions(expires_at) WHERE status = 'active';
+CREATE INDEX IF NOT EXISTS idx_study_session_answers_session_id ON study_session_answers(session_id);
+
+CREATE TRIGGER set_updated_at_study_sessions
+BEFORE UPDATE ON study_sessions
+FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
+
+-- study directions: forward asks the question, reverse asks the answer,
+-- both schedules the two independently. cards.direction NULL inherits
+-- the pack setting.
+ALTER TABLE packs
+  ADD COLUMN IF NOT EXISTS direction TEXT NOT NULL DEFAULT 'forward'
+      CHECK (direction IN ('forward', 'reverse', 'both'));
+
+ALTER TABLE cards
+  ADD COLUMN IF NOT EXISTS direction TEXT
+      CHECK (direction IN ('forward', 'reverse', 'both'));
+
+CREATE OR REPLACE FUNCTION card_directions(card_direction TEXT, pack_direction TEXT)
+RETURNS TEXT[] AS $$
+    SELECT CASE COALESCE(card_direction, pack_direction, 'forward')
+        WHEN 'both' THEN ARRAY['forward', 'reverse']
+        ELSE ARRAY[COALESCE(card_direction, pack_direction, 'forward')]
+    END;
+$$ LANGUAGE sql IMMUTABLE;
+
+ALTER TABLE card_progress
+  ADD COLUMN IF NOT EXISTS direction TEXT NOT NULL DEFAULT 'forward'
+      CHECK (direction IN ('forward', 'reverse'));
+ALTER TABLE card_progress DROP CONSTRAINT IF EXISTS card_progress_pkey;
+ALTER TABLE card_progress ADD PRIMARY KEY (user_id, card_id, direction);
+
+ALTER TABLE reviews
+  ADD COLUMN IF NOT EXISTS direction TEXT NOT NULL DEFAULT 'forward';
+
+ALTER TABLE study_session_answers
+  ADD COLUMN IF NOT EXISTS direction TEXT NOT NULL DEFAULT 'forward';
+
+-- session queue entries become "<card_id>:<direction>" keys
+ALTER TABLE study_sessions
+  ALTER COLUMN queue DROP DEFAULT,
+  ALTER COLUMN queue TYPE TEXT[] USING queue::text[],
+  ALTER COLUMN queue SET DEFAULT '{}';
+
+-- cloze cards keep "{{c1::...}}" markup in question; answer holds optional
+-- extra notes. Every cloze number is scheduled on its own, stored in the
+-- direction column as "c1", "c2", ...
+ALTER TABLE cards
+  ADD COLUMN IF NOT EXISTS card_type TEXT NOT NULL DEFAULT 'basic'
+      CHECK (card_type IN ('basic', 'cloze'));
+
+ALTER TABLE card_progress DROP CONSTRAINT IF EXISTS card_progress_direction_check;
+ALTER TABLE card_progress
+  ADD CONSTRAINT card_progress_direction_check
+      CHECK (direction IN ('forward', 'reverse') OR direction ~ '^c[0-9]+$');
+
+CREATE OR REPLACE FUNCTION card_prompts(card_type TEXT, question TEXT, card_direction TEXT, pack_direction TEXT)
+RETURNS TEXT[] AS $$
+    SELECT CASE card_type
+        WHEN 'cloze' THEN ARRAY(
+            SELECT 'c' || n
+            FROM (
+                SELECT DISTINCT m[1]::int AS n
+                FROM regexp_matches(question, '\{\{c([0-9]+)::', 'g') AS m
+            ) ords
+            ORDER BY n
+        )
+        ELSE card_directions(card_direction, pack_direction)
+    END;
+$$ LANGUAGE sql IMMUTABLE;
+
+-- rich content: markdown cards are rendered to sanitized HTML by the
+-- server. The question is no longer limited to 255 characters.
+ALTER TABLE cards
+  ALTER COLUMN question TYPE TEXT,
+  ADD COLUMN IF NOT EXISTS content_format TEXT NOT NULL DEFAULT 'plain'
+      CHECK (content_format IN ('plain', 'markdown'));
+
+ALTER TABLE cards DROP CONSTRAINT IF EXISTS cards_text_length_check;
+ALTER TABLE cards
+  ADD CONSTRAINT cards_text_length_check
+      CHECK (char_length(question) <= 4000 AND char_length(answer) <= 10000);
+
+-- media attachments, keyed by the SHA-256 of their content. Blobs live in
+-- the configured BlobStore; rows no card refers to are collected by the
+-- janitor.
+CREATE TABLE IF NOT EXISTS media (
+    hash TEXT PRIMARY KEY CHECK (hash ~ '^[0-9a-f]{64}$'),
+    content_type TEXT NOT NULL,
+    kind TEXT NOT NULL CHECK (kind IN ('image', 'audio')),
+    size_bytes BIGINT NOT NULL,
+    uploaded_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS card_media (
+    card_id UUID NOT NULL REFERENCES cards(id) ON DELETE CASCADE,
+    hash TEXT NOT NULL REFERENCES media(hash),
+    position SMALLINT NOT NULL DEFAULT 0,
+    PRIMARY KEY (card_id, hash)
+);
+CREATE INDEX IF NOT EXISTS idx_card_media_hash ON card_media(hash);
+
+-- soft delete: packs and cards go to the trash first and are purged by the
+-- janitor once the retention period has passed. Every change to a card is
+-- logged in card_revisions so it can be undone.
+ALTER TABLE packs
+  ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMPTZ,
+  ADD COLUMN IF NOT EXISTS deleted_by UUID REFERENCES users(id) ON DELETE SET NULL;
+
+ALTER TABLE cards
+  ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMPTZ,
+  ADD COLUMN IF NOT EXISTS deleted_by UUID REFERENCES users(id) ON DELETE SET NULL;
+
+CREATE INDEX IF NOT EXISTS idx_packs_deleted_at ON packs(deleted_at) WHERE deleted_at IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_cards_deleted_at ON cards(deleted_at) WHERE deleted_at IS NOT NULL;
+
+-- packs in the trash are visible to nobody
+CREATE OR REPLACE FUNCTION pack_visible(UUID, UUID)
+RETURNS BOOLEAN AS $$
+    SELECT EXISTS (
+        SELECT 1 FROM packs p
+        WHERE p.id = $1
+          AND p.deleted_at IS NULL
+          AND (p.owner_id IS NULL OR p.owner_id = $2 OR EXISTS (
+                SELECT 1 FROM subscriptions s
+                WHERE s.pack_id = p.id AND s.user_id = $2
+              ))
+    );
+$$ LANGUAGE sql STABLE;
+
+-- before/after hold the card as exported (see exportCard); before is NULL
+-- for a created card and after is NULL for a deleted one.
+CREATE TABLE IF NOT EXISTS card_revisions (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    card_id UUID NOT NULL
+        REFERENCES cards(id)
+        ON DELETE CASCADE,
+    pack_id UUID NOT NULL
+        REFERENCES packs(id)
+        ON DELETE CASCADE,
+    action TEXT NOT NULL CHECK (action IN ('create', 'update', 'delete', 'restore')),
+    before JSONB,
+    after  JSONB,
+    changed_by UUID
+        REFERENCES users(id)
+        ON DELETE SET NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_card_revisions_card ON card_revisions(card_id, created_at);
+
+-- pack names are unique per owner, so a fork can keep the name of its
+-- upstream. Packs in the trash do not reserve their name.
+ALTER TABLE packs DROP CONSTRAINT IF EXISTS packs_name_key;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_packs_owner_name
+    ON packs(owner_id, name) NULLS NOT DISTINCT
+    WHERE deleted_at IS NULL;
+
+-- forks: a pack copied from another one remembers its upstream, and every
+-- copied card the upstream card and the upstream updated_at it was last
+-- synced with. upstream_card_id has no foreign key so that a purged
+-- upstream card still shows up as removed.
+ALTER TABLE packs
+  ADD COLUMN IF NOT EXISTS forked_from UUID REFERENCES packs(id) ON DELETE SET NULL;
+
+ALTER TABLE cards
+  ADD COLUMN IF NOT EXISTS upstream_card_id UUID,
+  ADD COLUMN IF NOT EXISTS upstream_synced_at TIMESTAMPTZ;
+
+CREATE INDEX IF NOT EXISTS idx_packs_forked_from ON packs(forked_from);
+CREATE INDEX IF NOT EXISTS idx_cards_upstream_card_id ON cards(upstream_card_id);
+
+-- pack metadata. Visibility: private packs are seen by their owner and
+-- subscribers, unlisted ones by anybody who has the id, public ones are
+-- listed for everybody as well.
+ALTER TABLE packs
+  ALTER COLUMN name TYPE VARCHAR(100),
+  ADD COLUMN IF NOT EXISTS description TEXT NOT NULL DEFAULT ''
+      CHECK (char_length(description) <= 2000),
+  ADD COLUMN IF NOT EXISTS source_lang VARCHAR(35),
+  ADD COLUMN IF NOT EXISTS target_lang VARCHAR(35),
+  ADD COLUMN IF NOT EXISTS cover_color TEXT
+      CHECK (cover_color ~ '^#[0-9a-f]{6}$'),
+  ADD COLUMN IF NOT EXISTS cover_icon VARCHAR(32),
+  ADD COLUMN IF NOT EXISTS visibility TEXT NOT NULL DEFAULT 'private'
+      CHECK (visibility IN ('private', 'unlisted', 'public'));
+
+CREATE INDEX IF NOT EXISTS idx_packs_public ON packs(created_at) WHERE visibility = 'public';
+
+CREATE OR REPLACE FUNCTION pack_visible(UUID, UUID)
+RETURNS BOOLEAN AS $$
+    SELECT EXISTS (
+        SELECT 1 FROM packs p
+        WHERE p.id = $1
+          AND p.deleted_at IS NULL
+          AND (p.owner_id IS NULL OR p.owner_id = $2
+               OR p.visibility IN ('unlisted', 'public')
+               OR EXISTS (
+                    SELECT 1 FROM subscriptions s
+                    WHERE s.pack_id = p.id AND s.user_id = $2
+                  ))
+    );
+$$ LANGUAGE sql STABLE;
+
+-- public catalog: one subscription per user and pack, and one star rating
+-- (with an optional review) per user and pack.
+DELETE FROM subscriptions a
+USING subscriptions b
+WHERE a.user_id = b.user_id AND a.pack_id = b.pack_id AND a.id > b.id;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_subscriptions_user_pack ON subscriptions(user_id, pack_id);
+CREATE INDEX IF NOT EXISTS idx_subscriptions_pack_id ON subscriptions(pack_id);
+
+CREATE TABLE IF NOT EXISTS pack_ratings (
+    pack_id UUID NOT NULL
+        REFERENCES packs(id)
+        ON DELETE CASCADE,
+    user_id UUID NOT NULL
+        REFERENCES users(id)
+        ON DELETE CASCADE,
+    stars SMALLINT NOT NULL CHECK (stars BETWEEN 1 AND 5),
+    review TEXT NOT NULL DEFAULT '' CHECK (char_length(review) <= 2000),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (pack_id, user_id)
+);
+
+CREATE TRIGGER set_updated_at_pack_ratings
+BEFORE UPDATE ON pack_ratings
+FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
+
+-- share links: the token handed out is the link id signed with the server
+-- secret, so only the id is stored. A link can expire, be limited to a
+-- number of uses and be revoked.
+CREATE TABLE IF NOT EXISTS pack_share_links (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    pack_id UUID NOT NULL
+        REFERENCES packs(id)
+        ON DELETE CASCADE,
+    created_by UUID
+        REFERENCES users(id)
+        ON DELETE SET NULL,
+    expires_at TIMESTAMPTZ,
+    max_uses INT CHECK (max_uses > 0),
+    use_count INT NOT NULL DEFAULT 0,
+    revoked_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_pack_share_links_pack_id ON pack_share_links(pack_id);
+
+-- pack members: collaborators an owner has invited. The user in
+-- packs.owner_id is always an owner and has no row here; an invitation
+-- grants its role once it is accepted.
+CREATE TABLE IF NOT EXISTS pack_members (
+    pack_id UUID NOT NULL
+        REFERENCES packs(id)
+        ON DELETE CASCADE,
+    user_id UUID NOT NULL
+        REFERENCES users(id)
+        ON DELETE CASCADE,
+    role VARCHAR(10) NOT NULL CHECK (role IN ('owner', 'editor', 'viewer')),
+    invited_by UUID
+        REFERENCES users(id)
+        ON DELETE SET NULL,
+    accepted_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (pack_id, user_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_pack_members_user_id ON pack_members(user_id);
+
+-- pack_role is the role of user $2 in pack $1, NULL when they have none.
+-- Packs without an owner predate accounts and everybody owns them.
+CREATE OR REPLACE FUNCTION pack_role(UUID, UUID)
+RETURNS TEXT AS $$
+    SELECT CASE
+        WHEN p.owner_id IS NULL OR p.owner_id = $2 THEN 'owner'
+        ELSE (SELECT m.role FROM pack_members m
+              WHERE m.pack_id = p.id AND m.user_id = $2
+                AND m.accepted_at IS NOT NULL)
+    END
+    FROM packs p
+    WHERE p.id = $1 AND p.deleted_at IS NULL;
+$$ LANGUAGE sql STABLE;
+
+CREATE OR REPLACE FUNCTION pack_visible(UUID, UUID)
+RETURNS BOOLEAN AS $$
+    SELECT EXISTS (
+        SELECT 1 FROM packs p
+        WHERE p.id = $1
+          AND p.deleted_at IS NULL
+          AND (p.owner_id IS NULL OR p.owner_id = $2
+               OR p.visibility IN ('unlisted', 'public')
+               OR EXISTS (
+                    SELECT 1 FROM subscriptions s
+                    WHERE s.pack_id = p.id AND s.user_id = $2
+                  )
+               OR EXISTS (
+                    SELECT 1 FROM pack_members m
+                    WHERE m.pack_id = p.id AND m.user_id = $2
+                      AND m.accepted_at IS NOT NULL
+                  ))
+    );
+$$ LANGUAGE sql STABLE;
+
+-- organizations: teams with a shared pack library. A pack belongs either
+-- to a user or to an organization; admins own the packs of their
+-- organization and members can study them.
+CREATE TABLE IF NOT EXISTS organizations (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    name VARCHAR(100) NOT NULL UNIQUE,
+    created_by UUID
+        REFERENCES users(id)
+        ON DELETE SET NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE TRIGGER set_updated_at_organizations
+BEFORE UPDATE ON organizations
+FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
+
+CREATE TABLE IF NOT EXISTS organization_members (
+    org_id UUID NOT NULL
+        REFERENCES organizations(id)
+        ON DELETE CASCADE,
+    user_id UUID NOT NULL
+        REFERENCES users(id)
+        ON DELETE CASCADE,
+    role VARCHAR(10) NOT NULL DEFAULT 'member' CHECK (role IN ('admin', 'member')),
+    joined_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (org_id, user_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_organization_members_user_id ON organization_members(user_id);
+
+ALTER TABLE packs
+  ADD COLUMN IF NOT EXISTS org_id UUID REFERENCES organizations(id) ON DELETE CASCADE;
+ALTER TABLE packs DROP CONSTRAINT IF EXISTS packs_single_owner_check;
+ALTER TABLE packs
+  ADD CONSTRAINT packs_single_owner_check CHECK (owner_id IS NULL OR org_id IS NULL);
+
+-- names are unique per owner and per organization
+DROP INDEX IF EXISTS idx_packs_owner_name;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_packs_owner_org_name
+    ON packs(owner_id, org_id, name) NULLS NOT DISTINCT
+    WHERE deleted_at IS NULL;
+CREATE INDEX IF NOT EXISTS idx_packs_org_id ON packs(org_id);
+
+-- assignments: an admin asks members to study a pack, optionally by a due
+-- date. Assigned members are subscribed to the pack.
+CREATE TABLE IF NOT EXISTS pack_assignments (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    org_id UUID NOT NULL
+        REFERENCES organizations(id)
+        ON DELETE CASCADE,
+    pack_id UUID NOT NULL
+        REFERENCES packs(id)
+        ON DELETE CASCADE,
+    user_id UUID NOT NULL
+        REFERENCES users(id)
+        ON DELETE CASCADE,
+    assigned_by UUID
+        REFERENCES users(id)
+        ON DELETE SET NULL,
+    due_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    UNIQUE (pack_id, user_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_pack_assignments_org_id ON pack_assignments(org_id);
+CREATE INDEX IF NOT EXISTS idx_pack_assignments_user_id ON pack_assignments(user_id);
+
+-- pack_role with organizations: admins own the packs of their
+-- organization, members view them unless the pack gives them more.
+CREATE OR REPLACE FUNCTION pack_role(UUID, UUID)
+RETURNS TEXT AS $$
+    SELECT CASE
+        WHEN p.org_id IS NULL AND (p.owner_id IS NULL OR p.owner_id = $2) THEN 'owner'
+        WHEN om.role = 'admin' THEN 'owner'
+        ELSE COALESCE(
+            (SELECT m.role FROM pack_members m
+             WHERE m.pack_id = p.id AND m.user_id = $2
+               AND m.accepted_at IS NOT NULL),
+            CASE WHEN om.role IS NOT NULL THEN 'viewer' END)
+    END
+    FROM packs p
+    LEFT JOIN organization_members om ON om.org_id = p.org_id AND om.user_id = $2
+    WHERE p.id = $1 AND p.deleted_at IS NULL;
+$$ LANGUAGE sql STABLE;
+
+CREATE OR REPLACE FUNCTION pack_visible(UUID, UUID)
+RETURNS BOOLEAN AS $$
+    SELECT EXISTS (
+        SELECT 1 FROM packs p
+        WHERE p.id = $1
+          AND p.deleted_at IS NULL
+          AND (p.visibility IN ('unlisted', 'public')
+               OR pack_role(p.id, $2) IS NOT NULL
+               OR EXISTS (
+                    SELECT 1 FROM subscriptions s
+                    WHERE s.pack_id = p.id AND s.user_id = $2
+                  ))
+    );
+$$ LANGUAGE sql STABLE;
+
+-- mastered packs: a pack counts once per user however often it is
+-- finished without a mistake, replacing the user_stats.packs_mastered
+-- counter. Pack sessions already completed that way are carried over.
+CREATE TABLE IF NOT EXISTS pack_masteries (
+    user_id UUID NOT NULL
+        REFERENCES users(id)
+        ON DELETE CASCADE,
+    pack_id UUID NOT NULL
+        REFERENCES packs(id)
+        ON DELETE CASCADE,
+    mastered_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (user_id, pack_id)
+);
+
+INSERT INTO pack_masteries (user_id, pack_id, mastered_at)
+SELECT user_id, pack_id, COALESCE(MIN(completed_at), NOW())
+FROM study_sessions
+WHERE source = 'pack' AND status = 'completed' AND pack_id IS NOT NULL
+  AND total_cards > 0 AND wrong_count = 0 AND cardinality(queue) = 0
+GROUP BY user_id, pack_id
+ON CONFLICT DO NOTHING;
+
+ALTER TABLE user_stats DROP COLUMN IF EXISTS packs_mastered;
+
+-- study days, goals and streaks: a study day runs from day_rollover_hour
+-- to day_rollover_hour in the user's time zone. A day counts towards the
+-- streak when the daily goal (cards reviewed or minutes studied) is met; a
+-- few missed days a week can be covered by streak freezes.
+ALTER TABLE user_settings
+  ADD COLUMN IF NOT EXISTS timezone TEXT NOT NULL DEFAULT 'UTC',
+  ADD COLUMN IF NOT EXISTS day_rollover_hour INT NOT NULL DEFAULT 0
+      CHECK (day_rollover_hour BETWEEN 0 AND 23),
+  ADD COLUMN IF NOT EXISTS goal_type TEXT NOT NULL DEFAULT 'cards'
+      CHECK (goal_type IN ('cards', 'minutes')),
+  ADD COLUMN IF NOT EXISTS daily_goal INT NOT NULL DEFAULT 20
+      CHECK (daily_goal BETWEEN 1 AND 1440),
+  ADD COLUMN IF NOT EXISTS streak_freezes_per_week INT NOT NULL DEFAULT 1
+      CHECK (streak_freezes_per_week BETWEEN 0 AND 3);
+
+-- leaderboards: every change of user_stats.rating is logged so scores can
+-- be summed per week or month. The log is seeded from the review log,
+-- where every answer moved the rating by one.
+CREATE TABLE IF NOT EXISTS rating_events (
+    id BIGSERIAL PRIMARY KEY,
+    user_id UUID NOT NULL
+        REFERENCES users(id)
+        ON DELETE CASCADE,
+    delta INT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_rating_events_created_at ON rating_events(created_at, user_id);
+
+INSERT INTO rating_events (user_id, delta, created_at)
+SELECT user_id, SUM(CASE WHEN correct THEN 1 ELSE -1 END), date_trunc('hour', reviewed_at)
+FROM reviews
+WHERE NOT EXISTS (SELECT 1 FROM rating_events)
+GROUP BY user_id, date_trunc('hour', reviewed_at);
+
+-- weekly rankings are frozen once the week (Monday to Sunday, UTC) is over
+CREATE TABLE IF NOT EXISTS leaderboard_snapshots (
+    week_start DATE NOT NULL,
+    user_id UUID NOT NULL
+        REFERENCES users(id)
+        ON DELETE CASCADE,
+    score INT NOT NULL,
+    rank INT NOT NULL,
+    PRIMARY KEY (week_start, user_id)
+);
+
+-- friends: a request becomes a friendship once accepted. One row per pair
+-- whoever asked.
+CREATE TABLE IF NOT EXISTS friendships (
+    user_id UUID NOT NULL
+        REFERENCES users(id)
+        ON DELETE CASCADE,
+    friend_id UUID NOT NULL
+        REFERENCES users(id)
+        ON DELETE CASCADE,
+    accepted_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (user_id, friend_id),
+    CHECK (user_id <> friend_id)
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_friendships_pair
+    ON friendships(LEAST(user_id, friend_id), GREATEST(user_id, friend_id));
+CREATE INDEX IF NOT EXISTS idx_friendships_friend_id ON friendships(friend_id);
+
+ALTER TABLE user_settings
+  ADD COLUMN IF NOT EXISTS leaderboard_opt_out BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- achievements: milestones a user reached, awarded once each
+CREATE TABLE IF NOT EXISTS user_achievements (
+    user_id UUID NOT NULL
+        REFERENCES users(id)
+        ON DELETE CASCADE,
+    achievement TEXT NOT NULL,
+    awarded_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (user_id, achievement)
+);
+
+-- pack_listed: whether a pack shows up in listings and search for a user.
+-- Unlike pack_visible, unlisted packs are only listed for the users who
+-- have a role in them or subscribe to them.
+CREATE OR REPLACE FUNCTION pack_listed(UUID, UUID)
+RETURNS BOOLEAN AS $$
+    SELECT EXISTS (
+        SELECT 1 FROM packs p
+        WHERE p.id = $1
+          AND p.deleted_at IS NULL
+          AND (p.visibility = 'public'
+               OR pack_role(p.id, $2) IS NOT NULL
+               OR EXISTS (
+                    SELECT 1 FROM subscriptions s
+                    WHERE s.pack_id = p.id AND s.user_id = $2
+                  ))
+    );
+$$ LANGUAGE sql STABLE;
+
+-- organization invitations: admins invite users, who join once they
+-- accept. Until then joined_at is NULL and the invitee has no access.
+ALTER TABLE organization_members
+  ADD COLUMN IF NOT EXISTS invited_by UUID REFERENCES users(id) ON DELETE SET NULL,
+  ADD COLUMN IF NOT EXISTS created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+  ALTER COLUMN joined_at DROP NOT NULL;
+
+CREATE OR REPLACE FUNCTION pack_role(UUID, UUID)
+RETURNS TEXT AS $$
+    SELECT CASE
+        WHEN p.org_id IS NULL AND (p.owner_id IS NULL OR p.owner_id = $2) THEN 'owner'
+        WHEN om.role = 'admin' THEN 'owner'
+        ELSE COALESCE(
+            (SELECT m.role FROM pack_members m
+             WHERE m.pack_id = p.id AND m.user_id = $2
+               AND m.accepted_at IS NOT NULL),
+            CASE WHEN om.role IS NOT NULL THEN 'viewer' END)
+    END
+    FROM packs p
+    LEFT JOIN organization_members om
+           ON om.org_id = p.org_id AND om.user_id = $2 AND om.joined_at IS NOT NULL
+    WHERE p.id = $1 AND p.deleted_at IS NULL;
+$$ LANGUAGE sql STABLE;
+
+-- mastered pack size: the number of cards the pack had when it was
+-- mastered, so later growth does not count. Rows from before are given the
+-- current size.
+ALTER TABLE pack_masteries ADD COLUMN IF NOT EXISTS card_count INT;
+
+UPDATE pack_masteries pm
+SET card_count = (SELECT COUNT(*) FROM cards c WHERE c.pack_id = pm.pack_id AND c.deleted_at IS NULL)
+WHERE pm.card_count IS NULL;
+
+ALTER TABLE pack_masteries
+  ALTER COLUMN card_count SET DEFAULT 0,
+  ALTER COLUMN card_count SET NOT NULL;
+
+-- packs created: counted from the packs a user owns instead of a counter
+-- that was never decremented on delete.
+ALTER TABLE user_stats DROP COLUMN IF EXISTS packs_created;
+
+-- cloze numbers have at most three digits, as the server checks, so the
+-- cast cannot overflow on a card written before that check.
+CREATE OR REPLACE FUNCTION card_prompts(card_type TEXT, question TEXT, card_direction TEXT, pack_direction TEXT)
+RETURNS TEXT[] AS $$
+    SELECT CASE card_type
+        WHEN 'cloze' THEN ARRAY(
+            SELECT 'c' || n
+            FROM (
+                SELECT DISTINCT m[1]::int AS n
+                FROM regexp_matches(question, '\{\{c([0-9]{1,3})::', 'g') AS m
+            ) ords
+            WHERE n > 0
+            ORDER BY n
+        )
+        ELSE card_directions(card_direction, pack_direction)
+    END;
+$$ LANGUAGE sql IMMUTABLE;
+
+-- assignments are per organization: two organizations may assign the same
+-- pack to a member, each with its own due date.
+ALTER TABLE pack_assignments DROP CONSTRAINT IF EXISTS pack_assignments_pack_id_user_id_key;
+ALTER TABLE pack_assignments DROP CONSTRAINT IF EXISTS pack_assignments_org_id_pack_id_user_id_key;
+ALTER TABLE pack_assignments
+  ADD CONSTRAINT pack_assignments_org_id_pack_id_user_id_key UNIQUE (org_id, pack_id, user_id);
+
+-- fork sync compares content, not updated_at: studying a card bumps its
+-- updated_at without changing it. A copy remembers the hash of the upstream
+-- content it was last synced with. Copies whose upstream did not move since
+-- the sync take the upstream hash, the others their own content, which is
+-- what they were synced from unless edited locally.
+CREATE OR REPLACE FUNCTION card_content_hash(question TEXT, answer TEXT, card_type TEXT, content_format TEXT, direction TEXT)
+RETURNS TEXT AS $$
+    SELECT md5(json_build_array(question, answer, card_type, content_format, direction)::text);
+$$ LANGUAGE sql STABLE;
+
+ALTER TABLE cards ADD COLUMN IF NOT EXISTS upstream_hash TEXT;
+
+UPDATE cards f
+SET upstream_hash = CASE
+        WHEN u.updated_at <= f.upstream_synced_at
+            THEN card_content_hash(u.question, u.answer, u.card_type, u.content_format, u.direction)
+        ELSE card_content_hash(f.question, f.answer, f.card_type, f.content_format, f.direction)
+    END
+FROM cards u
+WHERE u.id = f.upstream_card_id AND f.upstream_hash IS NULL;
+
+-- the last answer to a card is wrong per user: a lapse resets reps to 0.
+-- cards.last_wrong was shared by all users and is no longer written.
+CREATE OR REPLACE FUNCTION card_last_wrong(UUID, UUID)
+RETURNS BOOLEAN AS $$
+    SELECT EXISTS (
+        SELECT 1 FROM card_progress cp
+        WHERE cp.card_id = $1 AND cp.user_id = $2
+          AND cp.reps = 0 AND cp.lapses > 0
+    );
+$$ LANGUAGE sql STABLE;
+
+UPDATE cards SET last_wrong = FALSE WHERE last_wrong;
+
+-- packs go with their owner: with SET NULL a deleted user's packs lost
+-- their owner and became legacy packs, which everybody owns.
+ALTER TABLE packs DROP CONSTRAINT IF EXISTS packs_owner_id_fkey;
+ALTER TABLE packs
+  ADD CONSTRAINT packs_owner_id_fkey
+      FOREIGN KEY (owner_id) REFERENCES users(id) ON DELETE CASCADE;
+
+-- settled streak days: each study day is settled once it is over, with the
+-- goal and freezes in effect then, so later changes to the settings do not
+-- rewrite the streak. Days before this table are settled with the settings
+-- of the first computation.
+CREATE TABLE IF NOT EXISTS streak_days (
+    user_id UUID NOT NULL
+        REFERENCES users(id)
+        ON DELETE CASCADE,
+    day DATE NOT NULL,
+    goal_type TEXT NOT NULL CHECK (goal_type IN ('cards', 'minutes')),
+    goal INT NOT NULL,
+    met BOOLEAN NOT NULL,
+    -- a freeze covered the missed day
+    frozen BOOLEAN NOT NULL,
+    PRIMARY KEY (user_id, day)
+);
+
+-- review durations are capped at ten minutes, as the server now does.
+UPDATE reviews SET duration_ms = 600000 WHERE duration_ms > 600000;
@@ -0,0 +1,23 @@
+-- name: AchievementFacts :one
+-- What the achievement rules look at, apart from the streak. Mastered packs
+-- count with the size they had when mastered.
+SELECT (SELECT COUNT(*) FROM packs p WHERE p.owner_id = @user_id AND p.deleted_at IS NULL)::int AS packs_created,
+       (SELECT COUNT(*) FROM reviews r WHERE r.user_id = @user_id)::bigint AS reviews,
+       COALESCE((
+           SELECT MAX(pm.card_count)
+           FROM pack_masteries pm
+           JOIN packs p ON p.id = pm.pack_id AND p.deleted_at IS NULL
+           WHERE pm.user_id = @user_id
+       ), 0)::bigint AS largest_mastered_pack;
+
+-- name: AwardAchievement :one
+-- No row when the user has the achievement already.
+INSERT INTO user_achievements (user_id, achievement)
+VALUES (@user_id, @achievement)
+ON CONFLICT DO NOTHING
+RETURNING *;
+
+-- name: ListUserAchievements :many
+SELECT * FROM user_achievements
+WHERE user_id = @user_id
+ORDER BY awarded_at, achievement;
@@ -1,32 +1,105 @@
 -- name: CreateCard :one
-INSERT INTO cards (question, answer, pack_id, rating)
-VALUES ($1, $2, $3, $4)
+INSERT INTO cards (question, answer, pack_id, rating, direction, card_type, content_format)
+VALUES ($1, $2, $3, $4, $5, $6, $7)
 RETURNING *;
 
 -- name: ReadCard :one
+-- Cards in the trash, or in a pack in the trash, are not found.
+SELECT c.*
+FROM cards c
+JOIN packs p ON p.id = c.pack_id
+WHERE c.id = $1 AND c.deleted_at IS NULL AND p.deleted_at IS NULL;
+
+-- name: CardLastWrong :one
+SELECT card_last_wrong(@card_id::uuid, @user_id::uuid)::bool;
+
+-- name: ReadCardAny :one
+-- Like ReadCard, but also finds cards in the trash.
 SELECT * FROM cards WHERE id = $1;
 
 -- name: UpdateCard :one
 UPDATE cards
-SET question = $1, answer = $2, rating = 2
-WHERE id = $3
+SET question = @question, answer = @answer, card_type = @card_type,
+    content_format = @content_format, direction = sqlc.narg('direction')
+WHERE id = @id AND deleted_at IS NULL
 RETURNING *;
 
 -- name: ListCardsByPack :many
-SELECT *
-FROM cards
-WHERE pack_id = $1
-ORDER BY created_at DESC;
+-- Keyset-paginated, see ListPacks for the cursor_* convention. Due cards
+-- have a prompt the user is due to review, as counted by ListPacks.
+SELECT sqlc.embed(c), card_last_wrong(c.id, @user_id::uuid)::bool AS user_last_wrong
+FROM cards c
+WHERE c.pack_id = @pack_id
+  AND c.deleted_at IS NULL
+  AND pack_visible(c.pack_id, @user_id::uuid)
+  AND (sqlc.narg('due')::bool IS NULL OR EXISTS (
+        SELECT 1 FROM card_progress cp
+        WHERE cp.card_id = c.id AND cp.user_id = @user_id::uuid AND cp.due_at <= NOW()
+      ) = sqlc.narg('due')::bool)
+  AND (sqlc.narg('cursor_id')::uuid IS NULL OR CASE
+        WHEN @sort_by::text = 'question' AND @sort_desc::bool
+            THEN (c.question, c.id) < (sqlc.narg('cursor_name')::text, sqlc.narg('cursor_id')::uuid)
+        WHEN @sort_by::text = 'question'
+            THEN (c.question, c.id) > (sqlc.narg('cursor_name')::text, sqlc.narg('cursor_id')::uuid)
+        WHEN @sort_by::text = 'updated_at' AND @sort_desc::bool
+            THEN (c.updated_at, c.id) < (sqlc.narg('cursor_time')::timestamptz, sqlc.narg('cursor_id')::uuid)
+        WHEN @sort_by::text = 'updated_at'
+            THEN (c.updated_at, c.id) > (sqlc.narg('cursor_time')::timestamptz, sqlc.narg('cursor_id')::uuid)
+        WHEN @sort_by::text = 'rating' AND @sort_desc::bool
+            THEN (COALESCE(c.rating, 0), c.id) < (sqlc.narg('cursor_count')::int, sqlc.narg('cursor_id')::uuid)
+        WHEN @sort_by::text = 'rating'
+            THEN (COALESCE(c.rating, 0), c.id) > (sqlc.narg('cursor_count')::int, sqlc.narg('cursor_id')::uuid)
+        WHEN @sort_desc::bool
+            THEN (c.created_at, c.id) < (sqlc.narg('cursor_time')::timestamptz, sqlc.narg('cursor_id')::uuid)
+        ELSE (c.created_at, c.id) > (sqlc.narg('cursor_time')::timestamptz, sqlc.narg('cursor_id')::uuid)
+      END)
+ORDER BY
+  CASE WHEN @sort_by::text = 'question'   AND NOT @sort_desc::bool THEN c.question END ASC,
+  CASE WHEN @sort_by::text = 'question'   AND @sort_desc::bool     THEN c.question END DESC,
+  CASE WHEN @sort_by::text = 'updated_at' AND NOT @sort_desc::bool THEN c.updated_at END ASC,
+  CASE WHEN @sort_by::text = 'updated_at' AND @sort_desc::bool     THEN c.updated_at END DESC,
+  CASE WHEN @sort_by::text = 'rating'     AND NOT @sort_desc::bool THEN COALESCE(c.rating, 0) END ASC,
+  CASE WHEN @sort_by::text = 'rating'     AND @sort_desc::bool     THEN COALESCE(c.rating, 0) END DESC,
+  -- ties are broken by id alone, as in the cursor
+  CASE WHEN @sort_by::text NOT IN ('question', 'updated_at', 'rating') AND NOT @sort_desc::bool THEN c.created_at END ASC,
+  CASE WHEN @sort_by::text NOT IN ('question', 'updated_at', 'rating') AND @sort_desc::bool     THEN c.created_at END DESC,
+  CASE WHEN NOT @sort_desc::bool THEN c.id END ASC,
+  CASE WHEN @sort_desc::bool     THEN c.id END DESC
+LIMIT @page_limit;
 
 -- name: ListRepeatCards :many
-SELECT * FROM cards
-WHERE pack_id = $1
-ORDER BY last_wrong DESC, created_at ASC;
+-- Cards the user last answered wrong come first.
+SELECT c.*, card_prompts(c.card_type, c.question, c.direction, p.direction)::text[] AS directions,
+       card_last_wrong(c.id, @user_id::uuid)::bool AS user_last_wrong
+FROM cards c
+JOIN packs p ON p.id = c.pack_id
+WHERE c.pack_id = @pack_id AND c.deleted_at IS NULL
+  AND pack_visible(c.pack_id, @user_id::uuid)
+ORDER BY user_last_wrong DESC, c.created_at ASC;
+
+-- name: SetCardDirection :execrows
+UPDATE cards
+SET direction = sqlc.narg('direction')
+FROM packs p
+WHERE cards.id = @id AND cards.pack_id = @pack_id AND cards.deleted_at IS NULL
+  AND p.id = cards.pack_id
+  AND pack_role(p.id, @owner_id::uuid) IN ('owner', 'editor');
 
--- name: MarkCardWrong :exec
+-- name: SoftDeleteCard :execrows
 UPDATE cards
-SET last_wrong = $1
-WHERE id = $2;
+SET deleted_at = NOW(), deleted_by = @deleted_by
+WHERE id = @id AND deleted_at IS NULL;
 
--- name: DeleteCard :exec
-DELETE FROM cards WHERE id = $1;
+-- name: RestoreCard :one
+UPDATE cards
+SET deleted_at = NULL, deleted_by = NULL
+WHERE id = $1 AND deleted_at IS NOT NULL
+RETURNING *;
+
+-- name: PurgeDeletedCards :execrows
+DELETE FROM cards WHERE deleted_at < @before::timestamptz;
+
+-- name: ListPackCards :many
+SELECT * FROM cards
+WHERE pack_id = $1 AND deleted_at IS NULL
+ORDER BY created_at, id;
@@ -0,0 +1,122 @@
+-- name: ListCatalog :many
+-- Public packs, keyset-paginated like ListPacks. rating_score is a Bayesian
+-- average (five votes of 3 stars are assumed for every pack) times 1000, so
+-- a single 5-star vote does not put a pack on top. Activity is the last
+-- change of the pack or any of its cards.
+WITH RECURSIVE category_tree AS (
+    SELECT categories.id FROM categories WHERE categories.id = sqlc.narg('category_id')::uuid
+    UNION ALL
+    SELECT ch.id FROM categories ch JOIN category_tree t ON ch.parent_id = t.id
+)
+SELECT p.id, p.name, p.description, cat.name AS category, p.category_id,
+       p.owner_id, u.username AS owner_name,
+       p.source_lang, p.target_lang, p.cover_color, p.cover_icon, p.created_at,
+       sc.subscriber_count::bigint AS subscriber_count,
+       cc.card_count::bigint AS card_count,
+       r.rating_count::bigint AS rating_count,
+       r.rating_avg::float8 AS rating_avg,
+       r.rating_score::bigint AS rating_score,
+       COALESCE(GREATEST(p.updated_at, cc.last_card_at), p.created_at)::timestamptz AS active_at,
+       EXISTS (
+           SELECT 1 FROM subscriptions s
+           WHERE s.pack_id = p.id AND s.user_id = @user_id::uuid
+       )::bool AS subscribed
+FROM packs p
+LEFT JOIN categories cat ON cat.id = p.category_id
+LEFT JOIN users u ON u.id = p.owner_id
+CROSS JOIN LATERAL (
+    SELECT COUNT(*) AS subscriber_count FROM subscriptions s WHERE s.pack_id = p.id
+) sc
+CROSS JOIN LATERAL (
+    SELECT COUNT(*) AS card_count, MAX(c.updated_at) AS last_card_at
+    FROM cards c
+    WHERE c.pack_id = p.id AND c.deleted_at IS NULL
+) cc
+CROSS JOIN LATERAL (
+    SELECT COUNT(*) AS rating_count,
+           COALESCE(AVG(pr.stars), 0) AS rating_avg,
+           1000 * (COALESCE(SUM(pr.stars), 0) + 15) / (COUNT(*) + 5) AS rating_score
+    FROM pack_ratings pr
+    WHERE pr.pack_id = p.id
+) r
+WHERE p.visibility = 'public' AND p.deleted_at IS NULL
+  AND (sqlc.narg('category_id')::uuid IS NULL OR p.category_id IN (SELECT category_tree.id FROM category_tree))
+  AND (sqlc.narg('query')::text IS NULL OR
+       to_tsvector('russian', p.name || ' ' || p.description) @@ websearch_to_tsquery('russian', sqlc.narg('query')::text))
+  AND (sqlc.narg('source_lang')::text IS NULL OR lower(p.source_lang) = lower(sqlc.narg('source_lang')::text))
+  AND (sqlc.narg('target_lang')::text IS NULL OR lower(p.target_lang) = lower(sqlc.narg('target_lang')::text))
+  AND (sqlc.narg('cursor_id')::uuid IS NULL OR CASE
+        WHEN @sort_by::text = 'name' AND @sort_desc::bool
+            THEN (p.name, p.id) < (sqlc.narg('cursor_name')::text, sqlc.narg('cursor_id')::uuid)
+        WHEN @sort_by::text = 'name'
+            THEN (p.name, p.id) > (sqlc.narg('cursor_name')::text, sqlc.narg('cursor_id')::uuid)
+        WHEN @sort_by::text = 'popular' AND @sort_desc::bool
+            THEN (sc.subscriber_count, p.id) < (sqlc.narg('cursor_count')::bigint, sqlc.narg('cursor_id')::uuid)
+        WHEN @sort_by::text = 'popular'
+            THEN (sc.subscriber_count, p.id) > (sqlc.narg('cursor_count')::bigint, sqlc.narg('cursor_id')::uuid)
+        WHEN @sort_by::text = 'rating' AND @sort_desc::bool
+            THEN (r.rating_score, p.id) < (sqlc.narg('cursor_count')::bigint, sqlc.narg('cursor_id')::uuid)
+        WHEN @sort_by::text = 'rating'
+            THEN (r.rating_score, p.id) > (sqlc.narg('cursor_count')::bigint, sqlc.narg('cursor_id')::uuid)
+        WHEN @sort_by::text = 'recent' AND @sort_desc::bool
+            THEN (COALESCE(GREATEST(p.updated_at, cc.last_card_at), p.created_at), p.id) < (sqlc.narg('cursor_time')::timestamptz, sqlc.narg('cursor_id')::uuid)
+        WHEN @sort_by::text = 'recent'
+            THEN (COALESCE(GREATEST(p.updated_at, cc.last_card_at), p.created_at), p.id) > (sqlc.narg('cursor_time')::timestamptz, sqlc.narg('cursor_id')::uuid)
+        WHEN @sort_desc::bool
+            THEN (p.created_at, p.id) < (sqlc.narg('cursor_time')::timestamptz, sqlc.narg('cursor_id')::uuid)
+        ELSE (p.created_at, p.id) > (sqlc.narg('cursor_time')::timestamptz, sqlc.narg('cursor_id')::uuid)
+      END)
+ORDER BY
+  CASE WHEN @sort_by::text = 'name'    AND NOT @sort_desc::bool THEN p.name END ASC,
+  CASE WHEN @sort_by::text = 'name'    AND @sort_desc::bool     THEN p.name END DESC,
+  CASE WHEN @sort_by::text = 'popular' AND NOT @sort_desc::bool THEN sc.subscriber_count END ASC,
+  CASE WHEN @sort_by::text = 'popular' AND @sort_desc::bool     THEN sc.subscriber_count END DESC,
+  CASE WHEN @sort_by::text = 'rating'  AND NOT @sort_desc::bool THEN r.rating_score END ASC,
+  CASE WHEN @sort_by::text = 'rating'  AND @sort_desc::bool     THEN r.rating_score END DESC,
+  CASE WHEN @sort_by::text = 'recent'  AND NOT @sort_desc::bool THEN COALESCE(GREATEST(p.updated_at, cc.last_card_at), p.created_at) END ASC,
+  CASE WHEN @sort_by::text = 'recent'  AND @sort_desc::bool     THEN COALESCE(GREATEST(p.updated_at, cc.last_card_at), p.created_at) END DESC,
+  CASE WHEN NOT @sort_desc::bool THEN p.created_at END ASC,
+  CASE WHEN @sort_desc::bool     THEN p.created_at END DESC,
+  CASE WHEN NOT @sort_desc::bool THEN p.id END ASC,
+  CASE WHEN @sort_desc::bool     THEN p.id END DESC
+LIMIT @page_limit;
+
+-- name: Subscribe :execrows
+INSERT INTO subscriptions (user_id, pack_id)
+VALUES (@user_id, @pack_id)
+ON CONFLICT (user_id, pack_id) DO NOTHING;
+
+-- name: Unsubscribe :execrows
+DELETE FROM subscriptions WHERE user_id = @user_id AND pack_id = @pack_id;
+
+-- name: UpsertPackRating :one
+INSERT INTO pack_ratings (pack_id, user_id, stars, review)
+VALUES (@pack_id, @user_id, @stars, @review)
+ON CONFLICT (pack_id, user_id) DO UPDATE
+SET stars = EXCLUDED.stars, review = EXCLUDED.review
+RETURNING *;
+
+-- name: DeletePackRating :execrows
+DELETE FROM pack_ratings WHERE pack_id = @pack_id AND user_id = @user_id;
+
+-- name: PackRatingSummary :one
+SELECT COUNT(*)::bigint AS rating_count,
+       COALESCE(AVG(stars), 0)::float8 AS rating_avg,
+       COUNT(*) FILTER (WHERE stars = 1)::bigint AS stars_1,
+       COUNT(*) FILTER (WHERE stars = 2)::bigint AS stars_2,
+       COUNT(*) FILTER (WHERE stars = 3)::bigint AS stars_3,
+       COUNT(*) FILTER (WHERE stars = 4)::bigint AS stars_4,
+       COUNT(*) FILTER (WHERE stars = 5)::bigint AS stars_5
+FROM pack_ratings
+WHERE pack_id = @pack_id;
+
+-- name: ListPackRatings :many
+-- Newest first, keyset-paginated on updated_at.
+SELECT pr.user_id, u.username, pr.stars, pr.review, pr.created_at, pr.updated_at
+FROM pack_ratings pr
+JOIN users u ON u.id = pr.user_id
+WHERE pr.pack_id = @pack_id
+  AND (sqlc.narg('cursor_id')::uuid IS NULL
+       OR (pr.updated_at, pr.user_id) < (sqlc.narg('cursor_time')::timestamptz, sqlc.narg('cursor_id')::uuid))
+ORDER BY pr.updated_at DESC, pr.user_id DESC
+LIMIT @page_limit;
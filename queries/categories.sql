@@ -0,0 +1,46 @@
+-- name: CreateCategory :one
+INSERT INTO categories (name, slug, parent_id, owner_id, created_by)
+VALUES (@name, slugify(COALESCE(NULLIF(@slug::text, ''), @name)), @parent_id, @owner_id, @created_by)
+RETURNING *;
+
+-- name: ReadCategory :one
+-- Only categories visible to the user: global ones and their own.
+SELECT * FROM categories
+WHERE id = @id AND (owner_id IS NULL OR owner_id = @user_id::uuid);
+
+-- name: FindCategoryBySlug :one
+-- The user's own category wins over a global one with the same slug.
+SELECT * FROM categories
+WHERE slug = slugify(@name::text) AND (owner_id IS NULL OR owner_id = @user_id::uuid)
+ORDER BY owner_id NULLS LAST
+LIMIT 1;
+
+-- name: ListCategories :many
+SELECT * FROM categories
+WHERE (owner_id IS NULL OR owner_id = @user_id::uuid)
+  AND (sqlc.narg('parent_id')::uuid IS NULL OR parent_id = sqlc.narg('parent_id')::uuid)
+ORDER BY name, id;
+
+-- name: UpdateCategory :one
+UPDATE categories
+SET name = @name,
+    slug = slugify(COALESCE(NULLIF(@slug::text, ''), @name)),
+    parent_id = @parent_id
+WHERE id = @id
+RETURNING *;
+
+-- name: IsCategoryDescendant :one
+-- Reports whether candidate lies in the subtree rooted at id (id included),
+-- used to refuse re-parenting that would create a cycle.
+WITH RECURSIVE tree AS (
+    SELECT categories.id FROM categories WHERE categories.id = @id::uuid
+    UNION ALL
+    SELECT ch.id FROM categories ch JOIN tree t ON ch.parent_id = t.id
+)
+SELECT EXISTS (SELECT 1 FROM tree WHERE tree.id = @candidate::uuid);
+
+-- name: CountCategoryChildren :one
+SELECT COUNT(*) FROM categories WHERE parent_id = $1;
+
+-- name: DeleteCategory :exec
+DELETE FROM categories WHERE id = $1;
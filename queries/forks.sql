@@ -0,0 +1,87 @@
+-- name: ForkPack :one
+-- The upstream category is kept when the new owner can use it. A fork
+-- starts out private.
+INSERT INTO packs (name, category_id, owner_id, direction, forked_from,
+                   description, source_lang, target_lang, cover_color, cover_icon)
+SELECT @name,
+       COALESCE(sqlc.narg('category_id')::uuid, (
+           SELECT cat.id FROM categories cat
+           WHERE cat.id = up.category_id
+             AND (cat.owner_id IS NULL OR cat.owner_id = @owner_id::uuid)
+       )),
+       @owner_id::uuid, up.direction, up.id,
+       up.description, up.source_lang, up.target_lang, up.cover_color, up.cover_icon
+FROM packs up
+WHERE up.id = @upstream_id
+RETURNING *;
+
+-- name: CopyUpstreamCards :many
+-- Copies live upstream cards that have no copy in the fork yet, all of them
+-- or only @card_ids. A copy in the trash counts, so cards deleted from the
+-- fork are not offered again.
+INSERT INTO cards (question, answer, pack_id, rating, direction, card_type, content_format,
+                   upstream_card_id, upstream_synced_at, upstream_hash)
+SELECT u.question, u.answer, @pack_id::uuid, 0, u.direction, u.card_type, u.content_format,
+       u.id, u.updated_at,
+       card_content_hash(u.question, u.answer, u.card_type, u.content_format, u.direction)
+FROM cards u
+WHERE u.pack_id = @upstream_id::uuid AND u.deleted_at IS NULL
+  AND (sqlc.narg('card_ids')::uuid[] IS NULL OR u.id = ANY(sqlc.narg('card_ids')::uuid[]))
+  AND NOT EXISTS (
+        SELECT 1 FROM cards f
+        WHERE f.pack_id = @pack_id::uuid AND f.upstream_card_id = u.id
+      )
+ORDER BY u.created_at, u.id
+RETURNING *;
+
+-- name: CopyUpstreamMedia :exec
+INSERT INTO card_media (card_id, hash, position)
+SELECT f.id, cm.hash, cm.position
+FROM cards f
+JOIN card_media cm ON cm.card_id = f.upstream_card_id
+WHERE f.id = ANY(@card_ids::uuid[])
+ON CONFLICT DO NOTHING;
+
+-- name: ListUpstreamAdded :many
+SELECT u.*
+FROM cards u
+WHERE u.pack_id = @upstream_id::uuid AND u.deleted_at IS NULL
+  AND NOT EXISTS (
+        SELECT 1 FROM cards f
+        WHERE f.pack_id = @pack_id::uuid AND f.upstream_card_id = u.id
+      )
+ORDER BY u.created_at, u.id;
+
+-- name: ListUpstreamChanged :many
+-- Copies whose upstream content changed since the last sync and differs
+-- from the copy. Local edits alone do not count.
+SELECT f.*,
+       u.question AS upstream_question, u.answer AS upstream_answer,
+       u.card_type AS upstream_card_type, u.content_format AS upstream_content_format,
+       u.direction AS upstream_direction, u.updated_at AS upstream_updated_at
+FROM cards f
+JOIN cards u ON u.id = f.upstream_card_id
+WHERE f.pack_id = @pack_id::uuid AND f.deleted_at IS NULL
+  AND u.pack_id = @upstream_id::uuid AND u.deleted_at IS NULL
+  AND card_content_hash(u.question, u.answer, u.card_type, u.content_format, u.direction)
+      IS DISTINCT FROM f.upstream_hash
+  AND (u.question, u.answer, u.card_type, u.content_format, u.direction)
+      IS DISTINCT FROM (f.question, f.answer, f.card_type, f.content_format, f.direction)
+ORDER BY f.created_at, f.id;
+
+-- name: ListUpstreamRemoved :many
+-- Copies whose upstream card was deleted or moved out of the upstream pack.
+SELECT f.*
+FROM cards f
+LEFT JOIN cards u ON u.id = f.upstream_card_id
+WHERE f.pack_id = @pack_id::uuid AND f.deleted_at IS NULL
+  AND f.upstream_card_id IS NOT NULL
+  AND (u.id IS NULL OR u.deleted_at IS NOT NULL OR u.pack_id <> @upstream_id::uuid)
+ORDER BY f.created_at, f.id;
+
+-- name: MarkUpstreamSynced :exec
+UPDATE cards f
+SET upstream_synced_at = u.updated_at,
+    upstream_hash = card_content_hash(u.question, u.answer, u.card_type, u.content_format, u.direction)
+FROM cards u
+WHERE u.id = f.upstream_card_id AND f.id = ANY(@card_ids::uuid[]);
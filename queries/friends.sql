@@ -0,0 +1,33 @@
+-- name: RequestFriend :one
+-- No row when the two users are friends or one asked already.
+INSERT INTO friendships (user_id, friend_id)
+VALUES (@user_id, @friend_id)
+ON CONFLICT DO NOTHING
+RETURNING *;
+
+-- name: AcceptFriendRequest :one
+UPDATE friendships
+SET accepted_at = NOW()
+WHERE user_id = @requester_id
+  AND friend_id = @user_id
+  AND accepted_at IS NULL
+RETURNING *;
+
+-- name: DeleteFriendship :execrows
+-- Unfriends, withdraws a request or declines one.
+DELETE FROM friendships
+WHERE (user_id = @user_id AND friend_id = @other_id)
+   OR (user_id = @other_id AND friend_id = @user_id);
+
+-- name: ListFriends :many
+-- Friends first, then requests: "incoming" ones wait for the user to
+-- accept, "outgoing" ones for the other side.
+SELECT u.id AS user_id, u.username,
+       (CASE WHEN f.accepted_at IS NOT NULL THEN 'friend'
+             WHEN f.friend_id = @user_id THEN 'incoming'
+             ELSE 'outgoing' END)::text AS status,
+       f.accepted_at, f.created_at
+FROM friendships f
+JOIN users u ON u.id = CASE WHEN f.user_id = @user_id THEN f.friend_id ELSE f.user_id END
+WHERE f.user_id = @user_id OR f.friend_id = @user_id
+ORDER BY f.accepted_at IS NULL, u.username;
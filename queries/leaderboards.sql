@@ -0,0 +1,92 @@
+-- name: Leaderboard :many
+-- Live ranking by rating points. With @since the score is what was earned
+-- from then on, otherwise the rating itself; only users who earned points
+-- in the period are ranked. The events of the period are summed in one
+-- pass over idx_rating_events_created_at. @org_id limits the board to
+-- members of an organization, @friends_of to a user and their friends.
+-- Users who opted out are left off. Returns the top @page_limit plus the
+-- row of @user_id.
+WITH period AS (
+    SELECT e.user_id, SUM(e.delta) AS total
+    FROM rating_events e
+    WHERE e.created_at >= sqlc.narg('since')::timestamptz
+      AND e.created_at < sqlc.arg('until')::timestamptz
+    GROUP BY e.user_id
+), scores AS (
+    SELECT u.id AS user_id, u.username,
+           (CASE WHEN sqlc.narg('since')::timestamptz IS NULL THEN COALESCE(us.rating, 0)
+                 ELSE p.total END)::int AS score
+    FROM users u
+    LEFT JOIN user_stats us ON us.user_id = u.id
+    LEFT JOIN user_settings st ON st.user_id = u.id
+    LEFT JOIN period p ON p.user_id = u.id
+    WHERE NOT COALESCE(st.leaderboard_opt_out, FALSE)
+      AND (CASE WHEN sqlc.narg('since')::timestamptz IS NULL THEN us.user_id IS NOT NULL
+                ELSE p.user_id IS NOT NULL END)
+      AND (sqlc.narg('org_id')::uuid IS NULL OR EXISTS (
+            SELECT 1 FROM organization_members om
+            WHERE om.org_id = sqlc.narg('org_id')::uuid AND om.user_id = u.id
+              AND om.joined_at IS NOT NULL))
+      AND (sqlc.narg('friends_of')::uuid IS NULL OR u.id = sqlc.narg('friends_of')::uuid OR EXISTS (
+            SELECT 1 FROM friendships f
+            WHERE f.accepted_at IS NOT NULL
+              AND ((f.user_id = sqlc.narg('friends_of')::uuid AND f.friend_id = u.id)
+                OR (f.friend_id = sqlc.narg('friends_of')::uuid AND f.user_id = u.id))))
+), ranked AS (
+    SELECT user_id, username, score,
+           RANK() OVER (ORDER BY score DESC)::int AS rank,
+           ROW_NUMBER() OVER (ORDER BY score DESC, username) AS position,
+           COUNT(*) OVER ()::int AS entrants
+    FROM scores
+)
+SELECT user_id, username, score, rank, entrants
+FROM ranked
+WHERE position <= sqlc.arg('page_limit')::int OR user_id = sqlc.arg('user_id')::uuid
+ORDER BY position;
+
+-- name: SnapshotWeeklyLeaderboard :execrows
+-- Freezes the global ranking of the week (UTC) starting @week_start. A week
+-- is only ever snapshotted once, so its ranks stay put.
+INSERT INTO leaderboard_snapshots (week_start, user_id, score, rank)
+SELECT sqlc.arg('week_start')::date, e.user_id, SUM(e.delta)::int,
+       RANK() OVER (ORDER BY SUM(e.delta) DESC)::int
+FROM rating_events e
+LEFT JOIN user_settings st ON st.user_id = e.user_id
+WHERE e.created_at >= (sqlc.arg('week_start')::date)::timestamp AT TIME ZONE 'UTC'
+  AND e.created_at < (sqlc.arg('week_start')::date + 7)::timestamp AT TIME ZONE 'UTC'
+  AND NOT COALESCE(st.leaderboard_opt_out, FALSE)
+  AND NOT EXISTS (
+      SELECT 1 FROM leaderboard_snapshots s
+      WHERE s.week_start = sqlc.arg('week_start')::date)
+GROUP BY e.user_id
+ON CONFLICT DO NOTHING;
+
+-- name: WeeklyLeaderboardSnapshot :many
+-- A past week as it was snapshotted. The global board keeps the frozen
+-- ranks; organization and friends boards are ranked among their entrants.
+-- Users who opt out later disappear from old weeks too.
+WITH ranked AS (
+    SELECT s.user_id, u.username, s.score,
+           (CASE WHEN sqlc.narg('org_id')::uuid IS NULL AND sqlc.narg('friends_of')::uuid IS NULL THEN s.rank
+                 ELSE RANK() OVER (ORDER BY s.score DESC) END)::int AS rank,
+           ROW_NUMBER() OVER (ORDER BY s.score DESC, u.username) AS position,
+           COUNT(*) OVER ()::int AS entrants
+    FROM leaderboard_snapshots s
+    JOIN users u ON u.id = s.user_id
+    LEFT JOIN user_settings st ON st.user_id = s.user_id
+    WHERE s.week_start = sqlc.arg('week_start')::date
+      AND NOT COALESCE(st.leaderboard_opt_out, FALSE)
+      AND (sqlc.narg('org_id')::uuid IS NULL OR EXISTS (
+            SELECT 1 FROM organization_members om
+            WHERE om.org_id = sqlc.narg('org_id')::uuid AND om.user_id = s.user_id
+              AND om.joined_at IS NOT NULL))
+      AND (sqlc.narg('friends_of')::uuid IS NULL OR s.user_id = sqlc.narg('friends_of')::uuid OR EXISTS (
+            SELECT 1 FROM friendships f
+            WHERE f.accepted_at IS NOT NULL
+              AND ((f.user_id = sqlc.narg('friends_of')::uuid AND f.friend_id = s.user_id)
+                OR (f.friend_id = sqlc.narg('friends_of')::uuid AND f.user_id = s.user_id))))
+)
+SELECT user_id, username, score, rank, entrants
+FROM ranked
+WHERE position <= sqlc.arg('page_limit')::int OR user_id = sqlc.arg('user_id')::uuid
+ORDER BY position;
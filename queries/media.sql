@@ -0,0 +1,71 @@
+-- name: CreateMedia :one
+-- Uploading known content returns the existing row and restarts its grace
+-- period, so an orphan is not collected right after it was uploaded again.
+INSERT INTO media (hash, content_type, kind, size_bytes, uploaded_by)
+VALUES (@hash, @content_type, @kind, @size_bytes, @uploaded_by)
+ON CONFLICT (hash) DO UPDATE SET created_at = now()
+RETURNING *;
+
+-- name: GetMedia :one
+SELECT * FROM media WHERE hash = $1;
+
+-- name: LockMedia :exec
+-- Serializes the upload and the collection of one hash until the end of the
+-- transaction, so a blob is not deleted under a row that was just created.
+SELECT pg_advisory_xact_lock(hashtext(@hash::text));
+
+-- name: MediaVisible :one
+-- Media can be seen by the user who uploaded it and by everybody who can
+-- see a card it is attached to.
+SELECT (EXISTS (
+    SELECT 1 FROM media m
+    WHERE m.hash = @hash AND m.uploaded_by = @user_id::uuid
+) OR EXISTS (
+    SELECT 1 FROM card_media cm
+    JOIN cards c ON c.id = cm.card_id
+    WHERE cm.hash = @hash AND c.deleted_at IS NULL
+      AND pack_visible(c.pack_id, @user_id::uuid)
+))::bool AS visible;
+
+-- name: TouchMedia :execrows
+-- Restarts the grace period of media about to be attached and counts the
+-- rows that still exist. The row lock makes a concurrent DeleteOrphanMedia
+-- either finish first or skip these rows.
+UPDATE media SET created_at = now() WHERE hash = ANY(@hashes::text[]);
+
+-- name: ListCardMedia :many
+SELECT cm.card_id, m.hash, m.content_type, m.kind, m.size_bytes
+FROM card_media cm
+JOIN media m ON m.hash = cm.hash
+WHERE cm.card_id = ANY(@card_ids::uuid[])
+ORDER BY cm.card_id, cm.position;
+
+-- name: ReplaceCardMedia :exec
+-- Sets the attachments of a card to @hashes, in that order.
+WITH removed AS (
+    DELETE FROM card_media
+    WHERE card_id = @card_id AND hash <> ALL(@hashes::text[])
+)
+INSERT INTO card_media (card_id, hash, position)
+SELECT @card_id, t.hash, t.ord - 1
+FROM unnest(@hashes::text[]) WITH ORDINALITY AS t(hash, ord)
+ON CONFLICT (card_id, hash) DO UPDATE SET position = EXCLUDED.position;
+
+-- name: ListPackMedia :many
+SELECT DISTINCT m.*
+FROM media m
+JOIN card_media cm ON cm.hash = m.hash
+JOIN cards c ON c.id = cm.card_id
+WHERE c.pack_id = $1 AND c.deleted_at IS NULL;
+
+-- name: ListOrphanMedia :many
+-- Media no card refers to. Fresh uploads get a grace period to be attached.
+SELECT m.hash FROM media m
+WHERE m.created_at < @before::timestamptz
+  AND NOT EXISTS (SELECT 1 FROM card_media cm WHERE cm.hash = m.hash);
+
+-- name: DeleteOrphanMedium :execrows
+-- Deletes @hash if it is still an orphan, see ListOrphanMedia.
+DELETE FROM media m
+WHERE m.hash = @hash AND m.created_at < @before::timestamptz
+  AND NOT EXISTS (SELECT 1 FROM card_media cm WHERE cm.hash = m.hash);
@@ -0,0 +1,52 @@
+-- name: PackRole :one
+-- Empty when the user has no role in the pack.
+SELECT COALESCE(pack_role(@pack_id::uuid, @user_id::uuid), '')::text AS role;
+
+-- name: ListPackMembers :many
+-- The owner of the pack first, then accepted members, then invitations.
+SELECT * FROM (
+    SELECT u.id AS user_id, u.username, 'owner'::text AS role,
+           NULL::uuid AS invited_by, p.created_at AS invited_at,
+           p.created_at AS accepted_at
+    FROM packs p
+    JOIN users u ON u.id = p.owner_id
+    WHERE p.id = @pack_id
+    UNION ALL
+    SELECT m.user_id, u.username, m.role::text,
+           m.invited_by, m.created_at, m.accepted_at
+    FROM pack_members m
+    JOIN users u ON u.id = m.user_id
+    WHERE m.pack_id = @pack_id
+) members
+ORDER BY accepted_at ASC NULLS LAST, username;
+
+-- name: InvitePackMember :one
+-- No row when the user is already a member or invited.
+INSERT INTO pack_members (pack_id, user_id, role, invited_by)
+VALUES (@pack_id, @user_id, @role, @invited_by)
+ON CONFLICT (pack_id, user_id) DO NOTHING
+RETURNING *;
+
+-- name: AcceptPackInvitation :one
+UPDATE pack_members
+SET accepted_at = NOW()
+WHERE pack_id = @pack_id AND user_id = @user_id AND accepted_at IS NULL
+RETURNING *;
+
+-- name: SetPackMemberRole :one
+UPDATE pack_members
+SET role = @role
+WHERE pack_id = @pack_id AND user_id = @user_id
+RETURNING *;
+
+-- name: RemovePackMember :execrows
+DELETE FROM pack_members WHERE pack_id = @pack_id AND user_id = @user_id;
+
+-- name: ListInvitations :many
+SELECT m.pack_id, p.name AS pack_name, m.role, m.invited_by,
+       u.username AS invited_by_username, m.created_at
+FROM pack_members m
+JOIN packs p ON p.id = m.pack_id
+LEFT JOIN users u ON u.id = m.invited_by
+WHERE m.user_id = @user_id AND m.accepted_at IS NULL AND p.deleted_at IS NULL
+ORDER BY m.created_at DESC;
@@ -0,0 +1,151 @@
+-- name: CreateOrganization :one
+-- The creator becomes the first admin.
+WITH org AS (
+    INSERT INTO organizations (name, created_by)
+    VALUES (@name, @created_by::uuid)
+    RETURNING *
+), admin AS (
+    INSERT INTO organization_members (org_id, user_id, role)
+    SELECT org.id, org.created_by, 'admin' FROM org
+)
+SELECT * FROM org;
+
+-- name: ReadOrganization :one
+SELECT * FROM organizations WHERE id = @id;
+
+-- name: RenameOrganization :one
+UPDATE organizations SET name = @name WHERE id = @id RETURNING *;
+
+-- name: ListUserOrganizations :many
+SELECT o.id, o.name, om.role, om.joined_at,
+       (SELECT COUNT(*) FROM organization_members m
+        WHERE m.org_id = o.id AND m.joined_at IS NOT NULL)::bigint AS member_count
+FROM organizations o
+JOIN organization_members om ON om.org_id = o.id
+WHERE om.user_id = @user_id AND om.joined_at IS NOT NULL
+ORDER BY o.name;
+
+-- name: OrgRole :one
+-- Empty when the user is not a member of the organization, or has not
+-- accepted the invitation yet.
+SELECT COALESCE((
+    SELECT role FROM organization_members
+    WHERE org_id = @org_id AND user_id = @user_id AND joined_at IS NOT NULL
+), '')::text AS role;
+
+-- name: InviteOrgMember :one
+-- No row when the user is a member or invited already.
+INSERT INTO organization_members (org_id, user_id, role, invited_by, joined_at)
+VALUES (@org_id, @user_id, @role, @invited_by, NULL)
+ON CONFLICT (org_id, user_id) DO NOTHING
+RETURNING *;
+
+-- name: AcceptOrgInvitation :one
+UPDATE organization_members
+SET joined_at = NOW()
+WHERE org_id = @org_id AND user_id = @user_id AND joined_at IS NULL
+RETURNING *;
+
+-- name: ListOrgInvitations :many
+SELECT om.org_id, o.name AS org_name, om.role, om.invited_by,
+       u.username AS invited_by_username, om.created_at
+FROM organization_members om
+JOIN organizations o ON o.id = om.org_id
+LEFT JOIN users u ON u.id = om.invited_by
+WHERE om.user_id = @user_id AND om.joined_at IS NULL
+ORDER BY om.created_at DESC;
+
+-- name: ListOrgMembers :many
+-- Members who joined, with their rating and the packs they own and mastered.
+SELECT om.user_id, u.username, om.role, om.joined_at,
+       COALESCE(us.rating, 0)::int AS rating,
+       (SELECT COUNT(*) FROM packs p WHERE p.owner_id = om.user_id AND p.deleted_at IS NULL)::int AS packs_created,
+       (SELECT COUNT(*) FROM pack_masteries pm WHERE pm.user_id = om.user_id)::int AS packs_mastered
+FROM organization_members om
+JOIN users u ON u.id = om.user_id
+LEFT JOIN user_stats us ON us.user_id = om.user_id
+WHERE om.org_id = @org_id AND om.joined_at IS NOT NULL
+ORDER BY om.role = 'member', u.username;
+
+-- name: ListPendingOrgMembers :many
+-- Invitations not accepted yet, for admins.
+SELECT om.user_id, u.username, om.role, om.created_at
+FROM organization_members om
+JOIN users u ON u.id = om.user_id
+WHERE om.org_id = @org_id AND om.joined_at IS NULL
+ORDER BY u.username;
+
+-- name: SetOrgMemberRole :one
+-- An organization always keeps one admin: demoting the last one finds no row.
+UPDATE organization_members m
+SET role = @role
+WHERE m.org_id = @org_id AND m.user_id = @user_id
+  AND (@role::text = 'admin' OR m.role <> 'admin' OR EXISTS (
+        SELECT 1 FROM organization_members o
+        WHERE o.org_id = @org_id AND o.user_id <> @user_id AND o.role = 'admin'
+          AND o.joined_at IS NOT NULL
+      ))
+RETURNING *;
+
+-- name: RemoveOrgMember :execrows
+-- Like SetOrgMemberRole, the last admin cannot be removed. Also withdraws
+-- or declines an invitation.
+DELETE FROM organization_members m
+WHERE m.org_id = @org_id AND m.user_id = @user_id
+  AND (m.role <> 'admin' OR EXISTS (
+        SELECT 1 FROM organization_members o
+        WHERE o.org_id = @org_id AND o.user_id <> @user_id AND o.role = 'admin'
+          AND o.joined_at IS NOT NULL
+      ));
+
+-- name: AssignPack :many
+-- Assigns a pack to the given members, or to all of them when user_ids is
+-- NULL, and subscribes them to it. Assigning again moves the due date of
+-- this organization's assignment only.
+WITH assigned AS (
+    INSERT INTO pack_assignments (org_id, pack_id, user_id, assigned_by, due_at)
+    SELECT om.org_id, @pack_id::uuid, om.user_id, @assigned_by::uuid, sqlc.narg('due_at')::timestamptz
+    FROM organization_members om
+    WHERE om.org_id = @org_id::uuid AND om.joined_at IS NOT NULL
+      AND (sqlc.narg('user_ids')::uuid[] IS NULL OR om.user_id = ANY(sqlc.narg('user_ids')::uuid[]))
+    ON CONFLICT (org_id, pack_id, user_id) DO UPDATE
+    SET due_at = EXCLUDED.due_at, assigned_by = EXCLUDED.assigned_by
+    RETURNING *
+), subscribed AS (
+    INSERT INTO subscriptions (user_id, pack_id)
+    SELECT assigned.user_id, assigned.pack_id FROM assigned
+    ON CONFLICT (user_id, pack_id) DO NOTHING
+)
+SELECT * FROM assigned;
+
+-- name: DeleteAssignment :execrows
+DELETE FROM pack_assignments WHERE id = @id AND org_id = @org_id;
+
+-- name: ListAssignmentProgress :many
+-- Assignments of current members with how many of the cards of the pack
+-- the member has studied. Filtered by organization, member or both.
+SELECT a.id, a.org_id, o.name AS org_name, a.pack_id, p.name AS pack_name,
+       a.user_id, u.username, a.due_at, a.created_at,
+       cc.card_count::bigint AS card_count,
+       st.studied_count::bigint AS studied_count,
+       st.last_studied_at::timestamptz AS last_studied_at
+FROM pack_assignments a
+JOIN organizations o ON o.id = a.org_id
+JOIN organization_members om ON om.org_id = a.org_id AND om.user_id = a.user_id AND om.joined_at IS NOT NULL
+JOIN packs p ON p.id = a.pack_id AND p.deleted_at IS NULL
+JOIN users u ON u.id = a.user_id
+CROSS JOIN LATERAL (
+    SELECT COUNT(*) AS card_count
+    FROM cards c
+    WHERE c.pack_id = a.pack_id AND c.deleted_at IS NULL
+) cc
+CROSS JOIN LATERAL (
+    SELECT COUNT(DISTINCT cp.card_id) AS studied_count,
+           MAX(cp.last_reviewed_at) AS last_studied_at
+    FROM card_progress cp
+    JOIN cards c ON c.id = cp.card_id
+    WHERE cp.user_id = a.user_id AND c.pack_id = a.pack_id AND c.deleted_at IS NULL
+) st
+WHERE (sqlc.narg('org_id')::uuid IS NULL OR a.org_id = sqlc.narg('org_id')::uuid)
+  AND (sqlc.narg('user_id')::uuid IS NULL OR a.user_id = sqlc.narg('user_id')::uuid)
+ORDER BY u.username, a.due_at ASC NULLS LAST, p.name;
@@ -1,22 +1,125 @@
 -- name: CreatePack :one
-INSERT INTO packs (name, category)
-VALUES ($1, $2)
+-- A pack has either owner_id or org_id.
+INSERT INTO packs (name, category_id, owner_id, org_id, direction, description,
+                   source_lang, target_lang, cover_color, cover_icon, visibility)
+VALUES (@name, @category_id, sqlc.narg('owner_id'), sqlc.narg('org_id'), @direction, @description,
+        @source_lang, @target_lang, @cover_color, @cover_icon, @visibility)
 RETURNING *;
 
 -- name: ReadPack :one
-SELECT * FROM packs WHERE id = $1;
+SELECT * FROM packs WHERE id = $1 AND deleted_at IS NULL;
 
 -- name: UpdatePack :one
 UPDATE packs
-SET name = $1, category = $2
-WHERE id = $3
+SET name = @name, category_id = @category_id, description = @description,
+    source_lang = @source_lang, target_lang = @target_lang,
+    cover_color = @cover_color, cover_icon = @cover_icon, visibility = @visibility
+WHERE id = @id AND pack_role(id, @user_id::uuid) = 'owner'
 RETURNING *;
 
 -- name: ListPacks :many
-SELECT id, name, category, created_at, updated_at
-FROM packs
-ORDER BY created_at DESC;
+-- Keyset-paginated listing: the cursor_* arguments hold the sort value and id
+-- of the last row of the previous page, only the one matching sort_by is used.
+-- A category filter matches the category itself and all of its descendants.
+WITH RECURSIVE category_tree AS (
+    SELECT categories.id FROM categories WHERE categories.id = sqlc.narg('category_id')::uuid
+    UNION ALL
+    SELECT ch.id FROM categories ch JOIN category_tree t ON ch.parent_id = t.id
+)
+SELECT p.id, p.name, cat.name AS category, p.category_id, p.owner_id,
+       p.description, p.source_lang, p.target_lang, p.cover_color, p.cover_icon,
+       p.visibility, p.forked_from, p.org_id,
+       p.created_at, p.updated_at, d.due_count::bigint AS due_count,
+       COALESCE(pack_role(p.id, @user_id::uuid), '')::text AS role
+FROM packs p
+LEFT JOIN categories cat ON cat.id = p.category_id
+CROSS JOIN LATERAL (
+    SELECT COUNT(DISTINCT c.id) AS due_count
+    FROM cards c
+    JOIN card_progress cp ON cp.card_id = c.id
+    WHERE c.pack_id = p.id
+      AND c.deleted_at IS NULL
+      AND cp.user_id = @user_id::uuid
+      AND cp.due_at <= NOW()
+) d
+WHERE p.deleted_at IS NULL
+  AND pack_listed(p.id, @user_id::uuid)
+  AND (sqlc.narg('visibility')::text IS NULL OR p.visibility = sqlc.narg('visibility')::text)
+  AND (sqlc.narg('source_lang')::text IS NULL OR lower(p.source_lang) = lower(sqlc.narg('source_lang')::text))
+  AND (sqlc.narg('target_lang')::text IS NULL OR lower(p.target_lang) = lower(sqlc.narg('target_lang')::text))
+  AND (sqlc.narg('category_id')::uuid IS NULL OR p.category_id IN (SELECT category_tree.id FROM category_tree))
+  AND (sqlc.narg('owner_id')::uuid IS NULL OR p.owner_id = sqlc.narg('owner_id')::uuid)
+  AND (sqlc.narg('org_id')::uuid IS NULL OR p.org_id = sqlc.narg('org_id')::uuid)
+  AND (sqlc.narg('subscribed')::bool IS NULL OR EXISTS (
+        SELECT 1 FROM subscriptions s
+        WHERE s.pack_id = p.id AND s.user_id = @user_id::uuid
+      ) = sqlc.narg('subscribed')::bool)
+  AND (sqlc.narg('cursor_id')::uuid IS NULL OR CASE
+        WHEN @sort_by::text = 'name' AND @sort_desc::bool
+            THEN (p.name, p.id) < (sqlc.narg('cursor_name')::text, sqlc.narg('cursor_id')::uuid)
+        WHEN @sort_by::text = 'name'
+            THEN (p.name, p.id) > (sqlc.narg('cursor_name')::text, sqlc.narg('cursor_id')::uuid)
+        WHEN @sort_by::text = 'updated_at' AND @sort_desc::bool
+            THEN (p.updated_at, p.id) < (sqlc.narg('cursor_time')::timestamptz, sqlc.narg('cursor_id')::uuid)
+        WHEN @sort_by::text = 'updated_at'
+            THEN (p.updated_at, p.id) > (sqlc.narg('cursor_time')::timestamptz, sqlc.narg('cursor_id')::uuid)
+        WHEN @sort_by::text = 'due_count' AND @sort_desc::bool
+            THEN (d.due_count, p.id) < (sqlc.narg('cursor_count')::bigint, sqlc.narg('cursor_id')::uuid)
+        WHEN @sort_by::text = 'due_count'
+            THEN (d.due_count, p.id) > (sqlc.narg('cursor_count')::bigint, sqlc.narg('cursor_id')::uuid)
+        WHEN @sort_desc::bool
+            THEN (p.created_at, p.id) < (sqlc.narg('cursor_time')::timestamptz, sqlc.narg('cursor_id')::uuid)
+        ELSE (p.created_at, p.id) > (sqlc.narg('cursor_time')::timestamptz, sqlc.narg('cursor_id')::uuid)
+      END)
+ORDER BY
+  CASE WHEN @sort_by::text = 'name'       AND NOT @sort_desc::bool THEN p.name END ASC,
+  CASE WHEN @sort_by::text = 'name'       AND @sort_desc::bool     THEN p.name END DESC,
+  CASE WHEN @sort_by::text = 'updated_at' AND NOT @sort_desc::bool THEN p.updated_at END ASC,
+  CASE WHEN @sort_by::text = 'updated_at' AND @sort_desc::bool     THEN p.updated_at END DESC,
+  CASE WHEN @sort_by::text = 'due_count'  AND NOT @sort_desc::bool THEN d.due_count END ASC,
+  CASE WHEN @sort_by::text = 'due_count'  AND @sort_desc::bool     THEN d.due_count END DESC,
+  -- ties are broken by id alone, as in the cursor
+  CASE WHEN @sort_by::text NOT IN ('name', 'updated_at', 'due_count') AND NOT @sort_desc::bool THEN p.created_at END ASC,
+  CASE WHEN @sort_by::text NOT IN ('name', 'updated_at', 'due_count') AND @sort_desc::bool     THEN p.created_at END DESC,
+  CASE WHEN NOT @sort_desc::bool THEN p.id END ASC,
+  CASE WHEN @sort_desc::bool     THEN p.id END DESC
+LIMIT @page_limit;
 
--- name: DeletePack :exec
-DELETE FROM packs WHERE id = $1;
+-- name: SoftDeletePack :execrows
+-- Only owners can delete a pack; legacy packs without one by anybody.
+UPDATE packs
+SET deleted_at = NOW(), deleted_by = @user_id
+WHERE id = @id AND pack_role(id, @user_id::uuid) = 'owner';
+
+-- name: RestorePack :one
+UPDATE packs
+SET deleted_at = NULL, deleted_by = NULL
+WHERE id = @id AND deleted_at IS NOT NULL
+  AND (owner_id = @user_id OR (owner_id IS NULL AND org_id IS NULL AND deleted_by = @user_id)
+       OR EXISTS (
+            SELECT 1 FROM pack_members m
+            WHERE m.pack_id = packs.id AND m.user_id = @user_id
+              AND m.role = 'owner' AND m.accepted_at IS NOT NULL
+          )
+       OR EXISTS (
+            SELECT 1 FROM organization_members om
+            WHERE om.org_id = packs.org_id AND om.user_id = @user_id AND om.role = 'admin'
+              AND om.joined_at IS NOT NULL
+          ))
+RETURNING *;
+
+-- name: PurgeDeletedPacks :execrows
+DELETE FROM packs WHERE deleted_at < @before::timestamptz;
+
+
+-- name: SetPackDirection :execrows
+UPDATE packs
+SET direction = @direction
+WHERE id = @id AND pack_role(id, @owner_id::uuid) = 'owner';
+
+-- name: PackVisible :one
+SELECT pack_visible(@pack_id::uuid, @user_id::uuid)::bool AS visible;
 
+-- name: PackEditable :one
+-- Owners and editors can change the cards of a pack.
+SELECT COALESCE(pack_role(@pack_id::uuid, @user_id::uuid) IN ('owner', 'editor'), false)::bool AS editable;
@@ -0,0 +1,77 @@
+-- name: GetCardProgress :one
+-- Progress columns are NULL when the user has never reviewed the card.
+SELECT c.id AS card_id, c.pack_id,
+       cp.reps, cp.lapses, cp.interval_days, cp.ease, cp.due_at
+FROM cards c
+LEFT JOIN card_progress cp
+       ON cp.card_id = c.id AND cp.user_id = @user_id::uuid AND cp.direction = @direction::text
+WHERE c.id = @card_id AND c.deleted_at IS NULL AND pack_visible(c.pack_id, @user_id::uuid);
+
+-- name: UpsertCardProgress :exec
+INSERT INTO card_progress (user_id, card_id, direction, reps, lapses, interval_days, ease, due_at, last_reviewed_at)
+VALUES (@user_id, @card_id, @direction, @reps, @lapses, @interval_days, @ease, @due_at, @reviewed_at)
+ON CONFLICT (user_id, card_id, direction) DO UPDATE
+SET reps = EXCLUDED.reps,
+    lapses = EXCLUDED.lapses,
+    interval_days = EXCLUDED.interval_days,
+    ease = EXCLUDED.ease,
+    due_at = EXCLUDED.due_at,
+    last_reviewed_at = EXCLUDED.last_reviewed_at;
+
+-- name: CreateReview :exec
+INSERT INTO reviews (user_id, card_id, pack_id, direction, correct, new_card, interval_days, ease, duration_ms, reviewed_at)
+VALUES (@user_id, @card_id, @pack_id, @direction, @correct, @new_card, @interval_days, @ease, @duration_ms, @reviewed_at);
+
+-- name: CountReviewsSince :one
+SELECT COUNT(*) FILTER (WHERE new_card)     AS new_done,
+       COUNT(*) FILTER (WHERE NOT new_card) AS reviews_done
+FROM reviews
+WHERE user_id = @user_id AND reviewed_at >= @since::timestamptz;
+
+-- name: ListDueReviewCards :many
+-- Cards the user has already studied whose due time has come. Progress of
+-- prompts the card no longer has (direction changed, cloze removed) is
+-- skipped.
+SELECT c.id, c.pack_id, c.question, c.answer, c.rating,
+       (cp.reps = 0 AND cp.lapses > 0)::bool AS last_wrong,
+       c.card_type, c.content_format, cp.direction, cp.due_at, cp.interval_days
+FROM card_progress cp
+JOIN cards c ON c.id = cp.card_id
+JOIN packs p ON p.id = c.pack_id
+WHERE cp.user_id = @user_id::uuid
+  AND cp.due_at <= @now::timestamptz
+  AND c.deleted_at IS NULL
+  AND cp.direction = ANY(card_prompts(c.card_type, c.question, c.direction, p.direction))
+  AND pack_visible(c.pack_id, @user_id::uuid)
+ORDER BY cp.due_at, c.id
+LIMIT @card_limit;
+
+-- name: ListNewQueueCards :many
+-- Unseen card directions from packs the user has a role in or subscribes to.
+-- Legacy packs without an owner give everybody a role and count only when
+-- subscribed.
+-- Ranking by the position inside each pack spreads the limit evenly across
+-- packs; reverse prompts of a card rank after all forward ones.
+SELECT q.id, q.pack_id, q.question, q.answer, q.rating, q.last_wrong, q.card_type, q.content_format, q.direction
+FROM (
+    SELECT c.id, c.pack_id, c.question, c.answer, c.rating, FALSE AS last_wrong, c.card_type, c.content_format,
+           d.direction::text AS direction,
+           ROW_NUMBER() OVER (
+               PARTITION BY c.pack_id
+               ORDER BY d.direction = 'reverse', c.created_at, c.id
+           ) AS pos
+    FROM cards c
+    JOIN packs p ON p.id = c.pack_id
+    CROSS JOIN LATERAL unnest(card_prompts(c.card_type, c.question, c.direction, p.direction)) AS d(direction)
+    WHERE c.deleted_at IS NULL AND p.deleted_at IS NULL
+      AND ((COALESCE(p.owner_id, p.org_id) IS NOT NULL AND pack_role(p.id, @user_id::uuid) IS NOT NULL) OR EXISTS (
+            SELECT 1 FROM subscriptions s
+            WHERE s.pack_id = p.id AND s.user_id = @user_id::uuid
+          ))
+      AND NOT EXISTS (
+            SELECT 1 FROM card_progress cp
+            WHERE cp.card_id = c.id AND cp.user_id = @user_id::uuid AND cp.direction = d.direction
+          )
+) q
+ORDER BY q.pos, q.pack_id
+LIMIT @card_limit;
@@ -0,0 +1,66 @@
+-- name: CreateCardRevision :exec
+INSERT INTO card_revisions (card_id, pack_id, action, before, after, changed_by)
+VALUES (@card_id, @pack_id, @action, @before, @after, @changed_by);
+
+-- name: ListCardRevisions :many
+SELECT r.id, r.action, r.before, r.after, r.changed_by, u.username, r.created_at
+FROM card_revisions r
+LEFT JOIN users u ON u.id = r.changed_by
+WHERE r.card_id = @card_id
+ORDER BY r.created_at DESC, r.id DESC;
+
+-- name: GetCardRevision :one
+SELECT * FROM card_revisions WHERE id = @id AND card_id = @card_id;
+
+-- name: ListTrashPacks :many
+-- Packs the user deleted or owns that are in the trash.
+SELECT p.id, p.name, p.deleted_at
+FROM packs p
+WHERE p.deleted_at IS NOT NULL
+  AND (p.owner_id = @user_id OR (p.owner_id IS NULL AND p.org_id IS NULL AND p.deleted_by = @user_id)
+       OR EXISTS (
+            SELECT 1 FROM pack_members m
+            WHERE m.pack_id = p.id AND m.user_id = @user_id
+              AND m.role = 'owner' AND m.accepted_at IS NOT NULL
+          )
+       OR EXISTS (
+            SELECT 1 FROM organization_members om
+            WHERE om.org_id = p.org_id AND om.user_id = @user_id AND om.role = 'admin'
+              AND om.joined_at IS NOT NULL
+          ))
+ORDER BY p.deleted_at DESC;
+
+-- name: ListTrashCards :many
+-- Cards in the trash from packs the user can still edit.
+SELECT c.id, c.pack_id, p.name AS pack_name, c.question, c.card_type, c.deleted_at
+FROM cards c
+JOIN packs p ON p.id = c.pack_id
+WHERE c.deleted_at IS NOT NULL AND p.deleted_at IS NULL
+  AND (p.owner_id = @user_id OR (p.owner_id IS NULL AND p.org_id IS NULL AND c.deleted_by = @user_id)
+       OR EXISTS (
+            SELECT 1 FROM pack_members m
+            WHERE m.pack_id = p.id AND m.user_id = @user_id
+              AND m.role IN ('owner', 'editor') AND m.accepted_at IS NOT NULL
+          )
+       OR EXISTS (
+            SELECT 1 FROM organization_members om
+            WHERE om.org_id = p.org_id AND om.user_id = @user_id AND om.role = 'admin'
+              AND om.joined_at IS NOT NULL
+          ))
+ORDER BY c.deleted_at DESC;
+
+-- name: ListPackRevisions :many
+-- Audit log of a pack, newest first and keyset-paginated on created_at.
+-- The question is taken from the card as it was after the change, or
+-- before it for a deletion.
+SELECT r.id, r.card_id, r.action, r.changed_by, u.username,
+       COALESCE(COALESCE(r.after, r.before)->>'question', '')::text AS question,
+       r.created_at
+FROM card_revisions r
+LEFT JOIN users u ON u.id = r.changed_by
+WHERE r.pack_id = @pack_id
+  AND (sqlc.narg('changed_by')::uuid IS NULL OR r.changed_by = sqlc.narg('changed_by')::uuid)
+  AND (sqlc.narg('cursor_id')::uuid IS NULL
+       OR (r.created_at, r.id) < (sqlc.narg('cursor_time')::timestamptz, sqlc.narg('cursor_id')::uuid))
+ORDER BY r.created_at DESC, r.id DESC
+LIMIT @page_limit;
@@ -0,0 +1,38 @@
+-- Search queries rebuild the exact tsvector expressions of idx_cards_search
+-- and idx_packs_search so the planner can use the GIN indexes.
+-- Highlights are delimited with U+E000/U+E001 (chr(57344)/chr(57345)) and
+-- turned into <mark> tags after HTML-escaping on the Go side. Results are
+-- limited to listed packs, so unlisted packs of others stay out.
+
+-- name: SearchCards :many
+SELECT c.id, c.pack_id, p.name AS pack_name,
+       ts_headline('russian', c.question, websearch_to_tsquery('russian', @query::text),
+                   'StartSel=' || chr(57344) || ', StopSel=' || chr(57345) || ', HighlightAll=TRUE')::text AS question_snippet,
+       ts_headline('russian', c.answer, websearch_to_tsquery('russian', @query::text),
+                   'StartSel=' || chr(57344) || ', StopSel=' || chr(57345) ||
+                   ', MaxWords=25, MinWords=8, MaxFragments=2')::text AS answer_snippet,
+       ts_rank(setweight(to_tsvector('russian', c.question), 'A') ||
+               setweight(to_tsvector('russian', c.answer),   'B'),
+               websearch_to_tsquery('russian', @query::text))::real AS rank
+FROM cards c
+JOIN packs p ON p.id = c.pack_id
+WHERE (setweight(to_tsvector('russian', c.question), 'A') ||
+       setweight(to_tsvector('russian', c.answer),   'B'))
+      @@ websearch_to_tsquery('russian', @query::text)
+  AND c.deleted_at IS NULL
+  AND pack_listed(p.id, @user_id::uuid)
+ORDER BY rank DESC, c.id
+LIMIT @result_limit;
+
+-- name: SearchPacks :many
+SELECT p.id, p.name, cat.name AS category,
+       ts_headline('russian', p.name, websearch_to_tsquery('russian', @query::text),
+                   'StartSel=' || chr(57344) || ', StopSel=' || chr(57345) || ', HighlightAll=TRUE')::text AS name_snippet,
+       ts_rank(to_tsvector('russian', p.name),
+               websearch_to_tsquery('russian', @query::text))::real AS rank
+FROM packs p
+LEFT JOIN categories cat ON cat.id = p.category_id
+WHERE to_tsvector('russian', p.name) @@ websearch_to_tsquery('russian', @query::text)
+  AND pack_listed(p.id, @user_id::uuid)
+ORDER BY rank DESC, p.id
+LIMIT @result_limit;
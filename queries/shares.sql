@@ -0,0 +1,49 @@
+-- name: CreateShareLink :one
+INSERT INTO pack_share_links (pack_id, created_by, expires_at, max_uses)
+VALUES (@pack_id, @created_by, @expires_at, @max_uses)
+RETURNING *;
+
+-- name: GetActiveShareLink :one
+SELECT * FROM pack_share_links
+WHERE id = @id
+  AND revoked_at IS NULL
+  AND (expires_at IS NULL OR expires_at > NOW())
+  AND (max_uses IS NULL OR use_count < max_uses);
+
+-- name: UseShareLink :one
+-- Takes one use of a link; no row when it is no longer valid.
+UPDATE pack_share_links
+SET use_count = use_count + 1
+WHERE id = @id
+  AND revoked_at IS NULL
+  AND (expires_at IS NULL OR expires_at > NOW())
+  AND (max_uses IS NULL OR use_count < max_uses)
+RETURNING *;
+
+-- name: ListActiveShareLinks :many
+SELECT * FROM pack_share_links
+WHERE pack_id = @pack_id
+  AND revoked_at IS NULL
+  AND (expires_at IS NULL OR expires_at > NOW())
+  AND (max_uses IS NULL OR use_count < max_uses)
+ORDER BY created_at DESC;
+
+-- name: RevokeShareLink :execrows
+UPDATE pack_share_links
+SET revoked_at = NOW()
+WHERE id = @id AND pack_id = @pack_id AND revoked_at IS NULL;
+
+-- name: PreviewPackCards :many
+SELECT * FROM cards
+WHERE pack_id = @pack_id AND deleted_at IS NULL
+ORDER BY created_at, id
+LIMIT @card_limit;
+
+-- name: CountPackCards :one
+SELECT COUNT(*) FROM cards WHERE pack_id = @pack_id AND deleted_at IS NULL;
+
+-- name: RefundShareLink :execrows
+-- Gives back a use taken by UseShareLink when the redemption failed.
+UPDATE pack_share_links
+SET use_count = use_count - 1
+WHERE id = @id AND use_count > 0;
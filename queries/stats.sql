@@ -0,0 +1,140 @@
+-- name: ReviewsPerDay :many
+-- One row per study day from @from_day to @to_day, days without reviews
+-- included. A study day starts at @rollover_hour in time zone @tz. Recalls
+-- are reviews of cards that were not new.
+WITH r AS (
+    SELECT ((reviews.reviewed_at AT TIME ZONE sqlc.arg('tz')::text)
+            - make_interval(hours => sqlc.arg('rollover_hour')::int))::date AS day,
+           reviews.correct, reviews.new_card, reviews.duration_ms
+    FROM reviews
+    WHERE reviews.user_id = sqlc.arg('user_id')::uuid
+      AND reviews.reviewed_at >= ((sqlc.arg('from_day')::date)::timestamp
+            + make_interval(hours => sqlc.arg('rollover_hour')::int)) AT TIME ZONE sqlc.arg('tz')::text
+      AND reviews.reviewed_at < ((sqlc.arg('to_day')::date + 1)::timestamp
+            + make_interval(hours => sqlc.arg('rollover_hour')::int)) AT TIME ZONE sqlc.arg('tz')::text
+)
+SELECT d.day::date AS day,
+       COUNT(r.day)::bigint AS reviews,
+       COUNT(r.day) FILTER (WHERE r.correct)::bigint AS correct,
+       COUNT(r.day) FILTER (WHERE r.new_card)::bigint AS new_cards,
+       COUNT(r.day) FILTER (WHERE NOT r.new_card)::bigint AS recalls,
+       COUNT(r.day) FILTER (WHERE NOT r.new_card AND r.correct)::bigint AS recalls_correct,
+       COALESCE(SUM(r.duration_ms), 0)::bigint AS time_ms
+FROM generate_series((sqlc.arg('from_day')::date)::timestamp, (sqlc.arg('to_day')::date)::timestamp, interval '1 day') AS d(day)
+LEFT JOIN r ON r.day = d.day::date
+GROUP BY d.day
+ORDER BY d.day;
+
+-- name: CardMaturityByPack :many
+-- Cards of the packs the user owns, subscribes to or has studied, by the
+-- longest interval of their prompts: new (never studied), young (under
+-- @mature_days) and mature.
+SELECT p.id AS pack_id, p.name,
+       COUNT(*) FILTER (WHERE m.max_interval IS NULL)::bigint AS new_cards,
+       COUNT(*) FILTER (WHERE m.max_interval < sqlc.arg('mature_days')::int)::bigint AS young_cards,
+       COUNT(*) FILTER (WHERE m.max_interval >= sqlc.arg('mature_days')::int)::bigint AS mature_cards
+FROM packs p
+JOIN cards c ON c.pack_id = p.id AND c.deleted_at IS NULL
+CROSS JOIN LATERAL (
+    SELECT MAX(cp.interval_days) AS max_interval
+    FROM card_progress cp
+    WHERE cp.card_id = c.id AND cp.user_id = sqlc.arg('user_id')::uuid
+) m
+WHERE p.deleted_at IS NULL
+  AND (p.owner_id = sqlc.arg('user_id')::uuid
+       OR EXISTS (
+            SELECT 1 FROM subscriptions s
+            WHERE s.pack_id = p.id AND s.user_id = sqlc.arg('user_id')::uuid
+          )
+       OR EXISTS (
+            SELECT 1 FROM card_progress cp
+            JOIN cards pc ON pc.id = cp.card_id
+            WHERE pc.pack_id = p.id AND cp.user_id = sqlc.arg('user_id')::uuid
+          ))
+GROUP BY p.id, p.name
+ORDER BY p.name, p.id;
+
+-- name: ProgressSummary :one
+-- Current state of the cards the user has studied.
+SELECT COUNT(*)::bigint AS studied,
+       COALESCE(AVG(cp.interval_days), 0)::float8 AS average_interval,
+       COUNT(*) FILTER (WHERE cp.due_at <= NOW())::bigint AS due_now
+FROM card_progress cp
+JOIN cards c ON c.id = cp.card_id AND c.deleted_at IS NULL
+JOIN packs p ON p.id = c.pack_id AND p.deleted_at IS NULL
+WHERE cp.user_id = sqlc.arg('user_id')::uuid;
+
+-- name: DueForecast :many
+-- Prompts falling due on each of the next @days study days (see
+-- ReviewsPerDay); overdue ones are counted for today.
+WITH due AS (
+    SELECT ((GREATEST(cp.due_at, NOW()) AT TIME ZONE sqlc.arg('tz')::text)
+            - make_interval(hours => sqlc.arg('rollover_hour')::int))::date AS day
+    FROM card_progress cp
+    JOIN cards c ON c.id = cp.card_id AND c.deleted_at IS NULL
+    JOIN packs p ON p.id = c.pack_id AND p.deleted_at IS NULL
+    WHERE cp.user_id = sqlc.arg('user_id')::uuid
+)
+SELECT d.day::date AS day, COUNT(due.day)::bigint AS due
+FROM generate_series(((NOW() AT TIME ZONE sqlc.arg('tz')::text)
+                      - make_interval(hours => sqlc.arg('rollover_hour')::int))::date::timestamp,
+                     (((NOW() AT TIME ZONE sqlc.arg('tz')::text)
+                      - make_interval(hours => sqlc.arg('rollover_hour')::int))::date + sqlc.arg('days')::int - 1)::timestamp,
+                     interval '1 day') AS d(day)
+LEFT JOIN due ON due.day = d.day::date
+GROUP BY d.day
+ORDER BY d.day;
+
+-- name: ExportReviews :many
+-- The review log of the user in keyset pages, oldest first.
+SELECT r.id, r.reviewed_at, r.card_id, r.pack_id,
+       COALESCE(p.name, '')::text AS pack_name,
+       COALESCE(c.question, '')::text AS question,
+       r.direction, r.correct, r.new_card, r.interval_days, r.ease, r.duration_ms
+FROM reviews r
+LEFT JOIN cards c ON c.id = r.card_id
+LEFT JOIN packs p ON p.id = r.pack_id
+WHERE r.user_id = @user_id
+  AND (sqlc.narg('cursor_time')::timestamptz IS NULL
+       OR (r.reviewed_at, r.id) > (sqlc.narg('cursor_time')::timestamptz, sqlc.narg('cursor_id')::uuid))
+ORDER BY r.reviewed_at, r.id
+LIMIT @page_limit;
+
+-- name: PackCardAnalytics :many
+-- How the active cards of a pack fare across everybody studying it, the
+-- most failed first. Lapses are wrong answers to cards that had been
+-- learned, summed over users; max_lapses is the worst single user.
+SELECT c.id AS card_id, c.question, c.card_type,
+       r.attempts, r.failures,
+       COALESCE(r.avg_duration_ms, 0)::float8 AS avg_duration_ms,
+       r.learners,
+       COALESCE(cp.lapses, 0)::bigint AS lapses,
+       COALESCE(cp.max_lapses, 0)::int AS max_lapses
+FROM cards c
+CROSS JOIN LATERAL (
+    SELECT COUNT(*)::bigint AS attempts,
+           COUNT(*) FILTER (WHERE NOT rv.correct)::bigint AS failures,
+           AVG(rv.duration_ms)::float8 AS avg_duration_ms,
+           COUNT(DISTINCT rv.user_id)::bigint AS learners
+    FROM reviews rv
+    WHERE rv.card_id = c.id
+) r
+CROSS JOIN LATERAL (
+    SELECT SUM(p.lapses) AS lapses, MAX(p.lapses) AS max_lapses
+    FROM card_progress p
+    WHERE p.card_id = c.id
+) cp
+WHERE c.pack_id = @pack_id AND c.deleted_at IS NULL
+ORDER BY r.failures::float8 / NULLIF(r.attempts, 0) DESC NULLS LAST, r.failures DESC, c.created_at, c.id;
+
+-- name: ListStreakDays :many
+SELECT * FROM streak_days
+WHERE user_id = @user_id AND day >= @from_day::date
+ORDER BY day;
+
+-- name: SettleStreakDays :exec
+-- A day is settled once, a concurrent request settling it again is ignored.
+INSERT INTO streak_days (user_id, day, goal_type, goal, met, frozen)
+SELECT @user_id, d.day, @goal_type, @goal, (@met::bool[])[d.ord], (@frozen::bool[])[d.ord]
+FROM unnest(@days::date[]) WITH ORDINALITY AS d(day, ord)
+ON CONFLICT (user_id, day) DO NOTHING;
@@ -0,0 +1,51 @@
+-- name: CreateStudySession :one
+INSERT INTO study_sessions (user_id, pack_id, source, tags, queue, total_cards, expires_at)
+VALUES (@user_id, @pack_id, @source, @tags::text[], @queue::text[], cardinality(@queue::text[]), @expires_at)
+RETURNING *;
+
+-- name: GetStudySession :one
+SELECT * FROM study_sessions
+WHERE id = @id AND user_id = @user_id;
+
+-- name: FindActiveStudySession :one
+-- An unexpired active session started from the same source, for resuming.
+SELECT * FROM study_sessions
+WHERE user_id = @user_id
+  AND status = 'active'
+  AND expires_at > NOW()
+  AND source = @source
+  AND pack_id IS NOT DISTINCT FROM sqlc.narg('pack_id')::uuid
+  AND tags = @tags::text[]
+ORDER BY created_at DESC
+LIMIT 1;
+
+-- name: ListActiveStudySessions :many
+SELECT * FROM study_sessions
+WHERE user_id = @user_id AND status = 'active' AND expires_at > NOW()
+ORDER BY updated_at DESC;
+
+-- name: AdvanceStudySession :execrows
+-- Optimistic update: fails when another request already moved the queue.
+UPDATE study_sessions
+SET queue = @new_queue::text[],
+    correct_count = correct_count + @correct_inc::int,
+    wrong_count   = wrong_count   + @wrong_inc::int,
+    expires_at = @expires_at
+WHERE id = @id
+  AND status = 'active'
+  AND queue = @old_queue::text[];
+
+-- name: CreateStudySessionAnswer :exec
+INSERT INTO study_session_answers (session_id, card_id, direction, correct, duration_ms)
+VALUES ($1, $2, $3, $4, $5);
+
+-- name: CompleteStudySession :one
+UPDATE study_sessions
+SET status = 'completed', completed_at = NOW()
+WHERE id = @id AND user_id = @user_id AND status = 'active'
+RETURNING *;
+
+-- name: ExpireStudySessions :execrows
+UPDATE study_sessions
+SET status = 'expired'
+WHERE status = 'active' AND expires_at <= NOW();
@@ -0,0 +1,96 @@
+-- name: CreateTag :one
+INSERT INTO tags (owner_id, name)
+VALUES ($1, $2)
+RETURNING *;
+
+-- name: ListTags :many
+SELECT t.id, t.name, t.created_at, COUNT(c.id) AS card_count
+FROM tags t
+LEFT JOIN card_tags ct ON ct.tag_id = t.id
+LEFT JOIN cards c ON c.id = ct.card_id AND c.deleted_at IS NULL
+WHERE t.owner_id = $1
+GROUP BY t.id
+ORDER BY lower(t.name);
+
+-- name: RenameTag :one
+UPDATE tags
+SET name = @name
+WHERE id = @id AND owner_id = @owner_id
+RETURNING *;
+
+-- name: DeleteTag :execrows
+DELETE FROM tags WHERE id = $1 AND owner_id = $2;
+
+-- name: TagCards :execrows
+-- Only the caller's tags and cards from packs they can see are linked.
+INSERT INTO card_tags (card_id, tag_id)
+SELECT c.id, t.id
+FROM cards c
+CROSS JOIN tags t
+WHERE c.id = ANY(@card_ids::uuid[])
+  AND t.id = ANY(@tag_ids::uuid[])
+  AND t.owner_id = @user_id::uuid
+  AND c.deleted_at IS NULL
+  AND pack_visible(c.pack_id, @user_id::uuid)
+ON CONFLICT DO NOTHING;
+
+-- name: UntagCards :execrows
+DELETE FROM card_tags ct
+USING tags t
+WHERE ct.tag_id = t.id
+  AND t.owner_id = @user_id::uuid
+  AND ct.tag_id = ANY(@tag_ids::uuid[])
+  AND ct.card_id = ANY(@card_ids::uuid[]);
+
+-- name: ListTagCards :many
+SELECT c.*
+FROM cards c
+JOIN card_tags ct ON ct.card_id = c.id
+JOIN tags t ON t.id = ct.tag_id
+WHERE t.id = @tag_id AND t.owner_id = @user_id::uuid
+  AND c.deleted_at IS NULL
+  AND pack_visible(c.pack_id, @user_id::uuid)
+ORDER BY c.created_at DESC;
+
+-- name: ListStudyCardsByTags :many
+-- Cards carrying any of the named tags, ordered like ListRepeatCards. The
+-- tag quiz draws its questions and distractors from these.
+SELECT c.*, card_prompts(c.card_type, c.question, c.direction, p.direction)::text[] AS directions,
+       card_last_wrong(c.id, @user_id::uuid)::bool AS user_last_wrong
+FROM cards c
+JOIN packs p ON p.id = c.pack_id
+WHERE EXISTS (
+        SELECT 1 FROM card_tags ct
+        JOIN tags t ON t.id = ct.tag_id
+        WHERE ct.card_id = c.id
+          AND t.owner_id = @user_id::uuid
+          AND lower(t.name) = ANY(@tag_names::text[])
+      )
+  AND c.deleted_at IS NULL
+  AND pack_visible(c.pack_id, @user_id::uuid)
+ORDER BY user_last_wrong DESC, c.created_at ASC
+LIMIT @card_limit;
+
+-- name: ListDueCardsByTags :many
+-- Prompts of cards carrying any of the named tags that are due for the user
+-- or were never studied, due ones first as in the daily queue.
+SELECT c.id, c.pack_id, c.question, c.answer, c.rating,
+       COALESCE(cp.reps = 0 AND cp.lapses > 0, FALSE)::bool AS last_wrong,
+       c.card_type, c.content_format, d.direction::text AS direction
+FROM cards c
+JOIN packs p ON p.id = c.pack_id
+CROSS JOIN LATERAL unnest(card_prompts(c.card_type, c.question, c.direction, p.direction)) AS d(direction)
+LEFT JOIN card_progress cp
+       ON cp.card_id = c.id AND cp.user_id = @user_id::uuid AND cp.direction = d.direction
+WHERE EXISTS (
+        SELECT 1 FROM card_tags ct
+        JOIN tags t ON t.id = ct.tag_id
+        WHERE ct.card_id = c.id
+          AND t.owner_id = @user_id::uuid
+          AND lower(t.name) = ANY(@tag_names::text[])
+      )
+  AND c.deleted_at IS NULL
+  AND pack_visible(c.pack_id, @user_id::uuid)
+  AND (cp.due_at IS NULL OR cp.due_at <= @now::timestamptz)
+ORDER BY cp.due_at ASC NULLS LAST, d.direction = 'reverse', c.created_at, c.id
+LIMIT @card_limit;
@@ -0,0 +1,31 @@
+-- name: GetUserSettings :one
+-- Falls back to the column defaults for users who never saved settings.
+SELECT COALESCE(us.new_cards_per_day, 20)::int AS new_cards_per_day,
+       COALESCE(us.reviews_per_day, 200)::int  AS reviews_per_day,
+       COALESCE(us.timezone, 'UTC')::text      AS timezone,
+       COALESCE(us.day_rollover_hour, 0)::int  AS day_rollover_hour,
+       COALESCE(us.goal_type, 'cards')::text   AS goal_type,
+       COALESCE(us.daily_goal, 20)::int        AS daily_goal,
+       COALESCE(us.streak_freezes_per_week, 1)::int AS streak_freezes_per_week,
+       COALESCE(us.leaderboard_opt_out, FALSE)::bool AS leaderboard_opt_out
+FROM users u
+LEFT JOIN user_settings us ON us.user_id = u.id
+WHERE u.id = $1;
+
+-- name: UpsertUserSettings :one
+INSERT INTO user_settings (user_id, new_cards_per_day, reviews_per_day, timezone,
+                           day_rollover_hour, goal_type, daily_goal, streak_freezes_per_week,
+                           leaderboard_opt_out)
+VALUES (@user_id, @new_cards_per_day, @reviews_per_day, @timezone,
+        @day_rollover_hour, @goal_type, @daily_goal, @streak_freezes_per_week,
+        @leaderboard_opt_out)
+ON CONFLICT (user_id) DO UPDATE
+SET new_cards_per_day = EXCLUDED.new_cards_per_day,
+    reviews_per_day   = EXCLUDED.reviews_per_day,
+    timezone          = EXCLUDED.timezone,
+    day_rollover_hour = EXCLUDED.day_rollover_hour,
+    goal_type         = EXCLUDED.goal_type,
+    daily_goal        = EXCLUDED.daily_goal,
+    streak_freezes_per_week = EXCLUDED.streak_freezes_per_week,
+    leaderboard_opt_out = EXCLUDED.leaderboard_opt_out
+RETURNING *;
@@ -3,21 +3,28 @@ INSERT INTO user_stats (user_id) VALUES ($1)
 ON CONFLICT DO NOTHING;
 
 -- name: AddUserRating :exec
-UPDATE user_stats
-SET rating = rating + $1
-WHERE user_id = $2;
+-- Logs the change for the leaderboards as well.
+WITH event AS (
+    INSERT INTO rating_events (user_id, delta)
+    VALUES (@user_id, @delta::int)
+)
+UPDATE user_stats us
+SET rating = us.rating + @delta::int
+WHERE us.user_id = @user_id;
 
--- name: IncPacksCreated :exec
-UPDATE user_stats
-SET packs_created = packs_created + 1
-WHERE user_id = $1;
-
--- name: IncPacksMastered :exec
-UPDATE user_stats
-SET packs_mastered = packs_mastered + 1
-WHERE user_id = $1;
+-- name: MarkPackMastered :exec
+-- Keeps the size of the pack at the time it was first mastered.
+INSERT INTO pack_masteries (user_id, pack_id, card_count)
+SELECT @user_id, @pack_id, COUNT(*)
+FROM cards c
+WHERE c.pack_id = @pack_id AND c.deleted_at IS NULL
+ON CONFLICT DO NOTHING;
 
 -- name: GetUserStats :one
-SELECT rating, packs_created, packs_mastered
-FROM user_stats
-WHERE user_id = $1;
+-- Packs created are the packs the user owns, forks included.
+SELECT COALESCE(us.rating, 0)::int AS rating,
+       (SELECT COUNT(*) FROM packs p WHERE p.owner_id = @user_id AND p.deleted_at IS NULL)::int AS packs_created,
+       (SELECT COUNT(*) FROM pack_masteries pm WHERE pm.user_id = @user_id)::int AS packs_mastered
+FROM users u
+LEFT JOIN user_stats us ON us.user_id = u.id
+WHERE u.id = @user_id;
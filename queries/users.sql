@@ -18,4 +18,5 @@ FROM users
 WHERE username = $1;
 
 -- name: DeleteUser :exec
+-- The packs the user owns are deleted with them.
 DELETE FROM users WHERE id = $1;
\ No newline at end of file